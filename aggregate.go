@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log/slog"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// aggregate rolls input documents up into one document per group, after
+// converting them with -mapping when one is given.
+func aggregate(args []string) {
+	fs := flag.NewFlagSet("aggregate", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	configFile := fs.String("aggregation", "./data/aggregation.json", "Path to the aggregation JSON file with group_by and aggregations")
+	mappingFile := fs.String("mapping", "", "Path to a mapping to convert documents with before grouping them (default: group the input as is)")
+	outputFile := fs.String("output", "./data/aggregated.json", "Path to output JSON file (- for stdout)")
+	limit := fs.Int("limit", -1, "Limit of documents to process (-1 for all)")
+	parseFlags(fs, args)
+
+	config, err := converter.LoadAggregateConfig(*configFile)
+	if err != nil {
+		fatal("failed to load aggregation file", "error", err)
+	}
+	aggregator, err := converter.NewAggregator(config)
+	if err != nil {
+		fatal("invalid aggregation file", "error", err)
+	}
+	var conv *converter.Converter
+	if *mappingFile != "" {
+		_, conv = openConverter(*mappingFile, *outputFile, false)
+	}
+
+	reader := input.open(*limit)
+	docs := 0
+	for {
+		rec, ok := reader.Next()
+		if !ok {
+			break
+		}
+		if rec.err != nil {
+			fatal("failed to unmarshal input data", "line", rec.line, "error", rec.err)
+		}
+		parts := []converter.ESDoc{rec.doc}
+		if conv != nil {
+			if parts, _, err = convertDoc(conv, rec.doc); err != nil {
+				fatal("failed to process doc", "id", converter.DocID(rec.doc), "error", err)
+			}
+		}
+		for _, doc := range parts {
+			if err = aggregator.Add(doc.Source); err != nil {
+				fatal("failed to aggregate doc", "id", converter.DocID(rec.doc), "error", err)
+			}
+			docs++
+		}
+	}
+	if err = reader.Err(); err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	reader.Close()
+	if conv != nil {
+		if err = conv.Close(); err != nil {
+			fatal(err.Error())
+		}
+	}
+
+	output, err := createFile(*outputFile, false)
+	if err != nil {
+		fatal("failed to create output file", "error", err)
+	}
+	writer := bufio.NewWriter(output)
+	results := aggregator.Results()
+	for i, doc := range results {
+		docJson, err := docJSON.marshal(doc)
+		if err != nil {
+			fatal("failed to marshal doc", "error", err)
+		}
+		if i > 0 {
+			writer.WriteByte('\n')
+		}
+		writer.Write(docJson)
+	}
+	if err = writer.Flush(); err != nil {
+		fatal("failed to write output file", "error", err)
+	}
+	if err = output.Close(); err != nil {
+		fatal("failed to close output file", "error", err)
+	}
+	slog.Info("aggregated documents", "docs", docs, "groups", len(results), "output", *outputFile)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// auditEntry is one line of the -audit log: a document, the rules that
+// wrote to its output in the order they ran, and how its conversion ended.
+type auditEntry struct {
+	Line    int         `json:"line"`
+	ID      string      `json:"id"`
+	Rules   []auditRule `json:"rules"`
+	Outcome string      `json:"outcome"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// auditRule is a rule that changed the output, with the changed fields.
+// Part numbers the output documents an explode rule split the document
+// into, from 1; it is 0 for a document that was not split.
+type auditRule struct {
+	Rule    string        `json:"rule"`
+	Part    int           `json:"part,omitempty"`
+	Changes []auditChange `json:"changes,omitempty"`
+	Removed []string      `json:"removed,omitempty"`
+}
+
+// auditChange is an output field a rule set, with its new value and the one
+// an earlier rule left, unless -audit-values is off.
+type auditChange struct {
+	Field    string          `json:"field"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Previous json.RawMessage `json:"previous,omitempty"`
+}
+
+// auditLog writes the -audit log of a fraction of the documents. Its
+// methods do nothing on a nil log.
+type auditLog struct {
+	file     io.WriteCloser
+	writer   *bufio.Writer
+	fraction float64
+	values   bool
+	rn       *rand.Rand
+
+	// entry is the document being converted, nil when it is not sampled;
+	// fields are the encoded leaf values of the output written so far, and
+	// output the output source they were taken from.
+	entry  *auditEntry
+	fields map[string]string
+	output uintptr
+	part   int
+}
+
+func newAuditLog(path string, fraction float64, values, dryRun bool) *auditLog {
+	if fraction <= 0 || fraction > 1 {
+		fatal("-audit-sample must be a fraction above 0 and at most 1", "value", fraction)
+	}
+	file, err := createFile(path, dryRun)
+	if err != nil {
+		fatal("failed to create audit log", "error", err)
+	}
+	return &auditLog{
+		file:     file,
+		writer:   bufio.NewWriter(file),
+		fraction: fraction,
+		values:   values,
+		rn:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// attach has conv report its rules to the log, besides any observer set
+// before.
+func (a *auditLog) attach(conv *converter.Converter) {
+	if a == nil {
+		return
+	}
+	next := conv.Observe
+	conv.Observe = func(rule string, source map[string]interface{}) {
+		a.observe(rule, source)
+		if next != nil {
+			next(rule, source)
+		}
+	}
+}
+
+// begin starts the entry of a document, when it is sampled.
+func (a *auditLog) begin(rec inputRecord) {
+	if a == nil {
+		return
+	}
+	a.entry = nil
+	if a.fraction < 1 && a.rn.Float64() >= a.fraction {
+		return
+	}
+	a.entry = &auditEntry{Line: rec.line, ID: converter.DocID(rec.doc), Rules: []auditRule{}}
+	a.fields, a.output, a.part = nil, 0, 0
+}
+
+func (a *auditLog) observe(rule string, source map[string]interface{}) {
+	if a.entry == nil {
+		return
+	}
+	// Every output document has a source of its own; a new one is the
+	// next part of an exploded document.
+	if output := reflect.ValueOf(source).Pointer(); output != a.output {
+		if a.output != 0 && a.part == 0 {
+			a.renumber()
+		}
+		if a.output != 0 {
+			a.part++
+		}
+		a.output, a.fields = output, map[string]string{}
+	}
+	fields := map[string]string{}
+	flattenAudit(source, "", fields)
+	entry := auditRule{Rule: rule, Part: a.part}
+	for _, field := range sortedKeys(fields) {
+		value, previous := fields[field], a.fields[field]
+		if value == previous {
+			continue
+		}
+		change := auditChange{Field: field}
+		if a.values {
+			change.Value = json.RawMessage(value)
+			if previous != "" {
+				change.Previous = json.RawMessage(previous)
+			}
+		}
+		entry.Changes = append(entry.Changes, change)
+	}
+	for _, field := range sortedKeys(a.fields) {
+		if _, ok := fields[field]; !ok {
+			entry.Removed = append(entry.Removed, field)
+		}
+	}
+	a.fields = fields
+	if len(entry.Changes) > 0 || len(entry.Removed) > 0 {
+		a.entry.Rules = append(a.entry.Rules, entry)
+	}
+}
+
+// renumber makes the rules of the first output document part 1, once a
+// second one shows the document was exploded.
+func (a *auditLog) renumber() {
+	for i := range a.entry.Rules {
+		a.entry.Rules[i].Part = 1
+	}
+	a.part = 1
+}
+
+// end writes the entry of the document begun last: converted into docs,
+// dropped when there are none, or failed with err.
+func (a *auditLog) end(docs []converter.ESDoc, err error) {
+	if a == nil || a.entry == nil {
+		return
+	}
+	switch {
+	case err != nil:
+		a.entry.Outcome, a.entry.Error = "error", err.Error()
+	case len(docs) == 0:
+		a.entry.Outcome = "dropped"
+	default:
+		a.entry.Outcome = "converted"
+	}
+	line, err := json.Marshal(a.entry)
+	if err != nil {
+		fatal("failed to marshal audit entry", "error", err)
+	}
+	a.writer.Write(line)
+	a.writer.WriteByte('\n')
+	a.entry, a.fields = nil, nil
+}
+
+func (a *auditLog) flush() error {
+	if a == nil {
+		return nil
+	}
+	return a.writer.Flush()
+}
+
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	if err := a.writer.Flush(); err != nil {
+		return err
+	}
+	return a.file.Close()
+}
+
+// flattenAudit adds the encoded values of the leaves of value to fields by
+// dotted path. Arrays are leaves.
+func flattenAudit(value interface{}, path string, fields map[string]string) {
+	if object, ok := value.(map[string]interface{}); ok && (len(object) > 0 || path == "") {
+		for key, item := range object {
+			if path != "" {
+				key = path + "." + key
+			}
+			flattenAudit(item, key, fields)
+		}
+		return
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(err.Error())
+	}
+	fields[path] = string(encoded)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
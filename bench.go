@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// benchReport is the result of a bench run. Stage times are summed over
+// all iterations; mapping rules are timed by section through the
+// converter's Observe hook, which adds a little overhead of its own.
+type benchReport struct {
+	Version      string             `json:"version"`
+	JSONEngine   string             `json:"json_engine"`
+	Iterations   int                `json:"iterations"`
+	Docs         int                `json:"docs"`
+	Bytes        int64              `json:"bytes"`
+	Errors       int                `json:"errors"`
+	Seconds      float64            `json:"seconds"`
+	DocsPerSec   float64            `json:"docs_per_sec"`
+	MBPerSec     float64            `json:"mb_per_sec"`
+	AllocsPerDoc float64            `json:"allocs_per_doc"`
+	BytesPerDoc  float64            `json:"bytes_per_doc"`
+	Stages       map[string]float64 `json:"stage_seconds"`
+}
+
+// bench runs the conversion pipeline over an input held in memory, without
+// writing any output, and reports its throughput and where the time went.
+func bench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	mappingFile := fs.String("mapping", "./data/mapping.json", "Path to mapping JSON file")
+	inputFile := fs.String("input", "./data/input.json", "Path to an NDJSON input file, read into memory once")
+	limit := fs.Int("limit", -1, "Limit of input documents to use (-1 for all)")
+	iterations := fs.Int("n", 5, "Number of passes over the input")
+	duration := fs.Duration("duration", 0, "Keep making passes until this much time has passed, e.g. 30s (overrides -n)")
+	asJSON := fs.Bool("json", false, "Print the results as JSON")
+	registerJSONEngine(fs)
+	var profiles profileOptions
+	profiles.register(fs)
+	parseFlags(fs, args)
+
+	lines, size := readBenchInput(*inputFile, *limit)
+	if len(lines) == 0 {
+		fatal("input has no documents", "input", *inputFile)
+	}
+	_, conv := openConverter(*mappingFile, "bench", true)
+	defer conv.Close()
+
+	stages := map[string]time.Duration{}
+	var last time.Time
+	conv.Observe = func(rule string, _ map[string]interface{}) {
+		now := time.Now()
+		section, _, _ := strings.Cut(rule, "[")
+		stages[section] += now.Sub(last)
+		last = now
+	}
+	enc := newDocEncoder()
+	report := benchReport{Version: currentBuildInfo().Version, JSONEngine: docJSON.name}
+
+	profiles.start()
+	defer stopProfiles()
+	var memStart, memEnd runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&memStart)
+	start := time.Now()
+	for pass := 0; ; pass++ {
+		if *duration > 0 && time.Since(start) >= *duration || *duration == 0 && pass == *iterations {
+			break
+		}
+		for _, line := range lines {
+			t := time.Now()
+			var doc converter.ESDoc
+			if err := unmarshalDoc(line, &doc); err != nil {
+				report.Errors++
+				continue
+			}
+			last = time.Now()
+			stages["decode"] += last.Sub(t)
+			newDocs, _, err := convertDoc(conv, doc)
+			// Observe moved last along the rule sections; the rest of
+			// convertDoc, such as explode and building the output, is other.
+			t = time.Now()
+			stages["other"] += t.Sub(last)
+			if err != nil {
+				report.Errors++
+				continue
+			}
+			for _, newDoc := range newDocs {
+				if _, err = enc.encode(newDoc); err != nil {
+					fatal("failed to marshal doc", "error", err)
+				}
+			}
+			stages["encode"] += time.Since(t)
+		}
+		report.Iterations++
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&memEnd)
+
+	if _, err := conv.Flush(); err != nil {
+		fatal(err.Error())
+	}
+	report.Docs = report.Iterations * len(lines)
+	report.Bytes = int64(report.Iterations) * size
+	report.Seconds = elapsed.Seconds()
+	report.DocsPerSec = float64(report.Docs) / elapsed.Seconds()
+	report.MBPerSec = float64(report.Bytes) / (1 << 20) / elapsed.Seconds()
+	report.AllocsPerDoc = float64(memEnd.Mallocs-memStart.Mallocs) / float64(report.Docs)
+	report.BytesPerDoc = float64(memEnd.TotalAlloc-memStart.TotalAlloc) / float64(report.Docs)
+	report.Stages = map[string]float64{}
+	for stage, d := range stages {
+		report.Stages[stage] = d.Seconds()
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fatal(err.Error())
+		}
+		return
+	}
+	fmt.Printf("converter %s, %s JSON engine\n", report.Version, report.JSONEngine)
+	fmt.Printf("%d passes over %d documents (%.1f MB) in %s, %d errors\n",
+		report.Iterations, len(lines), float64(size)/(1<<20), elapsed.Round(time.Millisecond), report.Errors)
+	fmt.Printf("  docs/sec:   %.0f\n", report.DocsPerSec)
+	fmt.Printf("  MB/sec:     %.2f\n", report.MBPerSec)
+	fmt.Printf("  allocs/doc: %.1f (%.0f bytes)\n", report.AllocsPerDoc, report.BytesPerDoc)
+	fmt.Println("stages:")
+	names := make([]string, 0, len(report.Stages))
+	total := 0.0
+	for stage, seconds := range report.Stages {
+		names = append(names, stage)
+		total += seconds
+	}
+	sort.Slice(names, func(i, j int) bool { return report.Stages[names[i]] > report.Stages[names[j]] })
+	for _, stage := range names {
+		seconds := report.Stages[stage]
+		fmt.Printf("  %-16s %10s %6.1f%%\n", stage, time.Duration(seconds*float64(time.Second)).Round(time.Microsecond), 100*seconds/total)
+	}
+}
+
+// readBenchInput reads up to limit non-blank lines of path into memory and
+// returns them with their total size.
+func readBenchInput(path string, limit int) ([][]byte, int64) {
+	file, err := os.Open(path)
+	if err != nil {
+		fatal("failed to open file", "error", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	var lines [][]byte
+	var size int64
+	for scanner.Scan() && (limit < 0 || len(lines) < limit) {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		lines = append(lines, bytes.Clone(scanner.Bytes()))
+		size += int64(len(scanner.Bytes())) + 1
+	}
+	if err = scanner.Err(); err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	return lines, size
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// checkpoint records how far a run got so that --resume can continue after
+// the last fully written document instead of starting over.
+type checkpoint struct {
+	Input   string           `json:"input"`
+	Offset  int64            `json:"offset"`
+	Line    int              `json:"line"`
+	Records int              `json:"records"`
+	LastID  string           `json:"last_id"`
+	Files   map[string]int64 `json:"files"`
+}
+
+// resumeSizes holds the file sizes of the checkpoint being resumed.
+// createFile reopens these files truncated to that size and appends to
+// them, dropping anything written after the checkpoint.
+var resumeSizes map[string]int64
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// save writes the checkpoint through a temporary file so an interrupted
+// write never leaves a truncated checkpoint behind.
+func (cp *checkpoint) save(path string, files ...string) error {
+	cp.Files = map[string]int64{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		cp.Files[file] = info.Size()
+	}
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// openTruncated opens path for appending after cutting it back to size.
+func openTruncated(path string, size int64) (io.WriteCloser, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err = file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// docDifference is a document that differs between two document sets.
+type docDifference struct {
+	Key    string      `json:"key"`
+	Status string      `json:"status"`
+	Fields []fieldDiff `json:"fields,omitempty"`
+}
+
+// docComparison compares documents with those of an expected set, such as
+// a golden output file, matching them by a key field, or by position for
+// documents without it.
+type docComparison struct {
+	expected map[string]map[string]interface{}
+	order    []string
+	keyField []string
+	ignored  map[string]bool
+	position int
+
+	Matched int             `json:"matched"`
+	Changed int             `json:"changed"`
+	Added   int             `json:"added"`
+	Removed int             `json:"removed"`
+	Docs    []docDifference `json:"docs"`
+}
+
+// loadComparison reads the expected documents from an NDJSON file, keyed
+// by the dotted keyField path, e.c. _id or _source.email. Changes to the
+// ignored _source fields are not reported.
+func loadComparison(path, keyField string, ignored []string) (*docComparison, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	c := &docComparison{
+		expected: map[string]map[string]interface{}{},
+		keyField: strings.Split(keyField, "."),
+		ignored:  map[string]bool{},
+		Docs:     []docDifference{},
+	}
+	for _, field := range ignored {
+		c.ignored[field] = true
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var doc map[string]interface{}
+		if err = unmarshalObject(scanner.Bytes(), &doc); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		key := c.key(doc)
+		c.expected[key] = doc
+		c.order = append(c.order, key)
+	}
+	c.position = 0
+	return c, scanner.Err()
+}
+
+func (c *docComparison) key(doc map[string]interface{}) string {
+	c.position++
+	switch key := converter.ExtractFieldValue(doc, c.keyField).(type) {
+	case nil:
+		return fmt.Sprintf("#%d", c.position)
+	case string:
+		return key
+	default:
+		return compactJSON(key)
+	}
+}
+
+// compare checks one document, given as JSON, against the expected set.
+// Ignored fields may be given with or without the _source prefix.
+func (c *docComparison) compare(data []byte) error {
+	var doc map[string]interface{}
+	if err := unmarshalObject(data, &doc); err != nil {
+		return err
+	}
+	key := c.key(doc)
+	expected, ok := c.expected[key]
+	if !ok {
+		c.Added++
+		c.Docs = append(c.Docs, docDifference{Key: key, Status: "added"})
+		return nil
+	}
+	delete(c.expected, key)
+
+	var fields []fieldDiff
+	for _, diff := range diffDocs(expected, doc) {
+		if !c.ignored[diff.Field] && !c.ignored[strings.TrimPrefix(diff.Field, "_source.")] {
+			fields = append(fields, diff)
+		}
+	}
+	if len(fields) == 0 {
+		c.Matched++
+		return nil
+	}
+	c.Changed++
+	c.Docs = append(c.Docs, docDifference{Key: key, Status: "changed", Fields: fields})
+	return nil
+}
+
+// finish records the expected documents that were not compared and
+// reports whether both sets matched.
+func (c *docComparison) finish() bool {
+	for _, key := range c.order {
+		if _, ok := c.expected[key]; ok {
+			c.Removed++
+			c.Docs = append(c.Docs, docDifference{Key: key, Status: "removed"})
+		}
+	}
+	return len(c.Docs) == 0
+}
+
+// log reports up to limit differing documents and the totals.
+func (c *docComparison) log(limit int) {
+	for i, doc := range c.Docs {
+		if i == limit {
+			slog.Warn("more differences not shown", "docs", len(c.Docs)-limit)
+			break
+		}
+		if doc.Status != "changed" {
+			slog.Warn("document differs", "key", doc.Key, "status", doc.Status)
+			continue
+		}
+		for _, field := range doc.Fields {
+			slog.Warn("document differs", "key", doc.Key, "status", doc.Status, "diff", field.String())
+		}
+	}
+	slog.Info("document comparison", "matched", c.Matched, "changed", c.Changed, "added", c.Added, "removed", c.Removed)
+}
+
+func (c *docComparison) write(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
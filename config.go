@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseFlags parses args into fs after applying the flag values of the
+// -config file, if any, so that command line flags override the file.
+// The file is YAML or JSON with flag names as keys (underscores may stand
+// in for dashes). Top-level keys apply to every command that has the flag;
+// a section named after a command applies only to it and must not contain
+// unknown flags. ${VAR} references in values are expanded from the
+// environment.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	fs.String("config", "", "Path to a YAML or JSON file with flag values (command line flags take precedence)")
+	logLevel := fs.String("log-level", "info", "Minimum level of logged messages: debug, info, warn or error")
+	logFormat := fs.String("log-format", "text", "Log format on stderr: text or json")
+	if path := findConfigFlag(args); path != "" {
+		if err := applyConfig(fs, path); err != nil {
+			fatal("failed to load config", "path", path, "error", err)
+		}
+	}
+	fs.Parse(args)
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		fatal(err.Error())
+	}
+}
+
+// parseArgs is parseFlags for commands taking positional arguments, which
+// may be mixed with the flags. It returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	parseFlags(fs, args)
+	var positional []string
+	for fs.NArg() > 0 {
+		positional = append(positional, fs.Arg(0))
+		fs.Parse(fs.Args()[1:])
+	}
+	return positional
+}
+
+// findConfigFlag returns the value of -config in args, ahead of parsing.
+func findConfigFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func applyConfig(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err = yaml.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	var section map[string]interface{}
+	if nested, ok := values[fs.Name()].(map[string]interface{}); ok {
+		section = nested
+		delete(values, fs.Name())
+	}
+	if err = setFlags(fs, values, false); err != nil {
+		return err
+	}
+	return setFlags(fs, section, true)
+}
+
+// setFlags sets every value on its flag. Unknown keys are an error when
+// strict is set and skipped otherwise, along with other commands' sections.
+func setFlags(fs *flag.FlagSet, values map[string]interface{}, strict bool) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		value := values[key]
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil {
+			if strict {
+				return fmt.Errorf("unknown flag %q for %s", key, fs.Name())
+			}
+			continue
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			if err := fs.Set(name, os.ExpandEnv(fmt.Sprint(item))); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
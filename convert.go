@@ -0,0 +1,631 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// lookupWindow is the number of documents whose lookup keys are
+// prefetched together from batching sources.
+const lookupWindow = 1000
+
+// convert runs the mapping over the whole input and writes the converted
+// documents to the output file.
+func convert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	registerOutputIndent(fs)
+	keyOrder := registerKeyOrder(fs)
+	mappingFile := fs.String("mapping", "./data/mapping.json", "Path to mapping JSON file")
+	outputFile := fs.String("output", "./data/output.json", "Path to output JSON file (- for stdout)")
+	limit := fs.Int("limit", -1, "Limit of documents to process (-1 for all)")
+	targetMappingFile := fs.String("target-mapping", "", "Path to the destination index mapping JSON to validate output documents against")
+	schemaFile := fs.String("schema", "", "Path to a JSON Schema every output document must satisfy")
+	schemaErrorsFile := fs.String("schema-errors", "", "Path to write documents violating the schema (default: <output>.schema-errors.ndjson)")
+	dryRun := fs.Bool("dry-run", false, "Run the full pipeline without writing the output or any other file")
+	showRuleStats := fs.Bool("rule-stats", false, "Log how many documents each mapping rule affected")
+	showStageTimes := fs.Bool("stage-times", false, "Log how the time of the run splits between the read, parse, map, enrich, generate, filter, encode and write stages")
+	unmappedReport := fs.String("unmapped-report", "", "Path to write a JSON report of unmapped source fields with occurrence counts")
+	onError := fs.String("on-error", OnErrorFail, "Action on documents that cannot be read or converted: fail, skip or collect")
+	rejectsFile := fs.String("rejects", "", "Path to write rejected input lines with their line numbers and errors under -on-error collect (default: <output>.rejects.ndjson)")
+	maxErrors := fs.Int("max-errors", 0, "Abort once more than this many documents fail (0 for no limit)")
+	maxErrorRate := fs.Float64("max-error-rate", 0, "Abort once more than this fraction of documents fail, e.g. 0.05 (0 for no limit)")
+	reportFile := fs.String("report", "", "Path to write a JSON summary of the run")
+	progressInterval := fs.Duration("progress", 0, "Report progress at this interval, e.g. 30s (a bar is drawn instead when stderr is a terminal; 0 disables)")
+	checkpointFile := fs.String("checkpoint", "", "Path to persist the input position after every batch so the run can be resumed")
+	resume := fs.Bool("resume", false, "Resume from the checkpoint (default: <output>.checkpoint.json) instead of starting over")
+	goldenFile := fs.String("golden", "", "Path to a blessed output file to compare the converted documents against; differences exit with status 1")
+	goldenDiffFile := fs.String("golden-diff", "", "Path to write the differences from -golden as JSON (default: <output>.golden-diff.json)")
+	goldenIgnore := fs.String("golden-ignore", "", "Comma-separated fields whose changes -golden does not report (random_generate fields are always ignored)")
+	esMappingFile := fs.String("es-mapping", "", "Path to write an Elasticsearch index mapping inferred from the converted documents (- for stdout)")
+	strictUnmapped := fs.String("strict-unmapped", "off", "Action on source fields not covered by the mapping, exclude or passthrough: off, warn or fail")
+	deltaPrevious := fs.String("delta-previous", "", "Path to a previous run's output; only documents that are new or changed since it are written")
+	deltaState := fs.String("delta-state", "", "Path to a state file of content hashes by _id, read to skip unchanged documents and rewritten after the run")
+	seenFile := fs.String("seen-state", "", "Path to a state of the documents emitted by earlier runs, by _index and _id; they are not written again")
+	seenExpected := fs.Int("seen-bloom", 0, "Keep -seen-state as a bloom filter sized for this many documents instead of an exact key set (0 for exact)")
+	seenRate := fs.Float64("seen-bloom-fp", 0.001, "False positive rate of the -seen-bloom filter, the chance a new document is taken as seen")
+	lineageNamespace := fs.String("lineage", "", "Stamp every output document with the tool version, mapping file hash, run ID, conversion time and source file and line under this field, e.g. _lineage")
+	runID := fs.String("run-id", "", "Run ID stamped by -lineage and written to the report (default: random)")
+	auditFile := fs.String("audit", "", "Path to write an NDJSON log of the rules that changed each document and the values they set")
+	auditSample := fs.Float64("audit-sample", 1, "Fraction of the documents -audit logs, e.g. 0.01")
+	auditValues := fs.Bool("audit-values", true, "Include the old and new field values in the -audit log; off logs only which fields each rule changed")
+	manifestFile := fs.String("manifest", "", "Path to write a JSON manifest of the files the run wrote, with the document count, size and sha256 of each")
+	registerMaxMemory(fs)
+	var profiles profileOptions
+	profiles.register(fs)
+	var execOpts execOptions
+	execOpts.register(fs)
+	var watchOpts watchOptions
+	watchOpts.register(fs)
+	parseFlags(fs, args)
+	if watchOpts.dir != "" {
+		// Children read a snapshot of the mapping, written with sorted keys.
+		if *keyOrder == "mapping" {
+			fatal("-key-order mapping cannot be used with -watch")
+		}
+		watch(fs, watchOpts)
+		return
+	}
+
+	profiles.start()
+	defer stopProfiles()
+	start := time.Now()
+	resources := startResourceMonitor()
+
+	// Side files are named after the output file, or "stdout" for -output -.
+	outputBase := *outputFile
+	if *outputFile == "-" {
+		outputBase = "stdout"
+		if *resume || *checkpointFile != "" {
+			fatal("checkpoints need an output file, not stdout")
+		}
+	}
+	if *resume && *checkpointFile == "" {
+		*checkpointFile = outputBase + ".checkpoint.json"
+	}
+	var resumed checkpoint
+	if *resume {
+		cp, err := loadCheckpoint(*checkpointFile)
+		if err != nil {
+			fatal("failed to load checkpoint", "error", err)
+		}
+		if cp.Input != input.file {
+			fatal("checkpoint is for another input", "checkpoint", *checkpointFile, "checkpoint_input", cp.Input, "input", input.file)
+		}
+		if *limit > 0 {
+			*limit = max(*limit-cp.Records, 0)
+			if *limit == 0 {
+				slog.Info("checkpoint already covers the limit, nothing to resume", "docs", cp.Records)
+				return
+			}
+		}
+		resumed = *cp
+		resumeSizes = cp.Files
+		input.offset, input.line, input.skip = cp.Offset, cp.Line, 0
+		slog.Info("resuming from checkpoint", "input", cp.Input, "line", cp.Line, "last_id", cp.LastID)
+	}
+
+	reader := input.open(*limit)
+	var progress *progressReporter
+	if *progressInterval > 0 {
+		progress = newProgressReporter(input.file, reader, *progressInterval)
+	}
+	mapping, conv := openConverter(*mappingFile, outputBase, *dryRun)
+
+	if *rejectsFile == "" {
+		*rejectsFile = outputBase + ".rejects.ndjson"
+	}
+	errs := newErrorHandler(*onError, *rejectsFile, *maxErrors, *maxErrorRate, *dryRun)
+
+	var err error
+	var validator *schemaValidator
+	if *schemaFile != "" {
+		if *schemaErrorsFile == "" {
+			*schemaErrorsFile = outputBase + ".schema-errors.ndjson"
+		}
+		if validator, err = openSchemaValidator(*schemaFile, *schemaErrorsFile, *dryRun); err != nil {
+			fatal("failed to load schema", "error", err)
+		}
+	}
+	var target *targetMapping
+	if *targetMappingFile != "" {
+		if target, err = loadTargetMapping(*targetMappingFile); err != nil {
+			fatal("failed to load target mapping", "error", err)
+		}
+	}
+	var coverage sourceCoverage
+	unmappedCounts := map[string]int{}
+	switch *strictUnmapped {
+	case "off", "warn", "fail":
+	default:
+		fatal("invalid -strict-unmapped value", "value", *strictUnmapped)
+	}
+	if *strictUnmapped != "off" || *unmappedReport != "" {
+		coverage = newSourceCoverage(mapping, conv.Lookups())
+	}
+	var stamp *lineage
+	if *lineageNamespace != "" {
+		if stamp, err = newLineage(*lineageNamespace, *runID, *mappingFile, input.file, start); err != nil {
+			fatal("failed to read mapping file", "error", err)
+		}
+		slog.Info("stamping lineage", "field", *lineageNamespace, "run_id", stamp.runID())
+	}
+	var golden *docComparison
+	if *goldenFile != "" {
+		if *resume {
+			fatal("-golden cannot be used with -resume")
+		}
+		var ignored []string
+		if *goldenIgnore != "" {
+			ignored = strings.Split(*goldenIgnore, ",")
+		}
+		for _, variant := range mapping.Variants() {
+			for field := range variant.RandomGenerate {
+				ignored = append(ignored, field)
+			}
+		}
+		if stamp != nil {
+			ignored = append(ignored, stamp.fields()...)
+		}
+		if golden, err = loadComparison(*goldenFile, "_id", ignored); err != nil {
+			fatal("failed to load golden file", "error", err)
+		}
+		if *goldenDiffFile == "" {
+			*goldenDiffFile = outputBase + ".golden-diff.json"
+		}
+	}
+	var inferred *typeInference
+	if *esMappingFile != "" {
+		if *esMappingFile == "-" && *outputFile == "-" && !*dryRun {
+			fatal("-es-mapping and -output cannot both be stdout")
+		}
+		if *resume {
+			slog.Warn("the index mapping only covers documents converted after resuming")
+		}
+		inferred = newTypeInference()
+	}
+
+	var delta *deltaFilter
+	if *deltaPrevious != "" || *deltaState != "" {
+		if *resume {
+			fatal("-delta-previous and -delta-state cannot be used with -resume")
+		}
+		delta = newDeltaFilter(mapping)
+		if stamp != nil {
+			delta.ignored = append(delta.ignored, stamp.path)
+		}
+		if *deltaPrevious != "" {
+			if err = delta.loadOutput(*deltaPrevious); err != nil {
+				fatal("failed to read previous output", "error", err)
+			}
+		}
+		if *deltaState != "" {
+			if err = delta.loadState(*deltaState); err != nil {
+				fatal("failed to read delta state", "error", err)
+			}
+		}
+	}
+	var seen *seenState
+	if *seenFile != "" {
+		if *seenRate <= 0 || *seenRate >= 1 {
+			fatal("-seen-bloom-fp must be between 0 and 1", "value", *seenRate)
+		}
+		if seen, err = loadSeenState(*seenFile, newSeenState(*seenExpected, *seenRate)); err != nil {
+			fatal("failed to read seen state", "error", err)
+		}
+	}
+	var audit *auditLog
+	if *auditFile != "" {
+		audit = newAuditLog(*auditFile, *auditSample, *auditValues, *dryRun)
+	}
+	var filter *execFilter
+	if execOpts.command != "" {
+		filter = newExecFilter(execOpts)
+	}
+
+	output, err := createFile(*outputFile, *dryRun)
+	if err != nil {
+		fatal("failed to create output file", "error", err)
+	}
+	writer := bufio.NewWriter(output)
+	enc := newDocEncoder()
+	setKeyOrder(enc, *keyOrder, *mappingFile)
+	outputBytes := resumed.Files[*outputFile]
+	cp := resumed
+	cp.Input = input.file
+
+	var report runReport
+	report.DryRun = *dryRun
+	saveCheckpoint := func() {
+		if err = writer.Flush(); err != nil {
+			fatal("failed to write output file", "error", err)
+		}
+		missesFiles, err := conv.Flush()
+		if err != nil {
+			fatal(err.Error())
+		}
+		files := append([]string{*outputFile}, missesFiles...)
+		if err = errs.flush(); err != nil {
+			fatal("failed to write rejects file", "error", err)
+		}
+		if errs.file != nil {
+			files = append(files, *rejectsFile)
+		}
+		if audit != nil {
+			if err = audit.flush(); err != nil {
+				fatal("failed to write audit log", "error", err)
+			}
+			files = append(files, *auditFile)
+		}
+		if validator != nil {
+			if err = validator.flush(); err != nil {
+				fatal("failed to write schema errors file", "error", err)
+			}
+			files = append(files, *schemaErrorsFile)
+		}
+		if err = cp.save(*checkpointFile, files...); err != nil {
+			fatal("failed to write checkpoint", "error", err)
+		}
+	}
+
+	if *showStageTimes {
+		stageTimer = newStageTimes()
+		stageTimer.observe(conv)
+	}
+	audit.attach(conv)
+	stop := trapSignals()
+	batch := make([]inputRecord, 0, lookupWindow)
+	for !stop.requested() {
+		batch = batch[:0]
+		docs := make([]converter.ESDoc, 0, lookupWindow)
+		for len(batch) < lookupWindow {
+			rec, ok := reader.Next()
+			if !ok {
+				break
+			}
+			batch = append(batch, rec)
+			if rec.err == nil {
+				docs = append(docs, rec.doc)
+			}
+		}
+		stageTimer.mark("read")
+		if len(batch) == 0 {
+			break
+		}
+		if err = conv.Prefetch(docs); err != nil {
+			fatal(err.Error())
+		}
+		stageTimer.mark("enrich")
+
+		processed := 0
+		converted := make([]converter.ESDoc, 0, len(batch))
+		for _, rec := range batch {
+			if stop.requested() {
+				break
+			}
+			processed++
+			errs.read()
+			progress.add()
+			if rec.err != nil {
+				errs.handle(rec, rec.err)
+				continue
+			}
+			doc := rec.doc
+
+			if coverage != nil {
+				fields := coverage.unmapped(doc.Source)
+				if len(fields) > 0 && *strictUnmapped == "fail" {
+					fatal("doc has unmapped fields", "id", converter.DocID(doc), "fields", fields)
+				}
+				for _, field := range fields {
+					if unmappedCounts[field] == 0 && *strictUnmapped == "warn" {
+						slog.Warn("unmapped field", "field", field, "first_seen_in", converter.DocID(doc))
+					}
+					unmappedCounts[field]++
+				}
+			}
+
+			stageTimer.mark("other")
+			audit.begin(rec)
+			newDocs, dropped, err := convertDoc(conv, doc)
+			audit.end(newDocs, err)
+			// The rules marked their stages; the rest, such as explode, maps.
+			stageTimer.mark("map")
+			if err != nil {
+				errs.handle(rec, fmt.Errorf("failed to process doc %s: %w", converter.DocID(doc), err))
+				continue
+			}
+			report.DocsDropped += dropped
+			if stamp != nil {
+				for _, newDoc := range newDocs {
+					stamp.stamp(newDoc, rec.line)
+				}
+			}
+			converted = append(converted, newDocs...)
+		}
+
+		if filter != nil {
+			stageTimer.mark("other")
+			filtered := filter.filter(converted)
+			report.DocsDropped += max(len(converted)-len(filtered), 0)
+			converted = filtered
+			stageTimer.mark("filter")
+		}
+		for _, newDoc := range converted {
+			var deltaHash string
+			if delta != nil {
+				var changed bool
+				deltaHash, changed, err = delta.changed(newDoc)
+				if err != nil {
+					fatal("failed to track delta", "error", err)
+				}
+				if !changed {
+					report.DocsUnchanged++
+					continue
+				}
+			}
+			if seen != nil {
+				done, err := seen.has(newDoc)
+				if err != nil {
+					fatal("failed to track seen documents", "error", err)
+				}
+				if done {
+					report.DocsSeen++
+					continue
+				}
+			}
+			if target != nil {
+				target.check(converter.DocID(newDoc), newDoc.Source, "")
+			}
+			if inferred != nil {
+				inferred.observe(newDoc.Source, "")
+			}
+			if validator != nil {
+				valid, err := validator.validate(newDoc)
+				if err != nil {
+					fatal("failed to validate doc", "id", converter.DocID(newDoc), "error", err)
+				}
+				if !valid {
+					report.DocsDropped++
+					continue
+				}
+			}
+			stageTimer.mark("other")
+			docJson, err := enc.encode(newDoc)
+			if err != nil {
+				fatal("failed to marshal new doc", "error", err)
+			}
+			stageTimer.mark("encode")
+			if outputBytes > 0 {
+				writer.WriteByte('\n')
+				outputBytes++
+			}
+			writer.Write(docJson)
+			outputBytes += int64(len(docJson))
+			stageTimer.mark("write")
+			report.DocsConverted++
+			if delta != nil {
+				if err = delta.record(newDoc, deltaHash); err != nil {
+					fatal("failed to track delta", "error", err)
+				}
+			}
+			if seen != nil {
+				if err = seen.add(newDoc); err != nil {
+					fatal("failed to track seen documents", "error", err)
+				}
+			}
+			if golden != nil {
+				if err = golden.compare(docJson); err != nil {
+					fatal("failed to compare with golden file", "error", err)
+				}
+			}
+		}
+
+		if processed > 0 {
+			last := batch[processed-1]
+			cp.Offset, cp.Line, cp.LastID = last.offset, last.line, converter.DocID(last.doc)
+			cp.Records += processed
+		}
+		if *checkpointFile != "" && !*dryRun {
+			saveCheckpoint()
+		}
+		stageTimer.mark("other")
+	}
+
+	interrupted := stop.requested()
+	if interrupted && !*dryRun && *outputFile != "-" {
+		if *checkpointFile == "" {
+			*checkpointFile = outputBase + ".checkpoint.json"
+		}
+		saveCheckpoint()
+		slog.Warn("interrupted, rerun with -resume to continue", "signal", stop.received.String(), "docs", cp.Records, "checkpoint", *checkpointFile)
+	}
+	if err = reader.Err(); err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	if err = reader.Close(); err != nil {
+		fatal("failed to close file", "error", err)
+	}
+	progress.finish()
+
+	stageTimer.mark("other")
+	if err = writer.Flush(); err != nil {
+		fatal("failed to write output file", "error", err)
+	}
+	stageTimer.mark("write")
+	if err = output.Close(); err != nil {
+		fatal("failed to close output file", "error", err)
+	}
+	if *dryRun {
+		slog.Info("dry run, output not written", "docs", report.DocsConverted, "bytes", outputBytes, "output", *outputFile)
+	}
+	if err = conv.Close(); err != nil {
+		fatal(err.Error())
+	}
+	if err = errs.Close(); err != nil {
+		fatal("failed to write rejects file", "error", err)
+	}
+	if err = audit.Close(); err != nil {
+		fatal("failed to write audit log", "error", err)
+	}
+	if validator != nil {
+		if err = validator.Close(); err != nil {
+			fatal("failed to write schema errors file", "error", err)
+		}
+	}
+	if *unmappedReport != "" && *dryRun {
+		for field, count := range unmappedCounts {
+			slog.Info("dry run, unmapped field", "field", field, "docs", count)
+		}
+	} else if *unmappedReport != "" {
+		if err = writeUnmappedReport(*unmappedReport, unmappedCounts); err != nil {
+			fatal("failed to write unmapped report", "error", err)
+		}
+	}
+	goldenMatched := true
+	if golden != nil && !interrupted {
+		goldenMatched = golden.finish()
+		golden.log(20)
+		if !*dryRun && !goldenMatched {
+			if err = golden.write(*goldenDiffFile); err != nil {
+				fatal("failed to write golden diff", "error", err)
+			}
+		}
+	}
+	if delta != nil && !interrupted {
+		if err = delta.log(); err != nil {
+			fatal("failed to compare with previous run", "error", err)
+		}
+		if *deltaState != "" && !*dryRun {
+			if err = delta.save(*deltaState); err != nil {
+				fatal("failed to write delta state", "error", err)
+			}
+		}
+	}
+	if delta != nil {
+		delta.close()
+	}
+	if seen != nil {
+		// Documents of an interrupted run were written all the same, so
+		// the state is saved either way.
+		seen.log(*seenFile)
+		if !*dryRun {
+			if err = seen.save(*seenFile); err != nil {
+				fatal("failed to write seen state", "error", err)
+			}
+		}
+		seen.close()
+	}
+	if inferred != nil {
+		// A dry run still yields the mapping, on stdout since no file may
+		// be written.
+		if *dryRun {
+			*esMappingFile = "-"
+		}
+		if err = writeIndexMapping(*esMappingFile, indexMapping(inferred, mapping)); err != nil {
+			fatal("failed to write index mapping", "error", err)
+		}
+		inferred.logConflicts()
+	}
+
+	elapsed := time.Since(start)
+	usage := resources.finish()
+	slog.Info("conversion finished", append([]any{"duration", elapsed.String()}, usage.logArgs()...)...)
+	stageTimer.log()
+	if errs.errors > 0 {
+		slog.Warn("documents rejected", "docs", errs.errors, "policy", errs.policy)
+	}
+	logStats(conv.Stats(), *showRuleStats)
+	if target != nil {
+		target.log()
+	}
+	if validator != nil {
+		slog.Info("schema violations", "docs", validator.violations)
+	}
+
+	if *checkpointFile != "" && !*dryRun && !interrupted {
+		if err = os.Remove(*checkpointFile); err != nil && !os.IsNotExist(err) {
+			fatal("failed to remove checkpoint", "error", err)
+		}
+	}
+
+	// The files the run wrote: NDJSON documents, then the others.
+	var docFiles, otherFiles []string
+	if !*dryRun {
+		docFiles = append(docFiles, *outputFile)
+		if errs.file != nil {
+			docFiles = append(docFiles, *rejectsFile)
+		}
+		if audit != nil {
+			docFiles = append(docFiles, *auditFile)
+		}
+		if validator != nil {
+			docFiles = append(docFiles, *schemaErrorsFile)
+		}
+		for _, lookup := range conv.Lookups() {
+			if lookup.OnMiss == converter.MissRoute {
+				docFiles = append(docFiles, lookup.MissesFile)
+			}
+		}
+		if *unmappedReport != "" {
+			otherFiles = append(otherFiles, *unmappedReport)
+		}
+		if *esMappingFile != "" && *esMappingFile != "-" {
+			otherFiles = append(otherFiles, *esMappingFile)
+		}
+		if !goldenMatched {
+			otherFiles = append(otherFiles, *goldenDiffFile)
+		}
+		if *deltaState != "" && !interrupted {
+			otherFiles = append(otherFiles, *deltaState)
+		}
+		if *seenFile != "" {
+			otherFiles = append(otherFiles, *seenFile)
+		}
+	}
+	if *manifestFile != "" && !*dryRun {
+		runID := ""
+		if stamp != nil {
+			runID = stamp.runID()
+		}
+		if err = writeManifest(*manifestFile, runID, !interrupted, docFiles, otherFiles); err != nil {
+			fatal("failed to write manifest", "error", err)
+		}
+		otherFiles = append(otherFiles, *manifestFile)
+	}
+
+	if *reportFile != "" {
+		report.DocsRead = errs.records
+		report.DocsRejected = errs.errors
+		if validator != nil {
+			report.SchemaViolations = validator.violations
+		}
+		report.finish(start, conv.Stats())
+		report.Resources = usage
+		if stamp != nil {
+			report.RunID = stamp.runID()
+		}
+		report.StageSeconds = stageTimer.seconds()
+		report.addOutputs(docFiles...)
+		report.addOutputs(otherFiles...)
+		report.Interrupted = interrupted
+		if err = report.write(*reportFile); err != nil {
+			fatal("failed to write report", "error", err)
+		}
+	}
+	stopProfiles()
+	if interrupted {
+		os.Exit(stop.exitCode())
+	}
+	if !goldenMatched {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// convertDir is a scratch directory holding a mapping and the inputs and
+// outputs of convert runs.
+type convertDir string
+
+func newConvertDir(t *testing.T, mapping string) convertDir {
+	t.Helper()
+	dir := convertDir(t.TempDir())
+	dir.write(t, "mapping.json", mapping)
+	return dir
+}
+
+func (d convertDir) path(name string) string {
+	return filepath.Join(string(d), name)
+}
+
+func (d convertDir) write(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(d.path(name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run converts input.json to output.json with the extra args and returns
+// the sorted IDs of the output documents.
+func (d convertDir) run(t *testing.T, args ...string) []string {
+	t.Helper()
+	convert(append([]string{"-input", d.path("input.json"), "-mapping", d.path("mapping.json"), "-output", d.path("output.json")}, args...))
+	var ids []string
+	for id := range readOutputDocs(t, d.path("output.json")) {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestConvertDeltaState(t *testing.T) {
+	dir := newConvertDir(t, `{"passthrough": ["n"]}`)
+	dir.write(t, "input.json", `{"_id":"a","_source":{"n":1}}
+{"_id":"b","_source":{"n":"two"}}
+`)
+	dir.write(t, "schema.json", `{"type": "object", "properties": {"n": {"type": "number"}}}`)
+	state := dir.path("delta.json")
+
+	if ids := dir.run(t, "-delta-state", state); !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Fatalf("first run wrote %v, want a and b", ids)
+	}
+	if ids := dir.run(t, "-delta-state", state); len(ids) != 0 {
+		t.Fatalf("unchanged run wrote %v, want nothing", ids)
+	}
+
+	dir.write(t, "input.json", `{"_id":"a","_source":{"n":1}}
+{"_id":"b","_source":{"n":"three"}}
+{"_id":"c","_source":{"n":3}}
+`)
+	if ids := dir.run(t, "-delta-state", state, "-schema", dir.path("schema.json")); !reflect.DeepEqual(ids, []string{"c"}) {
+		t.Fatalf("run with a schema wrote %v, want c", ids)
+	}
+	// b was rejected by the schema, so it is still changed for the next run.
+	if ids := dir.run(t, "-delta-state", state); !reflect.DeepEqual(ids, []string{"b"}) {
+		t.Errorf("run after the rejection wrote %v, want b", ids)
+	}
+}
+
+func TestConvertDeltaPrevious(t *testing.T) {
+	dir := newConvertDir(t, `{"passthrough": ["n"], "random_generate": {"r": {"type": "integer", "min": 0, "max": 1000000}}}`)
+	dir.write(t, "input.json", `{"_id":"a","_source":{"n":1}}
+{"_id":"b","_source":{"n":2}}
+`)
+	dir.run(t)
+	dir.write(t, "previous.json", readFile(t, dir.path("output.json")))
+
+	dir.write(t, "input.json", `{"_id":"a","_source":{"n":1}}
+{"_id":"b","_source":{"n":5}}
+`)
+	if ids := dir.run(t, "-delta-previous", dir.path("previous.json")); !reflect.DeepEqual(ids, []string{"b"}) {
+		t.Errorf("delta run wrote %v, want the changed b only", ids)
+	}
+}
+
+func TestConvertSeenState(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		args []string
+	}{
+		{"exact", nil},
+		{"bloom", []string{"-seen-bloom", "100"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := newConvertDir(t, `{"passthrough": ["n"]}`)
+			dir.write(t, "input.json", `{"_id":"a","_source":{"n":1}}
+{"_id":"b","_source":{"n":"two"}}
+{"_id":"a","_source":{"n":1}}
+`)
+			dir.write(t, "schema.json", `{"type": "object", "properties": {"n": {"type": "number"}}}`)
+			args := append([]string{"-seen-state", dir.path("seen.json")}, tc.args...)
+
+			if ids := dir.run(t, append(args, "-schema", dir.path("schema.json"))...); !reflect.DeepEqual(ids, []string{"a"}) {
+				t.Fatalf("run with a schema wrote %v, want a", ids)
+			}
+			if lines := strings.Count(strings.TrimSpace(readFile(t, dir.path("output.json"))), "\n") + 1; lines != 1 {
+				t.Fatalf("run with a schema wrote %d docs, want the repeated a once", lines)
+			}
+			// b was rejected by the schema, so it was never emitted.
+			if ids := dir.run(t, args...); !reflect.DeepEqual(ids, []string{"b"}) {
+				t.Errorf("run after the rejection wrote %v, want b", ids)
+			}
+			if ids := dir.run(t, args...); len(ids) != 0 {
+				t.Errorf("third run wrote %v, want nothing", ids)
+			}
+		})
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestConvertKeepsIntegersExact(t *testing.T) {
+	dir := newConvertDir(t, `{"passthrough": ["account", "ratio"], "default_values": {"owner": 9007199254740995}}`)
+	dir.write(t, "input.json", `{"_id":"a","_source":{"account":9007199254740993,"ratio":0.25}}
+`)
+	dir.run(t)
+	got := readFile(t, dir.path("output.json"))
+	for _, field := range []string{`"account":9007199254740993`, `"owner":9007199254740995`, `"ratio":0.25`} {
+		if !strings.Contains(got, field) {
+			t.Errorf("output %s lacks %s", got, field)
+		}
+	}
+}
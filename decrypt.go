@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// decrypt reverses the encrypt transform on fields of converted documents,
+// writing the documents with those fields in clear.
+func decrypt(args []string) {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	registerOutputIndent(fs)
+	outputFile := fs.String("output", "-", "Path to write the decrypted documents to (- for stdout)")
+	fields := fs.String("fields", "", "Comma-separated _source fields to decrypt")
+	keyEnv := fs.String("key-env", "", "Environment variable holding the base64 AES key")
+	keyFile := fs.String("key-file", "", "File holding the base64 AES key")
+	limit := fs.Int("limit", -1, "Limit of documents to decrypt (-1 for all)")
+	parseFlags(fs, args)
+
+	if *fields == "" {
+		fatal("-fields is required")
+	}
+	var call string
+	switch {
+	case *keyEnv != "":
+		call = fmt.Sprintf("decrypt(key_env=%q)", *keyEnv)
+	case *keyFile != "":
+		call = fmt.Sprintf("decrypt(key_file=%q)", *keyFile)
+	default:
+		fatal("-key-env or -key-file is required")
+	}
+	transform, err := converter.NewTransform(call)
+	if err != nil {
+		fatal(err.Error())
+	}
+	var paths [][]string
+	for _, field := range strings.Split(*fields, ",") {
+		paths = append(paths, strings.Split(strings.TrimSpace(field), "."))
+	}
+
+	output, err := createFile(*outputFile, false)
+	if err != nil {
+		fatal("failed to create output file", "error", err)
+	}
+	writer := bufio.NewWriter(output)
+	enc := newDocEncoder()
+	reader := input.open(*limit)
+	docs := 0
+	for {
+		rec, ok := reader.Next()
+		if !ok {
+			break
+		}
+		if rec.err != nil {
+			fatal("failed to unmarshal input data", "line", rec.line, "error", rec.err)
+		}
+		for _, path := range paths {
+			value := converter.ExtractFieldValue(rec.doc.Source, path)
+			if value == nil || value == converter.NullValue {
+				continue
+			}
+			decrypted, err := transform(value)
+			if err != nil {
+				fatal("failed to decrypt field", "id", converter.DocID(rec.doc), "field", strings.Join(path, "."), "error", err)
+			}
+			converter.InsertFieldValue(rec.doc.Source, path, decrypted)
+		}
+		docJson, err := enc.encode(rec.doc)
+		if err != nil {
+			fatal("failed to marshal doc", "error", err)
+		}
+		writer.Write(docJson)
+		writer.WriteByte('\n')
+		docs++
+	}
+	if err = reader.Err(); err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	reader.Close()
+	if err = writer.Flush(); err != nil {
+		fatal("failed to write output file", "error", err)
+	}
+	if err = output.Close(); err != nil {
+		fatal("failed to close output file", "error", err)
+	}
+	slog.Info("decrypted documents", "docs", docs, "output", *outputFile)
+}
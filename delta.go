@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// deltaFilter keeps only documents that are new or changed since a
+// previous run, comparing content hashes of _source by _id. Fields filled
+// by random_generate are left out of the hash, since they differ on every
+// run.
+type deltaFilter struct {
+	previous *spillMap
+	current  *spillMap
+	ignored  [][]string
+}
+
+func newDeltaFilter(mapping converter.FieldMapping) *deltaFilter {
+	d := &deltaFilter{previous: newSpillMap("delta-previous"), current: newSpillMap("delta-current")}
+	for _, variant := range mapping.Variants() {
+		for field := range variant.RandomGenerate {
+			d.ignored = append(d.ignored, strings.Split(field, "."))
+		}
+	}
+	return d
+}
+
+// loadOutput takes the previous hashes from a previous output file.
+func (d *deltaFilter) loadOutput(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var doc converter.ESDoc
+		if err = unmarshalDoc(scanner.Bytes(), &doc); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err = d.previous.set(converter.DocID(doc), d.hash(doc.Source)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// loadState takes the previous hashes from a state file. A missing file is
+// an empty state, as on the first run.
+func (d *deltaFilter) loadState(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dec := json.NewDecoder(bufio.NewReader(file))
+	err = decodeObject(dec, func(id string) error {
+		var hash string
+		if err := dec.Decode(&hash); err != nil {
+			return err
+		}
+		return d.previous.set(id, hash)
+	})
+	if err != nil {
+		return fmt.Errorf("invalid delta state %s: %w", path, err)
+	}
+	return nil
+}
+
+func (d *deltaFilter) hash(source map[string]interface{}) string {
+	if len(d.ignored) > 0 {
+		// genericJSON copies, so removing fields leaves source intact.
+		generic, err := genericJSON(source)
+		if err == nil {
+			for _, path := range d.ignored {
+				converter.DeleteFieldValue(generic, path)
+			}
+			source = generic
+		}
+	}
+	sum := sourceHash(source)
+	return hex.EncodeToString(sum[:])
+}
+
+// changed reports whether doc is new or differs from the previous run, and
+// returns its hash. An unchanged document is recorded right away, a changed
+// one by record once it is written, so that one rejected on the way out is
+// not taken for unchanged by the next run.
+func (d *deltaFilter) changed(doc converter.ESDoc) (string, bool, error) {
+	id := converter.DocID(doc)
+	hash := d.hash(doc.Source)
+	previous, _, err := d.previous.get(id)
+	if err != nil || previous != hash {
+		return hash, true, err
+	}
+	return hash, false, d.current.set(id, hash)
+}
+
+// record notes that doc was written with the hash changed returned.
+func (d *deltaFilter) record(doc converter.ESDoc, hash string) error {
+	return d.current.set(converter.DocID(doc), hash)
+}
+
+// gone counts the documents of the previous run this run did not produce.
+func (d *deltaFilter) gone() (int, error) {
+	n := 0
+	err := d.previous.each(func(id, _ string) error {
+		_, ok, err := d.current.get(id)
+		if !ok {
+			n++
+		}
+		return err
+	})
+	return n, err
+}
+
+// save writes the hashes of this run for the next one.
+func (d *deltaFilter) save(path string) error {
+	return writeAtomic(path, func(w *bufio.Writer) error {
+		return d.current.writeJSON(w, func(hash string) ([]byte, error) {
+			return json.Marshal(hash)
+		})
+	})
+}
+
+func (d *deltaFilter) log() error {
+	gone, err := d.gone()
+	if err != nil {
+		return err
+	}
+	slog.Info("delta against previous run", "previous", d.previous.len(), "current", d.current.len(), "gone", gone)
+	return nil
+}
+
+func (d *deltaFilter) close() {
+	d.previous.close()
+	d.current.close()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// fieldDiff is one difference between two versions of a document.
+type fieldDiff struct {
+	Field string      `json:"field"`
+	Kind  string      `json:"kind"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+func (d fieldDiff) String() string {
+	switch d.Kind {
+	case "added":
+		return fmt.Sprintf("+ %s: %s", d.Field, compactJSON(d.New))
+	case "removed":
+		return fmt.Sprintf("- %s: %s", d.Field, compactJSON(d.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", d.Field, compactJSON(d.Old), compactJSON(d.New))
+	}
+}
+
+// diffDocs lists the leaf fields added, removed or changed from before to
+// after, sorted by field. Arrays are compared as a whole.
+func diffDocs(before, after map[string]interface{}) []fieldDiff {
+	var diffs []fieldDiff
+	diffObjects(before, after, "", &diffs)
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Field < diffs[j].Field })
+	return diffs
+}
+
+func diffObjects(before, after map[string]interface{}, prefix string, diffs *[]fieldDiff) {
+	for key, oldValue := range before {
+		newValue, ok := after[key]
+		if !ok {
+			*diffs = append(*diffs, fieldDiff{Field: prefix + key, Kind: "removed", Old: oldValue})
+			continue
+		}
+		oldObject, oldIsObject := oldValue.(map[string]interface{})
+		newObject, newIsObject := newValue.(map[string]interface{})
+		if oldIsObject && newIsObject {
+			diffObjects(oldObject, newObject, prefix+key+".", diffs)
+		} else if !reflect.DeepEqual(oldValue, newValue) {
+			*diffs = append(*diffs, fieldDiff{Field: prefix + key, Kind: "changed", Old: oldValue, New: newValue})
+		}
+	}
+	for key, newValue := range after {
+		if _, ok := before[key]; !ok {
+			*diffs = append(*diffs, fieldDiff{Field: prefix + key, Kind: "added", New: newValue})
+		}
+	}
+}
+
+// genericJSON round-trips value through JSON, so documents built in Go
+// compare equal to the same documents read from a file.
+func genericJSON(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var generic map[string]interface{}
+	err = unmarshalObject(data, &generic)
+	return generic, err
+}
+
+func compactJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
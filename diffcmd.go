@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// diffCommand compares two NDJSON document sets joined by a key field and
+// prints the field-level differences from the first to the second, exiting
+// with status 1 when they differ.
+func diffCommand(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	key := fs.String("key", "_id", "Dotted path of the field to join documents by, e.g. _id or _source.email")
+	ignore := fs.String("ignore", "", "Comma-separated fields whose changes are not reported")
+	asJSON := fs.Bool("json", false, "Print the differences as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: diff [flags] a.ndjson b.ndjson\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var ignored []string
+	if *ignore != "" {
+		ignored = strings.Split(*ignore, ",")
+	}
+	comparison, err := loadComparison(files[0], *key, ignored)
+	if err != nil {
+		fatal("failed to read documents", "file", files[0], "error", err)
+	}
+	file, err := os.Open(files[1])
+	if err != nil {
+		fatal("failed to read documents", "file", files[1], "error", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err = comparison.compare(scanner.Bytes()); err != nil {
+			fatal("invalid document", "file", files[1], "line", line, "error", err)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		fatal("failed to read documents", "file", files[1], "error", err)
+	}
+	matched := comparison.finish()
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(comparison); err != nil {
+			fatal(err.Error())
+		}
+	} else {
+		for _, doc := range comparison.Docs {
+			switch doc.Status {
+			case "added":
+				fmt.Printf("+ %s (only in %s)\n", doc.Key, files[1])
+			case "removed":
+				fmt.Printf("- %s (only in %s)\n", doc.Key, files[0])
+			default:
+				fmt.Printf("~ %s\n", doc.Key)
+				for _, field := range doc.Fields {
+					fmt.Printf("    %s\n", field)
+				}
+			}
+		}
+		fmt.Printf("%d matched, %d changed, %d only in %s, %d only in %s\n",
+			comparison.Matched, comparison.Changed, comparison.Removed, files[0], comparison.Added, files[1])
+	}
+	if !matched {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// duplicateKeyPolicies are the -duplicate-keys values. The JSON engines keep
+// the last value of a key repeated in an object; warn logs the documents
+// that repeat keys, error rejects them, and array collects the values of a
+// key repeated in the source into an array, in input order.
+var duplicateKeyPolicies = []string{"ignore", "warn", "error", "array"}
+
+func validDuplicateKeyPolicy(policy string) bool {
+	for _, known := range duplicateKeyPolicies {
+		if policy == known {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDuplicateKeys applies policy to rec, a document decoded from data.
+func checkDuplicateKeys(policy string, data []byte, rec *inputRecord) {
+	if policy == "ignore" {
+		return
+	}
+	collected, paths, err := collectDuplicates(data)
+	if err != nil || len(paths) == 0 {
+		return
+	}
+	switch policy {
+	case "warn":
+		slog.Warn("duplicate keys in input document", "line", rec.line, "keys", paths)
+	case "error":
+		rec.raw = string(data)
+		rec.err = fmt.Errorf("duplicate keys: %s", strings.Join(paths, ", "))
+	case "array":
+		for _, path := range paths {
+			if !strings.HasPrefix(path, "_source.") {
+				rec.raw = string(data)
+				rec.err = fmt.Errorf("duplicate metadata key %s", path)
+				return
+			}
+		}
+		rec.doc.Source = converter.ExactNumbers(collected.(map[string]interface{})["_source"]).(map[string]interface{})
+	}
+}
+
+// collectDuplicates decodes data with the values of repeated keys collected
+// into arrays, returning the sorted dotted paths of the repeated keys.
+func collectDuplicates(data []byte) (interface{}, []string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	seen := map[string]bool{}
+	value, err := collectValue(dec, "", seen)
+	if err != nil {
+		return nil, nil, err
+	}
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return value, paths, nil
+}
+
+// collectValue reads the next value of dec at path, adding the paths of
+// repeated keys to seen.
+func collectValue(dec *json.Decoder, path string, seen map[string]bool) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+	if delim == '[' {
+		out := []interface{}{}
+		for dec.More() {
+			item, err := collectValue(dec, path, seen)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, item)
+		}
+		_, err = dec.Token()
+		return out, err
+	}
+	out := map[string]interface{}{}
+	// collected marks the keys whose values are already gathered in an
+	// array, which a value that is an array itself must not be taken for.
+	collected := map[string]bool{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := token.(string)
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		item, err := collectValue(dec, keyPath, seen)
+		if err != nil {
+			return nil, err
+		}
+		previous, repeated := out[key]
+		switch {
+		case !repeated:
+			out[key] = item
+		case collected[key]:
+			out[key] = append(previous.([]interface{}), item)
+		default:
+			out[key] = []interface{}{previous, item}
+			collected[key] = true
+			seen[keyPath] = true
+		}
+	}
+	_, err = dec.Token()
+	return out, err
+}
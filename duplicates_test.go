@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateKeys(t *testing.T) {
+	content := `{"_id":"a","_source":{"tag":"x","n":9007199254740993,"tag":["y","z"],"o":{"k":1,"k":2}}}
+{"_id":"b","_id":"c","_source":{}}
+{"_id":"d","_source":{"tag":"x"}}
+`
+	path := writeInput(t, content)
+	read := func(policy string) []inputRecord {
+		reader := (&inputOptions{file: path, duplicates: policy}).open(-1)
+		defer reader.Close()
+		var recs []inputRecord
+		for {
+			rec, ok := reader.Next()
+			if !ok {
+				return recs
+			}
+			recs = append(recs, rec)
+		}
+	}
+
+	for _, rec := range read("ignore") {
+		if rec.err != nil {
+			t.Errorf("ignore: line %d: %v", rec.line, rec.err)
+		}
+	}
+	if got := read("ignore")[0].doc.Source["tag"]; !reflect.DeepEqual(got, []interface{}{"y", "z"}) {
+		t.Errorf("ignore kept tag %v, want the last value", got)
+	}
+
+	recs := read("error")
+	if recs[0].err == nil || !strings.Contains(recs[0].err.Error(), "_source.o.k, _source.tag") {
+		t.Errorf("error: line 1 error = %v, want the repeated paths", recs[0].err)
+	}
+	if recs[1].err == nil || recs[2].err != nil {
+		t.Errorf("error: lines 2 and 3 errors = %v, %v; want only line 2 rejected", recs[1].err, recs[2].err)
+	}
+
+	recs = read("array")
+	want := map[string]interface{}{
+		"tag": []interface{}{"x", []interface{}{"y", "z"}},
+		"n":   int64(9007199254740993),
+		"o":   map[string]interface{}{"k": []interface{}{int64(1), int64(2)}},
+	}
+	if recs[0].err != nil || !reflect.DeepEqual(recs[0].doc.Source, want) {
+		t.Errorf("array: line 1 = %#v, %v; want %#v", recs[0].doc.Source, recs[0].err, want)
+	}
+	if recs[1].err == nil || !strings.Contains(recs[1].err.Error(), "metadata") {
+		t.Errorf("array: line 2 error = %v, want a repeated metadata key", recs[1].err)
+	}
+}
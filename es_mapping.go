@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// indexMapping builds the body of a PUT <index> request from the types
+// inferred from converted documents, with the mapping's declared types
+// taking precedence.
+func indexMapping(inferred *typeInference, mapping converter.FieldMapping) map[string]interface{} {
+	types := map[string]string{}
+	for path, typ := range inferred.types {
+		types[path] = typ
+	}
+	for _, variant := range mapping.Variants() {
+		for path, typ := range variant.Types {
+			types[path] = typ
+		}
+	}
+
+	paths := make([]string, 0, len(types))
+	for path := range types {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	properties := map[string]interface{}{}
+	for _, path := range paths {
+		parent := properties
+		segments := strings.Split(path, ".")
+		for _, segment := range segments[:len(segments)-1] {
+			field, _ := parent[segment].(map[string]interface{})
+			if field == nil {
+				field = map[string]interface{}{}
+				parent[segment] = field
+			}
+			nested, _ := field["properties"].(map[string]interface{})
+			if nested == nil {
+				nested = map[string]interface{}{}
+				field["properties"] = nested
+			}
+			parent = nested
+		}
+		name := segments[len(segments)-1]
+		field, _ := parent[name].(map[string]interface{})
+		if field == nil {
+			field = map[string]interface{}{}
+			parent[name] = field
+		}
+		switch typ := types[path]; typ {
+		case "object":
+			// Objects are implied by their properties.
+		case "text":
+			field["type"] = "text"
+			field["fields"] = map[string]interface{}{
+				"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256},
+			}
+		default:
+			field["type"] = typ
+		}
+	}
+	return map[string]interface{}{"mappings": map[string]interface{}{"properties": properties}}
+}
+
+// writeIndexMapping writes the index mapping to path, or stdout for "-".
+func writeIndexMapping(path string, body map[string]interface{}) error {
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = fmt.Println(string(data))
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
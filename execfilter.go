@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// execPassthrough is the -exec-on-error policy that keeps a batch as the
+// mapping converted it.
+const execPassthrough = "passthrough"
+
+// execOptions are the flags of the external filter stage.
+type execOptions struct {
+	command string
+	timeout time.Duration
+	retries int
+	onError string
+}
+
+func (o *execOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.command, "exec", "", "Shell command to pipe each batch of converted documents through as NDJSON, e.g. 'jq -c .'")
+	fs.DurationVar(&o.timeout, "exec-timeout", time.Minute, "Time a batch may take in the -exec command before it is killed")
+	fs.IntVar(&o.retries, "exec-retries", 0, "Times to rerun the -exec command on a failed batch")
+	fs.StringVar(&o.onError, "exec-on-error", OnErrorFail, "Action on a batch the -exec command fails for: fail, skip or passthrough")
+}
+
+// execFilter runs a command once per batch, writing the documents to its
+// stdin and reading the documents to keep from its stdout, one JSON object
+// per line. The command may drop, add or reorder documents. A non-zero
+// exit status or a line that is not a document fails the batch.
+type execFilter struct {
+	execOptions
+}
+
+func newExecFilter(opts execOptions) *execFilter {
+	switch opts.onError {
+	case OnErrorFail, OnErrorSkip, execPassthrough:
+	default:
+		fatal("invalid -exec-on-error value", "value", opts.onError)
+	}
+	return &execFilter{opts}
+}
+
+// filter returns the documents the command produced for docs, or applies
+// -exec-on-error once every attempt has failed.
+func (f *execFilter) filter(docs []converter.ESDoc) []converter.ESDoc {
+	if len(docs) == 0 {
+		return docs
+	}
+	var input bytes.Buffer
+	for _, doc := range docs {
+		line, err := docJSON.marshal(doc)
+		if err != nil {
+			fatal("failed to marshal new doc", "error", err)
+		}
+		input.Write(line)
+		input.WriteByte('\n')
+	}
+	var err error
+	for attempt := 0; attempt <= f.retries; attempt++ {
+		var out []converter.ESDoc
+		if out, err = f.run(input.Bytes()); err == nil {
+			return out
+		}
+		if attempt < f.retries {
+			slog.Warn("exec filter failed, retrying", "attempt", attempt+1, "error", err)
+		}
+	}
+	switch f.onError {
+	case OnErrorSkip:
+		slog.Warn("exec filter failed, dropping batch", "docs", len(docs), "error", err)
+		return nil
+	case execPassthrough:
+		slog.Warn("exec filter failed, keeping batch unfiltered", "docs", len(docs), "error", err)
+		return docs
+	}
+	fatal("exec filter failed", "command", f.command, "error", err)
+	return nil
+}
+
+func (f *execFilter) run(input []byte) ([]converter.ESDoc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", f.command)
+	// Children of the shell may hold the pipes open after it was killed.
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("timed out after %s", f.timeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+
+	var docs []converter.ESDoc
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var doc converter.ESDoc
+		if err := unmarshalDoc(scanner.Bytes(), &doc); err != nil {
+			return nil, fmt.Errorf("output line %d: %w", line, err)
+		}
+		if doc.Source == nil {
+			return nil, fmt.Errorf("output line %d: not a document with a _source", line)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, scanner.Err()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+type fieldWrite struct {
+	rule  string
+	value string
+}
+
+// explain converts a single document and prints, for each requested
+// output field, every rule that changed it and the value it left behind.
+func explain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	mappingFile := fs.String("mapping", "./data/mapping.json", "Path to mapping JSON file")
+	id := fs.String("id", "", "ID of the input document to explain")
+	fieldList := fs.String("field", "", "Comma-separated output fields to explain (default: all top-level fields)")
+	parseFlags(fs, args)
+
+	if *id == "" {
+		fatal("-id is required")
+	}
+	var doc *converter.ESDoc
+	for _, candidate := range input.read(-1) {
+		if converter.DocID(candidate) == *id {
+			doc = &candidate
+			break
+		}
+	}
+	if doc == nil {
+		fatal("document not found", "id", *id, "input", input.file)
+	}
+
+	var fields []string
+	for _, field := range strings.Split(*fieldList, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+
+	_, conv := openConverter(*mappingFile, os.DevNull, true)
+	defer conv.Close()
+	allFields := len(fields) == 0
+	writes := map[string][]fieldWrite{}
+	last := map[string]string{}
+	conv.Observe = func(rule string, source map[string]interface{}) {
+		if allFields {
+			for key := range source {
+				if _, seen := writes[key]; !seen {
+					writes[key] = nil
+					fields = append(fields, key)
+				}
+			}
+		}
+		for _, field := range fields {
+			value := converter.ExtractFieldValue(source, strings.Split(field, "."))
+			if value == nil {
+				continue
+			}
+			encoded, _ := json.Marshal(value)
+			if string(encoded) != last[field] {
+				last[field] = string(encoded)
+				writes[field] = append(writes[field], fieldWrite{rule: rule, value: string(encoded)})
+			}
+		}
+	}
+
+	if err := conv.Prefetch([]converter.ESDoc{*doc}); err != nil {
+		fatal(err.Error())
+	}
+	_, err := conv.Convert(*doc)
+	dropped := errors.Is(err, converter.ErrDropped)
+	if err != nil && !dropped {
+		fatal("failed to process doc", "id", *id, "error", err)
+	}
+
+	fmt.Printf("Document %s\n", *id)
+	for _, field := range fields {
+		fmt.Printf("%s:\n", field)
+		if len(writes[field]) == 0 {
+			fmt.Println("  not written by any rule")
+			continue
+		}
+		for _, write := range writes[field] {
+			fmt.Printf("  %s -> %s\n", write.rule, write.value)
+		}
+	}
+	if dropped {
+		fmt.Println("(document dropped by lookup miss policy or script)")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log/slog"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// fold turns an export of an index with a join field into nested
+// documents: every child is removed from the output and its _source
+// nested under -as in its parent. Children are matched to parents by the
+// parent ID the join field holds, {"name": "answer", "parent": "1"}. With
+// -relation only children of that relation are folded; others are written
+// unchanged apart from their own nested children. The join field is
+// dropped unless -keep-join-field is set.
+//
+// The deepest level is folded per run: a child with children of its own
+// gets them nested and keeps its join field, so that the next run folds it
+// into its parent, next to the children already nested there. Children
+// whose parent is not in the input are written unchanged and counted.
+func fold(args []string) {
+	fs := flag.NewFlagSet("fold", flag.ExitOnError)
+	inputFile := fs.String("input", "./data/input.json", "Path to the exported documents, parents and children alike")
+	outputFile := fs.String("output", "./data/folded.json", "Path to output JSON file (- for stdout)")
+	joinField := fs.String("join-field", "", "Dotted _source path of the join field")
+	relation := fs.String("relation", "", "Child relation to fold (default: every child)")
+	as := fs.String("as", "", "Dotted _source field of parents to nest children under (default: the relation name)")
+	one := fs.Bool("one", false, "Nest the first child as an object instead of an array of all of them")
+	keepJoinField := fs.Bool("keep-join-field", false, "Keep the join field in parents and children")
+	maxChildren := fs.Int("max-children", 1_000_000, "Children to hold in memory before spilling to disk")
+	registerJSONEngine(fs)
+	registerOutputIndent(fs)
+	parseFlags(fs, args)
+
+	if *joinField == "" {
+		fatal("-join-field is required")
+	}
+	if *as == "" {
+		if *as = *relation; *as == "" {
+			fatal("-as is required without -relation")
+		}
+	}
+	path := strings.Split(*joinField, ".")
+	// parentOf returns the parent ID of a child and whether it is of the
+	// relation being folded.
+	parentOf := func(doc converter.ESDoc) (string, bool) {
+		value, _ := converter.ExtractFieldValue(doc.Source, path).(map[string]interface{})
+		parent, _ := value["parent"].(string)
+		name, _ := value["name"].(string)
+		return parent, parent != "" && (*relation == "" || name == *relation)
+	}
+	strip := func(source map[string]interface{}) {
+		if !*keepJoinField {
+			converter.DeleteFieldValue(source, path)
+		}
+	}
+
+	// Only children without children of their own are folded this run.
+	parents := map[string]bool{}
+	readInput(*inputFile, func(doc converter.ESDoc) {
+		if parent, ok := parentOf(doc); ok {
+			parents[parent] = true
+		}
+	})
+	folded := func(doc converter.ESDoc) (string, bool) {
+		parent, ok := parentOf(doc)
+		return parent, ok && !parents[converter.DocID(doc)]
+	}
+
+	output, err := createFile(*outputFile, false)
+	if err != nil {
+		fatal("failed to create output file", "error", err)
+	}
+	writer := &joinWriter{Writer: bufio.NewWriter(output), enc: newDocEncoder(), opts: joinOptions{as: *as, one: *one, merge: true}}
+	children, orphans := 0, 0
+	runJoin(*inputFile, *inputFile, func(doc converter.ESDoc) string {
+		return converter.DocID(doc)
+	}, func(doc converter.ESDoc) string {
+		parent, ok := folded(doc)
+		if !ok {
+			return ""
+		}
+		return parent
+	}, *maxChildren, func(doc converter.ESDoc, nested []converter.ESDoc) {
+		for _, child := range nested {
+			strip(child.Source)
+		}
+		switch parent, ok := folded(doc); {
+		case ok:
+			children++
+		case parent != "" && len(nested) == 0:
+			writer.emit(doc)
+		case parent != "":
+			writer.write(doc, nested)
+		default:
+			strip(doc.Source)
+			writer.write(doc, nested)
+		}
+	}, func(orphan converter.ESDoc) {
+		orphans++
+		writer.emit(orphan)
+	})
+
+	if err = writer.Flush(); err != nil {
+		fatal("failed to write output file", "error", err)
+	}
+	if err = output.Close(); err != nil {
+		fatal("failed to close output file", "error", err)
+	}
+	if orphans > 0 {
+		slog.Warn("children without their parent in the input were written unchanged", "orphans", orphans)
+	}
+	slog.Info("folded children into parents", "docs", writer.docs, "with_children", writer.matched, "children", children-orphans, "output", *outputFile)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const foldInput = `{"_id":"q1","_source":{"title":"Why?","rel":{"name":"question"}}}
+{"_id":"a1","_source":{"text":"Because","rel":{"name":"answer","parent":"q1"}}}
+{"_id":"a2","_source":{"text":"No idea","rel":{"name":"answer","parent":"q1"}}}
+{"_id":"c1","_source":{"text":"Nice","rel":{"name":"comment","parent":"a1"}}}
+{"_id":"o1","_source":{"text":"Lost","rel":{"name":"answer","parent":"q9"}}}
+`
+
+// runFold runs fold over input and returns the output documents by ID
+// along with the output file.
+func runFold(t *testing.T, input string, args ...string) (map[string]map[string]interface{}, string) {
+	t.Helper()
+	dir := t.TempDir()
+	inputFile, output := filepath.Join(dir, "input.json"), filepath.Join(dir, "folded.json")
+	if err := os.WriteFile(inputFile, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fold(append([]string{"-input", inputFile, "-output", output, "-join-field", "rel"}, args...))
+	return readOutputDocs(t, output), output
+}
+
+// nestedTexts returns the sorted text fields of the documents nested under
+// field.
+func nestedTexts(source map[string]interface{}, field string) []string {
+	var texts []string
+	items, _ := source[field].([]interface{})
+	for _, item := range items {
+		texts = append(texts, item.(map[string]interface{})["text"].(string))
+	}
+	sort.Strings(texts)
+	return texts
+}
+
+func TestFoldOneLevelPerRun(t *testing.T) {
+	docs, output := runFold(t, foldInput, "-as", "replies")
+	if len(docs) != 3 {
+		t.Fatalf("first run wrote %v, want q1, a1 and o1", docs)
+	}
+	if got := nestedTexts(docs["q1"], "replies"); !reflect.DeepEqual(got, []string{"No idea"}) {
+		t.Errorf("q1 replies = %v, want the leaf answer", got)
+	}
+	if _, ok := docs["q1"]["rel"]; ok {
+		t.Error("q1 kept its join field")
+	}
+	a1 := docs["a1"]
+	if got := nestedTexts(a1, "replies"); !reflect.DeepEqual(got, []string{"Nice"}) {
+		t.Errorf("a1 replies = %v, want its comment", got)
+	}
+	if _, ok := a1["rel"]; !ok {
+		t.Error("a1 lost the join field the next run needs")
+	}
+	if comment := a1["replies"].([]interface{})[0].(map[string]interface{}); comment["rel"] != nil {
+		t.Errorf("nested comment kept its join field: %v", comment)
+	}
+	if rel, _ := docs["o1"]["rel"].(map[string]interface{}); rel["parent"] != "q9" {
+		t.Errorf("orphan o1 = %v, want it unchanged", docs["o1"])
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, _ = runFold(t, string(data), "-as", "replies")
+	if len(docs) != 2 {
+		t.Fatalf("second run wrote %v, want q1 and o1", docs)
+	}
+	if got := nestedTexts(docs["q1"], "replies"); !reflect.DeepEqual(got, []string{"Because", "No idea"}) {
+		t.Errorf("q1 replies after the second run = %v, want both answers", got)
+	}
+	for _, reply := range docs["q1"]["replies"].([]interface{}) {
+		if reply := reply.(map[string]interface{}); reply["text"] == "Because" {
+			if got := nestedTexts(reply, "replies"); !reflect.DeepEqual(got, []string{"Nice"}) {
+				t.Errorf("a1 replies after the second run = %v", got)
+			}
+		}
+	}
+}
+
+func TestFoldRelation(t *testing.T) {
+	docs, _ := runFold(t, foldInput, "-relation", "comment", "-keep-join-field")
+	if len(docs) != 4 || docs["c1"] != nil {
+		t.Fatalf("fold -relation comment wrote %v, want every doc but c1", docs)
+	}
+	if got := nestedTexts(docs["a1"], "comment"); !reflect.DeepEqual(got, []string{"Nice"}) {
+		t.Errorf("a1 comment = %v, want its comment", got)
+	}
+	if got := nestedTexts(docs["q1"], "comment"); len(got) != 0 {
+		t.Errorf("q1 comment = %v, want none", got)
+	}
+	if _, ok := docs["q1"]["rel"]; !ok {
+		t.Error("-keep-join-field dropped the join field")
+	}
+}
+
+func TestFoldSpilled(t *testing.T) {
+	docs, _ := runFold(t, foldInput, "-as", "replies", "-max-children", "1")
+	if len(docs) != 3 || docs["o1"] == nil {
+		t.Fatalf("spilled fold wrote %v, want q1, a1 and o1", docs)
+	}
+	if got := nestedTexts(docs["q1"], "replies"); !reflect.DeepEqual(got, []string{"No idea"}) {
+		t.Errorf("q1 replies = %v, want the leaf answer", got)
+	}
+	if got := nestedTexts(docs["a1"], "replies"); !reflect.DeepEqual(got, []string{"Nice"}) {
+		t.Errorf("a1 replies = %v, want its comment", got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"log/slog"
+	"strconv"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// generate writes n synthetic documents built only from the mapping's
+// default_values, random_generate rules and _id-keyed lookups, for load
+// tests and fixtures.
+func generate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	mappingFile := fs.String("mapping", "./data/mapping.json", "Path to mapping JSON file")
+	outputFile := fs.String("output", "./data/generated.json", "Path to output JSON file")
+	n := fs.Int("n", 10, "Number of documents to generate")
+	idPrefix := fs.String("id-prefix", "", "Prefix for the generated sequential document IDs")
+	registerJSONEngine(fs)
+	registerOutputIndent(fs)
+	keyOrder := registerKeyOrder(fs)
+	parseFlags(fs, args)
+
+	_, conv := openConverter(*mappingFile, *outputFile, false)
+	output, err := createFile(*outputFile, false)
+	if err != nil {
+		fatal("failed to create output file", "error", err)
+	}
+	writer := bufio.NewWriter(output)
+	enc := newDocEncoder()
+	setKeyOrder(enc, *keyOrder, *mappingFile)
+
+	written := 0
+	for i := 1; i <= *n; i++ {
+		id := *idPrefix + strconv.Itoa(i)
+		doc, err := conv.Convert(converter.ESDoc{
+			ESMeta: converter.ESMeta{ID: &id},
+			Source: map[string]interface{}{},
+		})
+		if errors.Is(err, converter.ErrDropped) {
+			continue
+		}
+		if err != nil {
+			fatal("failed to generate doc", "id", id, "error", err)
+		}
+		docJson, err := enc.encode(doc)
+		if err != nil {
+			fatal("failed to marshal new doc", "error", err)
+		}
+		if written > 0 {
+			writer.WriteByte('\n')
+		}
+		writer.Write(docJson)
+		written++
+	}
+	if err = writer.Flush(); err != nil {
+		fatal("failed to write output file", "error", err)
+	}
+	if err = output.Close(); err != nil {
+		fatal("failed to close output file", "error", err)
+	}
+	if err = conv.Close(); err != nil {
+		fatal(err.Error())
+	}
+	slog.Info("generated docs", "docs", written, "output", *outputFile)
+}
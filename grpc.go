@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+const grpcServiceName = "converter.v1.Converter"
+
+// convertProto mirrors proto/converter.proto. Building the descriptors
+// here lets the messages be handled with dynamicpb instead of protoc
+// generated code.
+var convertProto = &descriptorpb.FileDescriptorProto{
+	Name:    proto.String("converter/v1/converter.proto"),
+	Package: proto.String("converter.v1"),
+	Syntax:  proto.String("proto3"),
+	MessageType: []*descriptorpb.DescriptorProto{
+		{
+			Name: proto.String("ConvertRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				protoField("document", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+			},
+		},
+		{
+			Name: proto.String("ConvertResponse"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				protoField("document", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES),
+				protoField("dropped", 2, descriptorpb.FieldDescriptorProto_TYPE_BOOL),
+				protoField("error", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			},
+		},
+	},
+	Service: []*descriptorpb.ServiceDescriptorProto{{
+		Name: proto.String("Converter"),
+		Method: []*descriptorpb.MethodDescriptorProto{{
+			Name:            proto.String("Convert"),
+			InputType:       proto.String(".converter.v1.ConvertRequest"),
+			OutputType:      proto.String(".converter.v1.ConvertResponse"),
+			ClientStreaming: proto.Bool(true),
+			ServerStreaming: proto.Bool(true),
+		}},
+	}},
+}
+
+func protoField(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+}
+
+// grpcService serves the Convert stream with the HTTP server's converter.
+type grpcService struct {
+	server   *server
+	request  protoreflect.MessageDescriptor
+	response protoreflect.MessageDescriptor
+}
+
+type converterService interface{}
+
+// newGRPCServer registers the conversion, health and reflection services.
+func newGRPCServer(s *server) (*grpc.Server, *health.Server, error) {
+	file, err := protodesc.NewFile(convertProto, protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = protoregistry.GlobalFiles.FindFileByPath(file.Path()); err != nil {
+		if err = protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+			return nil, nil, err
+		}
+	}
+	service := &grpcService{
+		server:   s,
+		request:  file.Messages().ByName("ConvertRequest"),
+		response: file.Messages().ByName("ConvertResponse"),
+	}
+
+	grpcServer := grpc.NewServer(grpc.MaxRecvMsgSize(int(s.maxBody)))
+	grpcServer.RegisterService(&grpc.ServiceDesc{
+		ServiceName: grpcServiceName,
+		HandlerType: (*converterService)(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Convert",
+			Handler:       service.convert,
+			ServerStreams: true,
+			ClientStreams: true,
+		}},
+		Metadata: file.Path(),
+	}, service)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(grpcServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	reflection.Register(grpcServer)
+	return grpcServer, healthServer, nil
+}
+
+// convert answers every request of the stream with the converted document,
+// one response per part when explode split it, or with the reason it was
+// dropped or failed.
+func (g *grpcService) convert(_ interface{}, stream grpc.ServerStream) error {
+	documentIn := g.request.Fields().ByName("document")
+	documentOut := g.response.Fields().ByName("document")
+	dropped := g.response.Fields().ByName("dropped")
+	errorField := g.response.Fields().ByName("error")
+
+	for {
+		req := dynamicpb.NewMessage(g.request)
+		if err := stream.RecvMsg(req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		resp := dynamicpb.NewMessage(g.response)
+		responses := []*dynamicpb.Message{resp}
+		var doc converter.ESDoc
+		if err := unmarshalDoc(req.Get(documentIn).Bytes(), &doc); err != nil {
+			g.server.metrics.errors.Add(1)
+			resp.Set(errorField, protoreflect.ValueOfString(fmt.Sprintf("invalid JSON: %v", err)))
+		} else if results, err := g.server.convert([]converter.ESDoc{doc}); err != nil {
+			resp.Set(errorField, protoreflect.ValueOfString(err.Error()))
+		} else if len(results[0]) == 0 {
+			resp.Set(dropped, protoreflect.ValueOfBool(true))
+		} else {
+			responses = responses[:0]
+			for _, newDoc := range results[0] {
+				resp := dynamicpb.NewMessage(g.response)
+				resp.Set(documentOut, protoreflect.ValueOfBytes(newDoc))
+				responses = append(responses, resp)
+			}
+		}
+		for _, resp := range responses {
+			if err := stream.SendMsg(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// inferMapping scans sample documents and writes a starter mapping with an
+// identity field_mapping entry and an inferred type for every leaf path
+// found.
+func inferMapping(args []string) {
+	fs := flag.NewFlagSet("infer-mapping", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	outputFile := fs.String("output", "-", "Path to write the mapping to (- for stdout)")
+	limit := fs.Int("limit", 1000, "Number of documents to scan (-1 for all)")
+	withTypes := fs.Bool("types", true, "Include the inferred Elasticsearch type of every field")
+	parseFlags(fs, args)
+
+	mapping := converter.FieldMapping{FieldMapping: map[string]string{}}
+	types := newTypeInference()
+	for _, doc := range input.read(*limit) {
+		if mapping.Index == nil {
+			mapping.Index = doc.Index
+		}
+		collectPaths(doc.Source, "", mapping.FieldMapping)
+		types.observe(doc.Source, "")
+	}
+	if *withTypes {
+		mapping.Types = map[string]string{}
+		for path := range mapping.FieldMapping {
+			if typ := types.typeOf(path); typ != "" {
+				mapping.Types[path] = typ
+			}
+		}
+		types.logConflicts()
+	}
+
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		fatal("failed to marshal mapping", "error", err)
+	}
+	if *outputFile == "-" {
+		fmt.Println(string(data))
+		return
+	}
+	if err = os.WriteFile(*outputFile, data, 0644); err != nil {
+		fatal("failed to write mapping", "error", err)
+	}
+	slog.Info("wrote mapping", "fields", len(mapping.FieldMapping), "output", *outputFile)
+}
+
+// collectPaths records every leaf path of source, mapped to itself.
+func collectPaths(source map[string]interface{}, prefix string, paths map[string]string) {
+	for key, value := range source {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			collectPaths(nested, prefix+key+".", paths)
+			continue
+		}
+		paths[prefix+key] = prefix + key
+	}
+}
+
+// dateLayouts are the string formats inferred as dates, those the default
+// Elasticsearch date format accepts.
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// typeInference guesses the Elasticsearch type of every path from the
+// values seen at it. Objects inside arrays contribute to the same paths,
+// as Elasticsearch flattens them.
+type typeInference struct {
+	types     map[string]string
+	conflicts map[string]map[string]bool
+}
+
+func newTypeInference() *typeInference {
+	return &typeInference{types: map[string]string{}, conflicts: map[string]map[string]bool{}}
+}
+
+func (t *typeInference) observe(source map[string]interface{}, prefix string) {
+	for key, value := range source {
+		t.observeValue(prefix+key, value)
+	}
+}
+
+func (t *typeInference) observeValue(path string, value interface{}) {
+	switch typed := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		t.merge(path, "object")
+		t.observe(typed, path+".")
+	case []interface{}:
+		for _, item := range typed {
+			t.observeValue(path, item)
+		}
+	case bool:
+		t.merge(path, "boolean")
+	case int, int64, json.Number:
+		t.merge(path, "long")
+	case float64:
+		if typed == float64(int64(typed)) {
+			t.merge(path, "long")
+		} else {
+			t.merge(path, "double")
+		}
+	case string:
+		if typed != converter.NullValue {
+			t.merge(path, stringType(typed))
+		}
+	}
+}
+
+func stringType(value string) string {
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return "date"
+		}
+	}
+	if net.ParseIP(value) != nil {
+		return "ip"
+	}
+	if len(value) > 256 || strings.Count(value, " ") >= 5 {
+		return "text"
+	}
+	return "keyword"
+}
+
+// merge widens the type of path to cover typ as well.
+func (t *typeInference) merge(path, typ string) {
+	current, ok := t.types[path]
+	switch {
+	case !ok || current == typ:
+		t.types[path] = typ
+	case current == "long" && typ == "double" || current == "double" && typ == "long":
+		t.types[path] = "double"
+	case current == "text" && isString(typ) || typ == "text" && isString(current):
+		t.types[path] = "text"
+	case isString(current) && isString(typ):
+		t.types[path] = "keyword"
+	default:
+		if t.conflicts[path] == nil {
+			t.conflicts[path] = map[string]bool{current: true}
+		}
+		t.conflicts[path][typ] = true
+		if current != "object" {
+			t.types[path] = "keyword"
+		}
+	}
+}
+
+func isString(typ string) bool {
+	switch typ {
+	case "keyword", "text", "date", "ip":
+		return true
+	}
+	return false
+}
+
+// typeOf returns the inferred type of path, or "" when only nulls were seen.
+func (t *typeInference) typeOf(path string) string {
+	return t.types[path]
+}
+
+func (t *typeInference) logConflicts() {
+	paths := make([]string, 0, len(t.conflicts))
+	for path := range t.conflicts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		seen := make([]string, 0, len(t.conflicts[path]))
+		for typ := range t.conflicts[path] {
+			seen = append(seen, typ)
+		}
+		sort.Strings(seen)
+		slog.Warn("conflicting value types", "field", path, "types", strings.Join(seen, ","), "inferred", t.types[path])
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// initTemplates are the starting points init can scaffold a mapping from.
+var initTemplates = []string{"identity", "ecs", "flatten-all", "anonymize-pii"}
+
+// initMapping scaffolds a mapping file from a template, filled in with the
+// fields of sample documents:
+//
+//   - identity maps every field to itself, like infer-mapping;
+//   - ecs starts from the ECS preset that reads the most sample fields and
+//     maps the fields it does not read to themselves;
+//   - flatten-all maps every field to a top-level one named after its path;
+//   - anonymize-pii is identity with fields that look personal piped
+//     through mask_email, redact or pseudonymize.
+func initMapping(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	template := fs.String("template", "identity", "Template to start from: "+strings.Join(initTemplates, ", "))
+	preset := fs.String("preset", "", "Preset of -template ecs (default: the one reading the most sample fields): "+strings.Join(converter.Presets(), ", "))
+	separator := fs.String("separator", "_", "Separator of the path segments in the field names of -template flatten-all")
+	outputFile := fs.String("output", "mapping.json", "Path to write the mapping to (- for stdout)")
+	force := fs.Bool("force", false, "Overwrite the output file if it exists")
+	limit := fs.Int("limit", 1000, "Number of documents to scan (-1 for all)")
+	parseFlags(fs, args)
+
+	sample := scanSample(input.read(*limit))
+	var mapping converter.FieldMapping
+	switch *template {
+	case "identity":
+		mapping = sample.identity()
+	case "ecs":
+		mapping = sample.ecs(*preset)
+	case "flatten-all":
+		mapping = sample.flattened(*separator)
+	case "anonymize-pii":
+		mapping = sample.anonymized()
+	default:
+		fatal("unknown -template", "template", *template, "templates", strings.Join(initTemplates, ", "))
+	}
+
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		fatal("failed to marshal mapping", "error", err)
+	}
+	if *outputFile == "-" {
+		fmt.Println(string(data))
+		return
+	}
+	if _, err := os.Stat(*outputFile); err == nil && !*force {
+		fatal("output file exists, pass -force to overwrite it", "output", *outputFile)
+	}
+	if err = os.WriteFile(*outputFile, append(data, '\n'), 0644); err != nil {
+		fatal("failed to write mapping", "error", err)
+	}
+	slog.Info("wrote mapping", "template", *template, "fields", len(mapping.FieldMapping), "output", *outputFile)
+}
+
+// initSample is what init learned of the sample documents: the index of
+// the first, the leaf paths, their types and the first string seen at each.
+type initSample struct {
+	index  *string
+	paths  map[string]string
+	types  *typeInference
+	values map[string]string
+}
+
+func scanSample(docs []converter.ESDoc) *initSample {
+	s := &initSample{paths: map[string]string{}, types: newTypeInference(), values: map[string]string{}}
+	for _, doc := range docs {
+		if s.index == nil {
+			s.index = doc.Index
+		}
+		collectPaths(doc.Source, "", s.paths)
+		s.types.observe(doc.Source, "")
+		collectStrings(doc.Source, "", s.values)
+	}
+	s.types.logConflicts()
+	return s
+}
+
+// collectStrings records the first string value of every leaf path of
+// source, looking into arrays.
+func collectStrings(source map[string]interface{}, prefix string, values map[string]string) {
+	for key, value := range source {
+		path := prefix + key
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			collectStrings(typed, path+".", values)
+		case []interface{}:
+			for _, item := range typed {
+				if text, ok := item.(string); ok && text != "" && values[path] == "" {
+					values[path] = text
+				}
+			}
+		case string:
+			if typed != "" && typed != converter.NullValue && values[path] == "" {
+				values[path] = typed
+			}
+		}
+	}
+}
+
+// mapping returns a mapping of every sample path to target(path), with the
+// inferred types.
+func (s *initSample) mapping(target func(path string) string) converter.FieldMapping {
+	mapping := converter.FieldMapping{Index: s.index, FieldMapping: map[string]string{}, Types: map[string]string{}}
+	for path := range s.paths {
+		mapping.FieldMapping[target(path)] = path
+		if typ := s.types.typeOf(path); typ != "" {
+			mapping.Types[target(path)] = typ
+		}
+	}
+	return mapping
+}
+
+func (s *initSample) identity() converter.FieldMapping {
+	return s.mapping(func(path string) string { return path })
+}
+
+func (s *initSample) flattened(separator string) converter.FieldMapping {
+	return s.mapping(func(path string) string { return strings.ReplaceAll(path, ".", separator) })
+}
+
+// ecs returns a mapping starting from preset, or from the preset that
+// reads the most sample paths, with the paths the preset does not read
+// mapped to themselves.
+func (s *initSample) ecs(preset string) converter.FieldMapping {
+	names := []string{preset}
+	if preset == "" {
+		names = converter.Presets()
+	}
+	best, bestCovered := "", -1
+	var bestCoverage sourceCoverage
+	for _, name := range names {
+		presetMapping, err := converter.LoadPreset(name)
+		if err != nil {
+			fatal("failed to load preset", "error", err)
+		}
+		coverage := newSourceCoverage(presetMapping, nil)
+		covered := 0
+		for path := range s.paths {
+			if coverage.covers(path) {
+				covered++
+			}
+		}
+		if covered > bestCovered {
+			best, bestCovered, bestCoverage = name, covered, coverage
+		}
+	}
+	if bestCovered == 0 && preset == "" {
+		fatal("no preset reads any of the sample fields, pick one with -preset", "presets", strings.Join(names, ", "))
+	}
+	slog.Info("starting from preset", "preset", best, "matched_fields", bestCovered, "other_fields", len(s.paths)-bestCovered)
+	mapping := converter.FieldMapping{Preset: best, Index: s.index, FieldMapping: map[string]string{}}
+	for path := range s.paths {
+		if !bestCoverage.covers(path) {
+			mapping.FieldMapping[path] = path
+		}
+	}
+	return mapping
+}
+
+// piiRules pick the transform of the anonymize-pii template for a field
+// by its name, in order.
+var piiRules = []struct {
+	name      *regexp.Regexp
+	transform string
+}{
+	{regexp.MustCompile(`(?i)e_?mail`), "mask_email"},
+	{regexp.MustCompile(`(?i)phone|mobile|fax|ssn|social_security|credit_?card|card_?(number|no)|iban|account_?(number|no)`), "redact(keep_last=4)"},
+	{regexp.MustCompile(`(?i)(^|[._])((first|last|middle|full|given|family|sur|user|display)_?name|name|address|street|city|zip|post_?code|postal_code|birth_?date|dob|ip|client_?ip|remote_addr)$`), "pseudonymize(key_env=PSEUDONYM_KEY)"},
+}
+
+var emailValue = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// anonymized returns the identity mapping with the fields that look
+// personal, by name or by their sample value, transformed, and typed
+// keyword as their values no longer parse as dates or IPs.
+func (s *initSample) anonymized() converter.FieldMapping {
+	mapping := s.identity()
+	var anonymized []string
+	for path := range s.paths {
+		transform := piiTransform(path, s.values[path], s.types.typeOf(path))
+		if transform == "" {
+			continue
+		}
+		mapping.FieldMapping[path] = path + " | " + transform
+		mapping.Types[path] = "keyword"
+		anonymized = append(anonymized, path)
+	}
+	sort.Strings(anonymized)
+	slog.Info("anonymized fields, set PSEUDONYM_KEY before converting", "fields", strings.Join(anonymized, ","))
+	return mapping
+}
+
+func piiTransform(path, value, typ string) string {
+	if emailValue.MatchString(value) {
+		return "mask_email"
+	}
+	for _, rule := range piiRules {
+		if rule.name.MatchString(path) {
+			return rule.transform
+		}
+	}
+	if typ == "ip" {
+		return "pseudonymize(key_env=PSEUDONYM_KEY)"
+	}
+	return ""
+}
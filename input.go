@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// maxLineSize bounds a single NDJSON input line.
+const maxLineSize = 64 * 1024 * 1024
+
+// inputRecord is one document read from the input, or the error that
+// prevented decoding it, with the raw line, its 1-based line number and
+// the byte offset just past it.
+type inputRecord struct {
+	doc    converter.ESDoc
+	raw    string
+	line   int
+	offset int64
+	err    error
+}
+
+type docReader interface {
+	// Next returns the next record, or false at the end of the input.
+	Next() (inputRecord, bool)
+	// Err reports a read failure that ended the input early.
+	Err() error
+	// Progress returns the bytes consumed so far and the input size, or
+	// zeros when the input is not read incrementally.
+	Progress() (done, total int64)
+	Close() error
+}
+
+type inputOptions struct {
+	file       string
+	format     string
+	sheet      string
+	headerRow  int
+	idColumn   string
+	skip       int
+	sample     float64
+	sampleN    int
+	seed       int64
+	mmap       bool
+	encoding   string
+	duplicates string
+
+	// offset and line position the reader after the last record of a
+	// resumed checkpoint.
+	offset int64
+	line   int
+}
+
+func (o *inputOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.file, "input", "./data/input.json", "Path to input JSON file")
+	fs.StringVar(&o.format, "input-format", "", "Input format: ndjson or xlsx (default: by file extension)")
+	fs.StringVar(&o.sheet, "sheet", "", "Worksheet of an xlsx input (default: first sheet)")
+	fs.IntVar(&o.headerRow, "header-row", 1, "Header row number of an xlsx input")
+	fs.StringVar(&o.idColumn, "id-column", "id", "Column holding the document ID of an xlsx input")
+	fs.IntVar(&o.skip, "skip", 0, "Number of input documents to skip before processing (pairs with -limit)")
+	fs.Float64Var(&o.sample, "sample", 0, "Convert a random fraction of the input documents, e.g. 0.01")
+	fs.IntVar(&o.sampleN, "sample-n", 0, "Convert this many input documents chosen at random")
+	fs.Int64Var(&o.seed, "seed", 0, "Seed for -sample and -sample-n (default: random, logged for reruns)")
+	fs.BoolVar(&o.mmap, "mmap", false, "Map a local NDJSON input into memory and decode lines in place instead of copying them through a read buffer")
+	fs.StringVar(&o.encoding, "input-encoding", "utf8", "Character encoding of an NDJSON input, transcoded to UTF-8 before parsing: "+strings.Join(inputEncodingNames(), ", ")+"; a leading byte order mark is skipped")
+	fs.StringVar(&o.duplicates, "duplicate-keys", "ignore", "Handling of keys repeated within an input object: "+strings.Join(duplicateKeyPolicies, ", ")+" (collect the values of a repeated source key into an array)")
+	registerJSONEngine(fs)
+}
+
+// open returns a reader over at most limit records (-1 for all) after the
+// first skip records, sampled when -sample or -sample-n is set.
+func (o *inputOptions) open(limit int) docReader {
+	if o.sample > 0 || o.sampleN > 0 {
+		if o.sample > 0 && o.sampleN > 0 {
+			fatal("-sample and -sample-n are mutually exclusive")
+		}
+		if o.sample > 1 {
+			fatal("invalid -sample value, must be a fraction between 0 and 1", "value", o.sample)
+		}
+		if o.seed == 0 {
+			o.seed = time.Now().UnixNano()
+		}
+		slog.Info("sampling input", "seed", o.seed)
+		return &sampleReader{
+			reader:   o.openFile(-1),
+			rn:       rand.New(rand.NewSource(o.seed)),
+			fraction: o.sample,
+			n:        o.sampleN,
+			limit:    limit,
+		}
+	}
+	return o.openFile(limit)
+}
+
+func (o *inputOptions) openFile(limit int) docReader {
+	if o.format == "xlsx" || (o.format == "" && strings.HasSuffix(o.file, ".xlsx")) {
+		start := o.line + o.skip
+		if limit > 0 {
+			limit += start
+		}
+		docs, err := converter.ReadXLSXDocs(o.file, o.sheet, o.headerRow, o.idColumn, limit)
+		if err != nil {
+			fatal("failed to read input workbook", "error", err)
+		}
+		return &sliceReader{docs: docs, next: min(start, len(docs))}
+	}
+
+	if !validDuplicateKeyPolicy(o.duplicates) {
+		fatal("invalid -duplicate-keys value", "value", o.duplicates, "known", strings.Join(duplicateKeyPolicies, ", "))
+	}
+	file, err := os.Open(o.file)
+	if err != nil {
+		fatal("failed to open file", "error", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		fatal("failed to stat input file", "error", err)
+	}
+	decoder, bom, err := openInputDecoder(o.encoding, file)
+	if err != nil {
+		fatal(err.Error())
+	}
+	offset := o.offset
+	if offset == 0 {
+		offset = bom
+	}
+	if o.mmap && info.Mode().IsRegular() {
+		if decoder != nil {
+			slog.Warn("cannot map input that is not UTF-8, reading it instead", "input", o.file, "encoding", o.encoding)
+		} else if reader, err := openMmapReader(file, info.Size(), limit, o.skip, o.line, offset, o.duplicates); err == nil {
+			return reader
+		} else {
+			slog.Warn("cannot map input, reading it instead", "input", o.file, "error", err)
+		}
+	}
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		fatal("failed to seek input file", "error", err)
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	scanner.Split(decoder.split())
+	return &ndjsonReader{file: file, scanner: scanner, decoder: decoder, duplicates: o.duplicates, limit: limit, skip: o.skip, line: o.line, offset: offset, size: info.Size()}
+}
+
+// read returns every document of the input, failing on the first one that
+// cannot be decoded.
+func (o *inputOptions) read(limit int) []converter.ESDoc {
+	reader := o.open(limit)
+	defer reader.Close()
+
+	var docs []converter.ESDoc
+	for {
+		rec, ok := reader.Next()
+		if !ok {
+			break
+		}
+		if rec.err != nil {
+			fatal("failed to unmarshal input data", "line", rec.line, "error", rec.err)
+		}
+		docs = append(docs, rec.doc)
+	}
+	if err := reader.Err(); err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	return docs
+}
+
+type ndjsonReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	decoder *inputDecoder
+	// duplicates is the -duplicate-keys policy.
+	duplicates string
+	limit      int
+	skip       int
+	line       int
+	count      int
+	offset     int64
+	size       int64
+}
+
+func (r *ndjsonReader) Next() (inputRecord, bool) {
+	for {
+		if r.limit > 0 && r.count >= r.limit {
+			return inputRecord{}, false
+		}
+		if !r.scanner.Scan() {
+			return inputRecord{}, false
+		}
+		r.line++
+		r.offset += int64(len(r.scanner.Bytes()))
+		data := r.scanner.Bytes()
+		var decodeErr error
+		if r.decoder != nil {
+			data, decodeErr = r.decoder.decode(data)
+		} else {
+			data = trimLineEnd(data)
+		}
+		if decodeErr == nil && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if r.skip > 0 {
+			r.skip--
+			continue
+		}
+		r.count++
+
+		rec := inputRecord{raw: string(data), line: r.line, offset: r.offset}
+		if decodeErr != nil {
+			rec.raw = string(r.scanner.Bytes())
+			rec.err = fmt.Errorf("invalid %s text: %w", r.decoder.name, decodeErr)
+			return rec, true
+		}
+		stageTimer.mark("read")
+		err := unmarshalDoc(data, &rec.doc)
+		stageTimer.mark("parse")
+		if err != nil {
+			rec.err = fmt.Errorf("invalid JSON: %w", err)
+		} else {
+			checkDuplicateKeys(r.duplicates, data, &rec)
+		}
+		return rec, true
+	}
+}
+
+// scanRawLines is bufio.ScanLines keeping the line ending in the token, so
+// that offsets count every byte of a CRLF file.
+func scanRawLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// trimLineEnd removes the LF or CRLF a line read by scanRawLines ends with.
+func trimLineEnd(line []byte) []byte {
+	return bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+}
+
+func (r *ndjsonReader) Err() error {
+	return r.scanner.Err()
+}
+
+func (r *ndjsonReader) Progress() (int64, int64) {
+	return min(r.offset, r.size), r.size
+}
+
+func (r *ndjsonReader) Close() error {
+	return r.file.Close()
+}
+
+type sliceReader struct {
+	docs []converter.ESDoc
+	next int
+}
+
+func (r *sliceReader) Next() (inputRecord, bool) {
+	if r.next >= len(r.docs) {
+		return inputRecord{}, false
+	}
+	r.next++
+	return inputRecord{doc: r.docs[r.next-1], line: r.next}, true
+}
+
+func (r *sliceReader) Err() error {
+	return nil
+}
+
+func (r *sliceReader) Progress() (int64, int64) {
+	return 0, 0
+}
+
+func (r *sliceReader) Close() error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// inputEncodings are the -input-encoding values besides utf8, by name.
+// utf16 takes its byte order from the byte order mark, little-endian
+// without one.
+var inputEncodings = map[string]encoding.Encoding{
+	"latin1":       charmap.ISO8859_1,
+	"windows-1252": charmap.Windows1252,
+	"shift-jis":    japanese.ShiftJIS,
+	"utf16":        unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf16le":      unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf16be":      unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// inputDecoder transcodes the lines of an input to UTF-8. Lines are split
+// on the raw bytes and transcoded one by one, so the offsets of checkpoints
+// and progress stay those of the file; a newline byte never occurs inside
+// a character of the single-byte encodings or Shift JIS, and UTF-16 lines
+// are split on whole code units.
+type inputDecoder struct {
+	name    string
+	decoder *encoding.Decoder
+	// bigEndian and utf16 select the UTF-16 line splitting.
+	utf16     bool
+	bigEndian bool
+}
+
+func inputEncodingNames() []string {
+	names := []string{"utf8"}
+	for name := range inputEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// openInputDecoder returns the decoder of name for file, or nil for UTF-8,
+// with the length of the byte order mark file starts with.
+func openInputDecoder(name string, file *os.File) (*inputDecoder, int64, error) {
+	head := make([]byte, 3)
+	n, _ := file.ReadAt(head, 0)
+	head = head[:n]
+	name = strings.ToLower(name)
+	switch name {
+	case "", "utf8", "utf-8":
+		if bytes.HasPrefix(head, utf8BOM) {
+			return nil, int64(len(utf8BOM)), nil
+		}
+		return nil, 0, nil
+	case "utf16", "utf16le", "utf16be":
+		d := &inputDecoder{name: name, utf16: true, bigEndian: name == "utf16be"}
+		var bom int64
+		switch {
+		case name != "utf16be" && bytes.HasPrefix(head, utf16LEBOM):
+			bom = 2
+		case name != "utf16le" && bytes.HasPrefix(head, utf16BEBOM):
+			d.bigEndian, bom = true, 2
+		}
+		if d.bigEndian {
+			d.decoder = inputEncodings["utf16be"].NewDecoder()
+		} else {
+			d.decoder = inputEncodings["utf16le"].NewDecoder()
+		}
+		return d, bom, nil
+	}
+	enc, ok := inputEncodings[name]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown input encoding %q (known: %s)", name, strings.Join(inputEncodingNames(), ", "))
+	}
+	return &inputDecoder{name: name, decoder: enc.NewDecoder()}, 0, nil
+}
+
+// split returns the split function for the lines of the input. Like
+// scanRawLines, it keeps the line ending in the token, which decode
+// removes, so that offsets count every byte of the file.
+func (d *inputDecoder) split() bufio.SplitFunc {
+	if d == nil || !d.utf16 {
+		return scanRawLines
+	}
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		for i := 0; i+1 < len(data); i += 2 {
+			if d.bigEndian && data[i] == 0 && data[i+1] == '\n' || !d.bigEndian && data[i] == '\n' && data[i+1] == 0 {
+				return i + 2, data[:i+2], nil
+			}
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+func (d *inputDecoder) decode(line []byte) ([]byte, error) {
+	decoded, err := d.decoder.Bytes(line)
+	if err != nil {
+		return nil, err
+	}
+	return trimLineEnd(decoded), nil
+}
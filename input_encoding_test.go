@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInputEncodings(t *testing.T) {
+	lines := "{\"_id\":\"café\"}\r\n\r\n{\"_id\":\"naïve €\"}\r\n{\"_id\":\"z\"}"
+	for _, tc := range []struct {
+		encoding string
+		bom      string
+		text     string
+	}{
+		{"utf8", "\xEF\xBB\xBF", lines},
+		{"latin1", "", "{\"_id\":\"caf\xE9\"}\r\n\r\n{\"_id\":\"na\xEFve \xA4\"}\r\n{\"_id\":\"z\"}"},
+		{"windows-1252", "", "{\"_id\":\"caf\xE9\"}\r\n\r\n{\"_id\":\"na\xEFve \x80\"}\r\n{\"_id\":\"z\"}"},
+		{"utf16", "\xFF\xFE", lines},
+		{"utf16", "\xFE\xFF", lines},
+		{"utf16be", "", lines},
+	} {
+		t.Run(tc.encoding+"/"+tc.bom, func(t *testing.T) {
+			content := tc.text
+			switch {
+			case tc.encoding == "utf16be" || tc.bom == "\xFE\xFF":
+				content = encodeUTF16(tc.text, true)
+			case tc.encoding == "utf16":
+				content = encodeUTF16(tc.text, false)
+			}
+			content = tc.bom + content
+			want := []string{"café", "naïve €", "z"}
+			if tc.encoding == "latin1" {
+				want[1] = "naïve ¤"
+			}
+
+			input := inputOptions{file: writeInput(t, content), encoding: tc.encoding, duplicates: "ignore"}
+			ids, at := readIDs(t, input.open(-1), 0)
+			if !reflect.DeepEqual(ids, want) {
+				t.Fatalf("read %q, want %q", ids, want)
+			}
+			resumed := inputOptions{file: input.file, encoding: tc.encoding, offset: at.offset, line: at.line, duplicates: "ignore"}
+			if ids, _ = readIDs(t, resumed.open(-1), -1); !reflect.DeepEqual(ids, want[1:]) {
+				t.Errorf("resumed after line %d at offset %d read %q, want %q", at.line, at.offset, ids, want[1:])
+			}
+		})
+	}
+}
+
+// encodeUTF16 encodes the code points of s, all in the basic plane, as
+// UTF-16.
+func encodeUTF16(s string, bigEndian bool) string {
+	var out []byte
+	for _, r := range s {
+		if bigEndian {
+			out = append(out, byte(r>>8), byte(r))
+		} else {
+			out = append(out, byte(r), byte(r>>8))
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,100 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mmapReader reads an NDJSON file mapped into memory, decoding every line
+// in place instead of copying it out of a scanner buffer. Only lines that
+// fail to decode keep their raw text; rejects of the others are written
+// from the decoded document.
+type mmapReader struct {
+	file  *os.File
+	data  []byte
+	limit int
+	// duplicates is the -duplicate-keys policy.
+	duplicates string
+	skip       int
+	line       int
+	count      int
+	offset     int64
+}
+
+// openMmapReader maps file, positioned like openFile's scanner.
+func openMmapReader(file *os.File, size int64, limit, skip, line int, offset int64, duplicates string) (docReader, error) {
+	r := &mmapReader{file: file, duplicates: duplicates, limit: limit, skip: skip, line: line, offset: offset}
+	if size == 0 {
+		return r, nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file too large to map")
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	r.data = data
+	return r, nil
+}
+
+func (r *mmapReader) Next() (inputRecord, bool) {
+	for {
+		if r.limit > 0 && r.count >= r.limit {
+			return inputRecord{}, false
+		}
+		if r.offset >= int64(len(r.data)) {
+			return inputRecord{}, false
+		}
+		rest := r.data[r.offset:]
+		data := rest
+		if end := bytes.IndexByte(rest, '\n'); end >= 0 {
+			data = rest[:end]
+		}
+		r.line++
+		r.offset += int64(len(data)) + 1
+		data = bytes.TrimSuffix(data, []byte("\r"))
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if r.skip > 0 {
+			r.skip--
+			continue
+		}
+		r.count++
+
+		rec := inputRecord{line: r.line, offset: r.offset}
+		stageTimer.mark("read")
+		err := unmarshalDoc(data, &rec.doc)
+		stageTimer.mark("parse")
+		if err != nil {
+			rec.raw = string(data)
+			rec.err = fmt.Errorf("invalid JSON: %w", err)
+		} else {
+			checkDuplicateKeys(r.duplicates, data, &rec)
+		}
+		return rec, true
+	}
+}
+
+func (r *mmapReader) Err() error {
+	return nil
+}
+
+func (r *mmapReader) Progress() (int64, int64) {
+	return min(r.offset, int64(len(r.data))), int64(len(r.data))
+}
+
+func (r *mmapReader) Close() error {
+	if r.data != nil {
+		if err := syscall.Munmap(r.data); err != nil {
+			return err
+		}
+		r.data = nil
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func openMmapReader(file *os.File, size int64, limit, skip, line int, offset int64, duplicates string) (docReader, error) {
+	return nil, errors.New("memory-mapped input is not supported on this platform")
+}
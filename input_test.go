@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+func writeInput(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readIDs reads every record from reader and returns the document IDs, and
+// the position after the record at index stop.
+func readIDs(t *testing.T, reader docReader, stop int) ([]string, inputRecord) {
+	t.Helper()
+	defer reader.Close()
+	var ids []string
+	var at inputRecord
+	for {
+		rec, ok := reader.Next()
+		if !ok {
+			break
+		}
+		if rec.err != nil {
+			t.Fatalf("line %d: %v", rec.line, rec.err)
+		}
+		if len(ids) == stop {
+			at = rec
+		}
+		ids = append(ids, converter.DocID(rec.doc))
+	}
+	if err := reader.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ids, at
+}
+
+func TestNDJSONResume(t *testing.T) {
+	tests := map[string]string{
+		"lf":              "{\"_id\":\"a\"}\n{\"_id\":\"b\"}\n\n{\"_id\":\"c\"}\n{\"_id\":\"d\"}\n",
+		"crlf":            "{\"_id\":\"a\"}\r\n{\"_id\":\"b\"}\r\n\r\n{\"_id\":\"c\"}\r\n{\"_id\":\"d\"}\r\n",
+		"no final ending": "{\"_id\":\"a\"}\r\n{\"_id\":\"b\"}\r\n\r\n{\"_id\":\"c\"}\r\n{\"_id\":\"d\"}",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			input := inputOptions{file: writeInput(t, content), duplicates: "ignore"}
+			ids, at := readIDs(t, input.open(-1), 1)
+			if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(ids, want) {
+				t.Fatalf("read %v, want %v", ids, want)
+			}
+			if rest := content[at.offset:]; at.line != 2 || content[at.offset-1] != '\n' ||
+				!strings.HasPrefix(strings.TrimLeft(rest, "\r\n"), `{"_id":"c"}`) {
+				t.Fatalf("record b at line %d offset %d, before %q", at.line, at.offset, rest)
+			}
+
+			resumed := inputOptions{file: input.file, offset: at.offset, line: at.line, duplicates: "ignore"}
+			reader := resumed.open(-1)
+			rec, _ := reader.Next()
+			reader.Close()
+			if rec.line != 4 {
+				t.Errorf("resumed record c at line %d, want 4", rec.line)
+			}
+			ids, _ = readIDs(t, resumed.open(-1), -1)
+			if want := []string{"c", "d"}; !reflect.DeepEqual(ids, want) {
+				t.Errorf("resumed read %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func TestNDJSONProgress(t *testing.T) {
+	content := "{\"_id\":\"a\"}\r\n{\"_id\":\"b\"}"
+	reader := (&inputOptions{file: writeInput(t, content), duplicates: "ignore"}).open(-1)
+	defer reader.Close()
+	for {
+		if _, ok := reader.Next(); !ok {
+			break
+		}
+	}
+	if done, total := reader.Progress(); done != total || total != int64(len(content)) {
+		t.Errorf("Progress() = %d, %d; want %d, %d", done, total, len(content), len(content))
+	}
+}
+
+func TestCheckpointTruncatesFiles(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "output.json")
+	if err := os.WriteFile(output, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "checkpoint.json")
+	cp := checkpoint{Input: "input.json", Offset: 42, Line: 3, Records: 2, LastID: "b"}
+	if err := cp.save(path, output); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*loaded, cp) {
+		t.Errorf("loadCheckpoint() = %+v, want %+v", *loaded, cp)
+	}
+
+	// Output written after the checkpoint is dropped on resume.
+	if err = os.WriteFile(output, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := openTruncated(output, loaded.Files[output])
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("four\n"))
+	file.Close()
+	if data, _ := os.ReadFile(output); string(data) != "one\ntwo\nfour\n" {
+		t.Errorf("resumed output = %q", data)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// joinPartitions is the number of partitions a spilled join is split into.
+const joinPartitions = 16
+
+// joinOptions say how right documents are attached to left ones.
+type joinOptions struct {
+	leftKey, rightKey string
+	as                string
+	one, inner        bool
+	// merge keeps what is already nested under as, adding to an array
+	// rather than replacing it, as fold needs when run once per level.
+	merge bool
+}
+
+// join combines two inputs on a key: the _source of every right document
+// whose key matches a left document is nested into it under -as, as an
+// array or with -one as a single object. It is a hash join over the right
+// input; past -max-right documents both inputs are spilled to disk in
+// partitions by key and joined one partition at a time, which changes the
+// output order.
+func join(args []string) {
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	outputFile := fs.String("output", "./data/joined.json", "Path to output JSON file (- for stdout)")
+	var opts joinOptions
+	fs.StringVar(&opts.leftKey, "left-key", "_id", "Key of left documents: _id or a _source path such as _source.order_id")
+	fs.StringVar(&opts.rightKey, "right-key", "_id", "Key of right documents: _id or a _source path such as _source.order_id")
+	fs.StringVar(&opts.as, "as", "", "Dotted _source field of left documents to nest the matching right documents under")
+	fs.BoolVar(&opts.one, "one", false, "Nest the first matching right document as an object instead of an array of all of them")
+	fs.BoolVar(&opts.inner, "inner", false, "Drop left documents without a matching right document")
+	maxRight := fs.Int("max-right", 1_000_000, "Right documents to hold in memory before spilling both inputs to disk")
+	registerJSONEngine(fs)
+	registerOutputIndent(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: join [flags] left.ndjson right.ndjson\n")
+		fs.PrintDefaults()
+	}
+	files := parseArgs(fs, args)
+	if len(files) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if opts.as == "" {
+		fatal("-as is required")
+	}
+	for _, key := range []string{opts.leftKey, opts.rightKey} {
+		if key != "_id" && !strings.HasPrefix(key, "_source.") {
+			fatal("invalid join key, want _id or a _source path", "key", key)
+		}
+	}
+
+	output, err := createFile(*outputFile, false)
+	if err != nil {
+		fatal("failed to create output file", "error", err)
+	}
+	writer := &joinWriter{Writer: bufio.NewWriter(output), enc: newDocEncoder(), opts: opts}
+
+	runJoin(files[0], files[1], func(doc converter.ESDoc) string {
+		return joinKey(doc, opts.leftKey)
+	}, func(doc converter.ESDoc) string {
+		return joinKey(doc, opts.rightKey)
+	}, *maxRight, writer.write, nil)
+
+	if err = writer.Flush(); err != nil {
+		fatal("failed to write output file", "error", err)
+	}
+	if err = output.Close(); err != nil {
+		fatal("failed to close output file", "error", err)
+	}
+	slog.Info("joined documents", "docs", writer.docs, "matched", writer.matched, "output", *outputFile)
+}
+
+// runJoin calls write for every left document with the right documents
+// sharing its key, and unmatched, when set, for every right document whose
+// key no left document has, in key order. Right documents without a key
+// are ignored.
+func runJoin(leftPath, rightPath string, leftKey func(converter.ESDoc) string, rightKey func(converter.ESDoc) string, maxRight int, write func(converter.ESDoc, []converter.ESDoc), unmatched func(converter.ESDoc)) {
+	right, spill := loadJoinRight(rightPath, rightKey, maxRight)
+	if spill == nil {
+		matched := map[string]bool{}
+		readInput(leftPath, func(doc converter.ESDoc) {
+			key := leftKey(doc)
+			matched[key] = true
+			write(doc, right[key])
+		})
+		reportUnmatched(right, matched, unmatched)
+		return
+	}
+	defer spill.remove()
+	slog.Info("right input exceeds the in-memory limit, joining on disk", "partitions", joinPartitions, "dir", spill.dir)
+	readInput(leftPath, func(doc converter.ESDoc) {
+		spill.add("left", leftKey(doc), doc)
+	})
+	spill.flush()
+	for p := range joinPartitions {
+		right := map[string][]converter.ESDoc{}
+		spill.read("right", p, func(key string, doc converter.ESDoc) {
+			right[key] = append(right[key], doc)
+		})
+		matched := map[string]bool{}
+		spill.read("left", p, func(key string, doc converter.ESDoc) {
+			matched[key] = true
+			write(doc, right[key])
+		})
+		reportUnmatched(right, matched, unmatched)
+	}
+}
+
+// reportUnmatched calls unmatched for the right documents whose key is not
+// in matched.
+func reportUnmatched(right map[string][]converter.ESDoc, matched map[string]bool, unmatched func(converter.ESDoc)) {
+	if unmatched == nil {
+		return
+	}
+	var keys []string
+	for key := range right {
+		if !matched[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, doc := range right[key] {
+			unmatched(doc)
+		}
+	}
+}
+
+// joinKey returns the key of doc at path, "" when it has none.
+func joinKey(doc converter.ESDoc, path string) string {
+	if path == "_id" {
+		if doc.ID == nil {
+			return ""
+		}
+		return *doc.ID
+	}
+	value := converter.ExtractFieldValue(doc.Source, strings.Split(strings.TrimPrefix(path, "_source."), "."))
+	if value == nil || value == converter.NullValue {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func readInput(path string, fn func(doc converter.ESDoc)) {
+	input := inputOptions{file: path, headerRow: 1, idColumn: "id", encoding: "utf8", duplicates: "ignore"}
+	reader := input.open(-1)
+	defer reader.Close()
+	for {
+		rec, ok := reader.Next()
+		if !ok {
+			break
+		}
+		if rec.err != nil {
+			fatal("failed to unmarshal input data", "file", path, "line", rec.line, "error", rec.err)
+		}
+		fn(rec.doc)
+	}
+	if err := reader.Err(); err != nil {
+		fatal("failed to read input", "file", path, "error", err)
+	}
+}
+
+// loadJoinRight reads the documents of path that keyOf gives a key into
+// memory by key, or spills them once it holds more than maxRight.
+func loadJoinRight(path string, keyOf func(converter.ESDoc) string, maxRight int) (map[string][]converter.ESDoc, *joinSpill) {
+	right := map[string][]converter.ESDoc{}
+	var spill *joinSpill
+	count := 0
+	readInput(path, func(doc converter.ESDoc) {
+		key := keyOf(doc)
+		if key == "" {
+			return
+		}
+		if spill != nil {
+			spill.add("right", key, doc)
+			return
+		}
+		right[key] = append(right[key], doc)
+		if count++; count > maxRight {
+			spill = newJoinSpill()
+			for key, docs := range right {
+				for _, doc := range docs {
+					spill.add("right", key, doc)
+				}
+			}
+			right = nil
+		}
+	})
+	if spill != nil {
+		spill.flush()
+	}
+	return right, spill
+}
+
+type joinWriter struct {
+	*bufio.Writer
+	enc     *docEncoder
+	opts    joinOptions
+	docs    int
+	matched int
+}
+
+// write nests the sources of children into doc and writes it.
+func (w *joinWriter) write(doc converter.ESDoc, children []converter.ESDoc) {
+	if len(children) > 0 {
+		w.matched++
+	} else if w.opts.inner {
+		return
+	}
+	var value interface{}
+	if w.opts.one {
+		if len(children) > 0 {
+			value = children[0].Source
+		}
+	} else {
+		list := make([]interface{}, len(children))
+		for i, child := range children {
+			list[i] = child.Source
+		}
+		value = list
+	}
+	if doc.Source == nil {
+		doc.Source = map[string]interface{}{}
+	}
+	if value != nil {
+		path := strings.Split(w.opts.as, ".")
+		if w.opts.merge {
+			switch existing := converter.ExtractFieldValue(doc.Source, path).(type) {
+			case []interface{}:
+				if list, ok := value.([]interface{}); ok {
+					value = append(existing, list...)
+				}
+			case map[string]interface{}:
+				if w.opts.one {
+					value = existing
+				}
+			}
+		}
+		converter.InsertFieldValue(doc.Source, path, value)
+	}
+	w.emit(doc)
+}
+
+// emit writes doc as it is.
+func (w *joinWriter) emit(doc converter.ESDoc) {
+	docJson, err := w.enc.encode(doc)
+	if err != nil {
+		fatal("failed to marshal doc", "error", err)
+	}
+	if w.docs > 0 {
+		w.WriteByte('\n')
+	}
+	w.Write(docJson)
+	w.docs++
+}
+
+// joinSpill holds both inputs of a join on disk, each in partitions by the
+// hash of the key, one {"key": ..., "doc": ...} line per document.
+type joinSpill struct {
+	dir     string
+	files   map[string][]*os.File
+	writers map[string][]*bufio.Writer
+}
+
+type spilledDoc struct {
+	Key string          `json:"key"`
+	Doc converter.ESDoc `json:"doc"`
+}
+
+func newJoinSpill() *joinSpill {
+	dir, err := os.MkdirTemp("", "converter-join-")
+	if err != nil {
+		fatal("failed to create spill directory", "error", err)
+	}
+	s := &joinSpill{dir: dir, files: map[string][]*os.File{}, writers: map[string][]*bufio.Writer{}}
+	for _, side := range []string{"left", "right"} {
+		for p := range joinPartitions {
+			file, err := os.Create(s.path(side, p))
+			if err != nil {
+				fatal("failed to create spill file", "error", err)
+			}
+			s.files[side] = append(s.files[side], file)
+			s.writers[side] = append(s.writers[side], bufio.NewWriter(file))
+		}
+	}
+	return s
+}
+
+func (s *joinSpill) path(side string, partition int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s-%02d.ndjson", side, partition))
+}
+
+func (s *joinSpill) add(side, key string, doc converter.ESDoc) {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	line, err := json.Marshal(spilledDoc{Key: key, Doc: doc})
+	if err != nil {
+		fatal("failed to marshal doc", "error", err)
+	}
+	writer := s.writers[side][hash.Sum32()%joinPartitions]
+	writer.Write(line)
+	writer.WriteByte('\n')
+}
+
+// flush writes out the buffered documents of every partition.
+func (s *joinSpill) flush() {
+	for _, writers := range s.writers {
+		for _, writer := range writers {
+			if err := writer.Flush(); err != nil {
+				fatal("failed to write spill file", "error", err)
+			}
+		}
+	}
+}
+
+// remove deletes the partition files.
+func (s *joinSpill) remove() {
+	for _, files := range s.files {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+	os.RemoveAll(s.dir)
+}
+
+func (s *joinSpill) read(side string, partition int, fn func(key string, doc converter.ESDoc)) {
+	file, err := os.Open(s.path(side, partition))
+	if err != nil {
+		fatal("failed to open spill file", "error", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var spilled spilledDoc
+		if err := docJSON.unmarshal(scanner.Bytes(), &spilled); err != nil {
+			fatal("invalid spill file", "error", err)
+		}
+		converter.ExactNumbers(spilled.Doc.Source)
+		fn(spilled.Key, spilled.Doc)
+	}
+	if err := scanner.Err(); err != nil {
+		fatal("failed to read spill file", "error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const (
+	joinLeft = `{"_id":"o1","_source":{"customer":"c1"}}
+{"_id":"o2","_source":{"customer":"c2"}}
+{"_id":"o3","_source":{"customer":"c3"}}
+`
+	joinRight = `{"_id":"l1","_source":{"order_id":"o1","sku":"a"}}
+{"_id":"l2","_source":{"order_id":"o2","sku":"b"}}
+{"_id":"l3","_source":{"order_id":"o1","sku":"c"}}
+{"_id":"l4","_source":{"sku":"orphan"}}
+`
+)
+
+// runJoinCommand runs join over the given inputs and returns the output
+// documents by ID.
+func runJoinCommand(t *testing.T, left, right string, args ...string) map[string]map[string]interface{} {
+	t.Helper()
+	dir := t.TempDir()
+	leftFile, rightFile, output := filepath.Join(dir, "left.json"), filepath.Join(dir, "right.json"), filepath.Join(dir, "out.json")
+	if err := os.WriteFile(leftFile, []byte(left), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(rightFile, []byte(right), 0644); err != nil {
+		t.Fatal(err)
+	}
+	join(append(append([]string{"-output", output}, args...), leftFile, rightFile))
+	return readOutputDocs(t, output)
+}
+
+// readOutputDocs reads an NDJSON output file by document ID.
+func readOutputDocs(t *testing.T, path string) map[string]map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := map[string]map[string]interface{}{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var doc struct {
+			ID     string                 `json:"_id"`
+			Source map[string]interface{} `json:"_source"`
+		}
+		if err := json.Unmarshal([]byte(line), &doc); err != nil {
+			t.Fatalf("invalid output line %s: %v", line, err)
+		}
+		docs[doc.ID] = doc.Source
+	}
+	return docs
+}
+
+// skus returns the sorted sku fields of the documents nested under field.
+func skus(source map[string]interface{}, field string) []string {
+	var out []string
+	items, _ := source[field].([]interface{})
+	for _, item := range items {
+		out = append(out, item.(map[string]interface{})["sku"].(string))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestJoin(t *testing.T) {
+	for name, extra := range map[string][]string{"memory": nil, "spilled": {"-max-right", "1"}} {
+		t.Run(name, func(t *testing.T) {
+			docs := runJoinCommand(t, joinLeft, joinRight, append([]string{"-right-key", "_source.order_id", "-as", "lines"}, extra...)...)
+			if len(docs) != 3 {
+				t.Fatalf("join wrote %d docs, want 3", len(docs))
+			}
+			if got := skus(docs["o1"], "lines"); !reflect.DeepEqual(got, []string{"a", "c"}) {
+				t.Errorf("o1 lines = %v, want [a c]", got)
+			}
+			if got := skus(docs["o2"], "lines"); !reflect.DeepEqual(got, []string{"b"}) {
+				t.Errorf("o2 lines = %v, want [b]", got)
+			}
+			if lines, ok := docs["o3"]["lines"].([]interface{}); !ok || len(lines) != 0 {
+				t.Errorf("o3 lines = %v, want an empty array", docs["o3"]["lines"])
+			}
+		})
+	}
+}
+
+func TestJoinOneInner(t *testing.T) {
+	docs := runJoinCommand(t, joinLeft, joinRight, "-right-key", "_source.order_id", "-as", "order.line", "-one", "-inner")
+	if len(docs) != 2 || docs["o3"] != nil {
+		t.Fatalf("inner join wrote %v, want o1 and o2", docs)
+	}
+	line, _ := docs["o2"]["order"].(map[string]interface{})["line"].(map[string]interface{})
+	if line["sku"] != "b" {
+		t.Errorf("o2 order.line = %v, want sku b", line)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// jsonEngine encodes and decodes the documents streamed through the
+// commands. Engines other than encoding/json are compiled in with their
+// build tag: go build -tags jsoniter or -tags sonic. unmarshal decodes
+// numbers as json.Number, which unmarshalDoc makes exact Go numbers.
+type jsonEngine struct {
+	name       string
+	marshal    func(v any) ([]byte, error)
+	unmarshal  func(data []byte, v any) error
+	newEncoder func(w io.Writer) encoder
+}
+
+type encoder interface {
+	Encode(v any) error
+}
+
+var jsonEngines = map[string]jsonEngine{
+	"std": {"std", json.Marshal, unmarshalNumbers, func(w io.Writer) encoder { return json.NewEncoder(w) }},
+}
+
+// unmarshalNumbers is json.Unmarshal with UseNumber.
+func unmarshalNumbers(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid character after top-level value")
+	}
+	return nil
+}
+
+// unmarshalDoc decodes a document with the chosen engine, keeping its
+// numbers exact: see converter.ExactNumbers.
+func unmarshalDoc(data []byte, doc *converter.ESDoc) error {
+	if err := docJSON.unmarshal(data, doc); err != nil {
+		return err
+	}
+	converter.ExactNumbers(doc.Source)
+	return nil
+}
+
+// unmarshalObject is unmarshalDoc for a document decoded as a plain object.
+func unmarshalObject(data []byte, object *map[string]interface{}) error {
+	if err := docJSON.unmarshal(data, object); err != nil {
+		return err
+	}
+	converter.ExactNumbers(*object)
+	return nil
+}
+
+// docJSON is the engine chosen with -json-engine.
+var docJSON = jsonEngines["std"]
+
+// registerJSONEngine adds the -json-engine flag to fs.
+func registerJSONEngine(fs *flag.FlagSet) {
+	fs.Var(jsonEngineFlag{}, "json-engine", "JSON codec for documents: "+strings.Join(jsonEngineNames(), ", ")+" (default std)")
+}
+
+// jsonEngineFlag sets docJSON. Its String is the engine name, which -watch
+// mode passes on to its children.
+type jsonEngineFlag struct{}
+
+func (jsonEngineFlag) String() string {
+	return docJSON.name
+}
+
+func (jsonEngineFlag) Set(name string) error {
+	engine, ok := jsonEngines[name]
+	if !ok {
+		return fmt.Errorf("engine %q is not built in, rebuild with -tags %s", name, name)
+	}
+	docJSON = engine
+	return nil
+}
+
+// outputPretty and outputIndent are set with -pretty and -indent. Output
+// documents are compact, one per line, unless either is set.
+var (
+	outputPretty bool
+	outputIndent string
+)
+
+// registerOutputIndent adds the -pretty and -indent flags to fs.
+func registerOutputIndent(fs *flag.FlagSet) {
+	fs.BoolVar(&outputPretty, "pretty", false, "Indent output documents by two spaces for human review; the output is then a stream of JSON documents rather than NDJSON")
+	fs.Var(&indentFlag{}, "indent", "Indent output documents by this many spaces, or by a tab with \"tab\", like -pretty")
+}
+
+// indentFlag sets outputIndent from a number of spaces or "tab".
+type indentFlag struct {
+	value string
+}
+
+func (f *indentFlag) String() string {
+	return f.value
+}
+
+func (f *indentFlag) Set(value string) error {
+	if value == "tab" {
+		outputIndent = "\t"
+	} else if n, err := strconv.Atoi(value); err == nil && n >= 0 && n <= 16 {
+		outputIndent = strings.Repeat(" ", n)
+	} else {
+		return fmt.Errorf("indent must be a number of spaces up to 16 or \"tab\"")
+	}
+	f.value = value
+	return nil
+}
+
+// docEncoder encodes documents into one buffer reused from document to
+// document, sparing the write loops an allocation per document, and
+// indents them with -pretty or -indent. With an order, set by -key-order,
+// documents and objects are written with their keys in it.
+type docEncoder struct {
+	buf      bytes.Buffer
+	enc      encoder
+	indent   string
+	indented bytes.Buffer
+	order    *keyOrder
+}
+
+func newDocEncoder() *docEncoder {
+	e := &docEncoder{indent: outputIndent}
+	if e.indent == "" && outputPretty {
+		e.indent = "  "
+	}
+	e.enc = docJSON.newEncoder(&e.buf)
+	return e
+}
+
+// encode returns the JSON of v, which stays valid until the next call.
+func (e *docEncoder) encode(v any) ([]byte, error) {
+	e.buf.Reset()
+	var err error
+	switch value := v.(type) {
+	case converter.ESDoc:
+		if e.order != nil {
+			err = writeOrderedDoc(&e.buf, value, e.order)
+			break
+		}
+		err = e.enc.Encode(v)
+	case map[string]interface{}:
+		if e.order != nil {
+			err = writeOrdered(&e.buf, value, e.order)
+			break
+		}
+		err = e.enc.Encode(v)
+	default:
+		err = e.enc.Encode(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))
+	if e.indent == "" {
+		return data, nil
+	}
+	e.indented.Reset()
+	if err := json.Indent(&e.indented, data, "", e.indent); err != nil {
+		return nil, err
+	}
+	return e.indented.Bytes(), nil
+}
+
+func jsonEngineNames() []string {
+	names := make([]string, 0, len(jsonEngines))
+	for name := range jsonEngines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,15 @@
+//go:build jsoniter
+
+package main
+
+import (
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func init() {
+	api := jsoniter.ConfigCompatibleWithStandardLibrary
+	decode := jsoniter.Config{EscapeHTML: true, SortMapKeys: true, ValidateJsonRawMessage: true, UseNumber: true}.Froze()
+	jsonEngines["jsoniter"] = jsonEngine{"jsoniter", api.Marshal, decode.Unmarshal, func(w io.Writer) encoder { return api.NewEncoder(w) }}
+}
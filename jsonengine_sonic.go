@@ -0,0 +1,17 @@
+//go:build sonic
+
+package main
+
+import (
+	"io"
+
+	"github.com/bytedance/sonic"
+)
+
+func init() {
+	// ConfigStd matches encoding/json: sorted map keys, escaped HTML and
+	// valid UTF-8. Decoding adds UseNumber to it.
+	api := sonic.ConfigStd
+	decode := sonic.Config{EscapeHTML: true, SortMapKeys: true, CompactMarshaler: true, CopyString: true, ValidateString: true, UseNumber: true}.Froze()
+	jsonEngines["sonic"] = jsonEngine{"sonic", api.Marshal, decode.Unmarshal, func(w io.Writer) encoder { return api.NewEncoder(w) }}
+}
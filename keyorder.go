@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// orderedSections are the mapping sections whose fields -key-order mapping
+// writes first, in the order the mapping file declares them.
+var orderedSections = map[string]bool{
+	"passthrough":     true,
+	"field_mapping":   true,
+	"templates":       true,
+	"default_values":  true,
+	"random_generate": true,
+}
+
+// keyOrder ranks the keys of an object, with the orders of the objects
+// nested under them. Keys it does not rank follow the ranked ones, sorted.
+type keyOrder struct {
+	rank     map[string]int
+	children map[string]*keyOrder
+}
+
+func newKeyOrder() *keyOrder {
+	return &keyOrder{rank: map[string]int{}, children: map[string]*keyOrder{}}
+}
+
+// add ranks the segments of path after those added before.
+func (o *keyOrder) add(path []string) {
+	for _, key := range path {
+		if _, ok := o.rank[key]; !ok {
+			o.rank[key] = len(o.rank)
+			o.children[key] = newKeyOrder()
+		}
+		o = o.children[key]
+	}
+}
+
+func (o *keyOrder) keys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, iRanked := o.rank[keys[i]]
+		rj, jRanked := o.rank[keys[j]]
+		if iRanked != jRanked {
+			return iRanked
+		}
+		if iRanked {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// registerKeyOrder adds the -key-order flag to fs.
+func registerKeyOrder(fs *flag.FlagSet) *string {
+	return fs.String("key-order", "sorted", "Key order of output objects: sorted, or mapping for the fields in the order the mapping file declares them, then the others sorted")
+}
+
+// setKeyOrder applies a -key-order value to enc. Every JSON engine sorts
+// map keys already, so only mapping needs the mapping file.
+func setKeyOrder(enc *docEncoder, value, mappingFile string) {
+	switch value {
+	case "sorted":
+	case "mapping":
+		order, err := loadKeyOrder(mappingFile)
+		if err != nil {
+			fatal("failed to read mapping field order", "mapping", mappingFile, "error", err)
+		}
+		enc.order = order
+	default:
+		fatal("invalid -key-order, must be sorted or mapping", "value", value)
+	}
+}
+
+// loadKeyOrder reads the target fields of a mapping file in the order they
+// appear in it. The mappings of a multi-mapping file add theirs in turn.
+func loadKeyOrder(path string) (*keyOrder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	order := newKeyOrder()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err = walkMappingOrder(dec, "", order); err != nil {
+		return nil, fmt.Errorf("invalid mapping file: %w", err)
+	}
+	return order, nil
+}
+
+// walkMappingOrder reads the next value of dec, the member key of its
+// parent object, adding the fields of the ordered sections to order.
+func walkMappingOrder(dec *json.Decoder, key string, order *keyOrder) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+	switch {
+	case delim == '{' && orderedSections[key]:
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			order.add(strings.Split(name.(string), "."))
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+	case delim == '[' && key == "passthrough":
+		for dec.More() {
+			var field string
+			if err = dec.Decode(&field); err != nil {
+				return err
+			}
+			order.add(strings.Split(field, "."))
+		}
+	case delim == '{':
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err = walkMappingOrder(dec, name.(string), order); err != nil {
+				return err
+			}
+		}
+	default:
+		for dec.More() {
+			if err = walkMappingOrder(dec, "", order); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// writeOrdered encodes v to buf with its object keys in order. Values other
+// than objects and arrays are encoded by the JSON engine.
+func writeOrdered(buf *bytes.Buffer, v interface{}, order *keyOrder) error {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if value == nil {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteByte('{')
+		for i, key := range order.keys(value) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			name, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			buf.Write(name)
+			buf.WriteByte(':')
+			child := order.children[key]
+			if child == nil {
+				child = emptyKeyOrder
+			}
+			if err = writeOrdered(buf, value[key], child); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	case []interface{}:
+		if value == nil {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeOrdered(buf, element, order); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	data, err := docJSON.marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+// emptyKeyOrder sorts every key.
+var emptyKeyOrder = newKeyOrder()
+
+// writeOrderedDoc encodes doc to buf with the metadata first, as the JSON
+// engines do, and the keys of its source in order.
+func writeOrderedDoc(buf *bytes.Buffer, doc converter.ESDoc, order *keyOrder) error {
+	meta, err := docJSON.marshal(doc.ESMeta)
+	if err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(meta, []byte("}")))
+	buf.WriteString(`,"_source":`)
+	if err = writeOrdered(buf, doc.Source, order); err != nil {
+		return err
+	}
+	buf.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// lineageFields are the fields -lineage stamps under its namespace.
+var lineageFields = []string{"tool_version", "tool_commit", "mapping_sha256", "run_id", "converted_at", "source_file", "source_line"}
+
+// lineage stamps converted documents with where they came from: the build
+// of the tool, the mapping file by content, the run, and the input file and
+// line of the source document.
+type lineage struct {
+	path  []string
+	run   map[string]interface{}
+	input string
+}
+
+// newLineage reads the mapping file for its hash. An empty runID is
+// replaced by a random one.
+func newLineage(namespace, runID, mappingFile, input string, start time.Time) (*lineage, error) {
+	data, err := os.ReadFile(mappingFile)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if runID == "" {
+		runID = newRunID()
+	}
+	info := currentBuildInfo()
+	run := map[string]interface{}{
+		"tool_version":   info.Version,
+		"mapping_sha256": hex.EncodeToString(sum[:]),
+		"run_id":         runID,
+		"converted_at":   start.UTC().Format(time.RFC3339),
+	}
+	if info.Commit != "" {
+		run["tool_commit"] = info.Commit
+	}
+	return &lineage{path: strings.Split(namespace, "."), run: run, input: input}, nil
+}
+
+// newRunID returns 16 random bytes in hex.
+func newRunID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func (l *lineage) runID() string {
+	return l.run["run_id"].(string)
+}
+
+// fields returns the dotted paths of the namespace and the stamped fields,
+// for comparisons that must not report them as changes.
+func (l *lineage) fields() []string {
+	namespace := strings.Join(l.path, ".")
+	fields := []string{namespace}
+	for _, field := range lineageFields {
+		fields = append(fields, namespace+"."+field)
+	}
+	return fields
+}
+
+// stamp adds the lineage of a document converted from the given input line.
+func (l *lineage) stamp(doc converter.ESDoc, line int) {
+	fields := make(map[string]interface{}, len(l.run)+2)
+	for key, value := range l.run {
+		fields[key] = value
+	}
+	fields["source_file"] = l.input
+	fields["source_line"] = line
+	converter.InsertFieldValue(doc.Source, l.path, fields)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setupLogging installs the default logger for -log-level and -log-format.
+// Logs always go to stderr so that stdout can carry output documents.
+func setupLogging(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid -log-level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("invalid -log-format %q", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal logs msg with its attributes at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	stopProfiles()
+	os.Exit(1)
+}
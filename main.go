@@ -1,273 +1,145 @@
 package main
 
 import (
-	"bufio"
-	"encoding/base64"
-	"encoding/csv"
-	"encoding/json"
-	"flag"
-	"log"
-	"math"
-	"math/rand"
+	"errors"
+	"fmt"
+	"io"
 	"os"
-	"runtime"
+	"path/filepath"
 	"strings"
-	"time"
-)
 
-const (
-	NullValue = "NULL"
+	"github.com/ishtiaqhimel/converter/pkg/converter"
 )
 
-type ESMeta struct {
-	Index *string  `json:"_index"`
-	Type  *string  `json:"_type"`
-	ID    *string  `json:"_id"`
-	Score *float64 `json:"_score,omitempty"`
-}
-
-type ESDoc struct {
-	ESMeta
-	Source map[string]interface{} `json:"_source"`
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
 }
 
-type FieldMapping struct {
-	Index          *string                           `json:"index"`
-	FieldMapping   map[string]string                 `json:"field_mapping"`
-	DefaultValues  map[string]interface{}            `json:"default_values"`
-	RandomGenerate map[string]map[string]interface{} `json:"random_generate"`
-	File           map[string]string                 `json:"file"`
+var commands = []command{
+	{"convert", "Convert an input file with a mapping (the default command)", convert},
+	{"generate", "Generate synthetic documents from a mapping's defaults and generators", generate},
+	{"validate", "Check converted documents against a JSON Schema or index mapping", validate},
+	{"preview", "Print the first input documents next to their converted form", preview},
+	{"explain", "Trace which mapping rules wrote the fields of one document", explain},
+	{"aggregate", "Roll documents up into one summary document per group", aggregate},
+	{"reindex", "Convert documents from one Elasticsearch index into another", reindex},
+	{"verify", "Check that converted documents exist intact in the target index", verify},
+	{"bench", "Measure conversion throughput, allocations and stage timings", bench},
+	{"serve", "Serve a conversion API over HTTP with a preloaded mapping", serve},
+	{"ui", "Serve a local page previewing a mapping on a pasted document", ui},
+	{"infer-mapping", "Write a starter mapping for the fields of sample documents", inferMapping},
+	{"init", "Scaffold a mapping file from a template and sample documents", initMapping},
+	{"validate-mapping", "Check a mapping file for mistakes without running it", validateMapping},
+	{"decrypt", "Decrypt fields written by the encrypt transform", decrypt},
+	{"join", "Nest the documents of one input into those of another by key", join},
+	{"fold", "Nest the children of a join-field export into their parents", fold},
+	{"diff", "Compare two document sets joined by a key field", diffCommand},
+	{"test", "Run test cases of input and expected documents against a mapping", testMapping},
+	{"version", "Print the version, commit, build date and Go version", printVersion},
 }
 
 func main() {
-	inputFile := flag.String("input", "./data/input.json", "Path to input JSON file")
-	mappingFile := flag.String("mapping", "./data/mapping.json", "Path to mapping JSON file")
-	outputFile := flag.String("output", "./data/output.json", "Path to output JSON file")
-	limit := flag.Int("limit", -1, "Limit of documents to process (-1 for all)")
-	flag.Parse()
-
-	start := time.Now()
-	var memStart runtime.MemStats
-	runtime.ReadMemStats(&memStart)
-
-	file, err := os.Open(*inputFile)
-	if err != nil {
-		log.Fatal("failed to open file", err)
-	}
-	defer func(file *os.File) {
-		err = file.Close()
-		if err != nil {
-			log.Fatal("failed to close file", err)
-		}
-	}(file)
-
-	scanner := bufio.NewScanner(file)
-	var inputData []string
-	count := 0
-	for scanner.Scan() {
-		count++
-		if *limit > 0 && count > *limit {
-			break
-		}
-		inputData = append(inputData, scanner.Text())
-	}
-
-	var docs []ESDoc
-	for _, data := range inputData {
-		if strings.TrimSpace(data) == "" {
-			continue
-		}
-		var doc ESDoc
-		err = json.Unmarshal([]byte(data), &doc)
-		if err != nil {
-			log.Fatal("failed to unmarshal input data", err)
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		convert(args)
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			cmd.run(args[1:])
+			return
 		}
-		docs = append(docs, doc)
-	}
-
-	mappingBytes, err := os.ReadFile(*mappingFile)
-	if err != nil {
-		log.Fatal("failed to read mapping file", err)
 	}
-
-	var mapping FieldMapping
-	if err = json.Unmarshal(mappingBytes, &mapping); err != nil {
-		log.Fatal("failed to unmarshal mapping file", err)
+	if args[0] != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
 	}
-
-	var result []string
-	rn := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for _, doc := range docs {
-		newSource := map[string]interface{}{}
-		for newField, oldField := range mapping.FieldMapping {
-			value := extractFieldValue(doc.Source, strings.Split(oldField, "."))
-			if value != nil {
-				insertFieldValue(newSource, strings.Split(newField, "."), value)
-			}
-		}
-
-		for key, val := range mapping.DefaultValues {
-			insertFieldValue(newSource, strings.Split(key, "."), val)
-		}
-
-		for key, config := range mapping.RandomGenerate {
-			insertFieldValue(newSource, strings.Split(key, "."), generateRandomValue(rn, config))
-		}
-
-		for key, val := range mapping.File {
-			fileData := map[string]interface{}{}
-			dataMapByID := map[string]map[string]interface{}{}
-			if key == "path" {
-				if val == "" {
-					log.Fatal("file path is empty for", key)
-				}
-				dataMapByID = extractFileData(fileData, val)
-				if value, ok := dataMapByID[*doc.ID]; ok {
-					for v, k := range value {
-						insertFieldValue(newSource, strings.Split(v, "."), k)
-					}
-				}
-			}
-		}
-
-		newDoc := ESDoc{
-			ESMeta: ESMeta{
-				Index: mapping.Index,
-				Type:  doc.Type,
-				ID:    doc.ID,
-				Score: doc.Score,
-			},
-			Source: newSource,
-		}
-		docJson, err := json.Marshal(newDoc)
-		if err != nil {
-			log.Fatal("failed to marshal new doc", err)
-		}
-		result = append(result, string(docJson))
+	usage()
+	if args[0] != "help" {
+		os.Exit(2)
 	}
+}
 
-	err = os.WriteFile(*outputFile, []byte(strings.Join(result, "\n")), 0644)
-	if err != nil {
-		log.Fatal("failed to write output file", err)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", filepath.Base(os.Args[0]))
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
 	}
-
-	elapsed := time.Since(start)
-	var memEnd runtime.MemStats
-	runtime.ReadMemStats(&memEnd)
-	log.Printf("Time taken: %s\n", elapsed)
-	log.Printf("Memory used: %d MB\n", (memEnd.Alloc-memStart.Alloc)/(1024*1024))
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command.\n", filepath.Base(os.Args[0]))
 }
 
-func extractFieldValue(data map[string]interface{}, path []string) interface{} {
-	if len(path) == 0 {
-		return data
-	}
-	val, ok := data[path[0]]
-	if !ok {
-		return nil
-	}
-	if len(path) == 1 {
-		if val == nil {
-			return NullValue
-		}
-		return val
+// openConverter loads the mapping and opens its lookups and processors.
+// Routed misses are written through createFile.
+func openConverter(mappingFile, outputFile string, dryRun bool) (converter.FieldMapping, *converter.Converter) {
+	mapping, err := converter.LoadMapping(mappingFile)
+	if err != nil {
+		fatal("failed to load mapping file", "error", err)
 	}
-	switch typed := val.(type) {
-	case map[string]interface{}:
-		return extractFieldValue(typed, path[1:])
-	default:
-		return nil
+	conv, err := newConverter(mapping, outputFile, dryRun)
+	if err != nil {
+		fatal(err.Error())
 	}
+	return mapping, conv
 }
 
-func insertFieldValue(data map[string]interface{}, path []string, value interface{}) {
-	for i := 0; i < len(path)-1; i++ {
-		key := path[i]
-		if _, exists := data[key]; !exists {
-			data[key] = make(map[string]interface{})
-		}
-		data = data[key].(map[string]interface{})
+func newConverter(mapping converter.FieldMapping, outputFile string, dryRun bool) (*converter.Converter, error) {
+	opts := converter.Options{
+		OutputFile: outputFile,
+		CreateFile: func(path string) (io.WriteCloser, error) {
+			return createFile(path, dryRun)
+		},
 	}
-	if value == NullValue {
-		value = nil
+	if budget != nil {
+		opts.Reserve = budget.reserve
 	}
-	data[path[len(path)-1]] = value
+	return converter.New(mapping, opts)
 }
 
-func extractFileData(fileData map[string]interface{}, filePath string) map[string]map[string]interface{} {
-	file, err := os.Open(filePath)
+// convertDoc explodes doc by the mapping and converts every part. dropped
+// counts the parts the mapping dropped, or 1 when there are none.
+func convertDoc(conv *converter.Converter, doc converter.ESDoc) (docs []converter.ESDoc, dropped int, err error) {
+	parts, err := conv.Explode(doc)
 	if err != nil {
-		log.Fatal(err)
+		return nil, 0, err
 	}
-	defer func(file *os.File) {
-		err = file.Close()
-		if err != nil {
-			log.Fatal("failed to close file", err)
-		}
-	}(file)
-
-	records, err := csv.NewReader(file).ReadAll()
-	if err != nil {
-		log.Fatal(err)
+	if len(parts) == 0 {
+		return nil, 1, nil
 	}
-
-	headers := records[0]
-	idIndex := -1
-	for i, header := range headers {
-		if header == "id" {
-			idIndex = i
-			break
+	for _, part := range parts {
+		newDoc, err := conv.Convert(part)
+		if errors.Is(err, converter.ErrDropped) {
+			dropped++
+			continue
 		}
-	}
-
-	if idIndex == -1 {
-		log.Fatal("id column not found")
-	}
-
-	dataMapByID := make(map[string]map[string]interface{})
-	for _, row := range records[1:] {
-		id := row[idIndex]
-		fields := make(map[string]interface{})
-		for i, header := range headers {
-			if i != idIndex {
-				fields[header] = row[i]
-			}
+		if err != nil {
+			return nil, 0, err
 		}
-		dataMapByID[id] = fields
+		docs = append(docs, newDoc)
 	}
-	return dataMapByID
+	return docs, dropped, nil
 }
 
-func generateRandomValue(rn *rand.Rand, config map[string]interface{}) interface{} {
-	switch config["type"] {
-	case "binary":
-		data := make([]byte, 64)
-		rn.Read(data)
-		return base64.StdEncoding.EncodeToString(data)
-
-	case "boolean":
-		return rn.Intn(2) == 0
-
-	case "date":
-		// TODO: need to implement
-
-	case "long", "integer", "short", "byte":
-		mn := int(config["min"].(float64))
-		mx := int(config["max"].(float64))
-		return rn.Intn(mx-mn+1) + mn
-
-	case "double", "float", "half_float":
-		mn := config["min"].(float64)
-		mx := config["max"].(float64)
-		d := mn + rn.Float64()*(mx-mn)
-		return math.Round(d*100) / 100
+type nopWriteCloser struct {
+	io.Writer
+}
 
-	case "keyword", "wildcard", "constant_keyword":
-		values := config["values"].([]interface{})
-		return values[rn.Intn(len(values))] // TODO: generate complete random value
+func (nopWriteCloser) Close() error {
+	return nil
+}
 
-	default:
-		return nil
+// createFile creates path for writing, or returns a writer that discards
+// everything in dry-run mode. "-" writes to stdout. Files recorded in a
+// resumed checkpoint are appended to instead.
+func createFile(path string, dryRun bool) (io.WriteCloser, error) {
+	if dryRun {
+		return nopWriteCloser{io.Discard}, nil
+	}
+	if path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	if size, ok := resumeSizes[path]; ok {
+		return openTruncated(path, size)
 	}
-	return struct{}{}
+	return os.Create(path)
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// manifest lists the files of a run, for loaders to check that they have
+// all of them, whole, before ingesting. Complete is false for a run that was
+// interrupted.
+type manifest struct {
+	CreatedAt time.Time      `json:"created_at"`
+	RunID     string         `json:"run_id,omitempty"`
+	Complete  bool           `json:"complete"`
+	Files     []manifestFile `json:"files"`
+}
+
+// manifestFile is a file of a run. Docs is set for NDJSON document files.
+type manifestFile struct {
+	Path   string `json:"path"`
+	Docs   *int   `json:"docs,omitempty"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeManifest writes the manifest of docFiles, whose documents it counts,
+// and otherFiles to path. Files that do not exist, such as stdout, are left
+// out.
+func writeManifest(path, runID string, complete bool, docFiles, otherFiles []string) error {
+	m := manifest{CreatedAt: time.Now().UTC(), RunID: runID, Complete: complete, Files: []manifestFile{}}
+	for i, file := range append(docFiles, otherFiles...) {
+		entry, err := describeFile(file, i < len(docFiles))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, entry)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func describeFile(path string, countDocs bool) (manifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	var lines lineCounter
+	size, err := io.Copy(io.MultiWriter(hash, &lines), file)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	entry := manifestFile{Path: path, Bytes: size, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	if countDocs {
+		docs := lines.count()
+		entry.Docs = &docs
+	}
+	return entry, nil
+}
+
+// lineCounter counts the lines written to it that are not blank.
+type lineCounter struct {
+	lines int
+	open  bool
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n')
+		line := p
+		if end >= 0 {
+			line = p[:end]
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.open = true
+		}
+		if end < 0 {
+			break
+		}
+		if c.open {
+			c.lines++
+		}
+		c.open = false
+		p = p[end+1:]
+	}
+	return n, nil
+}
+
+func (c *lineCounter) count() int {
+	if c.open {
+		return c.lines + 1
+	}
+	return c.lines
+}
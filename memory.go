@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// memoryBudget is the -max-memory allowance shared by the structures that
+// can move to disk once it is used up: lookup tables and the delta and seen
+// sets. Sizes are estimates; the budget also becomes the runtime's soft
+// memory limit, so the collector works harder before it is reached.
+type memoryBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// budget is the budget set with -max-memory, or nil for no limit.
+var budget *memoryBudget
+
+// registerMaxMemory adds the -max-memory flag to fs.
+func registerMaxMemory(fs *flag.FlagSet) {
+	fs.Var(&maxMemoryFlag{}, "max-memory", "Memory budget, e.g. 512MB or 4GB; lookup tables and -delta/-seen sets beyond it are moved to temporary files")
+}
+
+// maxMemoryFlag sets budget. Its String is the size as given, which -watch
+// mode passes on to its children.
+type maxMemoryFlag struct {
+	value string
+}
+
+func (f *maxMemoryFlag) String() string {
+	return f.value
+}
+
+func (f *maxMemoryFlag) Set(value string) error {
+	limit, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	budget = &memoryBudget{limit: limit}
+	debug.SetMemoryLimit(limit)
+	f.value = value
+	return nil
+}
+
+// reserve takes n bytes from the budget, or reports false when they do not
+// fit. A nil budget fits everything.
+func (b *memoryBudget) reserve(n int64) bool {
+	if b == nil {
+		return true
+	}
+	if b.used.Add(n) > b.limit {
+		b.used.Add(-n)
+		return false
+	}
+	return true
+}
+
+func (b *memoryBudget) release(n int64) {
+	if b != nil {
+		b.used.Add(-n)
+	}
+}
+
+// parseSize parses a byte count with an optional KB, MB or GB suffix, in
+// powers of 1024.
+func parseSize(value string) (int64, error) {
+	text := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(text, unit.suffix) {
+			text, multiplier = strings.TrimSpace(strings.TrimSuffix(text, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the per-document
+// latency histogram; batchBuckets those of the batch one.
+var (
+	latencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+	batchBuckets   = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900}
+)
+
+// metrics holds the counters of a long-running serve or -watch process
+// and writes them in the Prometheus text exposition format. Lookup counts
+// live in the converters, which a mapping reload or a -watch child
+// replaces, so the counts of finished converters are kept in lookups and
+// those of the current one are added at scrape time.
+type metrics struct {
+	docsConverted atomic.Int64
+	docsDropped   atomic.Int64
+	errors        atomic.Int64
+	queueDepth    atomic.Int64
+	docLatency    *histogram
+	batchLatency  *histogram
+
+	mu      sync.Mutex
+	lookups map[string]lookupCount
+	current func() converter.Stats
+}
+
+type lookupCount struct {
+	hits, misses int
+}
+
+func newMetrics(current func() converter.Stats) *metrics {
+	return &metrics{
+		docLatency:   newHistogram(latencyBuckets),
+		batchLatency: newHistogram(batchBuckets),
+		lookups:      map[string]lookupCount{},
+		current:      current,
+	}
+}
+
+// retire adds the lookup counts of a converter that is no longer used.
+func (m *metrics) retire(lookups []converter.LookupStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, lookup := range lookups {
+		count := m.lookups[lookup.Name]
+		count.hits += lookup.Hits
+		count.misses += lookup.Misses
+		m.lookups[lookup.Name] = count
+	}
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *metrics) write(w io.Writer) {
+	writeMetric(w, "converter_docs_converted_total", "counter", "Documents converted.", m.docsConverted.Load())
+	writeMetric(w, "converter_docs_dropped_total", "counter", "Documents dropped by the mapping or a filter.", m.docsDropped.Load())
+	writeMetric(w, "converter_errors_total", "counter", "Documents, requests or files that failed to convert.", m.errors.Load())
+	writeMetric(w, "converter_queue_depth", "gauge", "Requests waiting for the converter, or files waiting to be converted in -watch mode.", m.queueDepth.Load())
+
+	m.mu.Lock()
+	lookups := make(map[string]lookupCount, len(m.lookups))
+	for name, count := range m.lookups {
+		lookups[name] = count
+	}
+	m.mu.Unlock()
+	if m.current != nil {
+		for _, lookup := range m.current().Lookups {
+			count := lookups[lookup.Name]
+			count.hits += lookup.Hits
+			count.misses += lookup.Misses
+			lookups[lookup.Name] = count
+		}
+	}
+	names := sortedNames(lookups)
+	fmt.Fprintln(w, "# HELP converter_lookup_hits_total Lookup keys found.")
+	fmt.Fprintln(w, "# TYPE converter_lookup_hits_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "converter_lookup_hits_total{lookup=%s} %d\n", quoteLabel(name), lookups[name].hits)
+	}
+	fmt.Fprintln(w, "# HELP converter_lookup_misses_total Lookup keys not found.")
+	fmt.Fprintln(w, "# TYPE converter_lookup_misses_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "converter_lookup_misses_total{lookup=%s} %d\n", quoteLabel(name), lookups[name].misses)
+	}
+
+	m.docLatency.write(w, "converter_doc_duration_seconds", "Time to convert one document.")
+	m.batchLatency.write(w, "converter_batch_duration_seconds", "Time to convert and write out a batch: an NDJSON request, or a file in -watch mode.")
+}
+
+func writeMetric(w io.Writer, name, typ, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, typ, name, value)
+}
+
+func sortedNames(m map[string]lookupCount) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
+
+// histogram counts observations in cumulative buckets like a Prometheus
+// histogram.
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int64, len(bounds))}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	var cumulative int64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// serveMetrics serves m at /metrics on addr in the background.
+func serveMetrics(addr string, m *metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			fatal("metrics server failed", "error", err)
+		}
+	}()
+	slog.Info("serving metrics", "addr", addr)
+}
+
+// setQueueDepth sets the queue depth gauge. A nil m records nothing, like
+// recordFile.
+func (m *metrics) setQueueDepth(n int) {
+	if m != nil {
+		m.queueDepth.Store(int64(n))
+	}
+}
+
+// recordFile counts a file converted by a -watch child from the report it
+// wrote, or as an error when the child failed.
+func (m *metrics) recordFile(report string, elapsed time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.batchLatency.observe(elapsed)
+	if err != nil {
+		m.errors.Add(1)
+		return
+	}
+	if report == "" {
+		return
+	}
+	data, readErr := os.ReadFile(report)
+	if readErr != nil {
+		return
+	}
+	var r runReport
+	if readErr = json.Unmarshal(data, &r); readErr != nil {
+		slog.Warn("failed to read child report", "report", report, "error", readErr)
+		return
+	}
+	m.docsConverted.Add(int64(r.DocsConverted))
+	m.docsDropped.Add(int64(r.DocsDropped))
+	m.errors.Add(int64(r.DocsRejected))
+	lookups := make([]converter.LookupStats, 0, len(r.Lookups))
+	for _, lookup := range r.Lookups {
+		lookups = append(lookups, converter.LookupStats{Name: lookup.Name, Hits: lookup.Hits, Misses: lookup.Misses})
+	}
+	m.retire(lookups)
+}
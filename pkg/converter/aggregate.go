@@ -0,0 +1,270 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// AggregateConfig describes a roll-up: documents are grouped by the
+// group_by fields and each group becomes one output document holding the
+// group fields and the aggregations.
+//
+//	{
+//	  "index": "logs-hourly",
+//	  "group_by": {"host": "host.name", "hour": "{{date \"2006-01-02T15:00\" .timestamp}}"},
+//	  "aggregations": {
+//	    "requests": {"type": "count"},
+//	    "bytes":    {"type": "sum", "field": "response.bytes"},
+//	    "paths":    {"type": "collect", "field": "url.path"}
+//	  }
+//	}
+//
+// A group_by value is a source field path, or a template as in the
+// templates section of a mapping.
+type AggregateConfig struct {
+	Index        string                    `json:"index"`
+	GroupBy      map[string]string         `json:"group_by"`
+	Aggregations map[string]AggregateField `json:"aggregations"`
+}
+
+// AggregateField is one aggregation: count, sum, avg, min, max, first,
+// last, collect or collect_distinct over field.
+type AggregateField struct {
+	Type  string `json:"type"`
+	Field string `json:"field"`
+}
+
+// LoadAggregateConfig reads an aggregation file.
+func LoadAggregateConfig(path string) (AggregateConfig, error) {
+	var config AggregateConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid aggregation file: %w", err)
+	}
+	return config, nil
+}
+
+// Aggregator accumulates groups in memory; it is not safe for concurrent
+// use.
+type Aggregator struct {
+	config  AggregateConfig
+	keys    []string
+	paths   map[string][]string
+	tmpls   map[string]*template.Template
+	groups  map[string]*aggregateGroup
+	order   []string
+	targets []string
+}
+
+type aggregateGroup struct {
+	key    map[string]interface{}
+	values map[string]*aggregateValue
+}
+
+type aggregateValue struct {
+	count int
+	sum   float64
+	value interface{}
+	list  []interface{}
+	seen  map[string]bool
+}
+
+// NewAggregator checks config and returns an empty aggregator.
+func NewAggregator(config AggregateConfig) (*Aggregator, error) {
+	if len(config.GroupBy) == 0 {
+		return nil, fmt.Errorf("group_by is required")
+	}
+	a := &Aggregator{config: config, paths: map[string][]string{}, tmpls: map[string]*template.Template{}, groups: map[string]*aggregateGroup{}}
+	for target, expr := range config.GroupBy {
+		a.keys = append(a.keys, target)
+		if strings.Contains(expr, "{{") {
+			tmpl, err := parseComputed(target, expr)
+			if err != nil {
+				return nil, fmt.Errorf("group_by %s: %w", target, err)
+			}
+			a.tmpls[target] = tmpl
+		} else {
+			a.paths[target] = strings.Split(expr, ".")
+		}
+	}
+	sort.Strings(a.keys)
+	for target, field := range config.Aggregations {
+		switch field.Type {
+		case "count":
+		case "sum", "avg", "min", "max", "first", "last", "collect", "collect_distinct":
+			if field.Field == "" {
+				return nil, fmt.Errorf("aggregations %s: field is required for %s", target, field.Type)
+			}
+		default:
+			return nil, fmt.Errorf("aggregations %s: unknown type %q", target, field.Type)
+		}
+		a.targets = append(a.targets, target)
+	}
+	sort.Strings(a.targets)
+	return a, nil
+}
+
+// Add puts source into its group.
+func (a *Aggregator) Add(source map[string]interface{}) error {
+	key := make(map[string]interface{}, len(a.keys))
+	parts := make([]string, len(a.keys))
+	for i, target := range a.keys {
+		var value interface{}
+		if tmpl, ok := a.tmpls[target]; ok {
+			var out strings.Builder
+			if err := tmpl.Execute(&out, source); err != nil {
+				return fmt.Errorf("group_by %s: %w", target, err)
+			}
+			value = out.String()
+		} else if value = ExtractFieldValue(source, a.paths[target]); value == NullValue {
+			value = nil
+		}
+		key[target] = value
+		parts[i] = fmt.Sprint(value)
+		if value == nil {
+			parts[i] = ""
+		}
+	}
+	id := strings.Join(parts, "|")
+	group, ok := a.groups[id]
+	if !ok {
+		group = &aggregateGroup{key: key, values: map[string]*aggregateValue{}}
+		for _, target := range a.targets {
+			group.values[target] = &aggregateValue{}
+		}
+		a.groups[id] = group
+		a.order = append(a.order, id)
+	}
+	for _, target := range a.targets {
+		field := a.config.Aggregations[target]
+		value := group.values[target]
+		if field.Type == "count" {
+			value.count++
+			continue
+		}
+		item := ExtractFieldValue(source, splitPath(field.Field))
+		if item == nil || item == NullValue {
+			continue
+		}
+		if err := value.add(field.Type, item); err != nil {
+			return fmt.Errorf("aggregations %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+func (v *aggregateValue) add(typ string, item interface{}) error {
+	v.count++
+	switch typ {
+	case "sum", "avg":
+		n, ok := toFloat(item)
+		if !ok {
+			return fmt.Errorf("%v is not a number", item)
+		}
+		v.sum += n
+	case "min", "max":
+		if v.count == 1 || (typ == "min") == (compareValues(item, v.value) < 0) {
+			v.value = item
+		}
+	case "first":
+		if v.count == 1 {
+			v.value = item
+		}
+	case "last":
+		v.value = item
+	case "collect":
+		v.list = append(v.list, item)
+	case "collect_distinct":
+		data, _ := json.Marshal(item)
+		if v.seen == nil {
+			v.seen = map[string]bool{}
+		}
+		if !v.seen[string(data)] {
+			v.seen[string(data)] = true
+			v.list = append(v.list, item)
+		}
+	}
+	return nil
+}
+
+func (v *aggregateValue) result(typ string) interface{} {
+	switch typ {
+	case "count":
+		return v.count
+	case "sum":
+		return v.sum
+	case "avg":
+		if v.count == 0 {
+			return nil
+		}
+		return v.sum / float64(v.count)
+	case "collect", "collect_distinct":
+		if v.list == nil {
+			return []interface{}{}
+		}
+		return v.list
+	}
+	return v.value
+}
+
+// Results returns one document per group, in the order the groups were
+// first seen. The _id joins the group values with "|".
+func (a *Aggregator) Results() []ESDoc {
+	docs := make([]ESDoc, 0, len(a.order))
+	for _, id := range a.order {
+		group := a.groups[id]
+		source := map[string]interface{}{}
+		for _, target := range a.keys {
+			InsertFieldValue(source, splitPath(target), group.key[target])
+		}
+		for _, target := range a.targets {
+			InsertFieldValue(source, splitPath(target), group.values[target].result(a.config.Aggregations[target].Type))
+		}
+		doc := ESDoc{Source: source}
+		doc.ID = &id
+		if a.config.Index != "" {
+			doc.Index = &a.config.Index
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case float64:
+		return typed, true
+	case int:
+		return float64(typed), true
+	case int64:
+		return float64(typed), true
+	case json.Number:
+		f, err := typed.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compareValues orders numbers numerically and anything else by its
+// string form, which suits RFC 3339 dates.
+func compareValues(a, b interface{}) int {
+	x, aNumber := toFloat(a)
+	y, bNumber := toFloat(b)
+	if aNumber && bNumber {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
@@ -0,0 +1,271 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// combiners are the functions a field_mapping source may call in place of
+// a field path to derive a value from several fields, e.g.
+//
+//	"total":   "sum(price, tax, shipping)"
+//	"contact": "coalesce(work_email, home_email, \"unknown\")"
+//
+// Arguments are field paths, or quoted literals. Absent and null fields
+// are skipped, and an array counts as its elements. The numeric ones also
+// read numeric strings, and write nothing when no argument has a value;
+// they are template functions as well, {{sum .price .tax}}. sum, min and
+// max of integers are integers, kept exact, and a sum too large for an
+// int64 fails the document, as does a NaN or infinite argument.
+var combiners = map[string]func(values []interface{}) (interface{}, error){
+	"sum":      sumValues,
+	"min":      minValues,
+	"max":      maxValues,
+	"avg":      avgValues,
+	"coalesce": coalesceValues,
+}
+
+// combinedSource is a combiner call of a field_mapping source.
+type combinedSource struct {
+	combine func(values []interface{}) (interface{}, error)
+	// args are the field paths read, nil for a literal in literals.
+	args     [][]string
+	literals []interface{}
+}
+
+// parseCombined parses spec as a combiner call, reporting false when it is
+// not one.
+func parseCombined(spec string) (*combinedSource, bool, error) {
+	open := strings.IndexByte(spec, '(')
+	if open < 0 || !strings.HasSuffix(spec, ")") {
+		return nil, false, nil
+	}
+	combine, ok := combiners[strings.TrimSpace(spec[:open])]
+	if !ok {
+		return nil, false, nil
+	}
+	items, err := splitOutside(spec[open+1:len(spec)-1], ',')
+	if err != nil {
+		return nil, true, err
+	}
+	c := &combinedSource{combine: combine}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			return nil, true, fmt.Errorf("%s: empty argument", spec[:open])
+		case strings.HasPrefix(item, `"`):
+			literal, err := strconv.Unquote(item)
+			if err != nil {
+				return nil, true, fmt.Errorf("%s: invalid quoted argument %s", spec[:open], item)
+			}
+			c.args = append(c.args, nil)
+			c.literals = append(c.literals, literal)
+		default:
+			c.args = append(c.args, strings.Split(item, "."))
+			c.literals = append(c.literals, nil)
+		}
+	}
+	return c, true, nil
+}
+
+// read returns the combined value of the fields of source, or nil for none.
+func (c *combinedSource) read(source map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, len(c.args))
+	for i, path := range c.args {
+		if path == nil {
+			values[i] = c.literals[i]
+			continue
+		}
+		if value := ExtractFieldValue(source, path); value != NullValue {
+			values[i] = value
+		}
+	}
+	return c.combine(values)
+}
+
+// fields returns the dotted field paths the call reads.
+func (c *combinedSource) fields() []string {
+	var fields []string
+	for _, path := range c.args {
+		if path != nil {
+			fields = append(fields, strings.Join(path, "."))
+		}
+	}
+	return fields
+}
+
+// numbers returns the numbers among values, arrays flattened, and the
+// same as int64s when all of them are integers, nil otherwise. NaN and the
+// infinities are not numbers here, since no document can hold them.
+func numbers(values []interface{}) ([]float64, []int64, error) {
+	var out []float64
+	var ints []int64
+	integers := true
+	var add func(value interface{}) error
+	add = func(value interface{}) error {
+		switch typed := value.(type) {
+		case nil:
+			return nil
+		case []interface{}:
+			for _, item := range typed {
+				if err := add(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		case string:
+			text := strings.TrimSpace(typed)
+			if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+				value = n
+				break
+			}
+			f, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", typed)
+			}
+			value = f
+		case json.Number:
+			if n, err := typed.Int64(); err == nil {
+				value = n
+			}
+		}
+		f, ok := toFloat(value)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%v is not a number", value)
+		}
+		out = append(out, f)
+		switch typed := value.(type) {
+		case int64:
+			ints = append(ints, typed)
+		case int:
+			ints = append(ints, int64(typed))
+		default:
+			// Whole decimals count as integers while float64 holds them
+			// exactly.
+			if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+				integers = false
+			}
+			ints = append(ints, int64(f))
+		}
+		return nil
+	}
+	for _, value := range values {
+		if err := add(value); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !integers {
+		ints = nil
+	}
+	return out, ints, nil
+}
+
+// sumInts adds ints, reporting false when the sum overflows an int64.
+func sumInts(ints []int64) (int64, bool) {
+	var sum int64
+	for _, n := range ints {
+		if (n > 0 && sum > math.MaxInt64-n) || (n < 0 && sum < math.MinInt64-n) {
+			return 0, false
+		}
+		sum += n
+	}
+	return sum, true
+}
+
+// sumFloats adds nums, failing when the sum is too large for a float64.
+func sumFloats(nums []float64) (float64, error) {
+	var sum float64
+	for _, n := range nums {
+		sum += n
+	}
+	if math.IsInf(sum, 0) {
+		return 0, fmt.Errorf("the sum is too large for a number")
+	}
+	return sum, nil
+}
+
+func sumValues(values []interface{}) (interface{}, error) {
+	nums, ints, err := numbers(values)
+	if err != nil || len(nums) == 0 {
+		return nil, err
+	}
+	if ints != nil {
+		sum, ok := sumInts(ints)
+		if !ok {
+			return nil, fmt.Errorf("the sum overflows a 64-bit integer")
+		}
+		return sum, nil
+	}
+	return sumFloats(nums)
+}
+
+func minValues(values []interface{}) (interface{}, error) {
+	return extremeValue(values, -1)
+}
+
+func maxValues(values []interface{}) (interface{}, error) {
+	return extremeValue(values, 1)
+}
+
+// extremeValue returns the smallest number of values for sign -1, the
+// largest for 1.
+func extremeValue(values []interface{}, sign int) (interface{}, error) {
+	nums, ints, err := numbers(values)
+	if err != nil || len(nums) == 0 {
+		return nil, err
+	}
+	if ints != nil {
+		extreme := ints[0]
+		for _, n := range ints[1:] {
+			if (sign > 0 && n > extreme) || (sign < 0 && n < extreme) {
+				extreme = n
+			}
+		}
+		return extreme, nil
+	}
+	extreme := nums[0]
+	for _, n := range nums[1:] {
+		if (n-extreme)*float64(sign) > 0 {
+			extreme = n
+		}
+	}
+	return extreme, nil
+}
+
+func avgValues(values []interface{}) (interface{}, error) {
+	nums, ints, err := numbers(values)
+	if err != nil || len(nums) == 0 {
+		return nil, err
+	}
+	// Integers are summed exactly while they fit.
+	if sum, ok := sumInts(ints); ints != nil && ok {
+		return float64(sum) / float64(len(ints)), nil
+	}
+	sum, err := sumFloats(nums)
+	if err != nil {
+		return nil, err
+	}
+	return sum / float64(len(nums)), nil
+}
+
+// coalesceValues returns the first value that is neither absent nor null.
+func coalesceValues(values []interface{}) (interface{}, error) {
+	for _, value := range values {
+		if value != nil {
+			return value, nil
+		}
+	}
+	return nil, nil
+}
+
+// templateCombiner adapts a combiner to a template function; the template
+// gets nil for absent fields already.
+func templateCombiner(combine func(values []interface{}) (interface{}, error)) func(values ...interface{}) (interface{}, error) {
+	return func(values ...interface{}) (interface{}, error) {
+		return combine(values)
+	}
+}
@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCombiners(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"sum", []interface{}{int64(9007199254740993), int64(1), nil}, int64(9007199254740994), false},
+		{"sum", []interface{}{json.Number("9007199254740993"), " 2 ", []interface{}{int64(1), 2.0}}, int64(9007199254740998), false},
+		{"sum", []interface{}{int64(1), 0.5}, 1.5, false},
+		{"sum", []interface{}{nil, nil}, nil, false},
+		{"sum", []interface{}{int64(math.MaxInt64), int64(1)}, nil, true},
+		{"sum", []interface{}{int64(math.MinInt64), int64(-1)}, nil, true},
+		{"sum", []interface{}{1e308, 1e308}, nil, true},
+		{"sum", []interface{}{"NaN"}, nil, true},
+		{"sum", []interface{}{int64(1), "Inf"}, nil, true},
+		{"sum", []interface{}{math.NaN()}, nil, true},
+		{"sum", []interface{}{"ten"}, nil, true},
+		{"min", []interface{}{int64(9007199254740993), int64(9007199254740992)}, int64(9007199254740992), false},
+		{"max", []interface{}{int64(9007199254740993), int64(9007199254740992)}, int64(9007199254740993), false},
+		{"max", []interface{}{int64(3), "4.5", nil}, 4.5, false},
+		{"min", []interface{}{"-Infinity", int64(1)}, nil, true},
+		{"avg", []interface{}{int64(1), int64(2)}, 1.5, false},
+		{"avg", []interface{}{int64(math.MaxInt64), int64(math.MaxInt64)}, float64(math.MaxInt64), false},
+		{"avg", []interface{}{"nan"}, nil, true},
+		{"coalesce", []interface{}{nil, "", "x"}, "", false},
+	}
+	for _, tt := range tests {
+		got, err := combiners[tt.name](tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s(%v) error = %v, want error %v", tt.name, tt.values, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s(%v) = %#v, want %#v", tt.name, tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestConvertCombiners(t *testing.T) {
+	c, err := New(parseMapping(t, `{"field_mapping": {
+		"total": "sum(price, tax, \"1\")",
+		"contact": "coalesce(work_email, home_email, \"unknown\")",
+		"top": "max(scores)"
+	}}`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	doc := parseDoc(t, `{"_id":"1","_source":{"tax":null,"home_email":"a@example.com","scores":[3,"7",5]}}`)
+	doc.Source["price"] = int64(9007199254740993)
+	got, err := c.Convert(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"total": int64(9007199254740994), "contact": "a@example.com", "top": int64(7)}
+	if !reflect.DeepEqual(got.Source, want) {
+		t.Errorf("Convert() = %#v, want %#v", got.Source, want)
+	}
+	// A NaN fails the document, not the run.
+	if _, err := c.Convert(parseDoc(t, `{"_id":"2","_source":{"price":"NaN"}}`)); err == nil {
+		t.Error("Convert() of a NaN price succeeded")
+	}
+	got, err = c.Convert(parseDoc(t, `{"_id":"3","_source":{"price":2.5}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Source["total"] != 3.5 {
+		t.Errorf("total = %#v, want 3.5", got.Source["total"])
+	}
+}
@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// computedFuncs are available in the templates section.
+var computedFuncs = template.FuncMap{
+	"date":     formatDate,
+	"default":  defaultValue,
+	"json":     toJSON,
+	"lower":    strings.ToLower,
+	"upper":    strings.ToUpper,
+	"trim":     strings.TrimSpace,
+	"sum":      templateCombiner(sumValues),
+	"min":      templateCombiner(minValues),
+	"max":      templateCombiner(maxValues),
+	"avg":      templateCombiner(avgValues),
+	"coalesce": templateCombiner(coalesceValues),
+}
+
+// parseComputed parses the template of a templates entry. Each entry is a
+// Go template rendered against the source document, so
+//
+//	"full_name": "{{.first}} {{upper .last}}"
+//	"label":     "{{.host.name}} ({{default \"unknown\" .env}})"
+//	"day":       "{{date \"2006-01-02\" .timestamp}}"
+//
+// writes a string built from several source fields. A missing field renders
+// as "<no value>" unless given a default. Templates are not expanded with
+// the mapping's vars, since they only run per document.
+func parseComputed(target, text string) (*template.Template, error) {
+	return template.New(target).Funcs(computedFuncs).Parse(text)
+}
+
+// formatDate formats value, an RFC 3339 or date-only string or epoch
+// milliseconds, with a Go layout.
+func formatDate(layout string, value interface{}) (string, error) {
+	var t time.Time
+	switch typed := value.(type) {
+	case string:
+		var err error
+		for _, l := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err = time.Parse(l, typed); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return "", fmt.Errorf("date: cannot parse %q", typed)
+		}
+	case float64:
+		t = time.UnixMilli(int64(typed)).UTC()
+	case int64:
+		t = time.UnixMilli(typed).UTC()
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("date: unsupported value %v", value)
+	}
+	return t.Format(layout), nil
+}
+
+// defaultValue returns value, or def when value is missing, null or empty.
+func defaultValue(def, value interface{}) interface{} {
+	if value == nil || value == "" {
+		return def
+	}
+	return value
+}
+
+func toJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// TemplateFields returns the source fields a templates entry refers to
+// directly, e.g. host.name for {{.host.name}}. Fields inside range or with
+// blocks are relative to their element and not included.
+func TemplateFields(text string) []string {
+	tmpl, err := parseComputed("", text)
+	if err != nil || tmpl.Tree == nil {
+		return nil
+	}
+	var fields []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node != nil {
+				for _, child := range node.Nodes {
+					walk(child)
+				}
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node != nil {
+				for _, cmd := range node.Cmds {
+					for _, arg := range cmd.Args {
+						walk(arg)
+					}
+				}
+			}
+		case *parse.FieldNode:
+			fields = append(fields, strings.Join(node.Ident, "."))
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.ElseList)
+		}
+	}
+	walk(tmpl.Tree.Root)
+	return fields
+}
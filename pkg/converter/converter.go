@@ -0,0 +1,268 @@
+// Package converter maps Elasticsearch documents from one index layout to
+// another: field mapping, defaults, generated values, lookups and ingest
+// style processors, driven by a mapping file.
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// ErrDropped is returned by Convert when a lookup miss policy or a script
+// removes the document from the output.
+var ErrDropped = errors.New("document dropped")
+
+// Options configures the files a Converter writes.
+type Options struct {
+	// OutputFile is the converted output path; default misses file names
+	// are derived from it.
+	OutputFile string
+	// CreateFile opens the files the converter writes, such as routed
+	// misses. Defaults to os.Create.
+	CreateFile func(path string) (io.WriteCloser, error)
+	// Reserve, when set, is asked for the estimated bytes of a CSV lookup
+	// table before it is loaded into memory. When it refuses, the table is
+	// served from a disk index in the temporary directory instead.
+	Reserve func(bytes int64) bool
+}
+
+// Converter applies a mapping, its lookups and processors to documents.
+// It is not safe for concurrent use.
+type Converter struct {
+	mapping    FieldMapping
+	program    *Program
+	lookups    []*Lookup
+	processors []Processor
+	stats      *ruleStats
+	rn         *rand.Rand
+	variants   *variants
+	beforeMap  documentScript
+	afterMap   documentScript
+
+	// Observe, when set, is called after every rule that may have written
+	// to the output source.
+	Observe func(rule string, source map[string]interface{})
+}
+
+// New opens the lookups and processors of mapping, or of each of its named
+// mappings.
+func New(mapping FieldMapping, opts Options) (*Converter, error) {
+	if opts.CreateFile == nil {
+		opts.CreateFile = func(path string) (io.WriteCloser, error) {
+			return os.Create(path)
+		}
+	}
+	if err := loadPlugins(mapping.Plugins); err != nil {
+		return nil, err
+	}
+	if len(mapping.Mappings) > 0 || len(mapping.Select) > 0 {
+		c := &Converter{mapping: mapping}
+		var err error
+		if c.variants, err = openVariants(mapping, opts, c.notify); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	program, err := Compile(mapping)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := lookupConfigs(mapping)
+	if err != nil {
+		return nil, err
+	}
+	lookups, err := openLookups(configs, opts)
+	if err != nil {
+		return nil, err
+	}
+	c := &Converter{
+		mapping: mapping,
+		program: program,
+		lookups: lookups,
+		stats:   newRuleStats(mapping),
+		rn:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if c.processors, err = openProcessors(mapping.Processors); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if c.beforeMap, c.afterMap, err = openScripts(mapping); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Prefetch resolves the lookup keys of docs ahead of converting them.
+func (c *Converter) Prefetch(docs []ESDoc) error {
+	if c.variants != nil {
+		return c.variants.prefetch(docs)
+	}
+	for _, lookup := range c.lookups {
+		if err := lookup.prefetch(docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Convert maps doc to its output form. It returns ErrDropped when a lookup
+// miss policy or a script removes the document from the output.
+func (c *Converter) Convert(doc ESDoc) (ESDoc, error) {
+	if c.variants != nil {
+		conv, err := c.variants.choose(doc)
+		if err != nil {
+			return ESDoc{}, err
+		}
+		return conv.Convert(doc)
+	}
+	if c.beforeMap != nil {
+		// Shallow copy: the hook replaces values rather than changing
+		// them, so the caller's document stays as it was.
+		doc.Source = maps.Clone(doc.Source)
+		kept, err := c.beforeMap.run(doc.Source)
+		if err != nil {
+			return ESDoc{}, err
+		}
+		if !kept {
+			return ESDoc{}, ErrDropped
+		}
+	}
+	newSource := map[string]interface{}{}
+	for i := range c.program.Rules {
+		rule := &c.program.Rules[i]
+		value, ok, err := rule.eval(doc.Source, c.rn)
+		if err != nil {
+			return ESDoc{}, err
+		}
+		if ok {
+			InsertFieldValue(newSource, rule.target, value)
+			c.stats.hit(rule.Name)
+			c.notify(rule.observed, newSource)
+		}
+	}
+
+	for _, lookup := range c.lookups {
+		kept, err := lookup.apply(doc, newSource)
+		if err != nil {
+			return ESDoc{}, err
+		}
+		if !kept {
+			return ESDoc{}, ErrDropped
+		}
+		c.notify(RuleName("lookup", lookup.Name), newSource)
+	}
+
+	for i, processor := range c.processors {
+		if err := processor.Process(newSource); err != nil {
+			return ESDoc{}, err
+		}
+		c.notify(RuleName("processor", fmt.Sprintf("%d:%s", i, c.mapping.Processors[i].Type)), newSource)
+	}
+
+	if c.afterMap != nil {
+		kept, err := c.afterMap.run(newSource)
+		if err != nil {
+			return ESDoc{}, err
+		}
+		if !kept {
+			return ESDoc{}, ErrDropped
+		}
+		c.notify(RuleName("after_map", ""), newSource)
+	}
+
+	return ESDoc{
+		ESMeta: ESMeta{
+			Index: c.mapping.Index,
+			Type:  doc.Type,
+			ID:    doc.ID,
+			Score: doc.Score,
+		},
+		Source: newSource,
+	}, nil
+}
+
+// Program returns the compiled field rules, or nil for a multi-mapping
+// file, whose mappings each have their own.
+func (c *Converter) Program() *Program {
+	return c.program
+}
+
+func (c *Converter) notify(rule string, source map[string]interface{}) {
+	if c.Observe != nil {
+		c.Observe(rule, source)
+	}
+}
+
+// Lookups returns the effective lookup configurations, with defaults such
+// as key fields and misses file paths filled in.
+func (c *Converter) Lookups() []LookupConfig {
+	configs := make([]LookupConfig, len(c.lookups))
+	for i, lookup := range c.lookups {
+		configs[i] = lookup.LookupConfig
+	}
+	if c.variants != nil {
+		c.variants.each(func(name string, conv *Converter) {
+			for _, config := range conv.Lookups() {
+				config.Name = name + "/" + config.Name
+				configs = append(configs, config)
+			}
+		})
+	}
+	return configs
+}
+
+// Flush writes out buffered misses and returns the files they went to.
+func (c *Converter) Flush() ([]string, error) {
+	var files []string
+	for _, lookup := range c.lookups {
+		if lookup.writer != nil {
+			if err := lookup.flush(); err != nil {
+				return nil, err
+			}
+			files = append(files, lookup.MissesFile)
+		}
+	}
+	if c.variants != nil {
+		var err error
+		c.variants.each(func(_ string, conv *Converter) {
+			if err == nil {
+				var sub []string
+				sub, err = conv.Flush()
+				files = append(files, sub...)
+			}
+		})
+		return files, err
+	}
+	return files, nil
+}
+
+// Close releases the lookups, processors and scripts, returning the first
+// error.
+func (c *Converter) Close() error {
+	if c.variants != nil {
+		return c.variants.Close()
+	}
+	var firstErr error
+	for _, lookup := range c.lookups {
+		if err := lookup.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, processor := range c.processors {
+		if err := processor.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for _, script := range []documentScript{c.beforeMap, c.afterMap} {
+		if script != nil {
+			script.close()
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseDoc(t *testing.T, text string) ESDoc {
+	t.Helper()
+	var doc ESDoc
+	if err := json.Unmarshal([]byte(text), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func parseMapping(t *testing.T, text string) FieldMapping {
+	t.Helper()
+	var mapping FieldMapping
+	if err := json.Unmarshal([]byte(text), &mapping); err != nil {
+		t.Fatal(err)
+	}
+	return mapping
+}
+
+func TestConvert(t *testing.T) {
+	path := writeLookupCSV(t, "id,city,zip\n1,Paris,75001\n")
+	mapping := parseMapping(t, `{
+		"index": "people-v2",
+		"passthrough": ["name"],
+		"field_mapping": {"profile.age": "age", "profile.missing": "nothing"},
+		"default_values": {"active": true},
+		"random_generate": {"height": {"type": "integer", "min": 160, "max": 160}},
+		"lookups": [{"name": "places", "path": "`+path+`", "columns": {"zip": "long"}}]
+	}`)
+	c, err := New(mapping, Options{OutputFile: filepath.Join(t.TempDir(), "out.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	got, err := c.Convert(parseDoc(t, `{"_index":"people","_type":"_doc","_id":"1","_source":{"name":"Alice","age":30,"other":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":    "Alice",
+		"profile": map[string]interface{}{"age": 30.0},
+		"active":  true,
+		"height":  160,
+		"city":    "Paris",
+		"zip":     int64(75001),
+	}
+	if !reflect.DeepEqual(got.Source, want) {
+		t.Errorf("Convert() source = %v, want %v", got.Source, want)
+	}
+	if *got.Index != "people-v2" || *got.ID != "1" || *got.Type != "_doc" {
+		t.Errorf("Convert() meta = %s %s %s", *got.Index, *got.Type, *got.ID)
+	}
+}
+
+func TestConvertMissPolicies(t *testing.T) {
+	path := writeLookupCSV(t, "id,city\n1,Paris\n")
+	misses := filepath.Join(t.TempDir(), "misses.ndjson")
+	tests := []struct {
+		lookup  string
+		want    map[string]interface{}
+		dropped bool
+	}{
+		{`{"path": "` + path + `"}`, map[string]interface{}{}, false},
+		{`{"path": "` + path + `", "on_miss": "default", "defaults": {"city": "unknown"}}`, map[string]interface{}{"city": "unknown"}, false},
+		{`{"path": "` + path + `", "on_miss": "drop"}`, nil, true},
+		{`{"path": "` + path + `", "on_miss": "route", "misses_file": "` + misses + `"}`, nil, true},
+	}
+	for _, tt := range tests {
+		c, err := New(parseMapping(t, `{"lookups": [`+tt.lookup+`]}`), Options{OutputFile: "out.json"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Convert(parseDoc(t, `{"_id":"2","_source":{}}`))
+		if tt.dropped {
+			if !errors.Is(err, ErrDropped) {
+				t.Errorf("%s: Convert() error = %v, want ErrDropped", tt.lookup, err)
+			}
+		} else if err != nil || !reflect.DeepEqual(got.Source, tt.want) {
+			t.Errorf("%s: Convert() = %v, %v; want %v", tt.lookup, got.Source, err, tt.want)
+		}
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(misses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"_id":"2"`) {
+		t.Errorf("misses file = %q, want the routed document", data)
+	}
+}
+
+func TestNewRejectsUnknownPolicy(t *testing.T) {
+	path := writeLookupCSV(t, "id,city\n1,Paris\n")
+	if _, err := New(parseMapping(t, `{"lookups": [{"path": "`+path+`", "on_miss": "explode"}]}`), Options{}); err == nil {
+		t.Error("New() accepted an unknown on_miss policy")
+	}
+}
@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"fmt"
+	"maps"
+)
+
+// ExplodeConfig splits one input document into one document per element of
+// an array before the mapping runs:
+//
+//	"explode": {"field": "items", "as": "item", "index_field": "item_index"}
+//
+// Each part is a copy of the document with the array replaced by one
+// element at as (default: field), and _id <parent _id><id_separator><index>
+// (separator default "-"). A value that is not an array is one element. A
+// document whose array is missing or empty yields no parts, unless
+// keep_empty is set, which keeps it once without the field.
+type ExplodeConfig struct {
+	Field       string `json:"field"`
+	As          string `json:"as,omitempty"`
+	IndexField  string `json:"index_field,omitempty"`
+	IDSeparator string `json:"id_separator,omitempty"`
+	KeepEmpty   bool   `json:"keep_empty,omitempty"`
+}
+
+func checkExplode(config *ExplodeConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Field == "" {
+		return fmt.Errorf("field is required")
+	}
+	return nil
+}
+
+// Explode splits doc by the mapping's explode rule, or returns it alone
+// when the mapping has none. Convert each part separately.
+func (c *Converter) Explode(doc ESDoc) ([]ESDoc, error) {
+	if c.variants != nil {
+		conv, err := c.variants.choose(doc)
+		if err != nil {
+			return nil, err
+		}
+		return conv.Explode(doc)
+	}
+	config := c.mapping.Explode
+	if config == nil {
+		return []ESDoc{doc}, nil
+	}
+	path := splitPath(config.Field)
+	value := ExtractFieldValue(doc.Source, path)
+	var elements []interface{}
+	switch typed := value.(type) {
+	case nil:
+	case []interface{}:
+		elements = typed
+	default:
+		if value != NullValue {
+			elements = []interface{}{value}
+		}
+	}
+
+	source := copyPath(doc.Source, path)
+	DeleteFieldValue(source, path)
+	if len(elements) == 0 {
+		if !config.KeepEmpty {
+			return nil, nil
+		}
+		doc.Source = source
+		return []ESDoc{doc}, nil
+	}
+
+	as := path
+	if config.As != "" {
+		as = splitPath(config.As)
+	}
+	var indexPath []string
+	if config.IndexField != "" {
+		indexPath = splitPath(config.IndexField)
+	}
+	separator := config.IDSeparator
+	if separator == "" {
+		separator = "-"
+	}
+	parts := make([]ESDoc, len(elements))
+	for i, element := range elements {
+		part := doc
+		part.Source = copyPath(source, as)
+		if element == nil {
+			element = NullValue
+		}
+		InsertFieldValue(part.Source, as, element)
+		if indexPath != nil {
+			part.Source = copyPath(part.Source, indexPath)
+			InsertFieldValue(part.Source, indexPath, i)
+		}
+		id := fmt.Sprintf("%s%s%d", DocID(doc), separator, i)
+		part.ID = &id
+		parts[i] = part
+	}
+	return parts, nil
+}
+
+// copyPath copies source and the objects along path, so values can be set
+// or removed at path without changing source.
+func copyPath(source map[string]interface{}, path []string) map[string]interface{} {
+	out := maps.Clone(source)
+	if out == nil {
+		out = map[string]interface{}{}
+	}
+	if len(path) > 1 {
+		if nested, ok := out[path[0]].(map[string]interface{}); ok {
+			out[path[0]] = copyPath(nested, path[1:])
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func explodeDoc(t *testing.T, mapping, doc string) []string {
+	t.Helper()
+	c, err := New(parseMapping(t, mapping), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	parts, err := c.Explode(parseDoc(t, doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, part := range parts {
+		data, _ := json.Marshal(struct {
+			ID     string                 `json:"_id"`
+			Source map[string]interface{} `json:"_source"`
+		}{*part.ID, part.Source})
+		out = append(out, string(data))
+	}
+	return out
+}
+
+func TestExplode(t *testing.T) {
+	tests := []struct {
+		name, explode, doc string
+		want               []string
+	}{
+		{"array", `{"field": "items"}`,
+			`{"_id":"o1","_source":{"n":1,"items":["a","b"]}}`,
+			[]string{`{"_id":"o1-0","_source":{"items":"a","n":1}}`, `{"_id":"o1-1","_source":{"items":"b","n":1}}`}},
+		{"as and index", `{"field": "order.items", "as": "item", "index_field": "item_index", "id_separator": "_"}`,
+			`{"_id":"o1","_source":{"order":{"no":7,"items":[{"sku":"x"},null]}}}`,
+			[]string{`{"_id":"o1_0","_source":{"item":{"sku":"x"},"item_index":0,"order":{"no":7}}}`, `{"_id":"o1_1","_source":{"item":null,"item_index":1,"order":{"no":7}}}`}},
+		{"nested index", `{"field": "items", "index_field": "meta.idx"}`,
+			`{"_id":"o1","_source":{"meta":{"src":"s"},"items":[1,2]}}`,
+			[]string{`{"_id":"o1-0","_source":{"items":1,"meta":{"idx":0,"src":"s"}}}`, `{"_id":"o1-1","_source":{"items":2,"meta":{"idx":1,"src":"s"}}}`}},
+		{"scalar", `{"field": "items"}`,
+			`{"_id":"o1","_source":{"items":"only"}}`,
+			[]string{`{"_id":"o1-0","_source":{"items":"only"}}`}},
+		{"empty", `{"field": "items"}`, `{"_id":"o1","_source":{"items":[]}}`, nil},
+		{"keep empty", `{"field": "items", "keep_empty": true}`,
+			`{"_id":"o1","_source":{"n":1}}`,
+			[]string{`{"_id":"o1","_source":{"n":1}}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := explodeDoc(t, `{"explode": `+tt.explode+`}`, tt.doc)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Explode() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("part %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExplodeLeavesInputUnchanged(t *testing.T) {
+	c, err := New(parseMapping(t, `{"explode": {"field": "order.items", "index_field": "order.idx"}}`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	doc := parseDoc(t, `{"_id":"o1","_source":{"order":{"items":[1,2]}}}`)
+	if _, err = c.Explode(doc); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := json.Marshal(doc.Source); string(data) != `{"order":{"items":[1,2]}}` {
+		t.Errorf("Explode() changed its input to %s", data)
+	}
+}
+
+func TestNewRejectsExplodeWithoutField(t *testing.T) {
+	if _, err := New(parseMapping(t, `{"explode": {"as": "item"}}`), Options{}); err == nil {
+		t.Error("New() accepted an explode rule without field")
+	}
+}
@@ -0,0 +1,179 @@
+package converter
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// fakerLocale holds the data faker generators draw from for one market.
+// In patterns # stands for a random digit.
+type fakerLocale struct {
+	firstNames []string
+	lastNames  []string
+	// familyFirst puts the family name before the given name.
+	familyFirst bool
+	streets     []string
+	cities      []string
+	// address is a pattern with {street}, {number}, {postcode} and {city}.
+	address  string
+	postcode string
+	phone    string
+	domains  []string
+}
+
+var fakerLocales = map[string]*fakerLocale{
+	"en": {
+		firstNames: []string{"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "David", "Emily", "Daniel", "Sarah"},
+		lastNames:  []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Miller", "Davis", "Wilson", "Taylor", "Clark", "Lewis", "Walker"},
+		streets:    []string{"Main St", "Oak Ave", "Maple Dr", "Cedar Ln", "Park Rd", "Elm St", "Washington Blvd", "Lake View Dr"},
+		cities:     []string{"Springfield", "Riverside", "Franklin", "Greenville", "Madison", "Clinton", "Salem", "Fairview"},
+		address:    "{number} {street}, {city} {postcode}",
+		postcode:   "#####",
+		phone:      "(###) ###-####",
+		domains:    []string{"example.com", "mail.example", "example.org"},
+	},
+	"de": {
+		firstNames: []string{"Lukas", "Anna", "Maximilian", "Sophie", "Leon", "Marie", "Felix", "Lena", "Jonas", "Hannah", "Paul", "Laura"},
+		lastNames:  []string{"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker", "Schulz", "Hoffmann", "Koch", "Richter"},
+		streets:    []string{"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße", "Lindenweg", "Kirchplatz"},
+		cities:     []string{"Berlin", "Hamburg", "München", "Köln", "Frankfurt am Main", "Stuttgart", "Leipzig", "Dresden"},
+		address:    "{street} {number}, {postcode} {city}",
+		postcode:   "#####",
+		phone:      "+49 ### #######",
+		domains:    []string{"beispiel.de", "mail.example", "example.de"},
+	},
+	"fr": {
+		firstNames: []string{"Gabriel", "Louise", "Léo", "Emma", "Raphaël", "Jade", "Arthur", "Alice", "Louis", "Chloé", "Jules", "Inès"},
+		lastNames:  []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand", "Leroy", "Moreau", "Simon", "Laurent"},
+		streets:    []string{"rue de la Paix", "avenue Victor Hugo", "rue du Moulin", "boulevard Saint-Michel", "rue de l'Église", "place de la Mairie"},
+		cities:     []string{"Paris", "Lyon", "Marseille", "Toulouse", "Nice", "Nantes", "Bordeaux", "Lille"},
+		address:    "{number} {street}, {postcode} {city}",
+		postcode:   "#####",
+		phone:      "+33 # ## ## ## ##",
+		domains:    []string{"exemple.fr", "mail.example", "example.fr"},
+	},
+	"es": {
+		firstNames: []string{"Hugo", "Lucía", "Martín", "Sofía", "Pablo", "María", "Daniel", "Paula", "Alejandro", "Valeria", "Diego", "Carmen"},
+		lastNames:  []string{"García", "Rodríguez", "González", "Fernández", "López", "Martínez", "Sánchez", "Pérez", "Gómez", "Martín", "Jiménez", "Ruiz"},
+		streets:    []string{"Calle Mayor", "Calle Real", "Avenida de la Constitución", "Calle del Sol", "Plaza de España", "Calle Nueva"},
+		cities:     []string{"Madrid", "Barcelona", "Valencia", "Sevilla", "Zaragoza", "Málaga", "Bilbao", "Granada"},
+		address:    "{street} {number}, {postcode} {city}",
+		postcode:   "#####",
+		phone:      "+34 ### ### ###",
+		domains:    []string{"ejemplo.es", "mail.example", "example.es"},
+	},
+	"pt-br": {
+		firstNames: []string{"Miguel", "Helena", "Arthur", "Alice", "Gael", "Laura", "Heitor", "Manuela", "Theo", "Valentina", "Davi", "Sophia"},
+		lastNames:  []string{"Silva", "Santos", "Oliveira", "Souza", "Rodrigues", "Ferreira", "Alves", "Pereira", "Lima", "Gomes", "Costa", "Ribeiro"},
+		streets:    []string{"Rua das Flores", "Avenida Paulista", "Rua São João", "Rua XV de Novembro", "Avenida Brasil", "Rua da Praia"},
+		cities:     []string{"São Paulo", "Rio de Janeiro", "Belo Horizonte", "Salvador", "Curitiba", "Recife", "Porto Alegre", "Fortaleza"},
+		address:    "{street}, {number} - {city}, {postcode}",
+		postcode:   "#####-###",
+		phone:      "+55 (##) 9####-####",
+		domains:    []string{"exemplo.com.br", "mail.example", "example.com.br"},
+	},
+	"ja": {
+		firstNames:  []string{"翔", "陽菜", "蓮", "結衣", "大翔", "さくら", "悠真", "美咲", "湊", "葵", "樹", "凛"},
+		lastNames:   []string{"佐藤", "鈴木", "高橋", "田中", "伊藤", "渡辺", "山本", "中村", "小林", "加藤", "吉田", "山田"},
+		familyFirst: true,
+		streets:     []string{"中央", "本町", "栄町", "緑町", "旭町", "新町"},
+		cities:      []string{"東京都新宿区", "大阪府大阪市", "神奈川県横浜市", "愛知県名古屋市", "北海道札幌市", "福岡県福岡市"},
+		address:     "〒{postcode} {city}{street}{number}丁目",
+		postcode:    "###-####",
+		phone:       "0#0-####-####",
+		domains:     []string{"example.jp", "mail.example", "example.co.jp"},
+	},
+}
+
+// fakerTypes are the random_generate types served by fakeValue.
+var fakerTypes = map[string]bool{
+	"name": true, "first_name": true, "last_name": true, "address": true, "street": true,
+	"city": true, "postcode": true, "phone": true, "email": true,
+}
+
+// findLocale resolves a locale such as de, pt-BR or pt_BR, falling back from
+// a region to its language. The default is en.
+func findLocale(name string) (*fakerLocale, error) {
+	if name == "" {
+		name = "en"
+	}
+	name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	if locale, ok := fakerLocales[name]; ok {
+		return locale, nil
+	}
+	language, _, _ := strings.Cut(name, "-")
+	for key, locale := range fakerLocales {
+		if key == language || strings.HasPrefix(key, language+"-") {
+			return locale, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported locale %q", name)
+}
+
+func fakeValue(rn *rand.Rand, typ string, locale *fakerLocale) string {
+	pick := func(values []string) string {
+		return values[rn.Intn(len(values))]
+	}
+	switch typ {
+	case "first_name":
+		return pick(locale.firstNames)
+	case "last_name":
+		return pick(locale.lastNames)
+	case "name":
+		if locale.familyFirst {
+			return pick(locale.lastNames) + " " + pick(locale.firstNames)
+		}
+		return pick(locale.firstNames) + " " + pick(locale.lastNames)
+	case "street":
+		return pick(locale.streets)
+	case "city":
+		return pick(locale.cities)
+	case "postcode":
+		return fillDigits(rn, locale.postcode)
+	case "phone":
+		return fillDigits(rn, locale.phone)
+	case "address":
+		return strings.NewReplacer(
+			"{street}", pick(locale.streets),
+			"{number}", fmt.Sprint(rn.Intn(199)+1),
+			"{postcode}", fillDigits(rn, locale.postcode),
+			"{city}", pick(locale.cities),
+		).Replace(locale.address)
+	case "email":
+		// Names in non-Latin scripts make poor local parts; use a
+		// generated one instead.
+		user := fmt.Sprintf("user%04d", rn.Intn(10000))
+		if !locale.familyFirst {
+			user = fmt.Sprintf("%s.%s%d", emailPart(pick(locale.firstNames)), emailPart(pick(locale.lastNames)), rn.Intn(100))
+		}
+		return user + "@" + pick(locale.domains)
+	}
+	return ""
+}
+
+// emailFold spells common Latin letters with diacritics in ASCII.
+var emailFold = strings.NewReplacer(
+	"ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss", "á", "a", "à", "a", "â", "a", "ã", "a",
+	"é", "e", "è", "e", "ê", "e", "í", "i", "î", "i", "ó", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ç", "c", "ñ", "n", "ë", "e", "ï", "i",
+)
+
+func emailPart(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, emailFold.Replace(strings.ToLower(name)))
+}
+
+func fillDigits(rn *rand.Rand, pattern string) string {
+	out := []byte(pattern)
+	for i, c := range out {
+		if c == '#' {
+			out[i] = byte('0' + rn.Intn(10))
+		}
+	}
+	return string(out)
+}
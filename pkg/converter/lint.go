@@ -0,0 +1,493 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Problem is one finding of LintMapping. Path locates it in the mapping
+// file, e.g. random_generate.height.min.
+type Problem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+func (p Problem) String() string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	if p.Path == "" {
+		return fmt.Sprintf("%s: %s", level, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", level, p.Path, p.Message)
+}
+
+// LintMapping checks a mapping file without opening its lookups or
+// processors: unknown keys, malformed field paths, targets written by more
+// than one rule and generator configs that cannot work. The error is only
+// set when the file cannot be read.
+func LintMapping(path string) ([]Problem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return LintMappingData(data)
+}
+
+// LintMappingData checks a mapping as LintMapping does, from its JSON.
+func LintMappingData(data []byte) ([]Problem, error) {
+	var err error
+	l := &linter{}
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&raw); err != nil {
+		l.errorf("", "%s", describeJSONError(data, err))
+		return l.problems, nil
+	}
+	if err = applyPresets(raw); err != nil {
+		l.errorf("preset", "%v", err)
+		return l.problems, nil
+	}
+	if err = resolveVars(raw); err != nil {
+		l.errorf("", "%v", err)
+		return l.problems, nil
+	}
+	l.checkKeys(raw, reflect.TypeOf(FieldMapping{}), "")
+
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, err
+	}
+	var mapping FieldMapping
+	if err = json.Unmarshal(data, &mapping); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			l.errorf(typeErr.Field, "%s where %s expected", typeErr.Value, typeErr.Type)
+		} else {
+			l.errorf("", "%v", err)
+		}
+		return l.sorted(), nil
+	}
+	l.checkMapping(mapping, "")
+	return l.sorted(), nil
+}
+
+// describeJSONError adds the line and column to syntax errors.
+func describeJSONError(data []byte, err error) string {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err.Error()
+	}
+	before := data[:min(int(syntaxErr.Offset), len(data))]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d, column %d: %v", line, column, err)
+}
+
+// fieldTypes are the Elasticsearch field types the types section may use.
+var fieldTypes = map[string]bool{
+	"binary": true, "boolean": true, "keyword": true, "constant_keyword": true, "wildcard": true,
+	"long": true, "integer": true, "short": true, "byte": true, "double": true, "float": true,
+	"half_float": true, "scaled_float": true, "unsigned_long": true, "date": true, "date_nanos": true,
+	"object": true, "flattened": true, "nested": true, "ip": true, "version": true, "text": true,
+	"match_only_text": true, "search_as_you_type": true, "geo_point": true, "geo_shape": true,
+}
+
+type linter struct {
+	problems []Problem
+}
+
+func (l *linter) errorf(path, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(path, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{Path: path, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+func (l *linter) sorted() []Problem {
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		return l.problems[i].Path < l.problems[j].Path
+	})
+	return l.problems
+}
+
+// checkKeys reports keys of value that the Go type it decodes into does
+// not know, which encoding/json would silently ignore.
+func (l *linter) checkKeys(value interface{}, typ reflect.Type, path string) {
+	switch typ.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(typ)
+		for key, item := range object {
+			field, ok := fields[key]
+			if !ok {
+				l.errorf(joinPath(path, key), "unknown key%s", suggestKey(key, fields))
+				continue
+			}
+			l.checkKeys(item, field, joinPath(path, key))
+		}
+	case reflect.Map:
+		if object, ok := value.(map[string]interface{}); ok {
+			for key, item := range object {
+				l.checkKeys(item, typ.Elem(), joinPath(path, key))
+			}
+		}
+	case reflect.Slice:
+		if list, ok := value.([]interface{}); ok {
+			for i, item := range list {
+				l.checkKeys(item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// suggestKey names the known key closest to a misspelled one.
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < bestDistance || d == bestDistance && name < best {
+			best, bestDistance = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (l *linter) checkMapping(m FieldMapping, path string) {
+	if len(m.Mappings) > 0 || len(m.Select) > 0 {
+		l.checkVariants(m, path)
+		return
+	}
+	if m.Index == nil {
+		l.warnf(joinPath(path, "index"), "not set, converted documents get a null _index")
+	}
+
+	// writers lists the rules writing each target, in the order they run,
+	// so the last one is the value that ends up in the output.
+	writers := map[string][]string{}
+	for i, field := range m.Passthrough {
+		at := fmt.Sprintf("%s[%d]", joinPath(path, "passthrough"), i)
+		if l.checkFieldPath(at, field) {
+			writers[field] = append(writers[field], "passthrough")
+		}
+	}
+	for i, file := range m.Plugins {
+		if err := loadPlugins([]string{file}); err != nil {
+			l.errorf(fmt.Sprintf("%s[%d]", joinPath(path, "plugins"), i), "%v", err)
+		}
+	}
+	for target, source := range m.FieldMapping {
+		at := joinPath(joinPath(path, "field_mapping"), target)
+		if _, err := parseFieldSource(source); err != nil {
+			l.errorf(at, "%v", err)
+		}
+		for _, field := range SourcePaths(source) {
+			l.checkFieldPath(at, field)
+		}
+		if l.checkFieldPath(at, target) {
+			writers[target] = append(writers[target], "field_mapping")
+		}
+	}
+	for target, text := range m.Templates {
+		at := joinPath(joinPath(path, "templates"), target)
+		if _, err := parseComputed(target, text); err != nil {
+			l.errorf(at, "%v", err)
+		}
+		if l.checkFieldPath(at, target) {
+			writers[target] = append(writers[target], "templates")
+		}
+	}
+	for target := range m.DefaultValues {
+		if l.checkFieldPath(joinPath(joinPath(path, "default_values"), target), target) {
+			writers[target] = append(writers[target], "default_values")
+		}
+	}
+	for target, config := range m.RandomGenerate {
+		at := joinPath(joinPath(path, "random_generate"), target)
+		if l.checkFieldPath(at, target) {
+			writers[target] = append(writers[target], "random_generate")
+		}
+		l.checkGenerator(at, config)
+	}
+	if m.Explode != nil {
+		if err := checkExplode(m.Explode); err != nil {
+			l.errorf(joinPath(path, "explode"), "%v", err)
+		} else {
+			l.checkFieldPath(joinPath(path, "explode.field"), m.Explode.Field)
+		}
+	}
+	for i, field := range m.Exclude {
+		l.checkFieldPath(fmt.Sprintf("%s[%d]", joinPath(path, "exclude"), i), field)
+	}
+	for field, typ := range m.Types {
+		at := joinPath(joinPath(path, "types"), field)
+		if l.checkFieldPath(at, field) && !fieldTypes[typ] {
+			l.warnf(at, "unknown Elasticsearch field type %q", typ)
+		}
+	}
+
+	targets := make([]string, 0, len(writers))
+	for target, rules := range writers {
+		targets = append(targets, target)
+		if len(rules) > 1 {
+			l.errorf(path, "%s is written by %s; %s wins", target, strings.Join(rules, " and "), rules[len(rules)-1])
+		}
+	}
+	sort.Strings(targets)
+	for i, target := range targets {
+		for _, other := range targets[i+1:] {
+			if strings.HasPrefix(other, target+".") {
+				l.warnf(path, "%s is written both as a value and as the parent of %s", target, other)
+			}
+		}
+	}
+
+	if m.Script != "" && m.AfterMap != "" {
+		l.errorf(joinPath(path, "script"), "script is the older name of after_map; set only one")
+	}
+	if m.BeforeMap == "" && m.AfterMapScript() == "" {
+		if m.ScriptLanguage != "" || m.ScriptTimeout != "" || m.ScriptMemoryMB != 0 {
+			l.warnf(path, "script_language, script_timeout and script_memory_mb have no effect without before_map or after_map")
+		}
+	} else if before, after, err := openScripts(m); err != nil {
+		l.errorf(path, "%v", err)
+	} else {
+		for _, script := range []documentScript{before, after} {
+			if script != nil {
+				script.close()
+			}
+		}
+	}
+	l.checkLookups(m, path)
+	for i, config := range m.Processors {
+		at := fmt.Sprintf("%s[%d]", joinPath(path, "processors"), i)
+		switch config.Type {
+		case "geoip", "user_agent":
+		case "content_hash":
+			if _, err := newContentHashProcessor(config); err != nil {
+				l.errorf(at, "%v", err)
+			}
+		case "currency":
+			if _, err := newCurrencyProcessor(config); err != nil {
+				l.errorf(at, "%v", err)
+			}
+		case "wasm":
+			if config.Module == "" {
+				l.errorf(at, "module is required")
+			}
+		case "":
+			l.errorf(at, "type is required")
+		default:
+			l.errorf(at, "unknown type %q", config.Type)
+		}
+		if config.Field == "" && processorNeedsField(config.Type) {
+			l.errorf(at, "field is required")
+		} else if config.Field != "" {
+			l.checkFieldPath(at+".field", config.Field)
+		}
+	}
+}
+
+func (l *linter) checkVariants(m FieldMapping, path string) {
+	rules := m
+	rules.Mappings, rules.Select = nil, nil
+	if !reflect.ValueOf(rules).IsZero() {
+		l.errorf(path, "a mapping with named mappings cannot define rules of its own")
+	}
+	if len(m.Mappings) == 0 {
+		l.errorf(joinPath(path, "mappings"), "select needs named mappings")
+	}
+	for name, sub := range m.Mappings {
+		at := joinPath(joinPath(path, "mappings"), name)
+		if len(sub.Mappings) > 0 || len(sub.Select) > 0 {
+			l.errorf(at, "named mappings cannot be nested")
+			continue
+		}
+		l.checkMapping(sub, at)
+	}
+	for i, s := range m.Select {
+		at := fmt.Sprintf("%s[%d]", joinPath(path, "select"), i)
+		if _, ok := m.Mappings[s.Mapping]; !ok {
+			l.errorf(at, "unknown mapping %q", s.Mapping)
+		}
+		if s.Matches != "" {
+			if _, err := regexp.Compile(s.Matches); err != nil {
+				l.errorf(at+".matches", "%v", err)
+			}
+		}
+		if s.Field == "" && i < len(m.Select)-1 {
+			l.warnf(at, "matches every document, later selectors are never used")
+		}
+	}
+}
+
+// checkFieldPath reports dotted paths with empty segments.
+func (l *linter) checkFieldPath(at, field string) bool {
+	if field == "" {
+		l.errorf(at, "empty field path")
+		return false
+	}
+	for _, segment := range strings.Split(field, ".") {
+		if segment == "" || strings.TrimSpace(segment) != segment {
+			l.errorf(at, "invalid field path %q", field)
+			return false
+		}
+	}
+	return true
+}
+
+func (l *linter) checkGenerator(at string, config map[string]interface{}) {
+	switch config["type"] {
+	case "binary", "boolean", "long", "integer", "short", "byte", "double", "float", "half_float",
+		"keyword", "wildcard", "constant_keyword",
+		"name", "first_name", "last_name", "address", "street", "city", "postcode", "phone", "email", "text":
+		if err := checkGenerator(config); err != nil {
+			l.errorf(at, "%v", err)
+		}
+	case "date":
+		l.warnf(at, "date generation is not implemented, the field is written as {}")
+	case nil:
+		l.errorf(at, "type is required")
+	default:
+		l.errorf(at, "unknown type %v", config["type"])
+	}
+}
+
+func (l *linter) checkLookups(m FieldMapping, path string) {
+	for key := range m.File {
+		if key != "path" {
+			l.errorf(joinPath(joinPath(path, "file"), key), "unknown key (only path is supported)")
+		}
+	}
+	configs, err := lookupConfigs(m)
+	if err != nil {
+		l.errorf(joinPath(path, "file"), "%v", err)
+		return
+	}
+	// The file section, when set, comes first in configs.
+	fromFile := len(configs) - len(m.Lookups)
+	names := map[string]bool{}
+	for i, config := range configs {
+		at := joinPath(path, "file")
+		if i >= fromFile {
+			at = fmt.Sprintf("%s[%d]", joinPath(path, "lookups"), i-fromFile)
+		}
+		if names[config.Name] {
+			l.errorf(at, "duplicate lookup name %q", config.Name)
+		}
+		names[config.Name] = true
+		switch config.OnMiss {
+		case MissIgnore, MissDefault, MissDrop, MissRoute:
+		default:
+			l.errorf(joinPath(at, "on_miss"), "unknown policy %q", config.OnMiss)
+		}
+		switch config.Type {
+		case "", "csv", "xlsx":
+			if config.Path == "" {
+				l.errorf(at, "path is required")
+			} else if _, err := os.Stat(config.Path); err != nil {
+				l.warnf(joinPath(at, "path"), "%v", err)
+			}
+		case "elasticsearch", "sql", "http", "redis":
+		default:
+			l.errorf(joinPath(at, "type"), "unknown type %q", config.Type)
+		}
+		if config.KeyField != "_id" {
+			l.checkFieldPath(joinPath(at, "key_field"), config.KeyField)
+		}
+	}
+}
+
+// checkGenerator reports random_generate configs that would fail while
+// converting, such as a missing range or an empty list of values.
+func checkGenerator(config map[string]interface{}) error {
+	switch config["type"] {
+	case "long", "integer", "short", "byte", "double", "float", "half_float":
+		mn, minOK := config["min"].(float64)
+		mx, maxOK := config["max"].(float64)
+		switch {
+		case !minOK:
+			return fmt.Errorf("min must be a number")
+		case !maxOK:
+			return fmt.Errorf("max must be a number")
+		case mn > mx:
+			return fmt.Errorf("min %v is greater than max %v", mn, mx)
+		}
+	case "keyword", "wildcard", "constant_keyword":
+		values, ok := config["values"].([]interface{})
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("values must be a non-empty list")
+		}
+	}
+	if config["type"] == "text" {
+		_, _, _, _, err := textConfig(config)
+		return err
+	}
+	if typ, _ := config["type"].(string); fakerTypes[typ] {
+		if locale, ok := config["locale"]; ok {
+			name, isString := locale.(string)
+			if !isString {
+				return fmt.Errorf("locale must be a string")
+			}
+			if _, err := findLocale(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,458 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MissIgnore  = "ignore"
+	MissDefault = "default"
+	MissDrop    = "drop"
+	MissRoute   = "route"
+)
+
+type LookupConfig struct {
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Path        string                 `json:"path"`
+	KeyColumn   string                 `json:"key_column"`
+	KeyField    string                 `json:"key_field"`
+	OnMiss      string                 `json:"on_miss"`
+	Defaults    map[string]interface{} `json:"defaults"`
+	MissesFile  string                 `json:"misses_file"`
+	Columns     map[string]string      `json:"columns"`
+	InferTypes  bool                   `json:"infer_types"`
+	DateFormat  string                 `json:"date_format"`
+	Storage     string                 `json:"storage"`
+	IndexPath   string                 `json:"index_path"`
+	CacheSize   int                    `json:"cache_size"`
+	BatchSize   int                    `json:"batch_size"`
+	URL         string                 `json:"url"`
+	Index       string                 `json:"index"`
+	Headers     map[string]string      `json:"headers"`
+	Fields      map[string]string      `json:"fields"`
+	Driver      string                 `json:"driver"`
+	DSN         string                 `json:"dsn"`
+	Query       string                 `json:"query"`
+	BatchQuery  string                 `json:"batch_query"`
+	CacheDir    string                 `json:"cache_dir"`
+	Retries     int                    `json:"retries"`
+	Concurrency int                    `json:"concurrency"`
+	RateLimit   float64                `json:"rate_limit"`
+	Command     string                 `json:"command"`
+	KeyTemplate string                 `json:"key_template"`
+	Sheet       string                 `json:"sheet"`
+	HeaderRow   int                    `json:"header_row"`
+	Delimiter   string                 `json:"delimiter"`
+	Comment     string                 `json:"comment"`
+	LazyQuotes  bool                   `json:"lazy_quotes"`
+}
+
+// LookupSource resolves a lookup key to the fields to merge into the
+// document.
+type LookupSource interface {
+	Get(key string) (map[string]interface{}, bool, error)
+	Close() error
+}
+
+// BatchLookupSource is implemented by sources that can resolve many keys
+// in a single round trip. Keys that are not found are absent from the
+// result.
+type BatchLookupSource interface {
+	LookupSource
+	GetMany(keys []string) (map[string]map[string]interface{}, error)
+}
+
+type memorySource map[string]map[string]interface{}
+
+func (s memorySource) Get(key string) (map[string]interface{}, bool, error) {
+	fields, ok := s[key]
+	return fields, ok, nil
+}
+
+func (s memorySource) Close() error {
+	return nil
+}
+
+type Lookup struct {
+	LookupConfig
+	source LookupSource
+	hits   int
+	misses int
+	file   io.WriteCloser
+	writer *bufio.Writer
+}
+
+// lookupConfigs returns the configured lookups, including the legacy
+// single-file "file" section, with defaults filled in.
+func lookupConfigs(mapping FieldMapping) ([]LookupConfig, error) {
+	var configs []LookupConfig
+	for key, val := range mapping.File {
+		if key == "path" {
+			if val == "" {
+				return nil, fmt.Errorf("file path is empty")
+			}
+			configs = append(configs, LookupConfig{Name: "file", Path: val})
+		}
+	}
+	configs = append(configs, mapping.Lookups...)
+
+	for i := range configs {
+		if configs[i].Name == "" {
+			configs[i].Name = fmt.Sprintf("lookup%d", i)
+		}
+		remote := configs[i].Type != "" && configs[i].Type != "csv" && configs[i].Type != "xlsx"
+		if configs[i].KeyColumn == "" {
+			if configs[i].Type == "elasticsearch" {
+				configs[i].KeyColumn = "_id"
+			} else {
+				configs[i].KeyColumn = "id"
+			}
+		}
+		if (remote || configs[i].Storage == "disk") && configs[i].CacheSize == 0 {
+			configs[i].CacheSize = defaultCacheSize
+		}
+		if configs[i].BatchSize <= 0 {
+			configs[i].BatchSize = 500
+		}
+		if configs[i].KeyField == "" {
+			configs[i].KeyField = "_id"
+		}
+		if configs[i].OnMiss == "" {
+			configs[i].OnMiss = MissIgnore
+		}
+	}
+	return configs, nil
+}
+
+func openLookups(configs []LookupConfig, opts Options) ([]*Lookup, error) {
+	var lookups []*Lookup
+	closeAll := func() {
+		for _, lookup := range lookups {
+			lookup.Close()
+		}
+	}
+	for _, config := range configs {
+		switch config.OnMiss {
+		case MissIgnore, MissDefault, MissDrop, MissRoute:
+		default:
+			closeAll()
+			return nil, fmt.Errorf("unknown on_miss policy %q for lookup %s", config.OnMiss, config.Name)
+		}
+		if opts.Reserve != nil {
+			config = spillLookup(config, opts.Reserve)
+		}
+		source, err := openLookupSource(config)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to open lookup %s: %w", config.Name, err)
+		}
+		lookup := &Lookup{LookupConfig: config, source: source}
+		lookups = append(lookups, lookup)
+
+		if config.OnMiss == MissRoute {
+			if lookup.MissesFile == "" {
+				lookup.MissesFile = fmt.Sprintf("%s.%s.misses.ndjson", opts.OutputFile, config.Name)
+			}
+			file, err := opts.CreateFile(lookup.MissesFile)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to create misses file: %w", err)
+			}
+			lookup.file = file
+			lookup.writer = bufio.NewWriter(file)
+		}
+	}
+	return lookups, nil
+}
+
+func openLookupSource(config LookupConfig) (LookupSource, error) {
+	var (
+		source LookupSource
+		err    error
+	)
+	switch config.Type {
+	case "", "csv":
+		source, err = openFileSource(config)
+	case "xlsx":
+		var data map[string]map[string]interface{}
+		data, err = xlsxLookupData(config)
+		source = memorySource(data)
+	case "elasticsearch":
+		source, err = newESSource(config)
+	case "sql":
+		source, err = openSQLSource(config)
+	case "http":
+		source, err = newHTTPSource(config)
+	case "redis":
+		source, err = openRedisSource(config)
+	default:
+		err = fmt.Errorf("unknown type %q", config.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config.CacheSize > 0 {
+		source = newCachedSource(source, config.CacheSize, config.BatchSize)
+	}
+	return source, nil
+}
+
+func openFileSource(config LookupConfig) (LookupSource, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("lookup path is empty")
+	}
+	if utf8.RuneCountInString(config.Delimiter) > 1 || utf8.RuneCountInString(config.Comment) > 1 {
+		return nil, fmt.Errorf("delimiter and comment must be a single character")
+	}
+	switch config.Storage {
+	case "", "memory":
+		data, err := extractFileData(config)
+		if err != nil {
+			return nil, err
+		}
+		return memorySource(data), nil
+	case "disk":
+		disk, err := openDiskSource(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open disk index: %w", err)
+		}
+		return disk, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", config.Storage)
+	}
+}
+
+// prefetch resolves the keys of docs ahead of time when the source supports
+// batching, so that apply is served from the cache.
+func (l *Lookup) prefetch(docs []ESDoc) error {
+	cache, ok := l.source.(*cachedSource)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if key := l.key(doc); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if err := cache.Prefetch(keys); err != nil {
+		return fmt.Errorf("lookup %s failed: %w", l.Name, err)
+	}
+	return nil
+}
+
+func (l *Lookup) key(doc ESDoc) string {
+	if l.KeyField == "_id" {
+		if doc.ID == nil {
+			return ""
+		}
+		return *doc.ID
+	}
+	value := ExtractFieldValue(doc.Source, splitPath(l.KeyField))
+	if value == nil || value == NullValue {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// apply enriches newSource with the fields found for doc. It reports false
+// when the miss policy removes the document from the output.
+func (l *Lookup) apply(doc ESDoc, newSource map[string]interface{}) (bool, error) {
+	var fields map[string]interface{}
+	ok := false
+	if key := l.key(doc); key != "" {
+		var err error
+		if fields, ok, err = l.source.Get(key); err != nil {
+			return false, fmt.Errorf("lookup %s failed: %w", l.Name, err)
+		}
+	}
+	if ok {
+		l.hits++
+		for field, value := range fields {
+			InsertFieldValue(newSource, strings.Split(field, "."), value)
+		}
+		return true, nil
+	}
+
+	l.misses++
+	switch l.OnMiss {
+	case MissDefault:
+		for field, value := range l.Defaults {
+			InsertFieldValue(newSource, splitPath(field), value)
+		}
+	case MissDrop:
+		return false, nil
+	case MissRoute:
+		docJson, err := json.Marshal(doc)
+		if err != nil {
+			return false, err
+		}
+		l.writer.Write(docJson)
+		return false, l.writer.WriteByte('\n')
+	}
+	return true, nil
+}
+
+func (l *Lookup) flush() error {
+	if l.writer == nil {
+		return nil
+	}
+	if err := l.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write misses file: %w", err)
+	}
+	return nil
+}
+
+func (l *Lookup) Close() error {
+	if err := l.source.Close(); err != nil {
+		return fmt.Errorf("failed to close lookup %s: %w", l.Name, err)
+	}
+	if l.file == nil {
+		return nil
+	}
+	if err := l.flush(); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+// project converts a nested lookup record into the fields to insert,
+// either through the configured fields (target -> record path) or by
+// flattening every leaf into a dotted path.
+func (c LookupConfig) project(record map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if len(c.Fields) > 0 {
+		for target, path := range c.Fields {
+			if value := ExtractFieldValue(record, splitPath(path)); value != nil {
+				fields[target] = value
+			}
+		}
+		return fields
+	}
+	flattenFields(record, "", fields)
+	return fields
+}
+
+func flattenFields(record map[string]interface{}, prefix string, fields map[string]interface{}) {
+	for key, value := range record {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenFields(nested, prefix+key+".", fields)
+			continue
+		}
+		if value == nil {
+			value = NullValue
+		}
+		fields[prefix+key] = value
+	}
+}
+
+// csvReader returns a reader configured with the lookup's delimiter (tab
+// by default for .tsv files), comment character, and quote handling.
+func (c LookupConfig) csvReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	if c.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(c.Delimiter)
+	} else if strings.HasSuffix(c.Path, ".tsv") {
+		reader.Comma = '\t'
+	}
+	if c.Comment != "" {
+		reader.Comment, _ = utf8.DecodeRuneInString(c.Comment)
+	}
+	reader.LazyQuotes = c.LazyQuotes
+	return reader
+}
+
+func (c LookupConfig) recordFields(headers []string, keyIndex int, row []string) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for i, header := range headers {
+		if i != keyIndex {
+			value, err := c.columnValue(header, row[i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for column %s of id %s: %w", header, row[keyIndex], err)
+			}
+			fields[header] = value
+		}
+	}
+	return fields, nil
+}
+
+// columnValue converts a raw CSV cell to the JSON type declared for its
+// column, or the inferred one when infer_types is set. Empty typed cells
+// become null.
+func (c LookupConfig) columnValue(column, raw string) (interface{}, error) {
+	typ, ok := c.Columns[column]
+	if !ok {
+		if !c.InferTypes {
+			return raw, nil
+		}
+		return inferValue(raw), nil
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	switch typ {
+	case "long", "integer", "short", "byte":
+		return strconv.ParseInt(raw, 10, 64)
+	case "double", "float", "half_float":
+		return parseFinite(raw)
+	case "boolean":
+		return strconv.ParseBool(raw)
+	case "date":
+		layout := c.DateFormat
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, raw)
+		if err != nil {
+			return nil, err
+		}
+		return t.Format(time.RFC3339), nil
+	case "keyword", "text", "string":
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("unknown column type %q", typ)
+	}
+}
+
+func inferValue(raw string) interface{} {
+	if raw == "" {
+		return nil
+	}
+	if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return i
+	}
+	if f, err := parseFinite(raw); err == nil {
+		return f
+	}
+	switch strings.ToLower(raw) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return raw
+}
+
+// parseFinite parses a float, refusing NaN and infinities, which JSON
+// cannot hold: "nan" and "inf" are names as often as numbers.
+func parseFinite(raw string) (float64, error) {
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a finite number", raw)
+	}
+	return f, nil
+}
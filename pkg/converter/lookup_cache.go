@@ -0,0 +1,95 @@
+package converter
+
+import "container/list"
+
+// defaultCacheSize is the cache_size of remote lookups and of lookups kept
+// in disk storage.
+const defaultCacheSize = 10000
+
+type cacheEntry struct {
+	key    string
+	fields map[string]interface{}
+	found  bool
+}
+
+// cachedSource keeps the most recently used keys of a slower source in
+// memory, including misses.
+type cachedSource struct {
+	source    LookupSource
+	size      int
+	batchSize int
+	order     *list.List
+	entries   map[string]*list.Element
+}
+
+func newCachedSource(source LookupSource, size, batchSize int) *cachedSource {
+	return &cachedSource{
+		source:    source,
+		size:      size,
+		batchSize: batchSize,
+		order:     list.New(),
+		entries:   make(map[string]*list.Element, size),
+	}
+}
+
+func (c *cachedSource) Get(key string) (map[string]interface{}, bool, error) {
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		entry := elem.Value.(*cacheEntry)
+		return entry.fields, entry.found, nil
+	}
+
+	fields, found, err := c.source.Get(key)
+	if err != nil {
+		return nil, false, err
+	}
+	c.put(key, fields, found)
+	return fields, found, nil
+}
+
+// Prefetch loads the uncached keys in batches when the underlying source
+// implements BatchLookupSource. It is a no-op otherwise.
+func (c *cachedSource) Prefetch(keys []string) error {
+	batch, ok := c.source.(BatchLookupSource)
+	if !ok {
+		return nil
+	}
+	var missing []string
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if _, cached := c.entries[key]; !cached && !seen[key] {
+			seen[key] = true
+			missing = append(missing, key)
+		}
+	}
+	for start := 0; start < len(missing); start += c.batchSize {
+		end := min(start+c.batchSize, len(missing))
+		found, err := batch.GetMany(missing[start:end])
+		if err != nil {
+			return err
+		}
+		for _, key := range missing[start:end] {
+			fields, ok := found[key]
+			c.put(key, fields, ok)
+		}
+	}
+	return nil
+}
+
+func (c *cachedSource) put(key string, fields map[string]interface{}, found bool) {
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		elem.Value = &cacheEntry{key: key, fields: fields, found: found}
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, fields: fields, found: found})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *cachedSource) Close() error {
+	return c.source.Close()
+}
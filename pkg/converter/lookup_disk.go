@@ -0,0 +1,267 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var indexMagic = []byte("CVIDX002")
+
+// indexHeaderSize is the size of the index header: the magic, the size and
+// modification time of the data file, the slot count and a hash of the
+// settings the index was built with.
+const indexHeaderSize = 40
+
+// memoryPerByte estimates the heap an in-memory lookup table takes per byte
+// of its CSV file: every cell becomes a string in a map of its row.
+const memoryPerByte = 12
+
+// spillLookup moves a CSV lookup that would be loaded into memory to disk
+// storage, with a cache of its hot keys, when reserve refuses its estimated
+// size. The index is kept in the temporary directory, where later runs find
+// it again.
+func spillLookup(config LookupConfig, reserve func(bytes int64) bool) LookupConfig {
+	if (config.Type != "" && config.Type != "csv") || config.Storage != "" {
+		return config
+	}
+	info, err := os.Stat(config.Path)
+	if err != nil || reserve(info.Size()*memoryPerByte) {
+		// A missing file is reported when the lookup is opened.
+		return config
+	}
+	config.Storage = "disk"
+	if config.CacheSize == 0 {
+		config.CacheSize = defaultCacheSize
+	}
+	if config.IndexPath == "" {
+		path, _ := filepath.Abs(config.Path)
+		hash := fnv.New64a()
+		hash.Write([]byte(path))
+		config.IndexPath = filepath.Join(os.TempDir(), fmt.Sprintf("converter-%x.idx", hash.Sum64()))
+	}
+	slog.Info("lookup exceeds the memory budget, serving it from disk", "lookup", config.Name, "index", config.IndexPath)
+	return config
+}
+
+// diskSource serves lookups from the CSV file itself through an on-disk
+// open-addressing hash index of row offsets, so memory use does not grow
+// with the number of rows. For duplicate keys the last row wins, as it does
+// in memory storage.
+type diskSource struct {
+	config   LookupConfig
+	data     *os.File
+	index    *os.File
+	headers  []string
+	keyIndex int
+	slots    uint64
+}
+
+func openDiskSource(config LookupConfig) (*diskSource, error) {
+	data, err := os.Open(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newDiskSource(config, data)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newDiskSource(config LookupConfig, data *os.File) (*diskSource, error) {
+	info, err := data.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := config.csvReader(data).Read()
+	if err != nil {
+		return nil, err
+	}
+
+	indexPath := config.IndexPath
+	if indexPath == "" {
+		indexPath = config.Path + ".idx"
+	}
+	keyIndex, err := keyColumnIndex(headers, config.KeyColumn)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	s := &diskSource{
+		config:   config,
+		data:     data,
+		headers:  headers,
+		keyIndex: keyIndex,
+	}
+
+	if s.index, err = os.OpenFile(indexPath, os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return nil, err
+	}
+	if s.slots, err = s.readHeader(info); err != nil {
+		s.index.Close()
+		return nil, err
+	}
+	if s.slots == 0 {
+		slog.Info("building lookup index", "lookup", config.Name, "index", indexPath)
+		if err = s.build(info); err != nil {
+			s.index.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// readHeader returns the slot count of an existing index that matches the
+// data file and the settings, or 0 when the index has to be (re)built.
+func (s *diskSource) readHeader(info os.FileInfo) (uint64, error) {
+	header := make([]byte, indexHeaderSize)
+	if _, err := s.index.ReadAt(header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if !bytes.Equal(header[:8], indexMagic) ||
+		binary.LittleEndian.Uint64(header[8:]) != uint64(info.Size()) ||
+		binary.LittleEndian.Uint64(header[16:]) != uint64(info.ModTime().UnixNano()) ||
+		binary.LittleEndian.Uint64(header[32:]) != s.settingsHash() {
+		return 0, nil
+	}
+	return binary.LittleEndian.Uint64(header[24:]), nil
+}
+
+func (s *diskSource) build(info os.FileInfo) error {
+	rows := uint64(0)
+	reader := s.readerAt(0)
+	for {
+		if _, err := reader.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		rows++
+	}
+
+	s.slots = 1
+	for s.slots < rows*2 {
+		s.slots <<= 1
+	}
+	if err := s.index.Truncate(0); err != nil {
+		return err
+	}
+	if err := s.index.Truncate(int64(indexHeaderSize + s.slots*8)); err != nil {
+		return err
+	}
+
+	reader = s.readerAt(0)
+	if _, err := reader.Read(); err != nil {
+		return err
+	}
+	for {
+		offset := reader.InputOffset()
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		slot, _, err := s.findSlot(row[s.keyIndex])
+		if err != nil {
+			return err
+		}
+		if err = s.writeSlot(slot, uint64(offset)+1); err != nil {
+			return err
+		}
+	}
+
+	header := make([]byte, indexHeaderSize)
+	copy(header, indexMagic)
+	binary.LittleEndian.PutUint64(header[8:], uint64(info.Size()))
+	binary.LittleEndian.PutUint64(header[16:], uint64(info.ModTime().UnixNano()))
+	binary.LittleEndian.PutUint64(header[24:], s.slots)
+	binary.LittleEndian.PutUint64(header[32:], s.settingsHash())
+	_, err := s.index.WriteAt(header, 0)
+	return err
+}
+
+// settingsHash hashes the settings that decide which rows the index points
+// at and under which keys, so that changing them rebuilds the index.
+func (s *diskSource) settingsHash() uint64 {
+	reader := s.config.csvReader(nil)
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%q\x00%q\x00%t", s.config.KeyColumn, reader.Comma, reader.Comment, reader.LazyQuotes)
+	return h.Sum64()
+}
+
+// findSlot probes for key and returns the slot holding its row together
+// with the row, or the empty slot where it belongs and a nil row.
+func (s *diskSource) findSlot(key string) (uint64, []string, error) {
+	slot := s.slotFor(key)
+	for {
+		stored, err := s.readSlot(slot)
+		if err != nil || stored == 0 {
+			return slot, nil, err
+		}
+		row, err := s.readerAt(int64(stored - 1)).Read()
+		if err != nil {
+			return 0, nil, err
+		}
+		if row[s.keyIndex] == key {
+			return slot, row, nil
+		}
+		slot = (slot + 1) & (s.slots - 1)
+	}
+}
+
+func (s *diskSource) Get(key string) (map[string]interface{}, bool, error) {
+	_, row, err := s.findSlot(key)
+	if err != nil || row == nil {
+		return nil, false, err
+	}
+	fields, err := s.config.recordFields(s.headers, s.keyIndex, row)
+	return fields, err == nil, err
+}
+
+func (s *diskSource) Close() error {
+	if err := s.index.Close(); err != nil {
+		return err
+	}
+	return s.data.Close()
+}
+
+func (s *diskSource) slotFor(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64() & (s.slots - 1)
+}
+
+func (s *diskSource) readSlot(slot uint64) (uint64, error) {
+	buf := make([]byte, 8)
+	if _, err := s.index.ReadAt(buf, int64(indexHeaderSize+slot*8)); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+func (s *diskSource) writeSlot(slot, value uint64) error {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, value)
+	_, err := s.index.WriteAt(buf, int64(indexHeaderSize+slot*8))
+	return err
+}
+
+// readerAt returns a CSV reader positioned at offset of the data file.
+func (s *diskSource) readerAt(offset int64) *csv.Reader {
+	return s.config.csvReader(bufio.NewReader(io.NewSectionReader(s.data, offset, 1<<62)))
+}
@@ -0,0 +1,194 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const lookupCSV = `id,name,city
+1,Alice,Paris
+2,Bob,Berlin
+1,Alicia,Lyon
+3,Carol,"Rome, Italy"
+`
+
+func writeLookupCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "lookup.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiskSourceMatchesMemory(t *testing.T) {
+	config := LookupConfig{Name: "people", Path: writeLookupCSV(t, lookupCSV), KeyColumn: "id"}
+	data, err := extractFileData(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := memorySource(data)
+	disk, err := openDiskSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+
+	for _, key := range []string{"1", "2", "3", "4"} {
+		want, wantFound, _ := memory.Get(key)
+		got, found, err := disk.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%s): %v", key, err)
+		}
+		if found != wantFound || !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(%s) = %v, %v; memory storage has %v, %v", key, got, found, want, wantFound)
+		}
+	}
+	if got, _, _ := disk.Get("1"); got["name"] != "Alicia" {
+		t.Errorf("duplicate key 1 resolved to %v, want the last row", got)
+	}
+}
+
+func TestDiskSourceRebuildsIndex(t *testing.T) {
+	path := writeLookupCSV(t, lookupCSV)
+	config := LookupConfig{Name: "people", Path: path, KeyColumn: "id"}
+	disk, err := openDiskSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	disk.Close()
+
+	// The index is reused as long as the file and the key column stay.
+	disk, err = openDiskSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, found, _ := disk.Get("2"); !found || got["name"] != "Bob" {
+		t.Errorf("reused index: Get(2) = %v, %v", got, found)
+	}
+	disk.Close()
+
+	config.KeyColumn = "name"
+	disk, err = openDiskSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, found, _ := disk.Get("Carol"); !found || got["city"] != "Rome, Italy" {
+		t.Errorf("after changing key_column: Get(Carol) = %v, %v", got, found)
+	}
+	disk.Close()
+
+	if err := os.WriteFile(path, []byte("id,name,city\n9,Dan,Oslo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config.KeyColumn = "id"
+	disk, err = openDiskSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+	if _, found, _ := disk.Get("1"); found {
+		t.Error("index was not rebuilt after the data file changed")
+	}
+	if got, found, _ := disk.Get("9"); !found || got["name"] != "Dan" {
+		t.Errorf("after changing the data file: Get(9) = %v, %v", got, found)
+	}
+}
+
+type countingSource struct {
+	memorySource
+	calls int
+}
+
+func (s *countingSource) Get(key string) (map[string]interface{}, bool, error) {
+	s.calls++
+	return s.memorySource.Get(key)
+}
+
+func TestCachedSource(t *testing.T) {
+	source := &countingSource{memorySource: memorySource{
+		"a": {"v": "1"},
+		"b": {"v": "2"},
+	}}
+	cache := newCachedSource(source, 2, 500)
+
+	for _, key := range []string{"a", "a", "missing", "missing"} {
+		cache.Get(key)
+	}
+	if source.calls != 2 {
+		t.Errorf("source was asked %d times, want 2 for one hit and one miss", source.calls)
+	}
+
+	// "b" evicts the least recently used key, "a".
+	cache.Get("b")
+	cache.Get("a")
+	if source.calls != 4 {
+		t.Errorf("source was asked %d times, want 4 after eviction", source.calls)
+	}
+	if fields, found, _ := cache.Get("a"); !found || fields["v"] != "1" {
+		t.Errorf("cached Get(a) = %v, %v", fields, found)
+	}
+}
+
+func TestLookupConfigsCacheDiskStorage(t *testing.T) {
+	configs, err := lookupConfigs(FieldMapping{Lookups: []LookupConfig{
+		{Path: "a.csv", Storage: "disk"},
+		{Path: "b.csv", Storage: "disk", CacheSize: 5},
+		{Path: "c.csv"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := configs[0].CacheSize; got != defaultCacheSize {
+		t.Errorf("disk lookup cache_size = %d, want %d", got, defaultCacheSize)
+	}
+	if got := configs[1].CacheSize; got != 5 {
+		t.Errorf("explicit cache_size = %d, want 5", got)
+	}
+	if got := configs[2].CacheSize; got != 0 {
+		t.Errorf("memory lookup cache_size = %d, want 0", got)
+	}
+}
+
+func TestDiskSourceRebuildsOnCSVSettingsChange(t *testing.T) {
+	config := LookupConfig{Name: "people", Path: writeLookupCSV(t, "id,name\n#1,Hidden\n1,Alice\n"), KeyColumn: "id"}
+	disk, err := openDiskSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := disk.Get("#1"); !found {
+		t.Error("Get(#1) missed without a comment character")
+	}
+	disk.Close()
+
+	config.Comment = "#"
+	disk, err = openDiskSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer disk.Close()
+	if _, found, _ := disk.Get("#1"); found {
+		t.Error("index was not rebuilt after the comment character changed")
+	}
+	if got, found, _ := disk.Get("1"); !found || got["name"] != "Alice" {
+		t.Errorf("Get(1) = %v, %v", got, found)
+	}
+}
+
+func TestSpillLookup(t *testing.T) {
+	config := LookupConfig{Name: "people", Path: writeLookupCSV(t, lookupCSV)}
+	if got := spillLookup(config, func(int64) bool { return true }); got.Storage != "" {
+		t.Errorf("lookup within the budget moved to %q storage", got.Storage)
+	}
+	got := spillLookup(config, func(int64) bool { return false })
+	if got.Storage != "disk" || got.CacheSize != defaultCacheSize || got.IndexPath == "" {
+		t.Errorf("lookup beyond the budget = storage %q, cache_size %d, index %q; want disk storage with the default cache and an index",
+			got.Storage, got.CacheSize, got.IndexPath)
+	}
+	config.CacheSize = 5
+	if got = spillLookup(config, func(int64) bool { return false }); got.CacheSize != 5 {
+		t.Errorf("spilled lookup cache_size = %d, want the configured 5", got.CacheSize)
+	}
+}
@@ -0,0 +1,118 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// esSource looks keys up in another Elasticsearch index, by document ID
+// through _mget or by any other field through a terms query.
+type esSource struct {
+	config LookupConfig
+	client *http.Client
+}
+
+func newESSource(config LookupConfig) (*esSource, error) {
+	if config.URL == "" || config.Index == "" {
+		return nil, fmt.Errorf("url and index are required")
+	}
+	return &esSource{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *esSource) Get(key string) (map[string]interface{}, bool, error) {
+	found, err := s.GetMany([]string{key})
+	if err != nil {
+		return nil, false, err
+	}
+	fields, ok := found[key]
+	return fields, ok, nil
+}
+
+func (s *esSource) GetMany(keys []string) (map[string]map[string]interface{}, error) {
+	found := make(map[string]map[string]interface{}, len(keys))
+	if s.config.KeyColumn == "_id" {
+		var resp struct {
+			Docs []struct {
+				ID     string                 `json:"_id"`
+				Found  bool                   `json:"found"`
+				Source map[string]interface{} `json:"_source"`
+			} `json:"docs"`
+		}
+		if err := s.do("/_mget", map[string]interface{}{"ids": keys}, &resp); err != nil {
+			return nil, err
+		}
+		for _, doc := range resp.Docs {
+			if doc.Found {
+				found[doc.ID] = s.config.project(ExactNumbers(doc.Source).(map[string]interface{}))
+			}
+		}
+		return found, nil
+	}
+
+	var resp struct {
+		Hits struct {
+			Hits []struct {
+				Source map[string]interface{} `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	query := map[string]interface{}{
+		"size":  len(keys),
+		"query": map[string]interface{}{"terms": map[string]interface{}{s.config.KeyColumn: keys}},
+	}
+	if err := s.do("/_search", query, &resp); err != nil {
+		return nil, err
+	}
+	for _, hit := range resp.Hits.Hits {
+		// Exact numbers print like the key of a document does, so that a
+		// numeric key field matches it.
+		source := ExactNumbers(hit.Source).(map[string]interface{})
+		key := ExtractFieldValue(source, strings.Split(s.config.KeyColumn, "."))
+		if key != nil {
+			found[fmt.Sprint(key)] = s.config.project(source)
+		}
+	}
+	return found, nil
+}
+
+func (s *esSource) Close() error {
+	return nil
+}
+
+func (s *esSource) do(endpoint string, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(s.config.URL, "/") + "/" + s.config.Index + endpoint
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, val := range s.config.Headers {
+		req.Header.Set(key, os.ExpandEnv(val))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, msg)
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
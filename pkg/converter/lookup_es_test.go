@@ -0,0 +1,49 @@
+package converter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestESSourceExactNumbers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/people/_mget":
+			w.Write([]byte(`{"docs": [{"_id": "1", "found": true, "_source": {"account": 9007199254740993}}, {"_id": "2", "found": false}]}`))
+		case "/people/_search":
+			w.Write([]byte(`{"hits": {"hits": [{"_source": {"account": 9007199254740993, "name": "Alice"}}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	byID, err := newESSource(LookupConfig{URL: server.URL, Index: "people", KeyColumn: "_id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := byID.GetMany([]string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]interface{}{"1": {"account": int64(9007199254740993)}}
+	if !reflect.DeepEqual(found, want) {
+		t.Errorf("_mget lookup = %#v, want %#v", found, want)
+	}
+
+	// A numeric key field matches the key it was written as.
+	byField, err := newESSource(LookupConfig{URL: server.URL, Index: "people", KeyColumn: "account"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, ok, err := byField.Get("9007199254740993")
+	if err != nil || !ok {
+		t.Fatalf("Get by account = %v, %v, %v", fields, ok, err)
+	}
+	if data, _ := json.Marshal(fields); string(data) != `{"account":9007199254740993,"name":"Alice"}` {
+		t.Errorf("Get by account = %s", data)
+	}
+}
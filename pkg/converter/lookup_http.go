@@ -0,0 +1,256 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errRetryable = errors.New("retryable response")
+
+// httpSource calls a REST endpoint per key. The url is a template in which
+// {key} is replaced by the escaped lookup key; fields maps target fields to
+// JSONPath expressions evaluated against the response body.
+type httpSource struct {
+	config  LookupConfig
+	client  *http.Client
+	limiter *rateLimiter
+}
+
+func newHTTPSource(config LookupConfig) (*httpSource, error) {
+	if !strings.Contains(config.URL, "{key}") {
+		return nil, fmt.Errorf("url must contain {key}")
+	}
+	if config.CacheDir != "" {
+		if err := os.MkdirAll(config.CacheDir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &httpSource{
+		config:  config,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		limiter: newRateLimiter(config.RateLimit),
+	}, nil
+}
+
+func (s *httpSource) Get(key string) (map[string]interface{}, bool, error) {
+	body, err := s.fetch(strings.ReplaceAll(s.config.URL, "{key}", url.PathEscape(key)))
+	if err != nil || body == nil {
+		return nil, false, err
+	}
+
+	record, err := unmarshalExact(body)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid response for key %s: %w", key, err)
+	}
+	fields := make(map[string]interface{})
+	if len(s.config.Fields) == 0 {
+		if object, ok := record.(map[string]interface{}); ok {
+			flattenFields(object, "", fields)
+		}
+		return fields, true, nil
+	}
+	for target, path := range s.config.Fields {
+		if value, ok := evalJSONPath(record, path); ok {
+			fields[target] = value
+		}
+	}
+	return fields, true, nil
+}
+
+// GetMany issues up to concurrency requests in parallel.
+func (s *httpSource) GetMany(keys []string) (map[string]map[string]interface{}, error) {
+	found := make(map[string]map[string]interface{}, len(keys))
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, max(s.config.Concurrency, 1))
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fields, ok, err := s.Get(key)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if ok {
+				found[key] = fields
+			}
+		}(key)
+	}
+	wg.Wait()
+	return found, firstErr
+}
+
+func (s *httpSource) Close() error {
+	return nil
+}
+
+// fetch returns the response body for target, or nil when the endpoint
+// reports 404. Responses are served from and stored in cache_dir when set.
+func (s *httpSource) fetch(target string) ([]byte, error) {
+	var cachePath string
+	if s.config.CacheDir != "" {
+		sum := sha256.Sum256([]byte(target))
+		cachePath = filepath.Join(s.config.CacheDir, hex.EncodeToString(sum[:]))
+		if body, err := os.ReadFile(cachePath); err == nil {
+			if len(body) == 0 {
+				return nil, nil
+			}
+			return body, nil
+		}
+	}
+
+	var (
+		body []byte
+		err  error
+	)
+	for attempt := 0; attempt <= s.config.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<(attempt-1)) * 200 * time.Millisecond)
+		}
+		s.limiter.wait()
+		body, err = s.request(target)
+		if err == nil || !errors.Is(err, errRetryable) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		if err = os.WriteFile(cachePath, body, 0644); err != nil {
+			return nil, err
+		}
+	}
+	if len(body) == 0 {
+		return nil, nil
+	}
+	return body, nil
+}
+
+func (s *httpSource) request(target string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, val := range s.config.Headers {
+		req.Header.Set(key, os.ExpandEnv(val))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errRetryable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return []byte{}, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("%w: %s returned %s", errRetryable, target, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// rateLimiter spaces out calls to at most perSecond per second. A zero
+// rate disables limiting.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	if perSecond <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+func (r *rateLimiter) wait() {
+	if r.interval == 0 {
+		return
+	}
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// evalJSONPath evaluates a JSONPath subset ($, .name, ['name'], [index])
+// against value.
+func evalJSONPath(value interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	if path != "" && path[0] != '.' && path[0] != '[' {
+		path = "." + path
+	}
+	for path != "" {
+		var segment string
+		switch {
+		case strings.HasPrefix(path, "."):
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+			segment, path = path[:end], path[end:]
+		case strings.HasPrefix(path, "["):
+			end := strings.Index(path, "]")
+			if end == -1 {
+				return nil, false
+			}
+			segment, path = path[1:end], path[end+1:]
+			if index, err := strconv.Atoi(segment); err == nil {
+				list, ok := value.([]interface{})
+				if !ok || index < 0 || index >= len(list) {
+					return nil, false
+				}
+				value = list[index]
+				continue
+			}
+			segment = strings.Trim(segment, `'"`)
+		default:
+			return nil, false
+		}
+
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[segment]; !ok {
+			return nil, false
+		}
+	}
+	if value == nil {
+		return NullValue, true
+	}
+	return value, true
+}
@@ -0,0 +1,250 @@
+package converter
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisSource reads enrichment data from Redis with GET (JSON values) or
+// HGETALL (hash fields, typed like CSV columns). The key_template replaces
+// {key} with the lookup key; batches are pipelined over one connection.
+type redisSource struct {
+	config LookupConfig
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func openRedisSource(config LookupConfig) (*redisSource, error) {
+	if config.KeyTemplate == "" {
+		config.KeyTemplate = "{key}"
+	}
+	switch config.Command {
+	case "":
+		config.Command = "get"
+	case "get", "hgetall":
+	default:
+		return nil, fmt.Errorf("unknown command %q", config.Command)
+	}
+
+	u, err := url.Parse(os.ExpandEnv(config.URL))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("url must use the redis:// scheme")
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s := &redisSource{
+		config: config,
+		conn:   conn,
+		reader: bufio.NewReader(conn),
+		writer: bufio.NewWriter(conn),
+	}
+
+	if password, ok := u.User.Password(); ok {
+		args := []string{"AUTH", password}
+		if u.User.Username() != "" {
+			args = []string{"AUTH", u.User.Username(), password}
+		}
+		if _, err = s.call(args...); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if _, err = s.call("SELECT", db); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *redisSource) Get(key string) (map[string]interface{}, bool, error) {
+	found, err := s.GetMany([]string{key})
+	if err != nil {
+		return nil, false, err
+	}
+	fields, ok := found[key]
+	return fields, ok, nil
+}
+
+// redisTimeout bounds every round trip to the server, a whole pipelined
+// batch included.
+const redisTimeout = 30 * time.Second
+
+// GetMany pipelines a command per key. Every reply is read before an error
+// is returned, so that the replies of the next batch are not taken for
+// this one's; an I/O or protocol error closes the connection instead.
+func (s *redisSource) GetMany(keys []string) (map[string]map[string]interface{}, error) {
+	s.conn.SetDeadline(time.Now().Add(redisTimeout))
+	for _, key := range keys {
+		s.writeCommand(strings.ToUpper(s.config.Command), strings.ReplaceAll(s.config.KeyTemplate, "{key}", key))
+	}
+	if err := s.writer.Flush(); err != nil {
+		s.conn.Close()
+		return nil, err
+	}
+
+	found := make(map[string]map[string]interface{}, len(keys))
+	var firstErr error
+	for _, key := range keys {
+		reply, err := s.readReply()
+		if err != nil {
+			var redisErr redisError
+			if !errors.As(err, &redisErr) {
+				s.conn.Close()
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		fields, ok, err := s.decode(reply)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("invalid value for key %s: %w", key, err)
+			}
+			continue
+		}
+		if ok {
+			found[key] = fields
+		}
+	}
+	return found, firstErr
+}
+
+func (s *redisSource) Close() error {
+	return s.conn.Close()
+}
+
+func (s *redisSource) decode(reply interface{}) (map[string]interface{}, bool, error) {
+	if s.config.Command == "get" {
+		value, ok := reply.(string)
+		if !ok {
+			return nil, false, nil
+		}
+		decoded, err := unmarshalExact([]byte(value))
+		if err != nil {
+			return nil, false, err
+		}
+		record, ok := decoded.(map[string]interface{})
+		if !ok && decoded != nil {
+			return nil, false, fmt.Errorf("value is not a JSON object")
+		}
+		return s.config.project(record), true, nil
+	}
+
+	items, _ := reply.([]interface{})
+	if len(items) == 0 {
+		return nil, false, nil
+	}
+	fields := make(map[string]interface{}, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		field, _ := items[i].(string)
+		raw, _ := items[i+1].(string)
+		value, err := s.config.columnValue(field, raw)
+		if err != nil {
+			return nil, false, err
+		}
+		fields[field] = value
+	}
+	return fields, true, nil
+}
+
+func (s *redisSource) call(args ...string) (interface{}, error) {
+	s.conn.SetDeadline(time.Now().Add(redisTimeout))
+	s.writeCommand(args...)
+	if err := s.writer.Flush(); err != nil {
+		return nil, err
+	}
+	return s.readReply()
+}
+
+func (s *redisSource) writeCommand(args ...string) {
+	fmt.Fprintf(s.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(s.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readReply decodes one RESP reply. Nil bulk strings and arrays decode to
+// nil, bulk and simple strings to string, integers to int64. An array is
+// read to its end even when an element is an error reply, which is then
+// returned.
+func (s *redisSource) readReply() (interface{}, error) {
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(s.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		var firstErr error
+		for i := range items {
+			if items[i], err = s.readReply(); err != nil {
+				var redisErr redisError
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,140 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeRedis answers GET from values and HGETALL from hashes over RESP. A
+// value of "-ERR" is sent as an error reply.
+func fakeRedis(t *testing.T, values map[string]string, hashes map[string][]string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeRedis(conn, values, hashes)
+		}
+	}()
+	return "redis://" + listener.Addr().String()
+}
+
+func serveFakeRedis(conn net.Conn, values map[string]string, hashes map[string][]string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			reader.ReadString('\n')
+			arg, _ := reader.ReadString('\n')
+			args[i] = strings.TrimSuffix(arg, "\r\n")
+		}
+
+		switch args[0] {
+		case "AUTH", "SELECT":
+			fmt.Fprint(conn, "+OK\r\n")
+		case "GET":
+			value, ok := values[args[1]]
+			switch {
+			case !ok:
+				fmt.Fprint(conn, "$-1\r\n")
+			case value == "-ERR":
+				fmt.Fprint(conn, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n")
+			default:
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+			}
+		case "HGETALL":
+			fields := hashes[args[1]]
+			fmt.Fprintf(conn, "*%d\r\n", len(fields))
+			for _, field := range fields {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(field), field)
+			}
+		}
+	}
+}
+
+func TestRedisSourceGet(t *testing.T) {
+	url := fakeRedis(t, map[string]string{
+		"user:1": `{"name":"Alice","age":30}`,
+		"user:3": `[1, 2]`,
+	}, nil)
+	source, err := openRedisSource(LookupConfig{URL: strings.Replace(url, "redis://", "redis://:secret@", 1) + "/2", KeyTemplate: "user:{key}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	fields, found, err := source.Get("1")
+	if err != nil || !found || fields["name"] != "Alice" || fields["age"] != int64(30) {
+		t.Errorf("Get(1) = %v, %v, %v", fields, found, err)
+	}
+	if _, found, err := source.Get("2"); found || err != nil {
+		t.Errorf("Get(2) = %v, %v; want a miss", found, err)
+	}
+	if _, _, err := source.Get("3"); err == nil {
+		t.Error("Get(3) of a value that is no JSON object returned no error")
+	}
+}
+
+func TestRedisSourceHGetAll(t *testing.T) {
+	url := fakeRedis(t, nil, map[string][]string{
+		"7": {"name", "Bob", "visits", "12"},
+	})
+	source, err := openRedisSource(LookupConfig{URL: url, Command: "hgetall", Columns: map[string]string{"visits": "long"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	fields, found, err := source.Get("7")
+	if err != nil || !found || fields["name"] != "Bob" || fields["visits"] != int64(12) {
+		t.Errorf("Get(7) = %v, %v, %v", fields, found, err)
+	}
+	if _, found, err := source.Get("8"); found || err != nil {
+		t.Errorf("Get(8) = %v, %v; want a miss", found, err)
+	}
+}
+
+func TestRedisSourceFailedBatchKeepsReplies(t *testing.T) {
+	url := fakeRedis(t, map[string]string{
+		"a":     `{"v":"a"}`,
+		"bad":   `not json`,
+		"wrong": "-ERR",
+		"b":     `{"v":"b"}`,
+	}, nil)
+	source, err := openRedisSource(LookupConfig{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	if _, err := source.GetMany([]string{"a", "bad", "wrong", "b"}); err == nil {
+		t.Fatal("GetMany with an invalid value returned no error")
+	}
+	// Every reply of the failed batch was consumed, so the next batch gets
+	// its own.
+	found, err := source.GetMany([]string{"b", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found["a"]["v"] != "a" || found["b"]["v"] != "b" {
+		t.Errorf("GetMany after a failed batch = %v", found)
+	}
+}
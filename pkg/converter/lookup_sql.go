@@ -0,0 +1,145 @@
+package converter
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// sqlSource runs a parameterized query per key against a database/sql
+// driver. When batch_query is set, prefetching runs it once per batch with
+// the {keys} token expanded to one placeholder per key; its rows must
+// include the key column so results can be matched back.
+type sqlSource struct {
+	config LookupConfig
+	db     *sql.DB
+	stmt   *sql.Stmt
+}
+
+func openSQLSource(config LookupConfig) (*sqlSource, error) {
+	if config.Driver == "" || config.DSN == "" || config.Query == "" {
+		return nil, fmt.Errorf("driver, dsn and query are required")
+	}
+	db, err := sql.Open(config.Driver, os.ExpandEnv(config.DSN))
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := db.Prepare(config.Query)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqlSource{config: config, db: db, stmt: stmt}, nil
+}
+
+func (s *sqlSource) Get(key string) (map[string]interface{}, bool, error) {
+	rows, err := s.stmt.Query(key)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	records, err := s.scan(rows)
+	if err != nil || len(records) == 0 {
+		return nil, false, err
+	}
+	record := records[0]
+	delete(record, s.config.KeyColumn)
+	return record, true, nil
+}
+
+func (s *sqlSource) GetMany(keys []string) (map[string]map[string]interface{}, error) {
+	found := make(map[string]map[string]interface{}, len(keys))
+	if s.config.BatchQuery == "" {
+		for _, key := range keys {
+			fields, ok, err := s.Get(key)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				found[key] = fields
+			}
+		}
+		return found, nil
+	}
+
+	placeholders := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if s.config.Driver == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+		args[i] = key
+	}
+	query := strings.ReplaceAll(s.config.BatchQuery, "{keys}", strings.Join(placeholders, ", "))
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records, err := s.scan(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		key, ok := record[s.config.KeyColumn]
+		if !ok {
+			return nil, fmt.Errorf("batch_query result has no %s column", s.config.KeyColumn)
+		}
+		delete(record, s.config.KeyColumn)
+		found[fmt.Sprint(key)] = record
+	}
+	return found, nil
+}
+
+func (s *sqlSource) Close() error {
+	if err := s.stmt.Close(); err != nil {
+		return err
+	}
+	return s.db.Close()
+}
+
+func (s *sqlSource) scan(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			record[column] = sqlValue(values[i])
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func sqlValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case nil:
+		return NullValue
+	case []byte:
+		return string(typed)
+	case time.Time:
+		return typed.Format(time.RFC3339)
+	default:
+		return typed
+	}
+}
@@ -0,0 +1,75 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInferValue(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want interface{}
+	}{
+		{"", nil},
+		{"42", int64(42)},
+		{"-7", int64(-7)},
+		{"2.5", 2.5},
+		{"1e3", 1000.0},
+		{"true", true},
+		{"FALSE", false},
+		{"Nan", "Nan"},
+		{"inf", "inf"},
+		{"-Infinity", "-Infinity"},
+		{"Paris", "Paris"},
+	}
+	for _, tt := range tests {
+		if got := inferValue(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("inferValue(%q) = %#v, want %#v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestColumnValue(t *testing.T) {
+	config := LookupConfig{
+		Columns: map[string]string{
+			"count":   "long",
+			"price":   "double",
+			"active":  "boolean",
+			"created": "date",
+			"code":    "keyword",
+		},
+	}
+	tests := []struct {
+		column, raw string
+		want        interface{}
+		wantErr     bool
+	}{
+		{"count", "12", int64(12), false},
+		{"count", "1.5", nil, true},
+		{"price", "9.99", 9.99, false},
+		{"price", "NaN", nil, true},
+		{"price", "inf", nil, true},
+		{"active", "true", true, false},
+		{"active", "yes", nil, true},
+		{"created", "2024-03-01T10:00:00+02:00", "2024-03-01T10:00:00+02:00", false},
+		{"created", "yesterday", nil, true},
+		{"code", "007", "007", false},
+		{"count", "", nil, false},
+		{"untyped", "42", "42", false},
+	}
+	for _, tt := range tests {
+		got, err := config.columnValue(tt.column, tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("columnValue(%s, %q) error = %v, want error %v", tt.column, tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("columnValue(%s, %q) = %#v, want %#v", tt.column, tt.raw, got, tt.want)
+		}
+	}
+
+	config.InferTypes = true
+	if got, _ := config.columnValue("untyped", "42"); got != int64(42) {
+		t.Errorf("inferred columnValue = %#v, want int64(42)", got)
+	}
+}
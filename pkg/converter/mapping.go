@@ -0,0 +1,348 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+)
+
+// NullValue marks a field that is present but null, as opposed to absent.
+const NullValue = "NULL"
+
+type ESMeta struct {
+	Index *string  `json:"_index"`
+	Type  *string  `json:"_type"`
+	ID    *string  `json:"_id"`
+	Score *float64 `json:"_score,omitempty"`
+}
+
+type ESDoc struct {
+	ESMeta
+	Source map[string]interface{} `json:"_source"`
+}
+
+type FieldMapping struct {
+	// Preset names the built-in mapping the file starts from, e.g.
+	// ecs-nginx; see Presets and applyPresets.
+	Preset         string                            `json:"preset,omitempty"`
+	Index          *string                           `json:"index,omitempty"`
+	FieldMapping   map[string]string                 `json:"field_mapping,omitempty"`
+	Templates      map[string]string                 `json:"templates,omitempty"`
+	DefaultValues  map[string]interface{}            `json:"default_values,omitempty"`
+	RandomGenerate map[string]map[string]interface{} `json:"random_generate,omitempty"`
+	File           map[string]string                 `json:"file,omitempty"`
+	Exclude        []string                          `json:"exclude,omitempty"`
+	Passthrough    []string                          `json:"passthrough,omitempty"`
+	Lookups        []LookupConfig                    `json:"lookups,omitempty"`
+	Processors     []ProcessorConfig                 `json:"processors,omitempty"`
+	Explode        *ExplodeConfig                    `json:"explode,omitempty"`
+	// BeforeMap and AfterMap are scripts run on every document before and
+	// after the other rules; Script is the older name of AfterMap. See
+	// documentScript.
+	BeforeMap      string `json:"before_map,omitempty"`
+	AfterMap       string `json:"after_map,omitempty"`
+	Script         string `json:"script,omitempty"`
+	ScriptLanguage string `json:"script_language,omitempty"`
+	ScriptTimeout  string `json:"script_timeout,omitempty"`
+	ScriptMemoryMB int    `json:"script_memory_mb,omitempty"`
+	// Plugins are Go plugin files providing extra transforms; see
+	// TransformPlugin.
+	Plugins []string `json:"plugins,omitempty"`
+	// Types declares the Elasticsearch type of output fields by dotted
+	// path. It does not change conversion; it documents the intended
+	// destination mapping.
+	Types map[string]string `json:"types,omitempty"`
+	// Mappings and Select make a multi-mapping file: each document is
+	// converted with the named mapping its first matching selector picks.
+	Mappings map[string]FieldMapping `json:"mappings,omitempty"`
+	Select   []Selector              `json:"select,omitempty"`
+}
+
+// LoadMapping reads a mapping JSON file, resolving its preset, vars and
+// macros.
+func LoadMapping(mappingFile string) (FieldMapping, error) {
+	mappingBytes, err := os.ReadFile(mappingFile)
+	if err != nil {
+		return FieldMapping{}, err
+	}
+	mapping, err := ParseMapping(mappingBytes)
+	if err != nil {
+		return mapping, fmt.Errorf("invalid mapping file %s: %w", mappingFile, err)
+	}
+	return mapping, nil
+}
+
+// ParseMapping decodes a mapping as LoadMapping does, from its JSON.
+func ParseMapping(data []byte) (FieldMapping, error) {
+	var mapping FieldMapping
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return mapping, err
+	}
+	if err := applyPresets(raw); err != nil {
+		return mapping, err
+	}
+	if err := resolveVars(raw); err != nil {
+		return mapping, err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return mapping, err
+	}
+	decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&mapping); err != nil {
+		return mapping, err
+	}
+	mapping.exactNumbers()
+	return mapping, nil
+}
+
+// exactNumbers settles the json.Number values LoadMapping decodes: default
+// values, of the mapping and its lookups, keep exact integers, while the
+// random_generate settings, whose bounds the generators read as float64,
+// are made float64.
+func (m *FieldMapping) exactNumbers() {
+	ExactNumbers(m.DefaultValues)
+	for i := range m.Lookups {
+		ExactNumbers(m.Lookups[i].Defaults)
+	}
+	for _, config := range m.RandomGenerate {
+		floatNumbers(config)
+	}
+	for name, variant := range m.Mappings {
+		variant.exactNumbers()
+		m.Mappings[name] = variant
+	}
+}
+
+// DocID returns the document's _id, or an empty string when it has none.
+func DocID(doc ESDoc) string {
+	if doc.ID == nil {
+		return ""
+	}
+	return *doc.ID
+}
+
+// fieldPaths caches the split form of the field paths a mapping names, so
+// they are split once rather than for every document.
+var fieldPaths sync.Map
+
+// splitPath returns the segments of a dotted field path. The slice is shared
+// between callers and must not be modified.
+func splitPath(field string) []string {
+	if path, ok := fieldPaths.Load(field); ok {
+		return path.([]string)
+	}
+	path, _ := fieldPaths.LoadOrStore(field, strings.Split(field, "."))
+	return path.([]string)
+}
+
+// ExtractFieldValue returns the value at path, NullValue for a null leaf,
+// or nil when the path does not exist.
+func ExtractFieldValue(data map[string]interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return data
+	}
+	val, ok := data[path[0]]
+	if !ok {
+		return nil
+	}
+	if len(path) == 1 {
+		if val == nil {
+			return NullValue
+		}
+		return val
+	}
+	switch typed := val.(type) {
+	case map[string]interface{}:
+		return ExtractFieldValue(typed, path[1:])
+	default:
+		return nil
+	}
+}
+
+// InsertFieldValue sets the value at path, creating intermediate objects.
+// NullValue is stored as null.
+func InsertFieldValue(data map[string]interface{}, path []string, value interface{}) {
+	for i := 0; i < len(path)-1; i++ {
+		key := path[i]
+		if _, exists := data[key]; !exists {
+			data[key] = make(map[string]interface{})
+		}
+		data = data[key].(map[string]interface{})
+	}
+	if value == NullValue {
+		value = nil
+	}
+	data[path[len(path)-1]] = value
+}
+
+// DeleteFieldValue removes the value at path, if there is one.
+func DeleteFieldValue(data map[string]interface{}, path []string) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := data[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		data = next
+	}
+	delete(data, path[len(path)-1])
+}
+
+// ExactNumbers replaces the json.Number values within value, in place, by
+// an int64 for an integer and a float64 otherwise, so IDs beyond 2^53
+// keep every digit where float64 would round them. An integer too large
+// for int64 stays a json.Number, which encodes as it was written.
+func ExactNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		if n, err := typed.Int64(); err == nil {
+			return n
+		}
+		if !strings.ContainsAny(string(typed), ".eE") {
+			return typed
+		}
+		if f, err := typed.Float64(); err == nil {
+			return f
+		}
+		return typed
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = ExactNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = ExactNumbers(item)
+		}
+	}
+	return value
+}
+
+// unmarshalExact decodes the JSON value data holds, with its numbers made
+// exact as ExactNumbers makes them.
+func unmarshalExact(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return ExactNumbers(value), nil
+}
+
+// floatNumbers replaces the json.Number values within value, in place, by
+// float64, as json.Unmarshal would decode them.
+func floatNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		if f, err := typed.Float64(); err == nil {
+			return f
+		}
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = floatNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = floatNumbers(item)
+		}
+	}
+	return value
+}
+
+func extractFileData(config LookupConfig) (map[string]map[string]interface{}, error) {
+	file, err := os.Open(config.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := config.csvReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", config.Path)
+	}
+
+	headers := records[0]
+	idIndex, err := keyColumnIndex(headers, config.KeyColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	dataMapByID := make(map[string]map[string]interface{})
+	for _, row := range records[1:] {
+		if dataMapByID[row[idIndex]], err = config.recordFields(headers, idIndex, row); err != nil {
+			return nil, err
+		}
+	}
+	return dataMapByID, nil
+}
+
+func keyColumnIndex(headers []string, keyColumn string) (int, error) {
+	for i, header := range headers {
+		if header == keyColumn {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("%s column not found", keyColumn)
+}
+
+func generateRandomValue(rn *rand.Rand, config map[string]interface{}) interface{} {
+	switch config["type"] {
+	case "binary":
+		data := make([]byte, 64)
+		rn.Read(data)
+		return base64.StdEncoding.EncodeToString(data)
+
+	case "boolean":
+		return rn.Intn(2) == 0
+
+	case "date":
+		// TODO: need to implement
+
+	case "long", "integer", "short", "byte":
+		mn := int(config["min"].(float64))
+		mx := int(config["max"].(float64))
+		return rn.Intn(mx-mn+1) + mn
+
+	case "double", "float", "half_float":
+		mn := config["min"].(float64)
+		mx := config["max"].(float64)
+		d := mn + rn.Float64()*(mx-mn)
+		return math.Round(d*100) / 100
+
+	case "keyword", "wildcard", "constant_keyword":
+		values := config["values"].([]interface{})
+		return values[rn.Intn(len(values))] // TODO: generate complete random value
+
+	case "text":
+		return generateText(rn, config)
+
+	case "name", "first_name", "last_name", "address", "street", "city", "postcode", "phone", "email":
+		locale, _ := config["locale"].(string)
+		fakerLocale, err := findLocale(locale)
+		if err != nil {
+			return nil
+		}
+		return fakeValue(rn, config["type"].(string), fakerLocale)
+
+	default:
+		return nil
+	}
+	return struct{}{}
+}
@@ -0,0 +1,56 @@
+package converter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMappingExactNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapping.json")
+	err := os.WriteFile(path, []byte(`{
+		"default_values": {"big": 9007199254740993, "ratio": 0.5, "list": [1, 2]},
+		"random_generate": {"n": {"type": "integer", "min": 1, "max": 9}},
+		"lookups": [{"path": "x.csv", "defaults": {"id": 12345678901234567}}]
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := LoadMapping(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"big": int64(9007199254740993), "ratio": 0.5, "list": []interface{}{int64(1), int64(2)}}
+	if !reflect.DeepEqual(mapping.DefaultValues, want) {
+		t.Errorf("default_values = %#v, want %#v", mapping.DefaultValues, want)
+	}
+	if got := mapping.Lookups[0].Defaults["id"]; got != int64(12345678901234567) {
+		t.Errorf("lookup default = %#v, want the exact int64", got)
+	}
+	// The generators read their bounds as float64.
+	if got := mapping.RandomGenerate["n"]["max"]; got != 9.0 {
+		t.Errorf("random_generate max = %#v, want float64 9", got)
+	}
+}
+
+func TestUnmarshalExact(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want interface{}
+	}{
+		{`9007199254740993`, int64(9007199254740993)},
+		{`123456789012345678901234`, json.Number("123456789012345678901234")},
+		{`1.5`, 1.5},
+		{`{"a": [1, "x"]}`, map[string]interface{}{"a": []interface{}{int64(1), "x"}}},
+	} {
+		got, err := unmarshalExact([]byte(tc.in))
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("unmarshalExact(%s) = %#v, %v; want %#v", tc.in, got, err, tc.want)
+		}
+	}
+	if _, err := unmarshalExact([]byte(`{} {}`)); err == nil {
+		t.Error("unmarshalExact accepted trailing data")
+	}
+}
@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"fmt"
+	"plugin"
+	"sync"
+)
+
+// TransformPlugin is a transform defined outside this package. Programs
+// using the package as a library register one with RegisterTransform; Go
+// plugins listed under "plugins" in the mapping export them from a
+// function
+//
+//	func Transforms() []converter.TransformPlugin
+type TransformPlugin interface {
+	// Name is what field_mapping sources call the transform, as in
+	// "email | name(arg)".
+	Name() string
+	// New builds the transform for one call from its positional and
+	// named arguments.
+	New(positional []string, named map[string]string) (Transform, error)
+}
+
+// pluginsMu guards registration and the record of loaded plugin files.
+var (
+	pluginsMu     sync.Mutex
+	loadedPlugins = map[string]bool{}
+)
+
+// RegisterTransform makes t available to field_mapping sources. It fails
+// if a transform of that name already exists.
+func RegisterTransform(t TransformPlugin) error {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	return registerPlugin(t)
+}
+
+func registerPlugin(t TransformPlugin) error {
+	name := t.Name()
+	if _, ok := transforms[name]; ok {
+		return fmt.Errorf("transform %q is already registered", name)
+	}
+	registerTransform(name, func(args transformArgs) (Transform, error) {
+		return t.New(args.positional, args.named)
+	})
+	return nil
+}
+
+// loadPlugins opens the Go plugins at paths and registers their
+// transforms. A file is only loaded once per process, so rebuilding a
+// converter from the same mapping is safe.
+func loadPlugins(paths []string) error {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	for _, path := range paths {
+		if loadedPlugins[path] {
+			continue
+		}
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+		symbol, err := p.Lookup("Transforms")
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", path, err)
+		}
+		list, ok := symbol.(func() []TransformPlugin)
+		if !ok {
+			return fmt.Errorf("plugin %s: Transforms is %T, want func() []converter.TransformPlugin", path, symbol)
+		}
+		for _, t := range list() {
+			if err := registerPlugin(t); err != nil {
+				return fmt.Errorf("plugin %s: %w", path, err)
+			}
+		}
+		loadedPlugins[path] = true
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path"
+	"sort"
+	"strings"
+)
+
+// presetFiles are the built-in mappings a mapping file can start from with
+// "preset": each maps the fields of a common source shape to Elastic Common
+// Schema names.
+//
+//go:embed presets/*.json
+var presetFiles embed.FS
+
+// Presets returns the names of the built-in presets, sorted.
+func Presets() []string {
+	entries, _ := presetFiles.ReadDir("presets")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPreset returns the built-in preset name.
+func LoadPreset(name string) (FieldMapping, error) {
+	var mapping FieldMapping
+	data, err := presetData(name)
+	if err != nil {
+		return mapping, err
+	}
+	if err = json.Unmarshal(data, &mapping); err != nil {
+		return mapping, fmt.Errorf("preset %s: %w", name, err)
+	}
+	return mapping, nil
+}
+
+// applyPresets merges the preset a decoded mapping file names, and those
+// of its named mappings, under the file's own settings, in place. The file
+// wins: its objects are merged into the preset's key by key, a null
+// removing the preset's entry; its lists come after the preset's; and
+// anything else replaces the preset's value.
+//
+//	{"preset": "ecs-nginx", "index": "web", "field_mapping": {"url.domain": null}}
+func applyPresets(raw map[string]interface{}) error {
+	if named, ok := raw["mappings"].(map[string]interface{}); ok {
+		for name, value := range named {
+			if mapping, ok := value.(map[string]interface{}); ok {
+				if err := applyPresets(mapping); err != nil {
+					return fmt.Errorf("mappings %s: %w", name, err)
+				}
+			}
+		}
+	}
+	value, ok := raw["preset"]
+	if !ok {
+		return nil
+	}
+	name, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("preset must be a string")
+	}
+	data, err := presetData(name)
+	if err != nil {
+		return err
+	}
+	var preset map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&preset); err != nil {
+		return fmt.Errorf("preset %s: %w", name, err)
+	}
+	merged := mergePreset(preset, raw)
+	clear(raw)
+	maps.Copy(raw, merged)
+	return nil
+}
+
+func presetData(name string) ([]byte, error) {
+	data, err := presetFiles.ReadFile(path.Join("presets", name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown preset %q (known: %s)", name, strings.Join(Presets(), ", "))
+	}
+	return data, nil
+}
+
+// mergePreset returns the preset object with the settings of file merged
+// in, as applyPresets describes.
+func mergePreset(preset, file map[string]interface{}) map[string]interface{} {
+	for key, value := range file {
+		base := preset[key]
+		switch typed := value.(type) {
+		case nil:
+			delete(preset, key)
+			continue
+		case map[string]interface{}:
+			if object, ok := base.(map[string]interface{}); ok {
+				preset[key] = mergePreset(object, typed)
+				continue
+			}
+		case []interface{}:
+			if list, ok := base.([]interface{}); ok {
+				preset[key] = append(list, typed...)
+				continue
+			}
+		}
+		preset[key] = value
+	}
+	return preset
+}
@@ -0,0 +1,80 @@
+package converter
+
+import (
+	"fmt"
+)
+
+type ProcessorConfig struct {
+	Type          string   `json:"type"`
+	Field         string   `json:"field"`
+	TargetField   string   `json:"target_field"`
+	IgnoreMissing bool     `json:"ignore_missing"`
+	Database      string   `json:"database"`
+	Properties    []string `json:"properties"`
+	RegexFile     string   `json:"regex_file"`
+	Module        string   `json:"module"`
+	Function      string   `json:"function"`
+	Method        string   `json:"method"`
+	// CurrencyField, TargetCurrency, DateField, Rates and
+	// OriginalCurrencyField configure the currency processor.
+	CurrencyField         string `json:"currency_field"`
+	TargetCurrency        string `json:"target_currency"`
+	DateField             string `json:"date_field"`
+	Rates                 string `json:"rates"`
+	OriginalCurrencyField string `json:"original_currency_field"`
+}
+
+// Processor enriches an output document in place after mapping and
+// lookups, in the spirit of an Elasticsearch ingest processor.
+type Processor interface {
+	Process(source map[string]interface{}) error
+	Close() error
+}
+
+func openProcessors(configs []ProcessorConfig) ([]Processor, error) {
+	var processors []Processor
+	for i, config := range configs {
+		if config.Field == "" && processorNeedsField(config.Type) {
+			return processors, fmt.Errorf("field is required for processor %d (%s)", i, config.Type)
+		}
+		var (
+			processor Processor
+			err       error
+		)
+		switch config.Type {
+		case "geoip":
+			processor, err = newGeoIPProcessor(config)
+		case "user_agent":
+			processor, err = newUserAgentProcessor(config)
+		case "wasm":
+			processor, err = newWASMProcessor(config)
+		case "content_hash":
+			processor, err = newContentHashProcessor(config)
+		case "currency":
+			processor, err = newCurrencyProcessor(config)
+		default:
+			err = fmt.Errorf("unknown type %q", config.Type)
+		}
+		if err != nil {
+			return processors, fmt.Errorf("failed to open processor %d (%s): %w", i, config.Type, err)
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+// processorNeedsField reports whether processors of type t read a field.
+func processorNeedsField(t string) bool {
+	return t != "wasm" && t != "content_hash"
+}
+
+// stringField returns the string value at path, or false when the field is
+// absent, null, or not a string.
+func stringField(source map[string]interface{}, path string) (string, bool) {
+	value := ExtractFieldValue(source, splitPath(path))
+	str, ok := value.(string)
+	if !ok || value == NullValue {
+		return "", false
+	}
+	return str, true
+}
@@ -0,0 +1,197 @@
+package converter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// currencyProcessor converts the amount in field from the currency named
+// by currency_field to target_currency, with the rates of the rates file,
+// and writes it to target_field (default: field itself), the currency code
+// it had to original_currency_field (default: target_field with the suffix
+// _original_currency). With date_field, the rate is that of the date of the
+// document, or the latest date before it the table has; see
+// loadCurrencyRates for the rates file.
+type currencyProcessor struct {
+	config ProcessorConfig
+	rates  map[string][]datedRate
+}
+
+// datedRate is the value of one unit of a currency in the target currency
+// from a date on, as yyyy-mm-dd; undated rates have an empty date.
+type datedRate struct {
+	date string
+	rate float64
+}
+
+func newCurrencyProcessor(config ProcessorConfig) (*currencyProcessor, error) {
+	if config.CurrencyField == "" {
+		return nil, fmt.Errorf("currency_field is required")
+	}
+	if config.TargetCurrency == "" {
+		return nil, fmt.Errorf("target_currency is required")
+	}
+	if config.Rates == "" {
+		return nil, fmt.Errorf("rates is required")
+	}
+	if config.TargetField == "" {
+		config.TargetField = config.Field
+	}
+	if config.OriginalCurrencyField == "" {
+		config.OriginalCurrencyField = config.TargetField + "_original_currency"
+	}
+	rates, err := loadCurrencyRates(config.Rates)
+	if err != nil {
+		return nil, err
+	}
+	return &currencyProcessor{config: config, rates: rates}, nil
+}
+
+// loadCurrencyRates reads a rates file: a CSV file with currency and rate
+// columns and an optional date column, or a JSON object of rates by
+// currency, {"EUR": 1.08}, or of those by date, {"2024-01-15": {"EUR":
+// 1.08}}. A rate is the value of one unit of the currency in the target
+// currency.
+func loadCurrencyRates(path string) (map[string][]datedRate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rates := map[string][]datedRate{}
+	add := func(currency, date string, rate float64) {
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		rates[currency] = append(rates[currency], datedRate{date: date, rate: rate})
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		var table map[string]json.RawMessage
+		if err = json.Unmarshal(data, &table); err != nil {
+			return nil, fmt.Errorf("invalid rates file %s: %w", path, err)
+		}
+		for key, raw := range table {
+			var rate float64
+			if json.Unmarshal(raw, &rate) == nil {
+				add(key, "", rate)
+				continue
+			}
+			var byCurrency map[string]float64
+			if err = json.Unmarshal(raw, &byCurrency); err != nil {
+				return nil, fmt.Errorf("invalid rates file %s: %s is neither a rate nor rates by currency", path, key)
+			}
+			date, err := rateDate(key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid rates file %s: %w", path, err)
+			}
+			for currency, rate := range byCurrency {
+				add(currency, date, rate)
+			}
+		}
+	} else {
+		records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rates file %s: %w", path, err)
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("%s is empty", path)
+		}
+		columns := map[string]int{"date": -1}
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		currencyColumn, hasCurrency := columns["currency"]
+		rateColumn, hasRate := columns["rate"]
+		if !hasCurrency || !hasRate {
+			return nil, fmt.Errorf("rates file %s needs currency and rate columns", path)
+		}
+		for i, record := range records[1:] {
+			rate, err := strconv.ParseFloat(strings.TrimSpace(record[rateColumn]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("rates file %s, line %d: invalid rate %q", path, i+2, record[rateColumn])
+			}
+			date := ""
+			if columns["date"] >= 0 {
+				if date, err = rateDate(record[columns["date"]]); err != nil {
+					return nil, fmt.Errorf("rates file %s, line %d: %w", path, i+2, err)
+				}
+			}
+			add(record[currencyColumn], date, rate)
+		}
+	}
+	for _, dated := range rates {
+		sort.Slice(dated, func(i, j int) bool { return dated[i].date < dated[j].date })
+	}
+	return rates, nil
+}
+
+func rateDate(text string) (string, error) {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(text))
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q, must be yyyy-mm-dd", text)
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+func (p *currencyProcessor) Process(source map[string]interface{}) error {
+	amountValue := ExtractFieldValue(source, splitPath(p.config.Field))
+	currency, hasCurrency := stringField(source, p.config.CurrencyField)
+	if amountValue == nil || amountValue == NullValue || !hasCurrency {
+		if p.config.IgnoreMissing {
+			return nil
+		}
+		return fmt.Errorf("currency: field %s or %s is missing", p.config.Field, p.config.CurrencyField)
+	}
+	amount, ok := toFloat(amountValue)
+	if text, isText := amountValue.(string); isText {
+		var err error
+		amount, err = strconv.ParseFloat(strings.TrimSpace(text), 64)
+		ok = err == nil
+	}
+	if !ok {
+		return fmt.Errorf("currency: %s is not an amount", p.config.Field)
+	}
+	date := ""
+	if p.config.DateField != "" {
+		value := ExtractFieldValue(source, splitPath(p.config.DateField))
+		if value == nil || value == NullValue {
+			return fmt.Errorf("currency: field %s is missing", p.config.DateField)
+		}
+		t, err := detectTime(value, time.UTC)
+		if err != nil {
+			return fmt.Errorf("currency: %s: %w", p.config.DateField, err)
+		}
+		date = t.UTC().Format("2006-01-02")
+	}
+	rate, ok := p.rate(strings.ToUpper(strings.TrimSpace(currency)), date)
+	if !ok && date == "" {
+		return fmt.Errorf("currency: no rate for %s", currency)
+	}
+	if !ok {
+		return fmt.Errorf("currency: no rate for %s on %s", currency, date)
+	}
+	InsertFieldValue(source, splitPath(p.config.TargetField), amount*rate)
+	InsertFieldValue(source, splitPath(p.config.OriginalCurrencyField), currency)
+	return nil
+}
+
+// rate returns the rate of currency on date: that of the latest date on or
+// before it, else the undated one. The target currency is worth 1.
+func (p *currencyProcessor) rate(currency, date string) (float64, bool) {
+	dated := p.rates[currency]
+	i := sort.Search(len(dated), func(i int) bool { return dated[i].date > date })
+	if i > 0 {
+		return dated[i-1].rate, true
+	}
+	if currency == strings.ToUpper(p.config.TargetCurrency) {
+		return 1, true
+	}
+	return 0, false
+}
+
+func (p *currencyProcessor) Close() error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package converter
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// geoIPRecord covers the City, Country and ASN MaxMind database layouts.
+type geoIPRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Continent struct {
+		Code  string            `maxminddb:"code"`
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"continent"`
+	Country struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+		TimeZone  string   `maxminddb:"time_zone"`
+	} `maxminddb:"location"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	ASN          uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoIPProcessor mirrors the Elasticsearch geoip ingest processor: it looks
+// up the IP in field and writes the ES property names under target_field
+// (default "geo").
+type geoIPProcessor struct {
+	config ProcessorConfig
+	reader *maxminddb.Reader
+}
+
+func newGeoIPProcessor(config ProcessorConfig) (*geoIPProcessor, error) {
+	if config.Database == "" {
+		return nil, fmt.Errorf("database is required")
+	}
+	if config.TargetField == "" {
+		config.TargetField = "geo"
+	}
+	reader, err := maxminddb.Open(config.Database)
+	if err != nil {
+		return nil, err
+	}
+	return &geoIPProcessor{config: config, reader: reader}, nil
+}
+
+func (p *geoIPProcessor) Process(source map[string]interface{}) error {
+	raw, ok := stringField(source, p.config.Field)
+	if !ok {
+		if p.config.IgnoreMissing {
+			return nil
+		}
+		return fmt.Errorf("geoip: field %s is missing", p.config.Field)
+	}
+	ip := net.ParseIP(raw)
+	if ip == nil {
+		return fmt.Errorf("geoip: %q is not an IP address", raw)
+	}
+
+	var record geoIPRecord
+	network, found, err := p.reader.LookupNetwork(ip, &record)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+
+	geo := map[string]interface{}{
+		"ip":                ip.String(),
+		"network":           network.String(),
+		"city_name":         record.City.Names["en"],
+		"continent_code":    record.Continent.Code,
+		"continent_name":    record.Continent.Names["en"],
+		"country_iso_code":  record.Country.IsoCode,
+		"country_name":      record.Country.Names["en"],
+		"timezone":          record.Location.TimeZone,
+		"postal_code":       record.Postal.Code,
+		"organization_name": record.Organization,
+	}
+	if len(record.Subdivisions) > 0 {
+		geo["region_iso_code"] = record.Subdivisions[0].IsoCode
+		geo["region_name"] = record.Subdivisions[0].Names["en"]
+	}
+	if record.Location.Latitude != nil && record.Location.Longitude != nil {
+		geo["location"] = map[string]interface{}{
+			"lat": *record.Location.Latitude,
+			"lon": *record.Location.Longitude,
+		}
+	}
+	if record.ASN != 0 {
+		geo["asn"] = record.ASN
+	}
+
+	properties := p.config.Properties
+	if len(properties) == 0 {
+		for property := range geo {
+			properties = append(properties, property)
+		}
+	}
+	for _, property := range properties {
+		if value, ok := geo[property]; ok && value != "" {
+			InsertFieldValue(source, splitPath(p.config.TargetField+"."+property), value)
+		}
+	}
+	return nil
+}
+
+func (p *geoIPProcessor) Close() error {
+	return p.reader.Close()
+}
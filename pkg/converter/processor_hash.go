@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// contentHashProcessor stores a hash of the output source in target_field
+// (default "content_hash"), so that downstream stores can tell changed
+// documents from unchanged ones and upsert idempotently. It hashes the
+// canonical JSON of the source, whose object keys encoding/json sorts, less
+// target_field itself; properties restricts it to the fields listed. method
+// is sha256 (the default) or xxhash. Place it after the processors whose
+// output the hash should cover.
+type contentHashProcessor struct {
+	config ProcessorConfig
+	hash   func([]byte) string
+}
+
+func newContentHashProcessor(config ProcessorConfig) (*contentHashProcessor, error) {
+	if config.TargetField == "" {
+		config.TargetField = "content_hash"
+	}
+	p := &contentHashProcessor{config: config}
+	switch config.Method {
+	case "", "sha256":
+		p.hash = func(data []byte) string {
+			sum := sha256.Sum256(data)
+			return hex.EncodeToString(sum[:])
+		}
+	case "xxhash":
+		p.hash = func(data []byte) string {
+			return fmt.Sprintf("%016x", xxhash.Sum64(data))
+		}
+	default:
+		return nil, fmt.Errorf("unknown method %q, must be sha256 or xxhash", config.Method)
+	}
+	return p, nil
+}
+
+func (p *contentHashProcessor) Process(source map[string]interface{}) error {
+	target := splitPath(p.config.TargetField)
+	var hashed interface{}
+	if len(p.config.Properties) > 0 {
+		fields := map[string]interface{}{}
+		for _, field := range p.config.Properties {
+			switch value := ExtractFieldValue(source, splitPath(field)); value {
+			case nil:
+			case NullValue:
+				fields[field] = nil
+			default:
+				fields[field] = value
+			}
+		}
+		hashed = fields
+	} else {
+		DeleteFieldValue(source, target)
+		hashed = source
+	}
+	data, err := json.Marshal(hashed)
+	if err != nil {
+		return fmt.Errorf("content_hash: %w", err)
+	}
+	InsertFieldValue(source, target, p.hash(data))
+	return nil
+}
+
+func (p *contentHashProcessor) Close() error {
+	return nil
+}
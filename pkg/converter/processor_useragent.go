@@ -0,0 +1,96 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ua-parser/uap-go/uaparser"
+	"gopkg.in/yaml.v3"
+)
+
+// userAgentProcessor parses a user-agent string into the fields of the
+// Elasticsearch user_agent ingest processor under target_field (default
+// "user_agent"). regex_file selects a uap-core regexes.yaml instead of the
+// bundled definitions.
+type userAgentProcessor struct {
+	config ProcessorConfig
+	parser *uaparser.Parser
+}
+
+func newUserAgentProcessor(config ProcessorConfig) (*userAgentProcessor, error) {
+	if config.TargetField == "" {
+		config.TargetField = "user_agent"
+	}
+	var options []uaparser.Option
+	if config.RegexFile != "" {
+		data, err := os.ReadFile(config.RegexFile)
+		if err != nil {
+			return nil, err
+		}
+		var definitions uaparser.RegexDefinitions
+		if err = yaml.Unmarshal(data, &definitions); err != nil {
+			return nil, fmt.Errorf("invalid regex file: %w", err)
+		}
+		options = append(options, uaparser.WithRegexDefinitions(definitions))
+	}
+	parser, err := uaparser.New(options...)
+	if err != nil {
+		return nil, err
+	}
+	return &userAgentProcessor{config: config, parser: parser}, nil
+}
+
+func (p *userAgentProcessor) Process(source map[string]interface{}) error {
+	raw, ok := stringField(source, p.config.Field)
+	if !ok {
+		if p.config.IgnoreMissing {
+			return nil
+		}
+		return fmt.Errorf("user_agent: field %s is missing", p.config.Field)
+	}
+
+	client := p.parser.Parse(raw)
+	osVersion := joinVersion(client.Os.Major, client.Os.Minor, client.Os.Patch)
+	osFull := client.Os.Family
+	if osVersion != "" {
+		osFull += " " + osVersion
+	}
+	ua := map[string]interface{}{
+		"original":    raw,
+		"name":        client.UserAgent.Family,
+		"version":     joinVersion(client.UserAgent.Major, client.UserAgent.Minor, client.UserAgent.Patch),
+		"os.name":     client.Os.Family,
+		"os.version":  osVersion,
+		"os.full":     osFull,
+		"device.name": client.Device.Family,
+	}
+
+	properties := p.config.Properties
+	if len(properties) == 0 {
+		for property := range ua {
+			properties = append(properties, property)
+		}
+	}
+	for _, property := range properties {
+		if value, ok := ua[property]; ok && value != "" {
+			InsertFieldValue(source, splitPath(p.config.TargetField+"."+property), value)
+		}
+	}
+	return nil
+}
+
+func (p *userAgentProcessor) Close() error {
+	return nil
+}
+
+func joinVersion(parts ...string) string {
+	var version []string
+	for _, part := range parts {
+		if part == "" {
+			break
+		}
+		version = append(version, part)
+	}
+	return strings.Join(version, ".")
+}
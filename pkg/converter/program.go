@@ -0,0 +1,165 @@
+package converter
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Program is a single mapping compiled for conversion: field paths split,
+// transforms bound, templates parsed and generators prepared, so Convert
+// interprets none of the mapping per document. Rules are in the order
+// Convert applies them: passthrough, field_mapping, templates,
+// default_values and random_generate, each sorted by target field.
+type Program struct {
+	Rules []Rule
+}
+
+// Rule is one field rule of a Program.
+type Rule struct {
+	// Section is the mapping section the rule comes from.
+	Section string
+	// Target is the dotted output field the rule writes.
+	Target string
+	// Source is the source field, field_mapping expression or template the
+	// rule reads. It is empty for default_values and random_generate.
+	Source string
+	// Name is the rule as rule stats report it, e.g. field_mapping[name].
+	Name string
+
+	target   []string
+	observed string
+	from     fieldSource
+	tmpl     *template.Template
+	value    interface{}
+	generate func(rn *rand.Rand) interface{}
+}
+
+// Compile compiles the field rules of mapping, checking its transforms,
+// templates, generators and explode settings. Lookups and processors open
+// files and connections, so New sets them up rather than Compile. The named
+// mappings of a multi-mapping file are compiled one by one.
+func Compile(mapping FieldMapping) (*Program, error) {
+	if len(mapping.Mappings) > 0 || len(mapping.Select) > 0 {
+		return nil, fmt.Errorf("a multi-mapping file has no single program, compile its mappings instead")
+	}
+	if err := checkExplode(mapping.Explode); err != nil {
+		return nil, fmt.Errorf("explode: %w", err)
+	}
+	p := &Program{}
+	add := func(section, target, source string) *Rule {
+		p.Rules = append(p.Rules, Rule{
+			Section: section,
+			Target:  target,
+			Source:  source,
+			Name:    RuleName(section, target),
+			target:  splitPath(target),
+		})
+		rule := &p.Rules[len(p.Rules)-1]
+		rule.observed = rule.Name
+		return rule
+	}
+
+	for _, field := range mapping.Passthrough {
+		rule := add("passthrough", field, field)
+		rule.from = fieldSource{path: rule.target}
+	}
+	for _, newField := range sortedKeys(mapping.FieldMapping) {
+		oldField := mapping.FieldMapping[newField]
+		source, err := parseFieldSource(oldField)
+		if err != nil {
+			return nil, fmt.Errorf("field_mapping %s: %w", newField, err)
+		}
+		rule := add("field_mapping", newField, oldField)
+		rule.from = source
+		rule.observed += " from " + oldField
+	}
+	for _, target := range sortedKeys(mapping.Templates) {
+		tmpl, err := parseComputed(target, mapping.Templates[target])
+		if err != nil {
+			return nil, fmt.Errorf("templates %s: %w", target, err)
+		}
+		add("templates", target, mapping.Templates[target]).tmpl = tmpl
+	}
+	for _, key := range sortedKeys(mapping.DefaultValues) {
+		add("default_values", key, "").value = mapping.DefaultValues[key]
+	}
+	for _, key := range sortedKeys(mapping.RandomGenerate) {
+		generate, err := prepareGenerator(mapping.RandomGenerate[key])
+		if err != nil {
+			return nil, fmt.Errorf("random_generate %s: %w", key, err)
+		}
+		add("random_generate", key, "").generate = generate
+	}
+	return p, nil
+}
+
+// eval returns the value the rule writes for source, or false when it
+// writes none.
+func (r *Rule) eval(source map[string]interface{}, rn *rand.Rand) (interface{}, bool, error) {
+	switch {
+	case r.tmpl != nil:
+		var out strings.Builder
+		if err := r.tmpl.Execute(&out, source); err != nil {
+			return nil, false, fmt.Errorf("templates %s: %w", r.Target, err)
+		}
+		return out.String(), true, nil
+	case r.generate != nil:
+		return r.generate(rn), true, nil
+	case r.Section == "default_values":
+		return r.value, true, nil
+	}
+	value, err := r.from.read(source)
+	if err != nil {
+		return nil, false, fmt.Errorf("field_mapping %s: %w", r.Target, err)
+	}
+	if value == nil {
+		return nil, false, nil
+	}
+	if value, err = r.from.apply(value); err != nil {
+		return nil, false, fmt.Errorf("field_mapping %s: %w", r.Target, err)
+	}
+	return value, true, nil
+}
+
+// prepareGenerator checks a random_generate config and resolves what does
+// not change between documents, such as the locale or text vocabulary.
+func prepareGenerator(config map[string]interface{}) (func(rn *rand.Rand) interface{}, error) {
+	if err := checkGenerator(config); err != nil {
+		return nil, err
+	}
+	typ, _ := config["type"].(string)
+	switch {
+	case typ == "text":
+		minWords, maxWords, paragraphs, words, err := textConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		return func(rn *rand.Rand) interface{} {
+			return writeText(rn, minWords, maxWords, paragraphs, words)
+		}, nil
+	case fakerTypes[typ]:
+		locale, _ := config["locale"].(string)
+		fakerLocale, err := findLocale(locale)
+		if err != nil {
+			return nil, err
+		}
+		return func(rn *rand.Rand) interface{} {
+			return fakeValue(rn, typ, fakerLocale)
+		}, nil
+	}
+	return func(rn *rand.Rand) interface{} {
+		return generateRandomValue(rn, config)
+	}, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,59 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	program, err := Compile(parseMapping(t, `{
+		"passthrough": ["name"],
+		"field_mapping": {"tag": "label | redact", "profile.age": "age"},
+		"templates": {"greeting": "Hello {{.name}}"},
+		"default_values": {"active": true},
+		"random_generate": {"score": {"type": "integer", "min": 7, "max": 7}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, sources []string
+	for _, rule := range program.Rules {
+		names = append(names, rule.Name)
+		sources = append(sources, rule.Source)
+	}
+	wantNames := []string{
+		RuleName("passthrough", "name"),
+		RuleName("field_mapping", "profile.age"),
+		RuleName("field_mapping", "tag"),
+		RuleName("templates", "greeting"),
+		RuleName("default_values", "active"),
+		RuleName("random_generate", "score"),
+	}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("rules = %v, want %v", names, wantNames)
+	}
+	wantSources := []string{"name", "age", "label | redact", "Hello {{.name}}", "", ""}
+	if !reflect.DeepEqual(sources, wantSources) {
+		t.Errorf("sources = %q, want %q", sources, wantSources)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, mapping, want string
+	}{
+		{"unknown transform", `{"field_mapping": {"a": "b | nope", "z": "y"}}`, "field_mapping a"},
+		{"bad template", `{"templates": {"a": "{{.b"}}`, "templates a"},
+		{"bad generator", `{"random_generate": {"a": {"type": "integer", "min": 5, "max": 1}}}`, "random_generate a"},
+		{"multi-mapping", `{"mappings": {"a": {"passthrough": ["x"]}}}`, "multi-mapping"},
+		{"explode", `{"explode": {"as": "x"}}`, "explode"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Compile(parseMapping(t, tc.mapping))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Compile() error = %v, want one mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
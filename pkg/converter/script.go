@@ -0,0 +1,210 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// defaultScriptTimeout is the time budget of a script for one document.
+const defaultScriptTimeout = time.Second
+
+// documentScript is a compiled mapping hook. run applies it to a _source in
+// place and reports whether the document is kept. Hooks see the document
+// as doc and may change or replace it; calling drop() removes the document
+// from the output:
+//
+//	"after_map": "doc.total = doc.price * doc.qty; delete doc.tmp; if (!doc.total) drop();"
+//
+// before_map runs on the input document ahead of the rules, after_map (or
+// its older name script) on the output document after them. Lookup keys
+// are prefetched before before_map runs. script_language picks javascript
+// (the default) or lua for both.
+//
+// A run is interrupted once it exceeds script_timeout, or with
+// script_memory_mb once the heap grew by more than that while it ran. The
+// heap is sampled every millisecond, so the memory budget is approximate.
+type documentScript interface {
+	run(source map[string]interface{}) (bool, error)
+	close()
+}
+
+// openScripts compiles the before_map and after_map hooks of mapping; a
+// hook that is not set is nil.
+func openScripts(mapping FieldMapping) (before, after documentScript, err error) {
+	budget, err := newScriptBudget(mapping)
+	if err != nil {
+		return nil, nil, err
+	}
+	open := func(name, source string) (documentScript, error) {
+		if source == "" {
+			return nil, nil
+		}
+		var script documentScript
+		switch mapping.ScriptLanguage {
+		case "", "javascript":
+			script, err = newJSScript(name, source, budget)
+		case "lua":
+			script, err = newLuaScript(name, source, budget)
+		default:
+			return nil, fmt.Errorf("unknown script_language %q", mapping.ScriptLanguage)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return script, nil
+	}
+	if before, err = open("before_map", mapping.BeforeMap); err != nil {
+		return nil, nil, err
+	}
+	if after, err = open("after_map", mapping.AfterMapScript()); err != nil && before != nil {
+		before.close()
+	}
+	return before, after, err
+}
+
+// AfterMapScript returns the after_map hook, which may also be given as
+// script.
+func (m FieldMapping) AfterMapScript() string {
+	if m.AfterMap != "" {
+		return m.AfterMap
+	}
+	return m.Script
+}
+
+type scriptBudget struct {
+	timeout time.Duration
+	memory  uint64
+}
+
+func newScriptBudget(mapping FieldMapping) (scriptBudget, error) {
+	budget := scriptBudget{timeout: defaultScriptTimeout, memory: uint64(mapping.ScriptMemoryMB) << 20}
+	if mapping.ScriptTimeout != "" {
+		timeout, err := time.ParseDuration(mapping.ScriptTimeout)
+		if err != nil || timeout <= 0 {
+			return budget, fmt.Errorf("script_timeout %q is not a positive duration", mapping.ScriptTimeout)
+		}
+		budget.timeout = timeout
+	}
+	if mapping.ScriptMemoryMB < 0 {
+		return budget, fmt.Errorf("script_memory_mb must not be negative")
+	}
+	return budget, nil
+}
+
+// watch calls interrupt once a run exceeds the budget, until done is
+// closed.
+func (b scriptBudget) watch(done <-chan struct{}, stopped chan<- struct{}, interrupt func(reason string)) {
+	defer close(stopped)
+	timer := time.NewTimer(b.timeout)
+	defer timer.Stop()
+	var (
+		tick   <-chan time.Time
+		sample = []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+		start  uint64
+	)
+	if b.memory > 0 {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		tick = ticker.C
+		metrics.Read(sample)
+		start = sample[0].Value.Uint64()
+	}
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+			interrupt(fmt.Sprintf("exceeded its %s time budget", b.timeout))
+			return
+		case <-tick:
+			metrics.Read(sample)
+			if used := sample[0].Value.Uint64(); used > start && used-start > b.memory {
+				interrupt(fmt.Sprintf("exceeded its %d MB memory budget", b.memory>>20))
+				return
+			}
+		}
+	}
+}
+
+// limit runs fn under the budget; interrupt is how the budget stops it.
+func (b scriptBudget) limit(fn func() error, interrupt func(reason string)) error {
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go b.watch(done, stopped, interrupt)
+	err := fn()
+	close(done)
+	<-stopped
+	return err
+}
+
+// jsScript runs a hook with goja.
+type jsScript struct {
+	name    string
+	vm      *goja.Runtime
+	program *goja.Program
+	parse   goja.Callable
+	budget  scriptBudget
+	dropped bool
+}
+
+func newJSScript(name, source string, budget scriptBudget) (*jsScript, error) {
+	program, err := goja.Compile(name, source, false)
+	if err != nil {
+		return nil, err
+	}
+	s := &jsScript{name: name, program: program, budget: budget, vm: goja.New()}
+	s.vm.SetMaxCallStackSize(1024)
+	s.vm.Set("drop", func() { s.dropped = true })
+	s.parse, _ = goja.AssertFunction(s.vm.Get("JSON").ToObject(s.vm).Get("parse"))
+	return s, nil
+}
+
+func (s *jsScript) run(source map[string]interface{}) (bool, error) {
+	// The document goes in as a plain JS object rather than a wrapped Go
+	// map, so arrays can grow and doc can be reassigned.
+	data, err := json.Marshal(source)
+	if err != nil {
+		return false, err
+	}
+	doc, err := s.parse(goja.Undefined(), s.vm.ToValue(string(data)))
+	if err != nil {
+		return false, err
+	}
+	s.vm.Set("doc", doc)
+	s.dropped = false
+
+	err = s.budget.limit(func() error {
+		_, err := s.vm.RunProgram(s.program)
+		return err
+	}, func(reason string) { s.vm.Interrupt(reason) })
+	s.vm.ClearInterrupt()
+	if err != nil {
+		var interrupted *goja.InterruptedError
+		if errors.As(err, &interrupted) {
+			return false, fmt.Errorf("%s %v", s.name, interrupted.Value())
+		}
+		return false, fmt.Errorf("%s: %w", s.name, err)
+	}
+	if s.dropped {
+		return false, nil
+	}
+	out, ok := s.vm.Get("doc").Export().(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s: doc is no longer an object", s.name)
+	}
+	replaceSource(source, out)
+	return true, nil
+}
+
+func (s *jsScript) close() {}
+
+func replaceSource(source, out map[string]interface{}) {
+	clear(source)
+	for key, value := range out {
+		source[key] = value
+	}
+}
@@ -0,0 +1,167 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaScript runs a hook with gopher-lua. Objects become tables and arrays
+// tables marked as arrays, so they stay arrays even when emptied; JSON
+// null is the global null, since a nil value would remove the key. Like
+// JavaScript hooks, Lua hooks have no access to the host: only the base,
+// table, string and math libraries are open, without the base functions
+// that load files or modules.
+type luaScript struct {
+	name    string
+	state   *lua.LState
+	chunk   *lua.LFunction
+	budget  scriptBudget
+	array   *lua.LTable
+	null    *lua.LUserData
+	dropped bool
+}
+
+func newLuaScript(name, source string, budget scriptBudget) (*luaScript, error) {
+	state := lua.NewState(lua.Options{SkipOpenLibs: true, CallStackSize: 1024})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "require", "module"} {
+		state.SetGlobal(name, lua.LNil)
+	}
+	chunk, err := state.Load(strings.NewReader(source), name)
+	if err != nil {
+		state.Close()
+		return nil, err
+	}
+	s := &luaScript{name: name, state: state, chunk: chunk, budget: budget, array: state.NewTable(), null: state.NewUserData()}
+	state.SetGlobal("null", s.null)
+	state.SetGlobal("drop", state.NewFunction(func(*lua.LState) int {
+		s.dropped = true
+		return 0
+	}))
+	return s, nil
+}
+
+func (s *luaScript) run(source map[string]interface{}) (bool, error) {
+	s.state.SetGlobal("doc", s.toLua(source))
+	s.dropped = false
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	s.state.SetContext(ctx)
+	err := s.budget.limit(func() error {
+		s.state.Push(s.chunk)
+		return s.state.PCall(0, 0, nil)
+	}, func(reason string) { cancel(fmt.Errorf("%s", reason)) })
+	s.state.RemoveContext()
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil && ctx.Err() != nil {
+			return false, fmt.Errorf("%s %v", s.name, cause)
+		}
+		return false, fmt.Errorf("%s: %w", s.name, err)
+	}
+	if s.dropped {
+		return false, nil
+	}
+	out, ok := s.fromLua(s.state.GetGlobal("doc")).(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s: doc is no longer a table with string keys", s.name)
+	}
+	replaceSource(source, out)
+	return true, nil
+}
+
+func (s *luaScript) close() {
+	s.state.Close()
+}
+
+func (s *luaScript) toLua(value interface{}) lua.LValue {
+	switch typed := value.(type) {
+	case nil:
+		return s.null
+	case bool:
+		return lua.LBool(typed)
+	case string:
+		return lua.LString(typed)
+	case float64:
+		return lua.LNumber(typed)
+	case int:
+		return lua.LNumber(typed)
+	case int64:
+		return lua.LNumber(typed)
+	case json.Number:
+		f, _ := typed.Float64()
+		return lua.LNumber(f)
+	case map[string]interface{}:
+		table := s.state.CreateTable(0, len(typed))
+		for key, item := range typed {
+			table.RawSetString(key, s.toLua(item))
+		}
+		return table
+	case []interface{}:
+		table := s.state.CreateTable(len(typed), 0)
+		for _, item := range typed {
+			table.Append(s.toLua(item))
+		}
+		s.state.SetMetatable(table, s.array)
+		return table
+	default:
+		if value == NullValue {
+			return s.null
+		}
+		return lua.LString(fmt.Sprint(typed))
+	}
+}
+
+func (s *luaScript) fromLua(value lua.LValue) interface{} {
+	switch typed := value.(type) {
+	case lua.LBool:
+		return bool(typed)
+	case lua.LString:
+		return string(typed)
+	case lua.LNumber:
+		n := float64(typed)
+		if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+			return int64(n)
+		}
+		return n
+	case *lua.LTable:
+		if s.state.GetMetatable(typed) == s.array || (typed.Len() > 0 && countKeys(typed) == typed.Len()) {
+			out := make([]interface{}, 0, typed.Len())
+			for i := 1; i <= typed.Len(); i++ {
+				out = append(out, s.fromLua(typed.RawGetInt(i)))
+			}
+			return out
+		}
+		out := map[string]interface{}{}
+		typed.ForEach(func(key, item lua.LValue) {
+			if key, ok := key.(lua.LString); ok {
+				out[string(key)] = s.fromLua(item)
+			}
+		})
+		return out
+	}
+	return nil
+}
+
+func countKeys(table *lua.LTable) int {
+	n := 0
+	table.ForEach(func(lua.LValue, lua.LValue) { n++ })
+	return n
+}
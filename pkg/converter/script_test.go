@@ -0,0 +1,79 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConvertScripts(t *testing.T) {
+	tests := []struct {
+		language, before, after string
+	}{
+		{"javascript",
+			`doc.qty = doc.qty * 2; if (doc.skip) drop();`,
+			`doc.total = doc.price * doc.qty; delete doc.price; doc.tags.push("x");`},
+		{"lua",
+			`doc.qty = doc.qty * 2; if doc.skip then drop() end`,
+			`doc.total = doc.price * doc.qty; doc.price = nil; table.insert(doc.tags, string.upper("x"):lower())`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			mapping := map[string]interface{}{
+				"passthrough":     []string{"price", "qty", "tags"},
+				"script_language": tt.language,
+				"before_map":      tt.before,
+				"after_map":       tt.after,
+			}
+			data, _ := json.Marshal(mapping)
+			c, err := New(parseMapping(t, string(data)), Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			got, err := c.Convert(parseDoc(t, `{"_id":"1","_source":{"price":2.5,"qty":2,"tags":[]}}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out, _ := json.Marshal(got.Source); string(out) != `{"qty":4,"tags":["x"],"total":10}` {
+				t.Errorf("Convert() = %s", out)
+			}
+
+			if _, err = c.Convert(parseDoc(t, `{"_id":"2","_source":{"price":1,"qty":1,"tags":[],"skip":true}}`)); !errors.Is(err, ErrDropped) {
+				t.Errorf("Convert() of a dropped doc: %v, want ErrDropped", err)
+			}
+		})
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	for language, loop := range map[string]string{"javascript": "for (;;) {}", "lua": "while true do end"} {
+		c, err := New(parseMapping(t, `{"script_language": "`+language+`", "after_map": "`+loop+`", "script_timeout": "50ms"}`), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = c.Convert(parseDoc(t, `{"_id":"1","_source":{}}`)); err == nil || !strings.Contains(err.Error(), "time budget") {
+			t.Errorf("%s: Convert() of an endless loop: %v", language, err)
+		}
+		c.Close()
+	}
+}
+
+func TestLuaScriptHasNoHostAccess(t *testing.T) {
+	for _, global := range []string{"io", "os", "package", "debug", "dofile", "loadfile", "require", "module"} {
+		c, err := New(parseMapping(t, `{"script_language": "lua", "after_map": "doc.present = `+global+` ~= nil"}`), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Convert(parseDoc(t, `{"_id":"1","_source":{}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Source["present"] != false {
+			t.Errorf("%s is available to Lua hooks", global)
+		}
+		c.Close()
+	}
+}
@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Selector picks the named mapping a document is converted with. Field is
+// _index, _type, _id or a dotted source path. With Equals or Matches the
+// field's value must equal the string or match the regular expression;
+// with neither the field only has to be present. A selector without a
+// field matches every document, for use as a final fallback.
+type Selector struct {
+	Field   string `json:"field,omitempty"`
+	Equals  string `json:"equals,omitempty"`
+	Matches string `json:"matches,omitempty"`
+	Mapping string `json:"mapping"`
+}
+
+// Variants returns the named mappings of a multi-mapping file in name
+// order, or m itself for a plain mapping.
+func (m FieldMapping) Variants() []FieldMapping {
+	if len(m.Mappings) == 0 {
+		return []FieldMapping{m}
+	}
+	names := make([]string, 0, len(m.Mappings))
+	for name := range m.Mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	variants := make([]FieldMapping, len(names))
+	for i, name := range names {
+		variants[i] = m.Mappings[name]
+	}
+	return variants
+}
+
+type selector struct {
+	Selector
+	pattern *regexp.Regexp
+	conv    *Converter
+}
+
+// variants converts documents with one of several named mappings.
+type variants struct {
+	names     []string
+	named     map[string]*Converter
+	selectors []selector
+}
+
+func openVariants(mapping FieldMapping, opts Options, notify func(rule string, source map[string]interface{})) (*variants, error) {
+	if mapping.Index != nil || len(mapping.FieldMapping) > 0 || len(mapping.DefaultValues) > 0 ||
+		len(mapping.RandomGenerate) > 0 || len(mapping.File) > 0 || len(mapping.Exclude) > 0 ||
+		len(mapping.Passthrough) > 0 || len(mapping.Lookups) > 0 || len(mapping.Processors) > 0 ||
+		len(mapping.Types) > 0 {
+		return nil, fmt.Errorf("a mapping with named mappings cannot define rules of its own")
+	}
+	v := &variants{named: map[string]*Converter{}}
+	for name := range mapping.Mappings {
+		v.names = append(v.names, name)
+	}
+	sort.Strings(v.names)
+	for _, name := range v.names {
+		sub := mapping.Mappings[name]
+		if len(sub.Mappings) > 0 || len(sub.Select) > 0 {
+			v.Close()
+			return nil, fmt.Errorf("mapping %s: named mappings cannot be nested", name)
+		}
+		subOpts := opts
+		subOpts.OutputFile = opts.OutputFile + "." + name
+		conv, err := New(sub, subOpts)
+		if err != nil {
+			v.Close()
+			return nil, fmt.Errorf("mapping %s: %w", name, err)
+		}
+		prefix := name + "/"
+		conv.Observe = func(rule string, source map[string]interface{}) {
+			notify(prefix+rule, source)
+		}
+		v.named[name] = conv
+	}
+
+	for i, config := range mapping.Select {
+		s := selector{Selector: config, conv: v.named[config.Mapping]}
+		if s.conv == nil {
+			v.Close()
+			return nil, fmt.Errorf("select %d: unknown mapping %q", i, config.Mapping)
+		}
+		if config.Matches != "" {
+			var err error
+			if s.pattern, err = regexp.Compile(config.Matches); err != nil {
+				v.Close()
+				return nil, fmt.Errorf("select %d: %w", i, err)
+			}
+		}
+		v.selectors = append(v.selectors, s)
+	}
+	return v, nil
+}
+
+// choose returns the converter for doc: that of the first matching
+// selector or, without selectors, the mapping named after its _index.
+func (v *variants) choose(doc ESDoc) (*Converter, error) {
+	if len(v.selectors) == 0 {
+		if doc.Index != nil && v.named[*doc.Index] != nil {
+			return v.named[*doc.Index], nil
+		}
+		return nil, fmt.Errorf("no mapping named after index %q", deref(doc.Index))
+	}
+	for _, s := range v.selectors {
+		if s.matches(doc) {
+			return s.conv, nil
+		}
+	}
+	return nil, fmt.Errorf("no selector matches the document")
+}
+
+func (s selector) matches(doc ESDoc) bool {
+	if s.Field == "" {
+		return true
+	}
+	var value interface{}
+	switch s.Field {
+	case "_index":
+		value = derefValue(doc.Index)
+	case "_type":
+		value = derefValue(doc.Type)
+	case "_id":
+		value = derefValue(doc.ID)
+	default:
+		value = ExtractFieldValue(doc.Source, splitPath(s.Field))
+	}
+	if value == nil {
+		return false
+	}
+	text := fmt.Sprint(value)
+	switch {
+	case s.pattern != nil:
+		return s.pattern.MatchString(text)
+	case s.Equals != "":
+		return text == s.Equals
+	default:
+		return true
+	}
+}
+
+func (v *variants) prefetch(docs []ESDoc) error {
+	groups := map[*Converter][]ESDoc{}
+	for _, doc := range docs {
+		if conv, err := v.choose(doc); err == nil {
+			groups[conv] = append(groups[conv], doc)
+		}
+	}
+	for _, name := range v.names {
+		if group := groups[v.named[name]]; len(group) > 0 {
+			if err := v.named[name].Prefetch(group); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *variants) each(fn func(name string, conv *Converter)) {
+	for _, name := range v.names {
+		if conv := v.named[name]; conv != nil {
+			fn(name, conv)
+		}
+	}
+}
+
+func (v *variants) Close() error {
+	var firstErr error
+	v.each(func(_ string, conv *Converter) {
+		if err := conv.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	})
+	return firstErr
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefValue(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
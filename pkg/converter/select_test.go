@@ -0,0 +1,71 @@
+package converter
+
+import "testing"
+
+func TestConvertSelectsMapping(t *testing.T) {
+	c, err := New(parseMapping(t, `{
+		"mappings": {
+			"orders": {"index": "orders-v2", "field_mapping": {"total": "amount"}},
+			"users": {"index": "users-v2", "field_mapping": {"name": "login"}},
+			"other": {"index": "other-v2"}
+		},
+		"select": [
+			{"field": "kind", "equals": "order", "mapping": "orders"},
+			{"field": "_id", "matches": "^u-", "mapping": "users"},
+			{"mapping": "other"}
+		]
+	}`), Options{OutputFile: "out.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		doc, index, field string
+	}{
+		{`{"_id":"1","_source":{"kind":"order","amount":5}}`, "orders-v2", "total"},
+		{`{"_id":"u-1","_source":{"login":"alice"}}`, "users-v2", "name"},
+		{`{"_id":"x","_source":{"kind":"refund","amount":5}}`, "other-v2", ""},
+	}
+	for _, tt := range tests {
+		got, err := c.Convert(parseDoc(t, tt.doc))
+		if err != nil {
+			t.Fatalf("Convert(%s): %v", tt.doc, err)
+		}
+		if *got.Index != tt.index {
+			t.Errorf("Convert(%s) index = %s, want %s", tt.doc, *got.Index, tt.index)
+		}
+		if _, ok := got.Source[tt.field]; tt.field != "" && !ok {
+			t.Errorf("Convert(%s) = %v, want field %s", tt.doc, got.Source, tt.field)
+		}
+	}
+}
+
+func TestConvertSelectsMappingByIndex(t *testing.T) {
+	c, err := New(parseMapping(t, `{"mappings": {"a": {"index": "a-v2"}, "b": {"index": "b-v2"}}}`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if got, err := c.Convert(parseDoc(t, `{"_index":"b","_id":"1","_source":{}}`)); err != nil || *got.Index != "b-v2" {
+		t.Errorf("Convert(_index b) = %v, %v", got.Index, err)
+	}
+	if _, err := c.Convert(parseDoc(t, `{"_index":"c","_id":"1","_source":{}}`)); err == nil {
+		t.Error("Convert(_index c) found a mapping")
+	}
+}
+
+func TestNewRejectsInvalidVariants(t *testing.T) {
+	tests := map[string]string{
+		"own rules":       `{"index": "x", "mappings": {"a": {}}}`,
+		"nested":          `{"mappings": {"a": {"mappings": {"b": {}}}}}`,
+		"unknown mapping": `{"mappings": {"a": {}}, "select": [{"mapping": "b"}]}`,
+		"bad pattern":     `{"mappings": {"a": {}}, "select": [{"field": "_id", "matches": "(", "mapping": "a"}]}`,
+	}
+	for name, mapping := range tests {
+		if _, err := New(parseMapping(t, mapping), Options{}); err == nil {
+			t.Errorf("%s: New() accepted %s", name, mapping)
+		}
+	}
+}
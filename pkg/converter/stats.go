@@ -0,0 +1,103 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ruleStats counts how many documents each mapping rule affected.
+type ruleStats struct {
+	rules  []string
+	counts map[string]int
+}
+
+func newRuleStats(mapping FieldMapping) *ruleStats {
+	s := &ruleStats{counts: map[string]int{}}
+	for _, field := range mapping.Passthrough {
+		s.add("passthrough", field)
+	}
+	for newField := range mapping.FieldMapping {
+		s.add("field_mapping", newField)
+	}
+	for target := range mapping.Templates {
+		s.add("templates", target)
+	}
+	for key := range mapping.DefaultValues {
+		s.add("default_values", key)
+	}
+	for key := range mapping.RandomGenerate {
+		s.add("random_generate", key)
+	}
+	sort.Strings(s.rules)
+	return s
+}
+
+func (s *ruleStats) add(section, field string) {
+	rule := RuleName(section, field)
+	if _, ok := s.counts[rule]; !ok {
+		s.rules = append(s.rules, rule)
+		s.counts[rule] = 0
+	}
+}
+
+func (s *ruleStats) hit(rule string) {
+	s.counts[rule]++
+}
+
+// RuleName formats the name rules are reported under, e.g.
+// field_mapping[name].
+func RuleName(section, field string) string {
+	return fmt.Sprintf("%s[%s]", section, field)
+}
+
+// RuleCount is how many documents a mapping rule affected.
+type RuleCount struct {
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+}
+
+// LookupStats counts the hits and misses of one lookup.
+type LookupStats struct {
+	Name   string `json:"name"`
+	OnMiss string `json:"on_miss"`
+	Hits   int    `json:"hits"`
+	Misses int    `json:"misses"`
+}
+
+// Stats summarizes what a Converter did so far. Rules lists every mapping
+// rule followed by one lookup[name] rule per lookup, counting its hits.
+type Stats struct {
+	Rules   []RuleCount   `json:"rules"`
+	Lookups []LookupStats `json:"lookups"`
+}
+
+func (c *Converter) Stats() Stats {
+	var stats Stats
+	if c.variants != nil {
+		c.variants.each(func(name string, conv *Converter) {
+			sub := conv.Stats()
+			for _, rule := range sub.Rules {
+				rule.Rule = name + "/" + rule.Rule
+				stats.Rules = append(stats.Rules, rule)
+			}
+			for _, lookup := range sub.Lookups {
+				lookup.Name = name + "/" + lookup.Name
+				stats.Lookups = append(stats.Lookups, lookup)
+			}
+		})
+		return stats
+	}
+	for _, rule := range c.stats.rules {
+		stats.Rules = append(stats.Rules, RuleCount{Rule: rule, Count: c.stats.counts[rule]})
+	}
+	for _, lookup := range c.lookups {
+		stats.Rules = append(stats.Rules, RuleCount{Rule: RuleName("lookup", lookup.Name), Count: lookup.hits})
+		stats.Lookups = append(stats.Lookups, LookupStats{
+			Name:   lookup.Name,
+			OnMiss: lookup.OnMiss,
+			Hits:   lookup.hits,
+			Misses: lookup.misses,
+		})
+	}
+	return stats
+}
@@ -0,0 +1,117 @@
+package converter
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+)
+
+// loremWords is the default vocabulary of the text generator.
+var loremWords = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod
+	tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation
+	ullamco laboris nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit
+	esse cillum fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui
+	officia deserunt mollit anim id est laborum`)
+
+// vocabularies caches the word lists of vocabulary files by path.
+var vocabularies sync.Map
+
+// loadVocabulary returns the whitespace separated words of a file.
+func loadVocabulary(path string) ([]string, error) {
+	if words, ok := vocabularies.Load(path); ok {
+		return words.([]string), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(string(data))
+	if len(words) == 0 {
+		return nil, fmt.Errorf("vocabulary %s is empty", path)
+	}
+	vocabularies.Store(path, words)
+	return words, nil
+}
+
+// textConfig reads the settings of a text generator:
+//
+//	{"type": "text", "min_words": 5, "max_words": 30, "paragraphs": 2,
+//	 "vocabulary": "./data/words.txt"}
+//
+// Each paragraph holds between min_words and max_words words, in sentences
+// of four to twelve words.
+func textConfig(config map[string]interface{}) (minWords, maxWords, paragraphs int, words []string, err error) {
+	number := func(key string, def int) (int, error) {
+		value, ok := config[key]
+		if !ok {
+			return def, nil
+		}
+		n, ok := value.(float64)
+		if !ok || n != float64(int(n)) || n < 1 {
+			return 0, fmt.Errorf("%s must be a positive integer", key)
+		}
+		return int(n), nil
+	}
+	if minWords, err = number("min_words", 8); err != nil {
+		return
+	}
+	if maxWords, err = number("max_words", max(minWords, 20)); err != nil {
+		return
+	}
+	if minWords > maxWords {
+		err = fmt.Errorf("min_words %d is greater than max_words %d", minWords, maxWords)
+		return
+	}
+	if paragraphs, err = number("paragraphs", 1); err != nil {
+		return
+	}
+	words = loremWords
+	if path, ok := config["vocabulary"]; ok {
+		file, isString := path.(string)
+		if !isString {
+			err = fmt.Errorf("vocabulary must be a file path")
+			return
+		}
+		words, err = loadVocabulary(file)
+	}
+	return
+}
+
+func generateText(rn *rand.Rand, config map[string]interface{}) interface{} {
+	minWords, maxWords, paragraphs, words, err := textConfig(config)
+	if err != nil {
+		return nil
+	}
+	return writeText(rn, minWords, maxWords, paragraphs, words)
+}
+
+func writeText(rn *rand.Rand, minWords, maxWords, paragraphs int, words []string) string {
+	texts := make([]string, paragraphs)
+	for p := range texts {
+		var text strings.Builder
+		count := minWords + rn.Intn(maxWords-minWords+1)
+		sentence := 0
+		for i := 0; i < count; i++ {
+			word := words[rn.Intn(len(words))]
+			if sentence == 0 {
+				if text.Len() > 0 {
+					text.WriteByte(' ')
+				}
+				word = strings.ToUpper(word[:1]) + word[1:]
+				sentence = 4 + rn.Intn(9)
+			} else {
+				text.WriteByte(' ')
+			}
+			text.WriteString(word)
+			sentence--
+			if sentence == 0 || i == count-1 {
+				text.WriteByte('.')
+				sentence = 0
+			}
+		}
+		texts[p] = text.String()
+	}
+	return strings.Join(texts, "\n\n")
+}
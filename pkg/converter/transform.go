@@ -0,0 +1,250 @@
+package converter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Transform rewrites a mapped field value. It is not called for absent or
+// null fields.
+type Transform func(value interface{}) (interface{}, error)
+
+// transformFactory builds a transform from the arguments it was given in
+// the mapping.
+type transformFactory func(args transformArgs) (Transform, error)
+
+// transforms holds the transforms field_mapping sources can pipe through,
+// by name.
+var transforms = map[string]transformFactory{}
+
+func registerTransform(name string, factory transformFactory) {
+	transforms[name] = factory
+}
+
+// transformArgs are the arguments of one transform call, e.g.
+// hash(sha256, salt="pepper").
+type transformArgs struct {
+	name       string
+	positional []string
+	named      map[string]string
+}
+
+// get returns the named argument key, else the positional argument i, else
+// def. An i below zero only looks for the named argument.
+func (a transformArgs) get(i int, key, def string) string {
+	if value, ok := a.named[key]; ok {
+		return value
+	}
+	if i >= 0 && i < len(a.positional) {
+		return a.positional[i]
+	}
+	return def
+}
+
+func (a transformArgs) int(i int, key string, def int) (int, error) {
+	value := a.get(i, key, "")
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s must be an integer", a.name, key)
+	}
+	return n, nil
+}
+
+// fieldSource is a parsed field_mapping source: the path to read, or the
+// combiner call to evaluate, and the transforms to pipe the value through,
+// as in "email | mask_email".
+type fieldSource struct {
+	path       []string
+	combined   *combinedSource
+	transforms []Transform
+}
+
+// SourcePaths returns the source field paths of a field_mapping value,
+// without its transforms: the path, or the fields of a combiner call.
+func SourcePaths(spec string) []string {
+	parts, err := splitOutside(spec, '|')
+	if err != nil {
+		parts = []string{spec}
+	}
+	path := strings.TrimSpace(parts[0])
+	if combined, ok, err := parseCombined(path); ok && err == nil {
+		return combined.fields()
+	}
+	return []string{path}
+}
+
+func parseFieldSource(spec string) (fieldSource, error) {
+	parts, err := splitOutside(spec, '|')
+	if err != nil {
+		return fieldSource{}, err
+	}
+	source := fieldSource{}
+	combined, ok, err := parseCombined(strings.TrimSpace(parts[0]))
+	switch {
+	case err != nil:
+		return fieldSource{}, err
+	case ok:
+		source.combined = combined
+	default:
+		source.path = strings.Split(strings.TrimSpace(parts[0]), ".")
+	}
+	for _, part := range parts[1:] {
+		args, err := parseTransformCall(strings.TrimSpace(part))
+		if err != nil {
+			return fieldSource{}, err
+		}
+		factory, ok := transforms[args.name]
+		if !ok {
+			return fieldSource{}, fmt.Errorf("unknown transform %q (known: %s)", args.name, strings.Join(transformNames(), ", "))
+		}
+		transform, err := factory(args)
+		if err != nil {
+			return fieldSource{}, fmt.Errorf("%s: %w", args.name, err)
+		}
+		source.transforms = append(source.transforms, transform)
+	}
+	return source, nil
+}
+
+func transformNames() []string {
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseTransformCall parses name or name(arg, key=arg, ...). Arguments may
+// be double quoted to contain commas, parentheses or spaces.
+func parseTransformCall(call string) (transformArgs, error) {
+	args := transformArgs{named: map[string]string{}}
+	open := strings.IndexByte(call, '(')
+	if open < 0 {
+		args.name = call
+		if args.name == "" {
+			return args, fmt.Errorf("empty transform")
+		}
+		return args, nil
+	}
+	if !strings.HasSuffix(call, ")") {
+		return args, fmt.Errorf("transform %q: missing closing parenthesis", call)
+	}
+	args.name = strings.TrimSpace(call[:open])
+	inner := strings.TrimSpace(call[open+1 : len(call)-1])
+	if inner == "" {
+		return args, nil
+	}
+	items, err := splitOutside(inner, ',')
+	if err != nil {
+		return args, err
+	}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		key, value, named := "", item, false
+		if eq := strings.IndexByte(item, '='); eq > 0 && !strings.HasPrefix(item, `"`) {
+			key, value, named = strings.TrimSpace(item[:eq]), strings.TrimSpace(item[eq+1:]), true
+		}
+		if strings.HasPrefix(value, `"`) {
+			if value, err = strconv.Unquote(value); err != nil {
+				return args, fmt.Errorf("transform %s: invalid quoted argument %s", args.name, item)
+			}
+		}
+		if named {
+			args.named[key] = value
+		} else {
+			args.positional = append(args.positional, value)
+		}
+	}
+	return args, nil
+}
+
+// splitOutside splits s at every sep that is not inside double quotes or
+// parentheses.
+func splitOutside(s string, sep byte) ([]string, error) {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if quoted || depth != 0 {
+		return nil, fmt.Errorf("unbalanced quotes or parentheses in %q", s)
+	}
+	return append(parts, s[start:]), nil
+}
+
+// stringTransform applies fn to a string value, or to every element of an
+// array; other values are formatted as strings first.
+func stringTransform(fn func(string) (string, error)) Transform {
+	var transform Transform
+	transform = func(value interface{}) (interface{}, error) {
+		switch typed := value.(type) {
+		case string:
+			return fn(typed)
+		case []interface{}:
+			out := make([]interface{}, len(typed))
+			for i, item := range typed {
+				var err error
+				if out[i], err = transform(item); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		case map[string]interface{}:
+			return nil, fmt.Errorf("cannot transform an object")
+		default:
+			return fn(fmt.Sprint(typed))
+		}
+	}
+	return transform
+}
+
+// read returns the value of the source in source, before the transforms:
+// nil when it has none.
+func (s fieldSource) read(source map[string]interface{}) (interface{}, error) {
+	if s.combined != nil {
+		return s.combined.read(source)
+	}
+	return ExtractFieldValue(source, s.path), nil
+}
+
+func (s fieldSource) apply(value interface{}) (interface{}, error) {
+	if value == NullValue {
+		return value, nil
+	}
+	for _, transform := range s.transforms {
+		var err error
+		if value, err = transform(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+// NewTransform builds a transform from a call as written in a
+// field_mapping source, e.g. `decrypt(key_env=FIELD_KEY)`.
+func NewTransform(call string) (Transform, error) {
+	source, err := parseFieldSource("_ | " + call)
+	if err != nil {
+		return nil, err
+	}
+	return source.apply, nil
+}
@@ -0,0 +1,248 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+func init() {
+	registerTransform("array_dedupe", newArrayDedupeTransform)
+	registerTransform("array_sort", newArraySortTransform)
+	registerTransform("array_slice", newArraySliceTransform)
+	registerTransform("array_join", newArrayJoinTransform)
+	registerTransform("array_filter", newArrayFilterTransform)
+	registerTransform("split_to_array", newSplitToArrayTransform)
+	registerTransform("join_from_array", newJoinFromArrayTransform)
+}
+
+// asArray returns the elements of an array value; a single value is an
+// array of one, as it is to Elasticsearch.
+func asArray(value interface{}) []interface{} {
+	if items, ok := value.([]interface{}); ok {
+		return items
+	}
+	return []interface{}{value}
+}
+
+// newArrayDedupeTransform drops the elements equal to an earlier one,
+// keeping the order. Objects are equal when their JSON is.
+//
+//	tags | array_dedupe
+func newArrayDedupeTransform(args transformArgs) (Transform, error) {
+	return func(value interface{}) (interface{}, error) {
+		items := asArray(value)
+		seen := make(map[string]bool, len(items))
+		out := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			key, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[string(key)] {
+				seen[string(key)] = true
+				out = append(out, item)
+			}
+		}
+		return out, nil
+	}, nil
+}
+
+// newArraySortTransform sorts the elements, numbers numerically and
+// anything else by its string form, in order asc (the default) or desc.
+//
+//	tags | array_sort
+//	scores | array_sort(desc)
+func newArraySortTransform(args transformArgs) (Transform, error) {
+	order := args.get(0, "order", "asc")
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+	return func(value interface{}) (interface{}, error) {
+		out := append([]interface{}(nil), asArray(value)...)
+		sort.SliceStable(out, func(i, j int) bool {
+			if order == "desc" {
+				return compareValues(out[i], out[j]) > 0
+			}
+			return compareValues(out[i], out[j]) < 0
+		})
+		return out, nil
+	}, nil
+}
+
+// newArraySliceTransform keeps the elements from start up to, but not
+// including, end. Negative indexes count from the end, and end defaults to
+// the length, so array_slice(-3) keeps the last three elements.
+//
+//	tags | array_slice(0, 10)
+//	tags | array_slice(start=-3)
+func newArraySliceTransform(args transformArgs) (Transform, error) {
+	start, err := args.int(0, "start", 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := args.int(1, "end", 0)
+	if err != nil {
+		return nil, err
+	}
+	endSet := args.get(1, "end", "") != ""
+	return func(value interface{}) (interface{}, error) {
+		items := asArray(value)
+		from, to := sliceIndex(start, len(items)), len(items)
+		if endSet {
+			to = sliceIndex(end, len(items))
+		}
+		if from >= to {
+			return []interface{}{}, nil
+		}
+		return append([]interface{}(nil), items[from:to]...), nil
+	}, nil
+}
+
+// sliceIndex resolves an index that may count from the end into one
+// within 0 and n.
+func sliceIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return min(max(i, 0), n)
+}
+
+// newArrayJoinTransform joins the elements into a string with sep, ", "
+// by default. Objects and arrays are written as JSON; null elements are
+// skipped.
+//
+//	tags | array_join
+//	path | array_join("/")
+func newArrayJoinTransform(args transformArgs) (Transform, error) {
+	return joinTransform(args.get(0, "sep", ", ")), nil
+}
+
+func joinTransform(sep string) Transform {
+	return func(value interface{}) (interface{}, error) {
+		var parts []string
+		for _, item := range asArray(value) {
+			switch item.(type) {
+			case nil:
+				continue
+			case map[string]interface{}, []interface{}:
+				data, err := json.Marshal(item)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, string(data))
+			default:
+				parts = append(parts, fmt.Sprint(item))
+			}
+		}
+		return strings.Join(parts, sep), nil
+	}
+}
+
+// newSplitToArrayTransform splits a string such as "red, green,blue" into
+// an array on delimiter, a comma by default. The parts are trimmed of
+// spaces unless trim=false, and empty ones are dropped unless
+// keep_empty=true. An array has each of its strings split, the parts
+// flattened into one array.
+//
+//	tags | split_to_array
+//	path | split_to_array("/", keep_empty=true)
+func newSplitToArrayTransform(args transformArgs) (Transform, error) {
+	delimiter := args.get(0, "delimiter", ",")
+	if delimiter == "" {
+		return nil, fmt.Errorf("delimiter must not be empty")
+	}
+	trim, err := strconv.ParseBool(args.get(-1, "trim", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("trim must be true or false")
+	}
+	keepEmpty, err := strconv.ParseBool(args.get(-1, "keep_empty", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("keep_empty must be true or false")
+	}
+	return func(value interface{}) (interface{}, error) {
+		out := []interface{}{}
+		for _, item := range asArray(value) {
+			text, ok := item.(string)
+			if !ok {
+				if _, object := item.(map[string]interface{}); object {
+					return nil, fmt.Errorf("cannot split an object")
+				}
+				out = append(out, item)
+				continue
+			}
+			for _, part := range strings.Split(text, delimiter) {
+				if trim {
+					part = strings.TrimSpace(part)
+				}
+				if part != "" || keepEmpty {
+					out = append(out, part)
+				}
+			}
+		}
+		return out, nil
+	}, nil
+}
+
+// newJoinFromArrayTransform is split_to_array in reverse: it joins the
+// elements of an array into a string with delimiter, a comma by default,
+// like array_join.
+//
+//	tags | join_from_array
+//	tags | join_from_array(";")
+func newJoinFromArrayTransform(args transformArgs) (Transform, error) {
+	return joinTransform(args.get(0, "delimiter", ",")), nil
+}
+
+// newArrayFilterTransform keeps the elements for which expr is true. expr
+// is what goes between the braces of a templates entry, run with the
+// element as dot; the braces are left out since the mapping's vars expand
+// those in field_mapping. eq, ne, lt, le, gt and ge compare as array_sort
+// orders, so integers and decimals compare with each other.
+//
+//	tags | array_filter("ne (trim .) ``")
+//	scores | array_filter("gt . 50")
+//	users | array_filter("and .active (ne .role `bot`)")
+func newArrayFilterTransform(args transformArgs) (Transform, error) {
+	expr := args.get(0, "expr", "")
+	if strings.TrimSpace(expr) == "" {
+		return nil, fmt.Errorf("expr is required")
+	}
+	tmpl, err := template.New("array_filter").Funcs(computedFuncs).Funcs(filterFuncs).Option("missingkey=zero").Parse("{{" + expr + "}}")
+	if err != nil {
+		return nil, err
+	}
+	return func(value interface{}) (interface{}, error) {
+		return filterArray(tmpl, asArray(value))
+	}, nil
+}
+
+// filterFuncs replace the comparisons of templates, which fail on an
+// integer and a decimal.
+var filterFuncs = template.FuncMap{
+	"eq": func(a, b interface{}) bool { return compareValues(a, b) == 0 },
+	"ne": func(a, b interface{}) bool { return compareValues(a, b) != 0 },
+	"lt": func(a, b interface{}) bool { return compareValues(a, b) < 0 },
+	"le": func(a, b interface{}) bool { return compareValues(a, b) <= 0 },
+	"gt": func(a, b interface{}) bool { return compareValues(a, b) > 0 },
+	"ge": func(a, b interface{}) bool { return compareValues(a, b) >= 0 },
+}
+
+func filterArray(tmpl *template.Template, items []interface{}) ([]interface{}, error) {
+	out := make([]interface{}, 0, len(items))
+	var rendered bytes.Buffer
+	for _, item := range items {
+		rendered.Reset()
+		if err := tmpl.Execute(&rendered, item); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(rendered.String()) == "true" {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
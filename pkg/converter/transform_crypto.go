@@ -0,0 +1,82 @@
+package converter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+func init() {
+	registerTransform("encrypt", newEncryptTransform)
+	registerTransform("decrypt", newDecryptTransform)
+}
+
+// newEncryptTransform replaces the value with the base64 of a random nonce
+// followed by its JSON encoding sealed with AES-GCM, so any value, objects
+// included, decrypts back to itself. The key is the base64 of 16, 24 or 32
+// random bytes, e.g. from openssl rand -base64 32.
+//
+//	encrypt(key_env=FIELD_KEY)
+//	encrypt(key_file=/run/secrets/field-key)
+func newEncryptTransform(args transformArgs) (Transform, error) {
+	aead, err := newAEAD(args)
+	if err != nil {
+		return nil, err
+	}
+	return func(value interface{}) (interface{}, error) {
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+		if _, err = rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, nil)), nil
+	}, nil
+}
+
+// newDecryptTransform reverses encrypt given the same key.
+func newDecryptTransform(args transformArgs) (Transform, error) {
+	aead, err := newAEAD(args)
+	if err != nil {
+		return nil, err
+	}
+	return func(value interface{}) (interface{}, error) {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("encrypted value must be a string")
+		}
+		sealed, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("encrypted value is not base64: %w", err)
+		}
+		if len(sealed) < aead.NonceSize() {
+			return nil, fmt.Errorf("encrypted value is too short")
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt: %w", err)
+		}
+		return unmarshalExact(plaintext)
+	}, nil
+}
+
+func newAEAD(args transformArgs) (cipher.AEAD, error) {
+	encoded, err := loadKey(args)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key must be base64: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("key must be 16, 24 or 32 bytes: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,64 @@
+package converter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testFieldKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 bytes
+
+func TestEncryptDecrypt(t *testing.T) {
+	encrypt, err := NewTransform(`encrypt(key="` + testFieldKey + `")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypt, err := NewTransform(`decrypt(key="` + testFieldKey + `")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []interface{}{"alice", 42.5, true, map[string]interface{}{"street": "Main", "no": int64(1)}} {
+		sealed, err := encrypt(value)
+		if err != nil {
+			t.Fatalf("encrypt(%v): %v", value, err)
+		}
+		again, _ := encrypt(value)
+		if sealed == again {
+			t.Errorf("encrypt(%v) reused its nonce", value)
+		}
+		opened, err := decrypt(sealed)
+		if err != nil {
+			t.Fatalf("decrypt(encrypt(%v)): %v", value, err)
+		}
+		if !reflect.DeepEqual(opened, value) {
+			t.Errorf("decrypt(encrypt(%v)) = %v", value, opened)
+		}
+	}
+
+	sealed, _ := encrypt("alice")
+	tampered := []byte(sealed.(string))
+	if tampered[20] == 'A' {
+		tampered[20] = 'B'
+	} else {
+		tampered[20] = 'A'
+	}
+	for _, value := range []interface{}{string(tampered), "not base64!", "AAAA", 12.0} {
+		if _, err := decrypt(value); err == nil {
+			t.Errorf("decrypt(%v) succeeded", value)
+		}
+	}
+
+	otherKey, _ := NewTransform(`decrypt(key="` + strings.Repeat("A", 43) + `=")`)
+	if _, err := otherKey(sealed); err == nil {
+		t.Error("decrypt with another key succeeded")
+	}
+}
+
+func TestEncryptKeyErrors(t *testing.T) {
+	for _, call := range []string{"encrypt", `encrypt(key="not base64!")`, `encrypt(key="c2hvcnQ=")`} {
+		if _, err := NewTransform(call); err == nil {
+			t.Errorf("NewTransform(%s) succeeded", call)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package converter
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	registerTransform("decode", newDecodeTransform)
+	registerTransform("encode", newEncodeTransform)
+}
+
+// byteEncodings are the representations of binary data as text that decode
+// reads and decode and encode write.
+var byteEncodings = map[string]bool{"base64": true, "base64url": true, "hex": true}
+
+// newDecodeTransform decodes a base64 or hex payload, as message-queue
+// dumps carry them, and writes the bytes as to says: text (the default)
+// for UTF-8 text, json to parse them as JSON, numbers kept exact, or
+// base64, base64url (unpadded) or hex to re-encode them. base64 reads both
+// the standard and the URL-safe alphabet, with or without padding.
+//
+//	payload | decode(base64, to=json)
+//	digest | decode(hex, to=base64)
+func newDecodeTransform(args transformArgs) (Transform, error) {
+	from := args.get(0, "from", "")
+	if from != "base64" && from != "hex" {
+		return nil, fmt.Errorf("from must be base64 or hex")
+	}
+	to := args.get(1, "to", "text")
+	if to != "text" && to != "json" && !byteEncodings[to] {
+		return nil, fmt.Errorf("to must be text, json, base64, base64url or hex")
+	}
+	var transform Transform
+	transform = func(value interface{}) (interface{}, error) {
+		switch typed := value.(type) {
+		case []interface{}:
+			out := make([]interface{}, len(typed))
+			for i, item := range typed {
+				var err error
+				if out[i], err = transform(item); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		case string:
+			data, err := decodeBytes(from, typed)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", from, err)
+			}
+			switch to {
+			case "text":
+				if !utf8.Valid(data) {
+					return nil, fmt.Errorf("decoded bytes are not UTF-8 text; decode to base64 or hex instead")
+				}
+				return string(data), nil
+			case "json":
+				parsed, err := unmarshalExact(data)
+				if err != nil {
+					return nil, fmt.Errorf("decoded bytes are not JSON: %w", err)
+				}
+				return parsed, nil
+			}
+			return encodeBytes(to, data), nil
+		}
+		return nil, fmt.Errorf("cannot decode %T", value)
+	}
+	return transform, nil
+}
+
+// newEncodeTransform writes the UTF-8 bytes of a string as base64,
+// base64url or hex.
+//
+//	token | encode(base64url)
+func newEncodeTransform(args transformArgs) (Transform, error) {
+	to := args.get(0, "to", "")
+	if !byteEncodings[to] {
+		return nil, fmt.Errorf("to must be base64, base64url or hex")
+	}
+	return stringTransform(func(value string) (string, error) {
+		return encodeBytes(to, []byte(value)), nil
+	}), nil
+}
+
+func decodeBytes(from, text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if from == "hex" {
+		return hex.DecodeString(text)
+	}
+	text = strings.TrimRight(text, "=")
+	if strings.ContainsAny(text, "-_") {
+		return base64.RawURLEncoding.DecodeString(text)
+	}
+	return base64.RawStdEncoding.DecodeString(text)
+}
+
+func encodeBytes(to string, data []byte) string {
+	switch to {
+	case "base64url":
+		return base64.RawURLEncoding.EncodeToString(data)
+	case "hex":
+		return hex.EncodeToString(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
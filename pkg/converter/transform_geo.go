@@ -0,0 +1,659 @@
+package converter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerTransform("geo_point", newGeoPointTransform)
+	registerTransform("geo_shape", newGeoShapeTransform)
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// newGeoPointTransform rewrites a geo point in any of the forms
+// Elasticsearch accepts as the one to names: object for {"lat", "lon"} (the
+// default), string for "lat,lon", geohash, array for [lon, lat], geojson
+// for a GeoJSON point, or wkt for POINT (lon lat). A geohash becomes the
+// centre of its cell; precision is the length of the geohashes written,
+// 12 by default.
+//
+//	location | geo_point
+//	location | geo_point(to=geohash, precision=7)
+func newGeoPointTransform(args transformArgs) (Transform, error) {
+	to := args.get(0, "to", "object")
+	switch to {
+	case "object", "string", "geohash", "array", "geojson", "wkt":
+	default:
+		return nil, fmt.Errorf("to must be object, string, geohash, array, geojson or wkt")
+	}
+	precision, err := args.int(-1, "precision", 12)
+	if err != nil {
+		return nil, err
+	}
+	if precision < 1 || precision > 12 {
+		return nil, fmt.Errorf("precision must be between 1 and 12")
+	}
+	var transform Transform
+	transform = func(value interface{}) (interface{}, error) {
+		// An array of numbers is a point, any other array a list of them.
+		if items, ok := value.([]interface{}); ok && len(items) > 0 {
+			if _, number := toFloat(items[0]); !number {
+				out := make([]interface{}, len(items))
+				for i, item := range items {
+					var err error
+					if out[i], err = transform(item); err != nil {
+						return nil, err
+					}
+				}
+				return out, nil
+			}
+		}
+		lat, lon, err := readGeoPoint(value)
+		if err != nil {
+			return nil, err
+		}
+		if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("latitude %g or longitude %g out of range", lat, lon)
+		}
+		switch to {
+		case "string":
+			return formatCoordinate(lat) + "," + formatCoordinate(lon), nil
+		case "geohash":
+			return encodeGeohash(lat, lon, precision), nil
+		case "array":
+			return []interface{}{lon, lat}, nil
+		case "geojson":
+			return map[string]interface{}{"type": "Point", "coordinates": []interface{}{lon, lat}}, nil
+		case "wkt":
+			return "POINT (" + formatCoordinate(lon) + " " + formatCoordinate(lat) + ")", nil
+		}
+		return map[string]interface{}{"lat": lat, "lon": lon}, nil
+	}
+	return transform, nil
+}
+
+// readGeoPoint reads the forms of a point geo_point accepts.
+func readGeoPoint(value interface{}) (lat, lon float64, err error) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if _, ok := typed["type"]; ok {
+			shape, err := readGeoJSON(typed)
+			if err != nil {
+				return 0, 0, err
+			}
+			return pointOf(shape)
+		}
+		lat, latOK := toFloat(typed["lat"])
+		lon, lonOK := toFloat(typed["lon"])
+		if !latOK || !lonOK {
+			return 0, 0, fmt.Errorf("a geo point object needs numeric lat and lon")
+		}
+		return lat, lon, nil
+	case []interface{}:
+		if len(typed) < 2 {
+			return 0, 0, fmt.Errorf("a geo point array is [lon, lat]")
+		}
+		lon, lonOK := toFloat(typed[0])
+		lat, latOK := toFloat(typed[1])
+		if !latOK || !lonOK {
+			return 0, 0, fmt.Errorf("a geo point array is [lon, lat]")
+		}
+		return lat, lon, nil
+	case string:
+		text := strings.TrimSpace(typed)
+		if latText, lonText, ok := strings.Cut(text, ","); ok {
+			lat, latErr := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+			lon, lonErr := strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+			if latErr != nil || lonErr != nil {
+				return 0, 0, fmt.Errorf("%q is not a lat,lon pair", typed)
+			}
+			return lat, lon, nil
+		}
+		if strings.HasPrefix(strings.ToUpper(text), "POINT") {
+			shape, err := parseWKT(text)
+			if err != nil {
+				return 0, 0, err
+			}
+			return pointOf(shape)
+		}
+		return decodeGeohash(text)
+	}
+	return 0, 0, fmt.Errorf("cannot read %T as a geo point", value)
+}
+
+func pointOf(shape map[string]interface{}) (lat, lon float64, err error) {
+	position, ok := shape["coordinates"].([]interface{})
+	if shape["type"] != "Point" || !ok || len(position) < 2 {
+		return 0, 0, fmt.Errorf("%v is not a point", shape["type"])
+	}
+	return position[1].(float64), position[0].(float64), nil
+}
+
+// encodeGeohash returns the geohash of precision characters of the cell
+// that holds lat, lon.
+func encodeGeohash(lat, lon float64, precision int) string {
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	hash := make([]byte, 0, precision)
+	// Bits alternate between longitude and latitude, longitude first, five
+	// to a character.
+	bit, ch, even := 0, 0, true
+	for len(hash) < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lonRange, lon
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// decodeGeohash returns the centre of the cell of hash.
+func decodeGeohash(hash string) (lat, lon float64, err error) {
+	if hash == "" || len(hash) > 12 {
+		return 0, 0, fmt.Errorf("%q is not a geo point", hash)
+	}
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		ch := strings.IndexRune(geohashAlphabet, c)
+		if ch < 0 {
+			return 0, 0, fmt.Errorf("%q is not a geo point", hash)
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if ch&mask != 0 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2, nil
+}
+
+// formatCoordinate writes f in as few digits as read back the same.
+func formatCoordinate(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// geoShapeTypes are the GeoJSON geometry types by their WKT name, with the
+// Elasticsearch extension envelope, BBOX in WKT.
+var geoShapeTypes = map[string]string{
+	"POINT":              "Point",
+	"LINESTRING":         "LineString",
+	"POLYGON":            "Polygon",
+	"MULTIPOINT":         "MultiPoint",
+	"MULTILINESTRING":    "MultiLineString",
+	"MULTIPOLYGON":       "MultiPolygon",
+	"GEOMETRYCOLLECTION": "GeometryCollection",
+	"BBOX":               "envelope",
+}
+
+// geoShapeDepths are how deeply the coordinates of each type nest: 0 for a
+// position, 1 for a list of them, and so on.
+var geoShapeDepths = map[string]int{
+	"Point":           0,
+	"LineString":      1,
+	"MultiPoint":      1,
+	"envelope":        1,
+	"Polygon":         2,
+	"MultiLineString": 2,
+	"MultiPolygon":    3,
+}
+
+// newGeoShapeTransform rewrites a geo shape between GeoJSON and WKT, the two
+// forms Elasticsearch accepts: to is geojson (the default) or wkt. All the
+// GeoJSON geometry types are supported, and the envelope type, which is
+// BBOX (minLon, maxLon, maxLat, minLat) in WKT. Shapes Elasticsearch would
+// reject fail the document: a line string needs two positions, a polygon
+// ring four with the last the same as the first, and an envelope two
+// corners; EMPTY shapes pass.
+//
+//	area | geo_shape(to=wkt)
+func newGeoShapeTransform(args transformArgs) (Transform, error) {
+	to := args.get(0, "to", "geojson")
+	if to != "geojson" && to != "wkt" {
+		return nil, fmt.Errorf("to must be geojson or wkt")
+	}
+	var transform Transform
+	transform = func(value interface{}) (interface{}, error) {
+		var shape map[string]interface{}
+		var err error
+		switch typed := value.(type) {
+		case []interface{}:
+			out := make([]interface{}, len(typed))
+			for i, item := range typed {
+				if out[i], err = transform(item); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		case map[string]interface{}:
+			shape, err = readGeoJSON(typed)
+		case string:
+			shape, err = parseWKT(typed)
+		default:
+			return nil, fmt.Errorf("cannot read %T as a geo shape", value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if to == "wkt" {
+			return formatWKT(shape), nil
+		}
+		return shape, nil
+	}
+	return transform, nil
+}
+
+// geoShapeType returns the GeoJSON type of a GeoJSON or WKT type name,
+// matched regardless of case.
+func geoShapeType(name string) (string, bool) {
+	for wkt, kind := range geoShapeTypes {
+		if strings.EqualFold(name, wkt) || strings.EqualFold(name, kind) {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+// readGeoJSON checks a GeoJSON geometry and returns a copy with the type
+// names canonical and the coordinates float64.
+func readGeoJSON(object map[string]interface{}) (map[string]interface{}, error) {
+	name, _ := object["type"].(string)
+	kind, ok := geoShapeType(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown geo shape type %q", name)
+	}
+	if kind == "GeometryCollection" {
+		items, ok := object["geometries"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("a GeometryCollection needs geometries")
+		}
+		geometries := make([]interface{}, len(items))
+		for i, item := range items {
+			member, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("geometries must be objects")
+			}
+			var err error
+			if geometries[i], err = readGeoJSON(member); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]interface{}{"type": kind, "geometries": geometries}, nil
+	}
+	coordinates, err := readCoordinates(object["coordinates"], geoShapeDepths[kind])
+	if err == nil {
+		err = checkGeoShape(kind, coordinates)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", kind, err)
+	}
+	return map[string]interface{}{"type": kind, "coordinates": coordinates}, nil
+}
+
+// checkGeoShape checks the coordinates of a shape of kind beyond their
+// nesting. Empty coordinates are an EMPTY shape, which passes.
+func checkGeoShape(kind string, coordinates []interface{}) error {
+	if len(coordinates) == 0 {
+		return nil
+	}
+	switch kind {
+	case "LineString":
+		return checkLineString(coordinates)
+	case "MultiLineString":
+		for _, line := range coordinates {
+			if err := checkLineString(line.([]interface{})); err != nil {
+				return err
+			}
+		}
+	case "Polygon":
+		return checkPolygon(coordinates)
+	case "MultiPolygon":
+		for _, polygon := range coordinates {
+			if err := checkPolygon(polygon.([]interface{})); err != nil {
+				return err
+			}
+		}
+	case "envelope":
+		if len(coordinates) != 2 {
+			return fmt.Errorf("an envelope is two corners, upper left and lower right")
+		}
+	}
+	return nil
+}
+
+func checkLineString(positions []interface{}) error {
+	if len(positions) < 2 {
+		return fmt.Errorf("a line string needs at least two positions")
+	}
+	return nil
+}
+
+func checkPolygon(rings []interface{}) error {
+	for _, item := range rings {
+		ring := item.([]interface{})
+		if len(ring) < 4 {
+			return fmt.Errorf("a polygon ring needs at least four positions")
+		}
+		first, last := ring[0].([]interface{}), ring[len(ring)-1].([]interface{})
+		if !reflect.DeepEqual(first, last) {
+			return fmt.Errorf("a polygon ring must end at the position it starts at")
+		}
+	}
+	return nil
+}
+
+func readCoordinates(value interface{}, depth int) ([]interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("coordinates must be arrays")
+	}
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		if depth == 0 {
+			f, ok := toFloat(item)
+			if !ok {
+				return nil, fmt.Errorf("a position is an array of numbers")
+			}
+			out[i] = f
+			continue
+		}
+		var err error
+		if out[i], err = readCoordinates(item, depth-1); err != nil {
+			return nil, err
+		}
+	}
+	if depth == 0 && len(out) < 2 {
+		return nil, fmt.Errorf("a position needs at least two numbers")
+	}
+	return out, nil
+}
+
+// formatWKT writes a geometry read by readGeoJSON or parseWKT as WKT.
+func formatWKT(shape map[string]interface{}) string {
+	var b strings.Builder
+	writeWKT(&b, shape)
+	return b.String()
+}
+
+func writeWKT(b *strings.Builder, shape map[string]interface{}) {
+	kind := shape["type"].(string)
+	for name, k := range geoShapeTypes {
+		if k == kind {
+			b.WriteString(name)
+			break
+		}
+	}
+	if kind == "GeometryCollection" {
+		geometries := shape["geometries"].([]interface{})
+		if len(geometries) == 0 {
+			b.WriteString(" EMPTY")
+			return
+		}
+		b.WriteString(" (")
+		for i, geometry := range geometries {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeWKT(b, geometry.(map[string]interface{}))
+		}
+		b.WriteByte(')')
+		return
+	}
+	coordinates := shape["coordinates"].([]interface{})
+	if len(coordinates) == 0 {
+		b.WriteString(" EMPTY")
+		return
+	}
+	b.WriteByte(' ')
+	if kind == "envelope" {
+		// [[minLon, maxLat], [maxLon, minLat]] is BBOX (minLon, maxLon,
+		// maxLat, minLat).
+		if len(coordinates) == 2 {
+			upperLeft, lowerRight := coordinates[0].([]interface{}), coordinates[1].([]interface{})
+			fmt.Fprintf(b, "(%s, %s, %s, %s)", formatCoordinate(upperLeft[0].(float64)), formatCoordinate(lowerRight[0].(float64)),
+				formatCoordinate(upperLeft[1].(float64)), formatCoordinate(lowerRight[1].(float64)))
+		}
+		return
+	}
+	if geoShapeDepths[kind] == 0 {
+		b.WriteByte('(')
+		writeWKTCoordinates(b, coordinates, 0)
+		b.WriteByte(')')
+		return
+	}
+	writeWKTCoordinates(b, coordinates, geoShapeDepths[kind])
+}
+
+func writeWKTCoordinates(b *strings.Builder, coordinates []interface{}, depth int) {
+	if depth == 0 {
+		for i, f := range coordinates {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(formatCoordinate(f.(float64)))
+		}
+		return
+	}
+	b.WriteByte('(')
+	for i, item := range coordinates {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeWKTCoordinates(b, item.([]interface{}), depth-1)
+	}
+	b.WriteByte(')')
+}
+
+// wktParser reads WKT, a token at a time.
+type wktParser struct {
+	text string
+	pos  int
+}
+
+// parseWKT reads a WKT geometry as GeoJSON. Z and M coordinates are kept
+// as the third and fourth numbers of a position.
+func parseWKT(text string) (map[string]interface{}, error) {
+	p := &wktParser{text: text}
+	shape, err := p.geometry()
+	if err != nil {
+		return nil, fmt.Errorf("invalid WKT %q: %w", text, err)
+	}
+	if token := p.next(); token != "" {
+		return nil, fmt.Errorf("invalid WKT %q: unexpected %q at the end", text, token)
+	}
+	return shape, nil
+}
+
+// next returns the next token: a word, a number, or one of "(", ")" and
+// ",", or "" at the end.
+func (p *wktParser) next() string {
+	for p.pos < len(p.text) && unicode.IsSpace(rune(p.text[p.pos])) {
+		p.pos++
+	}
+	if p.pos == len(p.text) {
+		return ""
+	}
+	start := p.pos
+	if strings.IndexByte("(),", p.text[p.pos]) >= 0 {
+		p.pos++
+		return p.text[start:p.pos]
+	}
+	for p.pos < len(p.text) && !unicode.IsSpace(rune(p.text[p.pos])) && strings.IndexByte("(),", p.text[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *wktParser) peek() string {
+	pos := p.pos
+	token := p.next()
+	p.pos = pos
+	return token
+}
+
+func (p *wktParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, found %q", token, got)
+	}
+	return nil
+}
+
+func (p *wktParser) geometry() (map[string]interface{}, error) {
+	name := p.next()
+	kind, ok := geoShapeType(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown geometry type %q", name)
+	}
+	switch strings.ToUpper(p.peek()) {
+	case "Z", "M", "ZM":
+		p.next()
+	}
+	empty := strings.ToUpper(p.peek()) == "EMPTY"
+	if empty {
+		p.next()
+	}
+	if kind == "GeometryCollection" {
+		geometries := []interface{}{}
+		if empty {
+			return map[string]interface{}{"type": kind, "geometries": geometries}, nil
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		for {
+			geometry, err := p.geometry()
+			if err != nil {
+				return nil, err
+			}
+			geometries = append(geometries, geometry)
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": kind, "geometries": geometries}, nil
+	}
+	coordinates := []interface{}{}
+	if !empty {
+		list, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		coordinates = list
+	}
+	switch kind {
+	case "Point":
+		if len(coordinates) == 1 {
+			coordinates = coordinates[0].([]interface{})
+		}
+	case "MultiPoint":
+		// MULTIPOINT ((1 2), (3 4)) is MULTIPOINT (1 2, 3 4).
+		for i, item := range coordinates {
+			if inner := item.([]interface{}); len(inner) == 1 {
+				if position, ok := inner[0].([]interface{}); ok {
+					coordinates[i] = position
+				}
+			}
+		}
+	case "envelope":
+		if len(coordinates) != 4 {
+			return nil, fmt.Errorf("BBOX takes minLon, maxLon, maxLat, minLat")
+		}
+		var bounds [4]interface{}
+		for i, item := range coordinates {
+			position := item.([]interface{})
+			if len(position) != 1 {
+				return nil, fmt.Errorf("BBOX takes minLon, maxLon, maxLat, minLat")
+			}
+			bounds[i] = position[0]
+		}
+		coordinates = []interface{}{[]interface{}{bounds[0], bounds[2]}, []interface{}{bounds[1], bounds[3]}}
+	}
+	if len(coordinates) > 0 {
+		// The nesting must suit the type.
+		_, err := readCoordinates(coordinates, geoShapeDepths[kind])
+		if err == nil {
+			err = checkGeoShape(kind, coordinates)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return map[string]interface{}{"type": kind, "coordinates": coordinates}, nil
+}
+
+// list reads a parenthesised, comma separated list of positions or of
+// lists.
+func (p *wktParser) list() ([]interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	items := []interface{}{}
+	for {
+		if p.peek() == "(" {
+			item, err := p.list()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		} else {
+			item, err := p.position()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if token := p.next(); token == ")" {
+			return items, nil
+		} else if token != "," {
+			return nil, fmt.Errorf("expected \",\" or \")\", found %q", token)
+		}
+	}
+}
+
+func (p *wktParser) position() ([]interface{}, error) {
+	var position []interface{}
+	for {
+		token := p.peek()
+		if token == "," || token == ")" || token == "" {
+			break
+		}
+		f, err := strconv.ParseFloat(p.next(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number, found %q", token)
+		}
+		position = append(position, f)
+	}
+	if len(position) == 0 {
+		return nil, fmt.Errorf("empty position")
+	}
+	return position, nil
+}
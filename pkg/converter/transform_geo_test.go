@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGeoTransforms(t *testing.T) {
+	square := []interface{}{
+		[]interface{}{0.0, 0.0}, []interface{}{1.0, 0.0}, []interface{}{1.0, 1.0}, []interface{}{0.0, 0.0},
+	}
+	open := []interface{}{
+		[]interface{}{0.0, 0.0}, []interface{}{1.0, 0.0}, []interface{}{1.0, 1.0}, []interface{}{0.0, 1.0},
+	}
+	tests := []struct {
+		spec    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"geo_point", "52.52,13.405", map[string]interface{}{"lat": 52.52, "lon": 13.405}, false},
+		{"geo_point(to=array)", map[string]interface{}{"lat": 52.52, "lon": 13.405}, []interface{}{13.405, 52.52}, false},
+		{"geo_point(to=wkt)", []interface{}{13.405, 52.52}, "POINT (13.405 52.52)", false},
+		{"geo_point(to=geohash, precision=5)", "POINT (13.405 52.52)", "u33dc", false},
+		{"geo_point(to=string)", []interface{}{"u33db", []interface{}{1.0, 2.0}}, []interface{}{"52.53662109375,13.38134765625", "2,1"}, false},
+		{"geo_point", "91,0", nil, true},
+		{"geo_point(precision=13)", "0,0", nil, true},
+		{"geo_shape(to=wkt)", map[string]interface{}{"type": "Polygon", "coordinates": []interface{}{square}}, "POLYGON ((0 0, 1 0, 1 1, 0 0))", false},
+		{"geo_shape", "LINESTRING (0 0, 1 1)", map[string]interface{}{
+			"type": "LineString", "coordinates": []interface{}{[]interface{}{0.0, 0.0}, []interface{}{1.0, 1.0}},
+		}, false},
+		{"geo_shape", "POLYGON EMPTY", map[string]interface{}{"type": "Polygon", "coordinates": []interface{}{}}, false},
+		{"geo_shape", "BBOX (0, 1, 1, 0)", map[string]interface{}{
+			"type": "envelope", "coordinates": []interface{}{[]interface{}{0.0, 1.0}, []interface{}{1.0, 0.0}},
+		}, false},
+		{"geo_shape", map[string]interface{}{"type": "Polygon", "coordinates": []interface{}{open}}, nil, true},
+		{"geo_shape", map[string]interface{}{"type": "Polygon", "coordinates": []interface{}{square[1:]}}, nil, true},
+		{"geo_shape", map[string]interface{}{"type": "LineString", "coordinates": []interface{}{[]interface{}{0.0, 0.0}}}, nil, true},
+		{"geo_shape", map[string]interface{}{"type": "MultiPolygon", "coordinates": []interface{}{
+			[]interface{}{square}, []interface{}{open},
+		}}, nil, true},
+		{"geo_shape", map[string]interface{}{"type": "envelope", "coordinates": []interface{}{[]interface{}{0.0, 1.0}}}, nil, true},
+		{"geo_shape", map[string]interface{}{"type": "GeometryCollection", "geometries": []interface{}{
+			map[string]interface{}{"type": "Point", "coordinates": []interface{}{0.0, 0.0}},
+			map[string]interface{}{"type": "Polygon", "coordinates": []interface{}{open}},
+		}}, nil, true},
+		{"geo_shape", "POLYGON ((0 0, 1 0, 1 1, 0 1))", nil, true},
+		{"geo_shape", "MULTILINESTRING ((0 0, 1 1), (2 2))", nil, true},
+		{"geo_shape", "GEOMETRYCOLLECTION (POINT (0 0), POLYGON ((0 0, 1 0, 0 0)))", nil, true},
+		{"geo_shape(to=kml)", "POINT (0 0)", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := applyTransforms(tt.spec, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s(%v) error = %v, want error %v", tt.spec, tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s(%v) = %#v, want %#v", tt.spec, tt.value, got, tt.want)
+		}
+	}
+}
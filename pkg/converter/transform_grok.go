@@ -0,0 +1,294 @@
+package converter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerTransform("grok", newGrokTransform)
+	registerTransform("dissect", newDissectTransform)
+}
+
+// grokPatterns are the named patterns grok patterns may refer to, a subset
+// of those Logstash ships with.
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `(?:[+-]?(?:[0-9]+))`,
+	"BASE10NUM":         `(?:[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+))`,
+	"NUMBER":            `(?:%{BASE10NUM})`,
+	"POSINT":            `\b(?:[1-9][0-9]*)\b`,
+	"NONNEGINT":         `\b(?:[0-9]+)\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b(?:[0-9A-Za-z][0-9A-Za-z-]{0,62})(?:\.(?:[0-9A-Za-z][0-9A-Za-z-]{0,62}))*\.?`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"LOGLEVEL":          `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|EMERG(?:ENCY)?|[Ee]merg(?:ency)?)`,
+	"TIMESTAMP_ISO8601": `\d{4}-\d{2}-\d{2}[T ]\d{2}:?\d{2}(?::?\d{2}(?:[.,]\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`,
+	"HTTPDATE":          `\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`,
+	"URIPATH":           `(?:/[^\s?#]*)+`,
+	"URIPARAM":          `\?[^\s#]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+}
+
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(int|float))?\}`)
+
+// grokCapture is the output field of a named group, with the type its text
+// is converted to, if any.
+type grokCapture struct {
+	field []string
+	kind  string
+}
+
+// newGrokTransform matches a string against a grok pattern, a regular
+// expression in which %{NAME} stands for a named pattern and
+// %{NAME:field} also captures what it matched into field, as an int or
+// float with %{NAME:field:int}. It returns an object of the captures;
+// field paths with dots make nested objects. patterns_file adds patterns
+// of its own, one "NAME regex" per line. The pattern is not anchored.
+// on_error decides what becomes of strings that do not match: fail (the
+// default) fails the document, keep keeps the string, and null writes null.
+//
+//	grok("%{IPORHOST:client.ip} %{WORD:http.method} %{URIPATHPARAM:url} %{INT:status:int}")
+//	grok(pattern="%{APP_ID:app} %{GREEDYDATA:message}", patterns_file=patterns.txt, on_error=keep)
+func newGrokTransform(args transformArgs) (Transform, error) {
+	pattern := args.get(0, "pattern", "")
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	onError := args.get(-1, "on_error", "fail")
+	if onError != "fail" && onError != "keep" && onError != "null" {
+		return nil, fmt.Errorf("on_error must be fail, keep or null")
+	}
+	patterns := grokPatterns
+	if file := args.get(-1, "patterns_file", ""); file != "" {
+		var err error
+		if patterns, err = loadGrokPatterns(file); err != nil {
+			return nil, err
+		}
+	}
+	var captures []grokCapture
+	expanded, err := expandGrok(pattern, patterns, &captures, 0)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return func(value interface{}) (interface{}, error) {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot match %T with grok", value)
+		}
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			switch onError {
+			case "keep":
+				return text, nil
+			case "null":
+				return nil, nil
+			}
+			return nil, fmt.Errorf("%q does not match the grok pattern", text)
+		}
+		out := map[string]interface{}{}
+		for i, name := range re.SubexpNames() {
+			if !strings.HasPrefix(name, "grok") {
+				continue
+			}
+			n, _ := strconv.Atoi(name[len("grok"):])
+			capture := captures[n]
+			var item interface{} = match[i]
+			switch capture.kind {
+			case "int":
+				if item, err = strconv.ParseInt(match[i], 10, 64); err != nil {
+					return nil, fmt.Errorf("%s: %q is not an integer", strings.Join(capture.field, "."), match[i])
+				}
+			case "float":
+				if item, err = strconv.ParseFloat(match[i], 64); err != nil {
+					return nil, fmt.Errorf("%s: %q is not a number", strings.Join(capture.field, "."), match[i])
+				}
+			}
+			InsertFieldValue(out, capture.field, item)
+		}
+		return out, nil
+	}, nil
+}
+
+// expandGrok replaces the pattern references of pattern by their regular
+// expressions, adding a named group grok<n> for the n-th capture.
+func expandGrok(pattern string, patterns map[string]string, captures *[]grokCapture, depth int) (string, error) {
+	if depth > 20 {
+		return "", fmt.Errorf("patterns refer to each other in a loop")
+	}
+	var err error
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(reference string) string {
+		parts := grokReference.FindStringSubmatch(reference)
+		definition, ok := patterns[parts[1]]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("unknown grok pattern %s", parts[1])
+			}
+			return ""
+		}
+		inner, innerErr := expandGrok(definition, patterns, captures, depth+1)
+		if innerErr != nil && err == nil {
+			err = innerErr
+		}
+		if parts[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		*captures = append(*captures, grokCapture{field: strings.Split(parts[2], "."), kind: parts[3]})
+		return fmt.Sprintf("(?P<grok%d>%s)", len(*captures)-1, inner)
+	})
+	return expanded, err
+}
+
+// loadGrokPatterns returns the built-in patterns with those of path added.
+func loadGrokPatterns(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	patterns := make(map[string]string, len(grokPatterns))
+	for name, definition := range grokPatterns {
+		patterns[name] = definition
+	}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, definition, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: a pattern is a name, a space and a regular expression", path, line)
+		}
+		patterns[name] = strings.TrimSpace(definition)
+	}
+	return patterns, scanner.Err()
+}
+
+// dissectField is a %{...} of a dissect pattern: the field it writes, or
+// none when it is skipped, and whether it skips padding after it.
+type dissectField struct {
+	field  []string
+	padded bool
+}
+
+// newDissectTransform splits a string on the literal text between the
+// fields of a pattern, like the Elasticsearch dissect processor:
+// %{field} takes the text up to the next delimiter, %{} and %{?name} skip
+// it, and the last field takes the rest. %{field->} also skips repeats of
+// the delimiter after it, for padded columns. on_error works as for grok.
+//
+//	dissect("%{client} - - [%{timestamp}] \"%{method} %{path} %{}\" %{status}")
+func newDissectTransform(args transformArgs) (Transform, error) {
+	pattern := args.get(0, "pattern", "")
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	onError := args.get(-1, "on_error", "fail")
+	if onError != "fail" && onError != "keep" && onError != "null" {
+		return nil, fmt.Errorf("on_error must be fail, keep or null")
+	}
+	// The pattern alternates delimiters and fields: delimiters[i] precedes
+	// fields[i], and delimiters has one more for the text after the last
+	// field.
+	var fields []dissectField
+	var delimiters []string
+	rest := pattern
+	for {
+		open := strings.Index(rest, "%{")
+		if open < 0 {
+			delimiters = append(delimiters, rest)
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("missing } in pattern")
+		}
+		delimiters = append(delimiters, rest[:open])
+		name := rest[open+2 : open+end]
+		pad := strings.HasSuffix(name, "->")
+		name = strings.TrimSuffix(name, "->")
+		if len(fields) > 0 && delimiters[len(delimiters)-1] == "" {
+			return nil, fmt.Errorf("fields %%{%s} and the one before need a delimiter between them", name)
+		}
+		field := dissectField{padded: pad}
+		if name != "" && !strings.HasPrefix(name, "?") {
+			field.field = strings.Split(name, ".")
+		}
+		fields = append(fields, field)
+		rest = rest[open+end+1:]
+	}
+	return func(value interface{}) (interface{}, error) {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot dissect %T", value)
+		}
+		out, ok := dissect(text, fields, delimiters)
+		if ok {
+			return out, nil
+		}
+		switch onError {
+		case "keep":
+			return text, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%q does not match the dissect pattern", text)
+	}, nil
+}
+
+func dissect(text string, fields []dissectField, delimiters []string) (map[string]interface{}, bool) {
+	if !strings.HasPrefix(text, delimiters[0]) {
+		return nil, false
+	}
+	text = text[len(delimiters[0]):]
+	out := map[string]interface{}{}
+	for i, field := range fields {
+		next := delimiters[i+1]
+		var value string
+		switch {
+		case i == len(fields)-1 && next == "":
+			value, text = text, ""
+		case i == len(fields)-1:
+			if !strings.HasSuffix(text, next) {
+				return nil, false
+			}
+			value, text = text[:len(text)-len(next)], ""
+		default:
+			end := strings.Index(text, next)
+			if end < 0 {
+				return nil, false
+			}
+			value, text = text[:end], text[end+len(next):]
+			if field.padded {
+				// Padding repeats the delimiter after the field.
+				for strings.HasPrefix(text, next) {
+					text = text[len(next):]
+				}
+			}
+		}
+		if field.field != nil {
+			InsertFieldValue(out, field.field, value)
+		}
+	}
+	return out, true
+}
@@ -0,0 +1,58 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGrokAndDissect(t *testing.T) {
+	patterns := filepath.Join(t.TempDir(), "patterns.txt")
+	if err := os.WriteFile(patterns, []byte("# application IDs\nAPP_ID app-[0-9]+\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	access := `10.0.0.1 GET /search?q=go 200 0.25`
+	tests := []struct {
+		spec    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{`grok("%{IP:client.ip} %{WORD:method} %{URIPATHPARAM:url} %{INT:status:int} %{NUMBER:took:float}")`, access, map[string]interface{}{
+			"client": map[string]interface{}{"ip": "10.0.0.1"}, "method": "GET", "url": "/search?q=go", "status": int64(200), "took": 0.25,
+		}, false},
+		{`grok(pattern="%{APP_ID:app} %{GREEDYDATA:message}", patterns_file="` + patterns + `")`, "app-42 started", map[string]interface{}{
+			"app": "app-42", "message": "started",
+		}, false},
+		{`grok("%{INT:n:int} items")`, "many items", nil, true},
+		{`grok("%{INT:n:int} items", on_error=keep)`, "many items", "many items", false},
+		{`grok("%{INT:n:int} items", on_error=null)`, "many items", nil, false},
+		{`grok("%{NOPE:x}")`, "x", nil, true},
+		{`grok("%{WORD:x}")`, int64(1), nil, true},
+		{`dissect("%{client} [%{?ts}] %{method->} %{path}")`, "10.0.0.1 [15/Jan/2024] GET    /index", map[string]interface{}{
+			"client": "10.0.0.1", "method": "GET", "path": "/index",
+		}, false},
+		{`dissect("%{a.b},%{}")`, "x,ignored", map[string]interface{}{"a": map[string]interface{}{"b": "x"}}, false},
+		{`dissect("%{a}: %{b}")`, "no delimiter", nil, true},
+		{`dissect("%{a}: %{b}", on_error=keep)`, "no delimiter", "no delimiter", false},
+		{`dissect("%{a}%{b}")`, "ab", nil, true},
+		{"kv", `user=alice action="log in" status=200 flag`, map[string]interface{}{
+			"user": "alice", "action": "log in", "status": "200",
+		}, false},
+		{`kv(field_split="&", value_split=":", prefix="attr.")`, "a:1&b:2", map[string]interface{}{
+			"attr": map[string]interface{}{"a": "1", "b": "2"},
+		}, false},
+		{`kv(field_split="")`, "a=1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := applyTransforms(tt.spec, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s(%v) error = %v, want error %v", tt.spec, tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s(%v) = %#v, want %#v", tt.spec, tt.value, got, tt.want)
+		}
+	}
+}
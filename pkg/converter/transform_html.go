@@ -0,0 +1,129 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+
+	nethtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func init() {
+	registerTransform("strip_html", newStripHTMLTransform)
+	registerTransform("unescape_html", newUnescapeHTMLTransform)
+	registerTransform("collapse_whitespace", newCollapseWhitespaceTransform)
+}
+
+// blockElements are the elements strip_html ends a line at, so the words
+// either side of them do not run together.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+	atom.Br: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Figcaption: true, atom.Figure: true, atom.Footer: true, atom.Form: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Table: true, atom.Td: true, atom.Th: true, atom.Tr: true, atom.Ul: true,
+}
+
+// newStripHTMLTransform removes the markup of an HTML string, keeping its
+// text with the entities decoded. The contents of script, style, template
+// and similar elements, and comments, go with the markup; block elements
+// such as p, br and li end a line. Pipe the result through
+// collapse_whitespace to tidy the spacing.
+//
+//	body | strip_html | collapse_whitespace
+func newStripHTMLTransform(args transformArgs) (Transform, error) {
+	return stringTransform(func(value string) (string, error) {
+		return stripHTML(value), nil
+	}), nil
+}
+
+func stripHTML(text string) string {
+	var out strings.Builder
+	tokenizer := nethtml.NewTokenizer(strings.NewReader(text))
+	// skip is the element whose contents are being dropped, if any.
+	var skip atom.Atom
+	for {
+		switch tokenizer.Next() {
+		case nethtml.ErrorToken:
+			// The reader never fails, so this is the end of the text.
+			return out.String()
+		case nethtml.TextToken:
+			if skip == 0 {
+				out.Write(tokenizer.Text())
+			}
+		case nethtml.StartTagToken, nethtml.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := atom.Lookup(name)
+			switch {
+			case skip != 0:
+			case tag == atom.Script || tag == atom.Style || tag == atom.Template || tag == atom.Noscript || tag == atom.Iframe:
+				skip = tag
+			case blockElements[tag]:
+				out.WriteByte('\n')
+			}
+		case nethtml.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := atom.Lookup(name)
+			switch {
+			case tag == skip:
+				skip = 0
+			case skip == 0 && blockElements[tag]:
+				out.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// newUnescapeHTMLTransform decodes the HTML entities of a string, such as
+// &amp;, &eacute; and &#39;, leaving any markup in place.
+func newUnescapeHTMLTransform(args transformArgs) (Transform, error) {
+	return stringTransform(func(value string) (string, error) {
+		return html.UnescapeString(value), nil
+	}), nil
+}
+
+// newCollapseWhitespaceTransform turns every run of whitespace, Unicode
+// spaces such as the no-break space included, into a single space and
+// trims the ends. With keep_newlines=true, runs that contain a line break
+// become one line break instead, so paragraphs stay apart.
+//
+//	collapse_whitespace
+//	collapse_whitespace(keep_newlines=true)
+func newCollapseWhitespaceTransform(args transformArgs) (Transform, error) {
+	keepNewlines, err := strconv.ParseBool(args.get(-1, "keep_newlines", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("keep_newlines must be true or false")
+	}
+	return stringTransform(func(value string) (string, error) {
+		return collapseWhitespace(value, keepNewlines), nil
+	}), nil
+}
+
+func collapseWhitespace(text string, keepNewlines bool) string {
+	var out strings.Builder
+	out.Grow(len(text))
+	// space is the separator owed before the next word: 0 for none, else a
+	// space or a line break.
+	var space byte
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			if space != 0 && out.Len() > 0 {
+				out.WriteByte(space)
+			}
+			space = 0
+			out.WriteRune(r)
+			continue
+		}
+		if keepNewlines && (r == '\n' || r == '\r' || r == '\u2028' || r == '\u2029') {
+			space = '\n'
+		} else if space == 0 {
+			space = ' '
+		}
+	}
+	return out.String()
+}
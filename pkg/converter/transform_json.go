@@ -0,0 +1,43 @@
+package converter
+
+import "fmt"
+
+func init() {
+	registerTransform("parse_json", newParseJSONTransform)
+}
+
+// newParseJSONTransform decodes a string holding JSON, such as the message
+// of a structured log line, into its value, numbers kept exact as in input
+// documents. A value that is already an object or array passes through.
+// on_error decides what becomes of text that is not JSON: fail (the
+// default) fails the document, keep keeps the string, and null writes null.
+//
+//	parse_json
+//	parse_json(on_error=keep)
+func newParseJSONTransform(args transformArgs) (Transform, error) {
+	onError := args.get(0, "on_error", "fail")
+	if onError != "fail" && onError != "keep" && onError != "null" {
+		return nil, fmt.Errorf("on_error must be fail, keep or null")
+	}
+	return func(value interface{}) (interface{}, error) {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return value, nil
+		}
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot parse %T as JSON", value)
+		}
+		parsed, err := unmarshalExact([]byte(text))
+		if err == nil {
+			return parsed, nil
+		}
+		switch onError {
+		case "keep":
+			return text, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("invalid JSON text: %w", err)
+	}, nil
+}
@@ -0,0 +1,71 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	registerTransform("kv", newKVTransform)
+}
+
+// newKVTransform splits a string of key/value pairs, such as
+// `user=alice action="log in" status=200`, into an object. Pairs are split
+// on field_split (default a space) and keys from values on value_split
+// (default "="); values in double quotes may contain field_split. Pairs
+// without value_split are skipped. prefix is prepended to the keys, and
+// keys with dots make nested objects.
+//
+//	kv
+//	kv(field_split="&", value_split=":", prefix="attr_")
+func newKVTransform(args transformArgs) (Transform, error) {
+	fieldSplit := args.get(-1, "field_split", " ")
+	valueSplit := args.get(-1, "value_split", "=")
+	prefix := args.get(-1, "prefix", "")
+	if fieldSplit == "" || valueSplit == "" {
+		return nil, fmt.Errorf("field_split and value_split must not be empty")
+	}
+	return func(value interface{}) (interface{}, error) {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot split %T into key/value pairs", value)
+		}
+		out := map[string]interface{}{}
+		for _, pair := range splitQuoted(text, fieldSplit) {
+			key, val, found := strings.Cut(pair, valueSplit)
+			key = strings.TrimSpace(key)
+			if !found || key == "" {
+				continue
+			}
+			val = strings.TrimSpace(val)
+			if len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"' {
+				val = val[1 : len(val)-1]
+			}
+			InsertFieldValue(out, strings.Split(prefix+key, "."), val)
+		}
+		return out, nil
+	}, nil
+}
+
+// splitQuoted splits text on sep outside double quotes, dropping empty
+// parts.
+func splitQuoted(text, sep string) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '"':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(text[i:], sep):
+			if part := text[start:i]; strings.TrimSpace(part) != "" {
+				parts = append(parts, part)
+			}
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	if part := text[start:]; strings.TrimSpace(part) != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
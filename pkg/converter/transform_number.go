@@ -0,0 +1,143 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerTransform("format_number", newFormatNumberTransform)
+}
+
+// newFormatNumberTransform writes a number in plain decimal notation, never
+// with an exponent: format_number(decimals=2) turns 2.5 into 2.50, and
+// format_number alone writes 1e6 as 1000000 and 1.0 as 1. The result is a
+// JSON number, as formatted; numeric strings are formatted too. Fractions
+// are rounded half to even on their decimal digits, a float64 taken as the
+// shortest decimal that reads back as it, so that 2.675 becomes 2.68 with
+// two decimals; integers keep every digit, whatever their size.
+func newFormatNumberTransform(args transformArgs) (Transform, error) {
+	decimals, err := args.int(0, "decimals", -1)
+	if err != nil {
+		return nil, err
+	}
+	if decimals > 20 {
+		return nil, fmt.Errorf("decimals must be at most 20")
+	}
+	var transform Transform
+	transform = func(value interface{}) (interface{}, error) {
+		switch typed := value.(type) {
+		case []interface{}:
+			out := make([]interface{}, len(typed))
+			for i, item := range typed {
+				var err error
+				if out[i], err = transform(item); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		case float64:
+			return formatNumberText(strconv.FormatFloat(typed, 'g', -1, 64), decimals)
+		case int:
+			return formatInteger(strconv.Itoa(typed), decimals), nil
+		case int64:
+			return formatInteger(strconv.FormatInt(typed, 10), decimals), nil
+		case json.Number:
+			return formatNumberText(string(typed), decimals)
+		case string:
+			return formatNumberText(strings.TrimSpace(typed), decimals)
+		}
+		return nil, fmt.Errorf("cannot format %T as a number", value)
+	}
+	return transform, nil
+}
+
+// formatNumberText formats the text of a number, keeping the digits of an
+// integer as they are, bar leading zeros, and rounding a fraction on its
+// decimal digits. With decimals below 0 a fraction keeps all its digits.
+func formatNumberText(text string, decimals int) (interface{}, error) {
+	digits, negative := strings.CutPrefix(text, "-")
+	if isDigits(digits) {
+		if digits = strings.TrimLeft(digits, "0"); digits == "" {
+			digits = "0"
+		} else if negative {
+			digits = "-" + digits
+		}
+		return formatInteger(digits, decimals), nil
+	}
+	// ParseFloat admits only the syntax of a number, which big.Rat widens
+	// to fractions such as 1/3.
+	r, ok := new(big.Rat).SetString(text)
+	if _, err := strconv.ParseFloat(text, 64); !ok || (err != nil && !errors.Is(err, strconv.ErrRange)) {
+		return nil, fmt.Errorf("%q is not a number", text)
+	}
+	if decimals < 0 {
+		decimals = decimalPlaces(r.Denom())
+	}
+	return json.Number(roundHalfEven(r, decimals)), nil
+}
+
+// decimalPlaces returns the number of decimals a fraction with the
+// denominator denom, a product of twos and fives, is written with.
+func decimalPlaces(denom *big.Int) int {
+	twos := int(denom.TrailingZeroBits())
+	rest := new(big.Int).Rsh(denom, uint(twos))
+	fives, five, m := 0, big.NewInt(5), new(big.Int)
+	for rest.Cmp(big.NewInt(1)) > 0 {
+		if rest.QuoRem(rest, five, m); m.Sign() != 0 {
+			break
+		}
+		fives++
+	}
+	return max(twos, fives)
+}
+
+// roundHalfEven writes r with decimals digits after the point, the last
+// rounded half to even.
+func roundHalfEven(r *big.Rat, decimals int) string {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	num := new(big.Int).Mul(r.Num(), scale)
+	q, m := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	switch new(big.Int).Lsh(m.Abs(m), 1).Cmp(r.Denom()) {
+	case 1:
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(r.Sign())))
+		}
+	}
+	digits := new(big.Int).Abs(q).String()
+	if decimals > 0 {
+		if len(digits) <= decimals {
+			digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-decimals] + "." + digits[len(digits)-decimals:]
+	}
+	if q.Sign() < 0 {
+		digits = "-" + digits
+	}
+	return digits
+}
+
+func formatInteger(digits string, decimals int) json.Number {
+	if decimals <= 0 {
+		return json.Number(digits)
+	}
+	return json.Number(digits + "." + strings.Repeat("0", decimals))
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
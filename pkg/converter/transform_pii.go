@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	registerTransform("hash", newHashTransform)
+	registerTransform("redact", newRedactTransform)
+	registerTransform("mask_email", newMaskEmailTransform)
+}
+
+// newHashTransform replaces the value with the hex digest of the salt
+// followed by the value: hash(sha256, salt="...").
+func newHashTransform(args transformArgs) (Transform, error) {
+	var newHash func() hash.Hash
+	switch algorithm := args.get(0, "algorithm", "sha256"); algorithm {
+	case "sha256":
+		newHash = sha256.New
+	case "sha512":
+		newHash = sha512.New
+	case "sha1":
+		newHash = sha1.New
+	case "md5":
+		newHash = md5.New
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q", algorithm)
+	}
+	salt := args.get(1, "salt", "")
+	return stringTransform(func(value string) (string, error) {
+		h := newHash()
+		h.Write([]byte(salt))
+		h.Write([]byte(value))
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}), nil
+}
+
+// newRedactTransform replaces all but the first keep_first and last
+// keep_last characters with char: redact(keep_last=4).
+func newRedactTransform(args transformArgs) (Transform, error) {
+	keepLast, err := args.int(-1, "keep_last", 0)
+	if err != nil {
+		return nil, err
+	}
+	keepFirst, err := args.int(-1, "keep_first", 0)
+	if err != nil {
+		return nil, err
+	}
+	char := args.get(-1, "char", "*")
+	return stringTransform(func(value string) (string, error) {
+		return redact(value, keepFirst, keepLast, char), nil
+	}), nil
+}
+
+func redact(value string, keepFirst, keepLast int, char string) string {
+	n := utf8.RuneCountInString(value)
+	if keepFirst+keepLast >= n {
+		return value
+	}
+	runes := []rune(value)
+	return string(runes[:keepFirst]) + strings.Repeat(char, n-keepFirst-keepLast) + string(runes[n-keepLast:])
+}
+
+// newMaskEmailTransform keeps the first character of the local part and the
+// domain of an email address: john.doe@example.com becomes
+// j*******@example.com. Values without an @ are redacted entirely.
+func newMaskEmailTransform(args transformArgs) (Transform, error) {
+	char := args.get(-1, "char", "*")
+	return stringTransform(func(value string) (string, error) {
+		at := strings.LastIndexByte(value, '@')
+		if at < 0 {
+			return redact(value, 0, 0, char), nil
+		}
+		return redact(value[:at], 1, 0, char) + value[at:], nil
+	}), nil
+}
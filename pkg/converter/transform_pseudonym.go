@@ -0,0 +1,95 @@
+package converter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+func init() {
+	registerTransform("pseudonymize", newPseudonymizeTransform)
+}
+
+// newPseudonymizeTransform replaces every letter and digit with one derived
+// from an HMAC-SHA256 of the whole value, keeping case, length and all other
+// characters, so "Jane Roe <jane@example.com>" stays shaped like a name and
+// an address. Equal values yield equal pseudonyms across runs and fields for
+// the same key, keeping them joinable; context separates domains that must
+// not be.
+//
+//	pseudonymize(key_env=PSEUDONYM_KEY)
+//	pseudonymize(key_file=/run/secrets/pseudonym, context=customers)
+func newPseudonymizeTransform(args transformArgs) (Transform, error) {
+	key, err := loadKey(args)
+	if err != nil {
+		return nil, err
+	}
+	context := args.get(-1, "context", "")
+	return stringTransform(func(value string) (string, error) {
+		return pseudonymize(key, context, value), nil
+	}), nil
+}
+
+// loadKey reads the secret of a keyed transform from key, key_env or
+// key_file.
+func loadKey(args transformArgs) ([]byte, error) {
+	if key := args.get(-1, "key", ""); key != "" {
+		return []byte(key), nil
+	}
+	if name := args.get(-1, "key_env", ""); name != "" {
+		key := os.Getenv(name)
+		if key == "" {
+			return nil, fmt.Errorf("environment variable %s is empty", name)
+		}
+		return []byte(key), nil
+	}
+	if path := args.get(-1, "key_file", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimSpace(string(data))), nil
+	}
+	return nil, fmt.Errorf("one of key, key_env or key_file is required")
+}
+
+func pseudonymize(key []byte, context, value string) string {
+	// The keystream is HMAC(key, context, counter, value) for counter 0, 1,
+	// ... as long as the value needs.
+	var stream []byte
+	block := func(counter uint32) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(context))
+		mac.Write([]byte{0})
+		binary.Write(mac, binary.BigEndian, counter)
+		mac.Write([]byte(value))
+		stream = mac.Sum(stream)
+	}
+
+	var out strings.Builder
+	for i, r := range []rune(value) {
+		for i >= len(stream) {
+			block(uint32(len(stream) / sha256.Size))
+		}
+		b := stream[i]
+		switch {
+		case r >= '0' && r <= '9':
+			out.WriteByte('0' + b%10)
+		case r >= 'a' && r <= 'z':
+			out.WriteByte('a' + b%26)
+		case r >= 'A' && r <= 'Z':
+			out.WriteByte('A' + b%26)
+		case unicode.IsLetter(r) && unicode.IsUpper(r):
+			out.WriteByte('A' + b%26)
+		case unicode.IsLetter(r):
+			out.WriteByte('a' + b%26)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
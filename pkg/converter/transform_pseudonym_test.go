@@ -0,0 +1,78 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestPseudonymize(t *testing.T) {
+	value := "Jane Roe <jane.roe42@example.com>"
+	got, err := applyTransforms(`pseudonymize(key="k1")`, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pseudonym := got.(string)
+	if pseudonym == value || len(pseudonym) != len(value) {
+		t.Fatalf("pseudonymize(%q) = %q", value, pseudonym)
+	}
+	for i, r := range pseudonym {
+		want := rune(value[i])
+		switch {
+		case unicode.IsDigit(want):
+			if !unicode.IsDigit(r) {
+				t.Errorf("position %d: %q replaced digit %q", i, r, want)
+			}
+		case unicode.IsUpper(want):
+			if !unicode.IsUpper(r) {
+				t.Errorf("position %d: %q replaced upper case %q", i, r, want)
+			}
+		case unicode.IsLower(want):
+			if !unicode.IsLower(r) {
+				t.Errorf("position %d: %q replaced lower case %q", i, r, want)
+			}
+		case r != want:
+			t.Errorf("position %d: %q replaced %q", i, r, want)
+		}
+	}
+
+	again, _ := applyTransforms(`pseudonymize(key="k1")`, value)
+	otherKey, _ := applyTransforms(`pseudonymize(key="k2")`, value)
+	otherContext, _ := applyTransforms(`pseudonymize(key="k1", context=orders)`, value)
+	if again != pseudonym {
+		t.Errorf("pseudonymize is not deterministic: %q, %q", pseudonym, again)
+	}
+	if otherKey == pseudonym || otherContext == pseudonym {
+		t.Errorf("key or context did not change the pseudonym: %q, %q", otherKey, otherContext)
+	}
+
+	long := strings.Repeat("a", 100)
+	if got, _ := applyTransforms(`pseudonymize(key="k1")`, long); len(got.(string)) != len(long) {
+		t.Errorf("pseudonymize of %d letters has length %d", len(long), len(got.(string)))
+	}
+}
+
+func TestPseudonymizeKeySources(t *testing.T) {
+	want, _ := applyTransforms(`pseudonymize(key="secret")`, "alice")
+
+	t.Setenv("PSEUDONYM_TEST_KEY", "secret")
+	if got, err := applyTransforms("pseudonymize(key_env=PSEUDONYM_TEST_KEY)", "alice"); err != nil || got != want {
+		t.Errorf("key_env: %v, %v; want %v", got, err, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := applyTransforms("pseudonymize(key_file="+path+")", "alice"); err != nil || got != want {
+		t.Errorf("key_file: %v, %v; want %v", got, err, want)
+	}
+
+	for _, spec := range []string{"pseudonymize", "pseudonymize(key_env=PSEUDONYM_UNSET_KEY)", "pseudonymize(key_file=/nonexistent)"} {
+		if _, err := applyTransforms(spec, "alice"); err == nil {
+			t.Errorf("%s succeeded without a key", spec)
+		}
+	}
+}
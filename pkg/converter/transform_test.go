@@ -0,0 +1,220 @@
+package converter
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// applyTransforms pipes value through the transforms of a field_mapping
+// source such as "x | hash(md5)".
+func applyTransforms(spec string, value interface{}) (interface{}, error) {
+	source, err := parseFieldSource("x | " + spec)
+	if err != nil {
+		return nil, err
+	}
+	return source.apply(value)
+}
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		spec    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"hash", "alice", "2bd806c97f0e00af1a1fc3328fa763a9269723c8db8fac4f93af71db186d6e90", false},
+		{`hash(sha256, salt="pepper")`, "alice", "b1b68da447843a6519d8dd7a9c13c90aa1148805cbe55810f86712e6c294ff36", false},
+		{"hash(md5)", "alice", "6384e2b2184bcbf58eccf10ca7a6563c", false},
+		{"hash(algorithm=crc)", "alice", nil, true},
+		{"redact", "secret", "******", false},
+		{"redact(keep_last=4)", "4111111111111111", "************1111", false},
+		{`redact(keep_first=1, keep_last=1, char="#")`, "héllo", "h###o", false},
+		{"redact(keep_last=9)", "short", "short", false},
+		{"redact(keep_last=x)", "short", nil, true},
+		{"mask_email", "john.doe@example.com", "j*******@example.com", false},
+		{"mask_email", "nobody", "******", false},
+		{"mask_email", []interface{}{"a@b.c", "xy@b.c"}, []interface{}{"a@b.c", "x*@b.c"}, false},
+		{"mask_email", map[string]interface{}{"a": 1}, nil, true},
+		{"redact | hash(md5)", "x", "3389dae361af79b04c9c8e7057f60cc6", false},
+		{"redact", NullValue, NullValue, false},
+		{"format_number(decimals=2)", 2.5, json.Number("2.50"), false},
+		{"format_number(decimals=2)", 2.675, json.Number("2.68"), false},
+		{"format_number(decimals=2)", 2.665, json.Number("2.66"), false},
+		{"format_number(decimals=0)", -2.5, json.Number("-2"), false},
+		{"format_number(decimals=1)", "0.05", json.Number("0.0"), false},
+		{"format_number", 1e6, json.Number("1000000"), false},
+		{"format_number", 1.0, json.Number("1"), false},
+		{"format_number", 1.25e-7, json.Number("0.000000125"), false},
+		{"format_number(decimals=2)", int64(7), json.Number("7.00"), false},
+		{"format_number", json.Number("123456789012345678901234"), json.Number("123456789012345678901234"), false},
+		{"format_number", []interface{}{"007", " 1.50 "}, []interface{}{json.Number("7"), json.Number("1.5")}, false},
+		{"format_number", "NaN", nil, true},
+		{"format_number", "1/3", nil, true},
+		{"format_number", true, nil, true},
+		{`tz_convert(from="Europe/Berlin")`, "2024-07-01 12:00", "2024-07-01T10:00:00Z", false},
+		{`tz_convert(from="Europe/Berlin")`, "2024-01-01T00:00:00+05:00", "2023-12-31T19:00:00Z", false},
+		{`tz_convert(from="Europe/Berlin")`, "2024-10-27T02:30:00", "2024-10-27T00:30:00Z", false},
+		{`tz_convert(from="Europe/Berlin", ambiguous=later)`, "2024-10-27T02:30:00", "2024-10-27T01:30:00Z", false},
+		{`tz_convert(from="Europe/Berlin", ambiguous=error)`, "2024-10-27T02:30:00", nil, true},
+		{`tz_convert(from="Europe/Berlin", to="Europe/Berlin")`, "2024-03-31 02:30", "2024-03-31T03:30:00+02:00", false},
+		{`tz_convert(from="Europe/Berlin", nonexistent=error)`, "2024-03-31 02:30", nil, true},
+		{`tz_convert(UTC, "America/New_York", format="2006-01-02 15:04")`, "2024-01-15", "2024-01-14 19:00", false},
+		{`tz_convert(UTC, layout="02/01/2006 15:04")`, []interface{}{"15/01/2024 08:30"}, []interface{}{"2024-01-15T08:30:00Z"}, false},
+		{`tz_convert(from="Asia/Tokyo")`, int64(0), "1970-01-01T00:00:00Z", false},
+		{`tz_convert(from="Asia/Tokyo")`, "yesterday", nil, true},
+		{`tz_convert(from="Mars/Olympus")`, "2024-01-15", nil, true},
+		{`tz_convert(from="UTC", ambiguous=both)`, "2024-01-15", nil, true},
+		{"normalize_date", int64(1705312800), "2024-01-15T10:00:00Z", false},
+		{"normalize_date", int64(1705312800123), "2024-01-15T10:00:00.123Z", false},
+		{"normalize_date", int64(1705312800123456), "2024-01-15T10:00:00.123456Z", false},
+		{"normalize_date", int64(1705312800123456789), "2024-01-15T10:00:00.123456789Z", false},
+		{"normalize_date", 1705312800.5, "2024-01-15T10:00:00.5Z", false},
+		{"normalize_date", json.Number("1705312800000"), "2024-01-15T10:00:00Z", false},
+		{"normalize_date", "1705312800", "2024-01-15T10:00:00Z", false},
+		{"normalize_date", "20240115", "2024-01-15T00:00:00Z", false},
+		{"normalize_date", "Mon, 15 Jan 2024 11:00:00 +0100", "2024-01-15T10:00:00Z", false},
+		{"normalize_date", "2024/01/15 10:00:00", "2024-01-15T10:00:00Z", false},
+		{"normalize_date(from=Europe/Paris)", "2024-01-15 11:00", "2024-01-15T10:00:00Z", false},
+		{"normalize_date(format=epoch_millis)", "2024-01-15T10:00:00Z", int64(1705312800000), false},
+		{"normalize_date(format=epoch_second)", []interface{}{int64(1705312800000)}, []interface{}{int64(1705312800)}, false},
+		{`normalize_date(format="2006-01-02 15:04", to="Asia/Tokyo")`, "2024-01-15T10:00:00Z", "2024-01-15 19:00", false},
+		{"normalize_date", "soon", nil, true},
+		{"normalize_date", true, nil, true},
+		{"parse_url", "https://bob:pw@example.com:8443/files/report.pdf?q=1#top", map[string]interface{}{
+			"scheme": "https", "domain": "example.com", "port": int64(8443), "path": "/files/report.pdf",
+			"extension": "pdf", "query": "q=1", "fragment": "top", "user_info": "bob:pw", "username": "bob",
+			"password": "pw", "original": "https://bob:pw@example.com:8443/files/report.pdf?q=1#top",
+		}, false},
+		{"parse_url(keep_original=false, params=true)", "http://example.com/?tag=a&tag=b", map[string]interface{}{
+			"scheme": "http", "domain": "example.com", "path": "/", "query": "tag=a&tag=b",
+			"params": map[string]interface{}{"tag": []interface{}{"a", "b"}},
+		}, false},
+		{"parse_url", "http://%zz", nil, true},
+		{"parse_url", int64(1), nil, true},
+		{"parse_url(params=maybe)", "http://example.com", nil, true},
+		{"parse_json", `{"id": 9007199254740993, "tags": ["a"], "ratio": 0.5}`, map[string]interface{}{
+			"id": int64(9007199254740993), "tags": []interface{}{"a"}, "ratio": 0.5,
+		}, false},
+		{"parse_json", []interface{}{"kept"}, []interface{}{"kept"}, false},
+		{"parse_json", `{"a": 1} trailing`, nil, true},
+		{"parse_json(on_error=keep)", "not json", "not json", false},
+		{"parse_json(on_error=null)", "not json", nil, false},
+		{"parse_json", int64(1), nil, true},
+		{"parse_json(on_error=drop)", "{}", nil, true},
+		{"strip_html", `<p>Caf&eacute; <b>open</b></p><script>alert(1)</script><p>daily</p>`, "\nCafé open\n\ndaily\n", false},
+		{"strip_html | collapse_whitespace", "<ul><li>one</li><li>two<br>three</li></ul><!-- note -->", "one two three", false},
+		{"strip_html | collapse_whitespace(keep_newlines=true)", "<h1>Title</h1>\u00a0<p>Body  text</p>", "Title\nBody text", false},
+		{"unescape_html", "Tom &amp; Jerry &#39;<b>&lt;3</b>&#39;", "Tom & Jerry '<b><3</b>'", false},
+		{"collapse_whitespace", "  a\t\u00a0 b\n\nc  ", "a b c", false},
+		{"collapse_whitespace(keep_newlines=maybe)", "a", nil, true},
+		{"strip_html", []interface{}{"<i>x</i>"}, []interface{}{"x"}, false},
+		{"decode(base64)", "aMOpbGxv", "héllo", false},
+		{"decode(base64)", " aMOpbGxv== ", "héllo", false},
+		{"decode(base64, to=json)", "eyJpZCI6OTAwNzE5OTI1NDc0MDk5M30=", map[string]interface{}{"id": int64(9007199254740993)}, false},
+		{"decode(base64, to=hex)", "-_-_", "fbffbf", false},
+		{"decode(hex, to=base64)", "fbffbf", "+/+/", false},
+		{"decode(hex, to=base64url)", "fbffbf", "-_-_", false},
+		{"decode(base64)", "/w==", nil, true},
+		{"decode(hex)", "zz", nil, true},
+		{"decode(base64, to=json)", "aMOpbGxv", nil, true},
+		{"decode(rot13)", "x", nil, true},
+		{"decode(hex)", []interface{}{"6869"}, []interface{}{"hi"}, false},
+		{"encode(base64)", "a?b>", "YT9iPg==", false},
+		{"encode(base64url)", "a?b>", "YT9iPg", false},
+		{"encode(hex)", "hi", "6869", false},
+		{"encode(text)", "hi", nil, true},
+		{"array_dedupe", []interface{}{"a", int64(1), "a", map[string]interface{}{"k": "v"}, map[string]interface{}{"k": "v"}}, []interface{}{
+			"a", int64(1), map[string]interface{}{"k": "v"},
+		}, false},
+		{"array_dedupe", "solo", []interface{}{"solo"}, false},
+		{"array_sort", []interface{}{int64(10), 2.5, int64(3)}, []interface{}{2.5, int64(3), int64(10)}, false},
+		{"array_sort(desc)", []interface{}{"b", "c", "a"}, []interface{}{"c", "b", "a"}, false},
+		{"array_sort(sideways)", []interface{}{"a"}, nil, true},
+		{"array_slice(1, 3)", []interface{}{"a", "b", "c", "d"}, []interface{}{"b", "c"}, false},
+		{"array_slice(start=-2)", []interface{}{"a", "b", "c", "d"}, []interface{}{"c", "d"}, false},
+		{"array_slice(3, 1)", []interface{}{"a", "b", "c", "d"}, []interface{}{}, false},
+		{"array_slice(one)", []interface{}{"a"}, nil, true},
+		{"array_join", []interface{}{"a", nil, int64(2), []interface{}{"x"}}, `a, 2, ["x"]`, false},
+		{`array_join("/")`, []interface{}{"usr", "bin"}, "usr/bin", false},
+		{`array_filter("gt . 50")`, []interface{}{int64(10), 50.5, int64(70)}, []interface{}{50.5, int64(70)}, false},
+		{"array_filter(\"and .active (ne .role `bot`)\")", []interface{}{
+			map[string]interface{}{"active": true, "role": "admin"},
+			map[string]interface{}{"active": true, "role": "bot"},
+			map[string]interface{}{"active": false, "role": "admin"},
+		}, []interface{}{map[string]interface{}{"active": true, "role": "admin"}}, false},
+		{`array_filter("")`, []interface{}{"a"}, nil, true},
+		{"split_to_array", "red, green,,blue ", []interface{}{"red", "green", "blue"}, false},
+		{`split_to_array("/", keep_empty=true, trim=false)`, "/usr/ bin", []interface{}{"", "usr", " bin"}, false},
+		{"split_to_array", []interface{}{"a,b", int64(3), "c"}, []interface{}{"a", "b", int64(3), "c"}, false},
+		{"split_to_array", map[string]interface{}{"a": "b"}, nil, true},
+		{`split_to_array("")`, "a", nil, true},
+		{"join_from_array", []interface{}{"a", "b"}, "a,b", false},
+		{`join_from_array(";")`, []interface{}{"a", int64(1)}, "a;1", false},
+	}
+	for _, tt := range tests {
+		got, err := applyTransforms(tt.spec, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s(%v) error = %v, want error %v", tt.spec, tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s(%v) = %#v, want %#v", tt.spec, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseTransformCall(t *testing.T) {
+	tests := []struct {
+		call    string
+		want    transformArgs
+		wantErr bool
+	}{
+		{"upper", transformArgs{name: "upper", named: map[string]string{}}, false},
+		{`hash(sha1, salt="a, b")`, transformArgs{name: "hash", positional: []string{"sha1"}, named: map[string]string{"salt": "a, b"}}, false},
+		{`replace("=", "\"")`, transformArgs{name: "replace", positional: []string{"=", `"`}, named: map[string]string{}}, false},
+		{"hash(sha1", transformArgs{}, true},
+		{`hash("sha1)`, transformArgs{}, true},
+		{"", transformArgs{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTransformCall(tt.call)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTransformCall(%q) error = %v, want error %v", tt.call, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTransformCall(%q) = %+v, want %+v", tt.call, got, tt.want)
+		}
+	}
+}
+
+func TestParseFieldSourceErrors(t *testing.T) {
+	for _, spec := range []string{"x | nope", "x | hash(sha1", `x | "`} {
+		if _, err := parseFieldSource(spec); err == nil {
+			t.Errorf("parseFieldSource(%q) succeeded", spec)
+		}
+	}
+	if got := SourcePaths(" user.email | mask_email "); !reflect.DeepEqual(got, []string{"user.email"}) {
+		t.Errorf("SourcePaths() = %q, want [user.email]", got)
+	}
+	if got := SourcePaths("sum(price, tax.amount, \"1\") | round"); !reflect.DeepEqual(got, []string{"price", "tax.amount"}) {
+		t.Errorf("SourcePaths() = %q, want [price tax.amount]", got)
+	}
+}
+
+func TestConvertTransforms(t *testing.T) {
+	c, err := New(parseMapping(t, `{"field_mapping": {"contact": "email | mask_email", "card": "card | redact(keep_last=2)"}}`), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	got, err := c.Convert(parseDoc(t, `{"_id":"1","_source":{"email":"bob@example.com","card":"123456"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"contact": "b**@example.com", "card": "****56"}
+	if !reflect.DeepEqual(got.Source, want) {
+		t.Errorf("Convert() = %v, want %v", got.Source, want)
+	}
+}
@@ -0,0 +1,313 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	// The zone database is embedded so that tz_convert works on hosts
+	// without one, such as scratch containers.
+	_ "time/tzdata"
+)
+
+func init() {
+	registerTransform("tz_convert", newTZConvertTransform)
+	registerTransform("normalize_date", newNormalizeDateTransform)
+}
+
+// zonedLayouts are the layouts times with an offset are read in, and
+// localLayouts those of local times without one; fractional seconds are
+// optional where there are seconds.
+var (
+	zonedLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999Z0700",
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999 -0700",
+		time.RFC1123Z,
+		time.RFC1123,
+		time.RFC850,
+		time.UnixDate,
+		time.RubyDate,
+	}
+	localLayouts = []string{
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04",
+		"2006-01-02",
+		"2006/01/02 15:04:05.999999999",
+		"2006/01/02",
+		time.ANSIC,
+	}
+)
+
+// newTZConvertTransform reads a local time of one zone and writes it in
+// another, by default as RFC 3339:
+//
+//	tz_convert(from="Europe/Berlin", to=UTC)
+//	tz_convert(America/New_York, layout="01/02/2006 15:04", format="2006-01-02 15:04:05")
+//
+// Without layout, times are read in the common layouts, RFC 3339 and RFC
+// 1123 among them, whose offset wins over from, or local as
+// 2006-01-02T15:04:05, with a space for the T, optional seconds and
+// fractions, or as a bare date; epoch milliseconds are instants of their
+// own. to defaults to UTC. A local time the clocks fall back over
+// occurs twice: ambiguous picks the earlier (the default) or the later, or
+// fails with error. A time the clocks spring forward over does not occur:
+// nonexistent shifts it forward by the gap (the default), or fails with
+// error.
+func newTZConvertTransform(args transformArgs) (Transform, error) {
+	fromName := args.get(0, "from", "")
+	if fromName == "" {
+		return nil, fmt.Errorf("from must be a time zone name, e.g. Europe/Berlin")
+	}
+	from, err := time.LoadLocation(fromName)
+	if err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	to, err := time.LoadLocation(args.get(1, "to", "UTC"))
+	if err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+	layout := args.get(-1, "layout", "")
+	format := args.get(-1, "format", time.RFC3339Nano)
+	ambiguous := args.get(-1, "ambiguous", "earlier")
+	if ambiguous != "earlier" && ambiguous != "later" && ambiguous != "error" {
+		return nil, fmt.Errorf("ambiguous must be earlier, later or error")
+	}
+	nonexistent := args.get(-1, "nonexistent", "shift")
+	if nonexistent != "shift" && nonexistent != "error" {
+		return nil, fmt.Errorf("nonexistent must be shift or error")
+	}
+	var transform Transform
+	transform = func(value interface{}) (interface{}, error) {
+		var t time.Time
+		switch typed := value.(type) {
+		case []interface{}:
+			out := make([]interface{}, len(typed))
+			for i, item := range typed {
+				var err error
+				if out[i], err = transform(item); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		case float64:
+			t = time.UnixMilli(int64(typed))
+		case int64:
+			t = time.UnixMilli(typed)
+		case int:
+			t = time.UnixMilli(int64(typed))
+		case string:
+			wall, offset, err := parseWallClock(typed, layout)
+			if err != nil {
+				return nil, err
+			}
+			if offset {
+				t = wall
+				break
+			}
+			if t, err = localInstant(wall, from, ambiguous, nonexistent); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("cannot convert %T as a time", value)
+		}
+		return t.In(to).Format(format), nil
+	}
+	return transform, nil
+}
+
+// parseWallClock parses text with layout, or the default layouts, and
+// reports whether it carried its own offset. A time without one is
+// returned as its wall clock reading in UTC.
+func parseWallClock(text, layout string) (time.Time, bool, error) {
+	if layout != "" {
+		t, err := time.Parse(layout, text)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("cannot parse %q with layout %q", text, layout)
+		}
+		zoned := strings.Contains(layout, "Z07") || strings.Contains(layout, "-07") || strings.Contains(layout, "MST")
+		return t, zoned, nil
+	}
+	for _, l := range zonedLayouts {
+		if t, err := time.Parse(l, text); err == nil {
+			return t, true, nil
+		}
+	}
+	for _, l := range localLayouts {
+		if t, err := time.Parse(l, text); err == nil {
+			return t, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("cannot parse %q as a time", text)
+}
+
+// localInstant returns the instant the wall clock reading wall, given in
+// UTC, shows in loc. Clocks change at most once a day, so the offsets a day
+// either side are the only candidates.
+func localInstant(wall time.Time, loc *time.Location, ambiguous, nonexistent string) (time.Time, error) {
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	var matches []time.Time
+	for _, offset := range []int{before, after} {
+		t := wall.Add(-time.Duration(offset) * time.Second)
+		if sameWallClock(t.In(loc), wall) && (len(matches) == 0 || !matches[0].Equal(t)) {
+			matches = append(matches, t)
+		}
+	}
+	switch {
+	case len(matches) == 0 && nonexistent == "error":
+		return time.Time{}, fmt.Errorf("%s does not occur in %s", wall.Format("2006-01-02 15:04:05"), loc)
+	case len(matches) == 0:
+		// Read with the offset before the change, the time lands past it
+		// by the gap.
+		return wall.Add(-time.Duration(before) * time.Second), nil
+	case len(matches) == 1:
+		return matches[0], nil
+	case ambiguous == "error":
+		return time.Time{}, fmt.Errorf("%s occurs twice in %s", wall.Format("2006-01-02 15:04:05"), loc)
+	}
+	earlier, later := matches[0], matches[1]
+	if later.Before(earlier) {
+		earlier, later = later, earlier
+	}
+	if ambiguous == "later" {
+		return later, nil
+	}
+	return earlier, nil
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	wy, wmo, wd := wall.Date()
+	wh, wmi, ws := wall.Clock()
+	return y == wy && mo == wmo && d == wd && h == wh && mi == wmi && s == ws && t.Nanosecond() == wall.Nanosecond()
+}
+
+// newNormalizeDateTransform writes timestamps of mixed formats in one: it
+// tells epoch seconds, milliseconds, microseconds and nanoseconds apart by
+// their magnitude, which holds for times between 1973 and 5138, reads
+// numeric strings as epochs too, bar eight digits that make a basic date
+// such as 20240115, and reads other strings like tz_convert, local times in
+// from (default UTC). format is a Go layout, RFC 3339 by default, or
+// epoch_millis or epoch_second for a number; to is the zone of the layout,
+// UTC by default.
+//
+//	normalize_date
+//	normalize_date(format=epoch_millis)
+//	normalize_date(from=Europe/Paris, format="2006-01-02 15:04:05", to=Europe/Paris)
+func newNormalizeDateTransform(args transformArgs) (Transform, error) {
+	from, err := time.LoadLocation(args.get(-1, "from", "UTC"))
+	if err != nil {
+		return nil, fmt.Errorf("from: %w", err)
+	}
+	to, err := time.LoadLocation(args.get(-1, "to", "UTC"))
+	if err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+	format := args.get(0, "format", time.RFC3339Nano)
+	var transform Transform
+	transform = func(value interface{}) (interface{}, error) {
+		if items, ok := value.([]interface{}); ok {
+			out := make([]interface{}, len(items))
+			for i, item := range items {
+				var err error
+				if out[i], err = transform(item); err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		}
+		t, err := detectTime(value, from)
+		if err != nil {
+			return nil, err
+		}
+		switch format {
+		case "epoch_millis":
+			return t.UnixMilli(), nil
+		case "epoch_second":
+			return t.Unix(), nil
+		}
+		return t.In(to).Format(format), nil
+	}
+	return transform, nil
+}
+
+// detectTime reads value as normalize_date does, local times in from.
+func detectTime(value interface{}, from *time.Location) (time.Time, error) {
+	switch typed := value.(type) {
+	case float64:
+		return epochTime(typed), nil
+	case int64:
+		return epochInteger(typed), nil
+	case int:
+		return epochInteger(int64(typed)), nil
+	case json.Number:
+		if n, err := typed.Int64(); err == nil {
+			return epochInteger(n), nil
+		}
+		f, err := typed.Float64()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot read %s as a time", typed)
+		}
+		return epochTime(f), nil
+	case string:
+		text := strings.TrimSpace(typed)
+		if isBasicDate(text) {
+			text = text[:4] + "-" + text[4:6] + "-" + text[6:]
+		} else if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return epochInteger(n), nil
+		} else if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return epochTime(f), nil
+		}
+		wall, offset, err := parseWallClock(text, "")
+		if err != nil || offset {
+			return wall, err
+		}
+		return localInstant(wall, from, "earlier", "shift")
+	}
+	return time.Time{}, fmt.Errorf("cannot read %T as a time", value)
+}
+
+// epochTime reads n as epoch seconds, milliseconds, microseconds or
+// nanoseconds, whichever puts it between 1973 and 5138.
+func epochTime(n float64) time.Time {
+	switch abs := math.Abs(n); {
+	case abs < 1e11:
+		sec, frac := math.Modf(n)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e9))).UTC()
+	case abs < 1e14:
+		return time.UnixMicro(int64(math.Round(n * 1e3))).UTC()
+	case abs < 1e17:
+		return time.UnixMicro(int64(math.Round(n))).UTC()
+	}
+	return time.Unix(0, int64(n)).UTC()
+}
+
+// epochInteger is epochTime for integers, exact to the nanosecond.
+func epochInteger(n int64) time.Time {
+	switch abs := max(n, -n); {
+	case abs < 1e11:
+		return time.Unix(n, 0).UTC()
+	case abs < 1e14:
+		return time.UnixMilli(n).UTC()
+	case abs < 1e17:
+		return time.UnixMicro(n).UTC()
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// isBasicDate reports whether text is a valid date written as yyyymmdd.
+func isBasicDate(text string) bool {
+	if len(text) != 8 || !isDigits(text) {
+		return false
+	}
+	_, err := time.Parse("20060102", text)
+	return err == nil
+}
@@ -0,0 +1,84 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerTransform("parse_url", newParseURLTransform)
+}
+
+// newParseURLTransform splits a URL into the fields of the Elasticsearch
+// uri_parts processor: scheme, domain, port, path, extension, query,
+// fragment, user_info, username, password and original, less those the URL
+// lacks. params adds the query parameters as an object of arrays of values.
+//
+//	parse_url
+//	parse_url(keep_original=false, params=true)
+func newParseURLTransform(args transformArgs) (Transform, error) {
+	keepOriginal, err := strconv.ParseBool(args.get(-1, "keep_original", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("keep_original must be true or false")
+	}
+	params, err := strconv.ParseBool(args.get(-1, "params", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("params must be true or false")
+	}
+	return func(value interface{}) (interface{}, error) {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot parse %T as a URL", value)
+		}
+		u, err := url.Parse(strings.TrimSpace(text))
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q", text)
+		}
+		parts := map[string]interface{}{}
+		set := func(key, value string) {
+			if value != "" {
+				parts[key] = value
+			}
+		}
+		set("scheme", u.Scheme)
+		set("domain", u.Hostname())
+		if port := u.Port(); port != "" {
+			n, err := strconv.ParseInt(port, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port in URL %q", text)
+			}
+			parts["port"] = n
+		}
+		set("path", u.Path)
+		if ext := path.Ext(u.Path); len(ext) > 1 {
+			parts["extension"] = ext[1:]
+		}
+		set("query", u.RawQuery)
+		set("fragment", u.Fragment)
+		if u.User != nil {
+			set("user_info", u.User.String())
+			set("username", u.User.Username())
+			if password, ok := u.User.Password(); ok {
+				set("password", password)
+			}
+		}
+		if keepOriginal {
+			parts["original"] = text
+		}
+		if params && u.RawQuery != "" {
+			query := map[string]interface{}{}
+			for key, values := range u.Query() {
+				items := make([]interface{}, len(values))
+				for i, value := range values {
+					items[i] = value
+				}
+				query[key] = items
+			}
+			parts["params"] = query
+		}
+		return parts, nil
+	}, nil
+}
@@ -0,0 +1,188 @@
+package converter
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// maxMacroDepth bounds macros that use other macros, catching cycles.
+const maxMacroDepth = 10
+
+// templateFuncs are available in mapping templates besides the variables.
+var templateFuncs = template.FuncMap{
+	"env":   os.Getenv,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// resolveVars expands the "vars" and "macros" sections of a decoded mapping
+// file in place and removes them.
+//
+// Every string of the mapping, keys included, is a text/template executed
+// with the variables, e.g. "logs-{{.env}}", except the values of templates
+// sections. A list element of the form
+// {"macro": "name", "args": {...}} is replaced by the elements of the named
+// macro, itself a list expanded with the variables and args.
+func resolveVars(raw map[string]interface{}) error {
+	vars := map[string]interface{}{}
+	if section, ok := raw["vars"]; ok {
+		defined, ok := section.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("vars must be an object")
+		}
+		for name, value := range defined {
+			expanded, err := expand(value, nil)
+			if err != nil {
+				return fmt.Errorf("var %s: %w", name, err)
+			}
+			vars[name] = expanded
+		}
+	}
+	macros := map[string][]interface{}{}
+	if section, ok := raw["macros"]; ok {
+		defined, ok := section.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("macros must be an object")
+		}
+		for name, body := range defined {
+			if macros[name], ok = body.([]interface{}); !ok {
+				return fmt.Errorf("macro %s must be a list", name)
+			}
+		}
+	}
+	delete(raw, "vars")
+	delete(raw, "macros")
+
+	for key, value := range raw {
+		if key == "templates" {
+			continue
+		}
+		expanded, err := expand(value, vars)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if expanded, err = applyMacros(expanded, macros, vars, 0); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		raw[key] = expanded
+	}
+	return nil
+}
+
+// expand executes every string in value as a template over data.
+func expand(value interface{}, data map[string]interface{}) (interface{}, error) {
+	switch typed := value.(type) {
+	case string:
+		return expandString(typed, data)
+	case []interface{}:
+		out := make([]interface{}, len(typed))
+		for i, item := range typed {
+			var err error
+			if out[i], err = expand(item, data); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			expandedKey, err := expandString(key, data)
+			if err != nil {
+				return nil, err
+			}
+			if key == "templates" {
+				// Rendered per document instead; see parseComputed.
+				out[expandedKey] = item
+				continue
+			}
+			if out[expandedKey], err = expand(item, data); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		return value, nil
+	}
+}
+
+func expandString(text string, data map[string]interface{}) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("mapping").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err = tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// applyMacros splices macro uses found in the lists of value.
+func applyMacros(value interface{}, macros map[string][]interface{}, vars map[string]interface{}, depth int) (interface{}, error) {
+	switch typed := value.(type) {
+	case []interface{}:
+		var out []interface{}
+		for _, item := range typed {
+			use, ok := item.(map[string]interface{})
+			name, isMacro := use["macro"].(string)
+			if !ok || !isMacro {
+				expanded, err := applyMacros(item, macros, vars, depth)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, expanded)
+				continue
+			}
+			body, err := expandMacro(name, use["args"], macros, vars, depth)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, body...)
+		}
+		return out, nil
+	case map[string]interface{}:
+		for key, item := range typed {
+			expanded, err := applyMacros(item, macros, vars, depth)
+			if err != nil {
+				return nil, err
+			}
+			typed[key] = expanded
+		}
+		return typed, nil
+	default:
+		return value, nil
+	}
+}
+
+func expandMacro(name string, args interface{}, macros map[string][]interface{}, vars map[string]interface{}, depth int) ([]interface{}, error) {
+	if depth >= maxMacroDepth {
+		return nil, fmt.Errorf("macro %s: nested more than %d levels deep", name, maxMacroDepth)
+	}
+	body, ok := macros[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown macro %q", name)
+	}
+	data := maps.Clone(vars)
+	if args != nil {
+		given, ok := args.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("macro %s: args must be an object", name)
+		}
+		maps.Copy(data, given)
+	}
+	expanded, err := expand(body, data)
+	if err != nil {
+		return nil, fmt.Errorf("macro %s: %w", name, err)
+	}
+	expanded, err = applyMacros(expanded, macros, vars, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return expanded.([]interface{}), nil
+}
@@ -0,0 +1,216 @@
+package converter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// wasmModule is an instantiated WebAssembly module exporting
+//
+//	memory
+//	alloc(size i32) i32
+//	<function>(ptr i32, len i32) i64
+//
+// The function receives a JSON value in the memory alloc returned and
+// answers with another, packed as ptr<<32 | len. A trap is a failed
+// conversion. Modules get WASI without file system, network or
+// environment access, and _initialize runs for reactor modules. A module
+// that trapped is instantiated afresh for the next call.
+type wasmModule struct {
+	mu           sync.Mutex
+	runtime      wazero.Runtime
+	compiled     wazero.CompiledModule
+	functionName string
+	module       api.Module
+	alloc        api.Function
+	function     api.Function
+	name         string
+}
+
+func openWASMModule(path, function string) (*wasmModule, error) {
+	if function == "" {
+		function = "transform"
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCompilationCache(wasmCache))
+	m := &wasmModule{runtime: runtime, functionName: function, name: path}
+	if _, err = wasi_snapshot_preview1.Instantiate(ctx, runtime); err == nil {
+		m.compiled, err = runtime.CompileModule(ctx, code)
+	}
+	if err == nil {
+		err = m.instantiate()
+	}
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *wasmModule) instantiate() error {
+	if m.module != nil {
+		m.module.Close(context.Background())
+	}
+	config := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+	module, err := m.runtime.InstantiateModule(context.Background(), m.compiled, config)
+	if err != nil {
+		return err
+	}
+	m.module = module
+	m.alloc = module.ExportedFunction("alloc")
+	m.function = module.ExportedFunction(m.functionName)
+	switch {
+	case module.ExportedMemory("memory") == nil:
+		return fmt.Errorf("does not export memory")
+	case m.alloc == nil:
+		return fmt.Errorf("does not export alloc")
+	case m.function == nil:
+		return fmt.Errorf("does not export %s", m.functionName)
+	}
+	return nil
+}
+
+// call passes value through the module's function.
+func (m *wasmModule) call(value interface{}) (interface{}, error) {
+	input, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ctx := context.Background()
+	results, err := m.alloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		m.instantiate()
+		return nil, fmt.Errorf("%s: alloc: %w", m.name, err)
+	}
+	ptr := uint32(results[0])
+	memory := m.module.ExportedMemory("memory")
+	if !memory.Write(ptr, input) {
+		return nil, fmt.Errorf("%s: alloc returned memory out of range", m.name)
+	}
+	if results, err = m.function.Call(ctx, uint64(ptr), uint64(len(input))); err != nil {
+		if restartErr := m.instantiate(); restartErr != nil {
+			return nil, fmt.Errorf("%s: %w (restart failed: %v)", m.name, err, restartErr)
+		}
+		return nil, fmt.Errorf("%s: %w", m.name, err)
+	}
+	output, ok := memory.Read(uint32(results[0]>>32), uint32(results[0]))
+	if !ok {
+		return nil, fmt.Errorf("%s: result out of memory range", m.name)
+	}
+	out, err := unmarshalExact(output)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid result: %w", m.name, err)
+	}
+	return out, nil
+}
+
+func (m *wasmModule) Close() error {
+	return m.runtime.Close(context.Background())
+}
+
+// wasmCache keeps compiled code, so a module used by several rules or
+// reloaded with the mapping is only compiled once.
+var wasmCache = wazero.NewCompilationCache()
+
+// wasmModules shares the modules of wasm transforms, which have no
+// lifecycle of their own, by path and function.
+var (
+	wasmModulesMu sync.Mutex
+	wasmModules   = map[string]*wasmModule{}
+)
+
+func init() {
+	registerTransform("wasm", newWASMTransform)
+}
+
+// newWASMTransform passes a field through a module:
+// wasm("rules.wasm", function=normalize).
+func newWASMTransform(args transformArgs) (Transform, error) {
+	path := args.get(0, "module", "")
+	if path == "" {
+		return nil, fmt.Errorf("module is required")
+	}
+	function := args.get(1, "function", "transform")
+	key := path + "#" + function
+	wasmModulesMu.Lock()
+	defer wasmModulesMu.Unlock()
+	module, ok := wasmModules[key]
+	if !ok {
+		var err error
+		if module, err = openWASMModule(path, function); err != nil {
+			return nil, err
+		}
+		wasmModules[key] = module
+	}
+	return module.call, nil
+}
+
+// wasmProcessor passes the output document, or the value of field, through
+// a module. Without field the whole _source is replaced by the result;
+// with it the result goes to target_field, default field.
+type wasmProcessor struct {
+	config ProcessorConfig
+	module *wasmModule
+}
+
+func newWASMProcessor(config ProcessorConfig) (*wasmProcessor, error) {
+	if config.Module == "" {
+		return nil, fmt.Errorf("module is required")
+	}
+	if config.TargetField == "" {
+		config.TargetField = config.Field
+	}
+	module, err := openWASMModule(config.Module, config.Function)
+	if err != nil {
+		return nil, err
+	}
+	return &wasmProcessor{config: config, module: module}, nil
+}
+
+func (p *wasmProcessor) Process(source map[string]interface{}) error {
+	if p.config.Field == "" {
+		out, err := p.module.call(source)
+		if err != nil {
+			return err
+		}
+		object, ok := out.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("wasm: %s returned %T, want an object", p.config.Module, out)
+		}
+		replaceSource(source, object)
+		return nil
+	}
+	value := ExtractFieldValue(source, splitPath(p.config.Field))
+	if value == nil {
+		if p.config.IgnoreMissing {
+			return nil
+		}
+		return fmt.Errorf("wasm: field %s is missing", p.config.Field)
+	}
+	if value == NullValue {
+		value = nil
+	}
+	out, err := p.module.call(value)
+	if err != nil {
+		return err
+	}
+	InsertFieldValue(source, splitPath(p.config.TargetField), out)
+	return nil
+}
+
+func (p *wasmProcessor) Close() error {
+	return p.module.Close()
+}
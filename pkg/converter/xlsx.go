@@ -0,0 +1,401 @@
+package converter
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readXLSX returns the cell values of a worksheet, selected by name or the
+// first sheet when sheet is empty. Values are string, float64 (int64 for
+// whole numbers), bool, or nil for empty cells; numbers formatted as dates
+// are RFC 3339 strings in UTC.
+func readXLSX(filePath, sheet string) ([][]interface{}, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	var workbook struct {
+		Properties struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err = decodeZipXML(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err = decodeZipXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	var target string
+	for _, s := range workbook.Sheets {
+		if sheet == "" || s.Name == sheet {
+			for _, rel := range rels.Relationships {
+				if rel.ID == s.RID {
+					target = rel.Target
+				}
+			}
+			break
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("sheet %q not found", sheet)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	var sharedStrings []string
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []struct {
+				Text string `xml:"t"`
+				Runs []struct {
+					Text string `xml:"t"`
+				} `xml:"r"`
+			} `xml:"si"`
+		}
+		if err = decodeZipXML(files, "xl/sharedStrings.xml", &sst); err != nil {
+			return nil, err
+		}
+		for _, item := range sst.Items {
+			text := item.Text
+			for _, run := range item.Runs {
+				text += run.Text
+			}
+			sharedStrings = append(sharedStrings, text)
+		}
+	}
+
+	dateStyles, err := readDateStyles(files)
+	if err != nil {
+		return nil, err
+	}
+	date1904 := workbook.Properties.Date1904 == "1" || workbook.Properties.Date1904 == "true"
+
+	var worksheet struct {
+		Rows []struct {
+			Index int `xml:"r,attr"`
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Style  int    `xml:"s,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err = decodeZipXML(files, target, &worksheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]interface{}
+	for _, row := range worksheet.Rows {
+		if row.Index > len(rows)+1 {
+			rows = append(rows, make([][]interface{}, row.Index-len(rows)-1)...)
+		}
+		var values []interface{}
+		for i, cell := range row.Cells {
+			col := i
+			if cell.Ref != "" {
+				col = columnIndex(cell.Ref)
+			}
+			for len(values) <= col {
+				values = append(values, nil)
+			}
+			if (cell.Type == "" || cell.Type == "n") && cell.Value != "" &&
+				cell.Style < len(dateStyles) && dateStyles[cell.Style] {
+				values[col], err = dateCellValue(cell.Value, date1904)
+			} else {
+				values[col], err = cellValue(cell.Type, cell.Value, cell.Inline, sharedStrings)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cell %s: %w", cell.Ref, err)
+			}
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+func decodeZipXML(files map[string]*zip.File, name string, v interface{}) error {
+	file, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%s not found in workbook", name)
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return xml.NewDecoder(reader).Decode(v)
+}
+
+// columnIndex converts the letters of a cell reference such as "AB12" to a
+// zero-based column index.
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+func cellValue(typ, value, inline string, sharedStrings []string) (interface{}, error) {
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(value)
+		if err != nil || i < 0 || i >= len(sharedStrings) {
+			return nil, fmt.Errorf("invalid shared string %q", value)
+		}
+		return sharedStrings[i], nil
+	case "inlineStr":
+		return inline, nil
+	case "str", "e":
+		return value, nil
+	case "b":
+		return value == "1", nil
+	default:
+		if value == "" {
+			return nil, nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			return int64(f), nil
+		}
+		return f, nil
+	}
+}
+
+// readDateStyles reports for each cell style (the s attribute of a cell)
+// whether its number format shows a date or time.
+func readDateStyles(files map[string]*zip.File) ([]bool, error) {
+	if _, ok := files["xl/styles.xml"]; !ok {
+		return nil, nil
+	}
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := decodeZipXML(files, "xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+
+	custom := map[int]bool{}
+	for _, numFmt := range styles.NumFmts {
+		custom[numFmt.ID] = isDateFormatCode(numFmt.Code)
+	}
+	dateStyles := make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		if date, ok := custom[xf.NumFmtID]; ok {
+			dateStyles[i] = date
+		} else {
+			dateStyles[i] = isBuiltinDateFormat(xf.NumFmtID)
+		}
+	}
+	return dateStyles, nil
+}
+
+// isBuiltinDateFormat reports whether a built-in number format ID is one of
+// the date and time formats, including their East Asian variants.
+func isBuiltinDateFormat(id int) bool {
+	return id >= 14 && id <= 22 || id >= 27 && id <= 36 || id >= 45 && id <= 47 || id >= 50 && id <= 58
+}
+
+// isDateFormatCode reports whether a custom number format code contains a
+// date or time token outside quoted text, escapes and [bracketed] sections.
+func isDateFormatCode(code string) bool {
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '"':
+			if end := strings.IndexByte(code[i+1:], '"'); end >= 0 {
+				i += end + 1
+			} else {
+				return false
+			}
+		case '[':
+			if end := strings.IndexByte(code[i+1:], ']'); end >= 0 {
+				i += end + 1
+			} else {
+				return false
+			}
+		case '\\', '_', '*':
+			i++
+		case 'y', 'Y', 'm', 'M', 'd', 'D', 'h', 'H', 's', 'S':
+			return true
+		}
+	}
+	return false
+}
+
+// Excel's 1900 date system counts 1 January 1900 as day 1 and takes 1900 for
+// a leap year, so from day 61 (1 March) on serials are one day ahead of the
+// calendar; the fictitious 29 February, day 60, reads as 1 March. The 1904
+// system counts days from 1 January 1904.
+var (
+	excelEpoch1900 = time.Date(1899, time.December, 31, 0, 0, 0, 0, time.UTC)
+	excelEpoch1904 = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// dateCellValue converts the serial number of a date cell, whose fraction is
+// the time of day, to an RFC 3339 timestamp rounded to the second.
+func dateCellValue(value string, date1904 bool) (interface{}, error) {
+	serial, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(serial) || math.Abs(serial) > 1e7 {
+		return nil, fmt.Errorf("invalid date serial %q", value)
+	}
+	epoch := excelEpoch1904
+	if !date1904 {
+		epoch = excelEpoch1900
+		if serial >= 61 {
+			serial--
+		}
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	t := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	return t.Format(time.RFC3339), nil
+}
+
+// xlsxRecords splits a worksheet into its header row (1-based headerRow)
+// and the data rows that follow it.
+func xlsxRecords(filePath, sheet string, headerRow int) ([]string, [][]interface{}, error) {
+	rows, err := readXLSX(filePath, sheet)
+	if err != nil {
+		return nil, nil, err
+	}
+	if headerRow <= 0 {
+		headerRow = 1
+	}
+	if len(rows) < headerRow {
+		return nil, nil, fmt.Errorf("header row %d not found", headerRow)
+	}
+	var headers []string
+	for _, value := range rows[headerRow-1] {
+		if value == nil {
+			headers = append(headers, "")
+		} else {
+			headers = append(headers, fmt.Sprint(value))
+		}
+	}
+	return headers, rows[headerRow:], nil
+}
+
+// xlsxLookupData loads an XLSX lookup into memory keyed by key_column.
+// Native cell types are kept unless the column has a declared type.
+func xlsxLookupData(config LookupConfig) (map[string]map[string]interface{}, error) {
+	headers, rows, err := xlsxRecords(config.Path, config.Sheet, config.HeaderRow)
+	if err != nil {
+		return nil, err
+	}
+	keyIndex, err := keyColumnIndex(headers, config.KeyColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]map[string]interface{})
+	for _, row := range rows {
+		if keyIndex >= len(row) || row[keyIndex] == nil {
+			continue
+		}
+		fields := make(map[string]interface{})
+		for i, header := range headers {
+			if i == keyIndex || header == "" {
+				continue
+			}
+			var value interface{}
+			if i < len(row) {
+				value = row[i]
+			}
+			if _, typed := config.Columns[header]; typed && value != nil {
+				if value, err = config.columnValue(header, fmt.Sprint(value)); err != nil {
+					return nil, fmt.Errorf("invalid value for column %s: %w", header, err)
+				}
+			}
+			if value == nil {
+				value = NullValue
+			}
+			fields[header] = value
+		}
+		data[fmt.Sprint(row[keyIndex])] = fields
+	}
+	return data, nil
+}
+
+// ReadXLSXDocs turns each data row of a worksheet into a document whose
+// source holds the row's cells under their (dotted) header names. The ID
+// comes from idColumn, or the row number when the column is absent.
+func ReadXLSXDocs(filePath, sheet string, headerRow int, idColumn string, limit int) ([]ESDoc, error) {
+	if headerRow <= 0 {
+		headerRow = 1
+	}
+	headers, rows, err := xlsxRecords(filePath, sheet, headerRow)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []ESDoc
+	for n, row := range rows {
+		if limit > 0 && n >= limit {
+			break
+		}
+		if len(row) == 0 {
+			continue
+		}
+		source := map[string]interface{}{}
+		var id string
+		for i, header := range headers {
+			if header == "" || i >= len(row) || row[i] == nil {
+				continue
+			}
+			if header == idColumn {
+				id = fmt.Sprint(row[i])
+				continue
+			}
+			InsertFieldValue(source, strings.Split(header, "."), row[i])
+		}
+		if id == "" {
+			id = strconv.Itoa(headerRow + n + 1)
+		}
+		docs = append(docs, ESDoc{ESMeta: ESMeta{ID: &id}, Source: source})
+	}
+	return docs, nil
+}
@@ -0,0 +1,128 @@
+package converter
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+
+const testStyles = `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="3">
+<numFmt numFmtId="164" formatCode="yyyy\-mm\-dd\ hh:mm"/>
+<numFmt numFmtId="165" formatCode="&quot;day&quot;\ 0.00"/>
+<numFmt numFmtId="166" formatCode="[Red]#,##0"/>
+</numFmts>
+<cellXfs count="5">
+<xf numFmtId="0"/>
+<xf numFmtId="14"/>
+<xf numFmtId="164"/>
+<xf numFmtId="165"/>
+<xf numFmtId="166"/>
+</cellXfs>
+</styleSheet>`
+
+const testSheet = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1">
+<c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c><c r="E1" t="s"><v>4</v></c>
+</row>
+<row r="2">
+<c r="A2"><v>1</v></c><c r="B2" s="1"><v>45122</v></c><c r="C2" s="2"><v>45122.75</v></c><c r="D2" s="3"><v>2.5</v></c><c r="E2" s="4"><v>1200</v></c>
+</row>
+<row r="3">
+<c r="A3"><v>2</v></c><c r="B3" s="1"><v>59</v></c><c r="C3" s="2"><v>61</v></c>
+</row>
+</sheetData>
+</worksheet>`
+
+const testSharedStrings = `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>id</t></si><si><t>day</t></si><si><t>at</t></si><si><t>score</t></si><si><t>total</t></si>
+</sst>`
+
+func writeTestXLSX(t *testing.T, workbookPr string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.xlsx")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	parts := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+` + workbookPr + `<sheets><sheet name="Data" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`,
+		"xl/_rels/workbook.xml.rels": testRels,
+		"xl/styles.xml":              testStyles,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/sheet1.xml":   testSheet,
+	}
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadXLSXDates(t *testing.T) {
+	rows, err := readXLSX(writeTestXLSX(t, ""), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{
+		{"id", "day", "at", "score", "total"},
+		{int64(1), "2023-07-15T00:00:00Z", "2023-07-15T18:00:00Z", 2.5, int64(1200)},
+		{int64(2), "1900-02-28T00:00:00Z", "1900-03-01T00:00:00Z"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("readXLSX() = %v, want %v", rows, want)
+	}
+}
+
+func TestReadXLSXDate1904(t *testing.T) {
+	rows, err := readXLSX(writeTestXLSX(t, `<workbookPr date1904="1"/>`), "Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rows[1][1]; got != "2027-07-16T00:00:00Z" {
+		t.Errorf("1904 date = %v, want 2027-07-16T00:00:00Z", got)
+	}
+}
+
+func TestIsDateFormatCode(t *testing.T) {
+	tests := map[string]bool{
+		"yyyy-mm-dd":           true,
+		"[$-409]h:mm AM/PM":    true,
+		"d/m/yy;@":             true,
+		"0.00":                 false,
+		"#,##0_);[Red](#,##0)": false,
+		`"days "0`:             false,
+		`\d0`:                  false,
+		"General":              false,
+	}
+	for code, want := range tests {
+		if got := isDateFormatCode(code); got != want {
+			t.Errorf("isDateFormatCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// preview prints the first documents of the input next to their converted
+// form, without writing any file.
+func preview(args []string) {
+	fs := flag.NewFlagSet("preview", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	mappingFile := fs.String("mapping", "./data/mapping.json", "Path to mapping JSON file")
+	n := fs.Int("n", 5, "Number of documents to preview")
+	parseFlags(fs, args)
+
+	docs := input.read(*n)
+	_, conv := openConverter(*mappingFile, os.DevNull, true)
+	defer conv.Close()
+	if err := conv.Prefetch(docs); err != nil {
+		fatal(err.Error())
+	}
+
+	for i, doc := range docs {
+		newDocs, _, err := convertDoc(conv, doc)
+		if err != nil {
+			fatal("failed to process doc", "id", converter.DocID(doc), "error", err)
+		}
+
+		fmt.Printf("=== document %d (_id %s) ===\n", i+1, converter.DocID(doc))
+		fmt.Println("--- input ---")
+		printJSON(doc)
+		fmt.Println("--- output ---")
+		for _, newDoc := range newDocs {
+			printJSON(newDoc)
+		}
+		if len(newDocs) == 0 {
+			fmt.Println("(dropped by lookup miss policy, script or explode)")
+		}
+		fmt.Println()
+	}
+}
+
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fatal("failed to marshal doc", "error", err)
+	}
+	fmt.Println(string(data))
+}
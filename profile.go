@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+)
+
+// profileOptions capture Go profiles of a run for looking into slow
+// conversions with go tool pprof and go tool trace.
+type profileOptions struct {
+	cpu   string
+	mem   string
+	trace string
+}
+
+func (o *profileOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.cpu, "cpuprofile", "", "Path to write a CPU profile of the run to")
+	fs.StringVar(&o.mem, "memprofile", "", "Path to write a heap profile to at the end of the run")
+	fs.StringVar(&o.trace, "trace", "", "Path to write an execution trace of the run to")
+}
+
+// stopProfiles ends the profiles begun by start. fatal calls it too, so a
+// failed run still leaves its profiles behind.
+var stopProfiles = func() {}
+
+// start begins the CPU profile and the trace.
+func (o *profileOptions) start() {
+	var files []*os.File
+	create := func(path string) *os.File {
+		file, err := os.Create(path)
+		if err != nil {
+			fatal("failed to create profile", "error", err)
+		}
+		files = append(files, file)
+		return file
+	}
+	if o.cpu != "" {
+		if err := pprof.StartCPUProfile(create(o.cpu)); err != nil {
+			fatal("failed to start CPU profile", "error", err)
+		}
+	}
+	if o.trace != "" {
+		if err := trace.Start(create(o.trace)); err != nil {
+			fatal("failed to start trace", "error", err)
+		}
+	}
+	stopProfiles = sync.OnceFunc(func() {
+		if o.cpu != "" {
+			pprof.StopCPUProfile()
+		}
+		if o.trace != "" {
+			trace.Stop()
+		}
+		if o.mem != "" {
+			file, err := os.Create(o.mem)
+			if err == nil {
+				runtime.GC()
+				err = pprof.WriteHeapProfile(file)
+				file.Close()
+			}
+			if err != nil {
+				slog.Error("failed to write heap profile", "error", err)
+			}
+		}
+		for _, file := range files {
+			file.Close()
+		}
+	})
+}
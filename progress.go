@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// ttyRefresh is how often the progress bar is redrawn on a terminal.
+const ttyRefresh = 200 * time.Millisecond
+
+// progressReporter shows how far a run has got: a bar redrawn in place when
+// stderr is a terminal, otherwise a log line every interval.
+type progressReporter struct {
+	file     string
+	reader   docReader
+	interval time.Duration
+	tty      bool
+	start    time.Time
+	last     time.Time
+	docs     int
+}
+
+func newProgressReporter(file string, reader docReader, interval time.Duration) *progressReporter {
+	p := &progressReporter{file: file, reader: reader, interval: interval, start: time.Now()}
+	p.last = p.start
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		p.tty = true
+		p.interval = ttyRefresh
+	}
+	return p
+}
+
+// add counts a processed document and reports when the interval has passed.
+func (p *progressReporter) add() {
+	if p == nil {
+		return
+	}
+	p.docs++
+	if p.docs%100 != 0 {
+		return
+	}
+	if now := time.Now(); now.Sub(p.last) >= p.interval {
+		p.last = now
+		p.report(false)
+	}
+}
+
+// finish prints the final state and ends the bar's line.
+func (p *progressReporter) finish() {
+	if p == nil {
+		return
+	}
+	p.report(true)
+}
+
+func (p *progressReporter) report(final bool) {
+	elapsed := time.Since(p.start)
+	rate := float64(p.docs) / max(elapsed.Seconds(), 1e-9)
+	done, total := p.reader.Progress()
+
+	status := fmt.Sprintf("%s: %d docs, %.0f docs/s", p.file, p.docs, rate)
+	args := []any{"file", p.file, "docs", p.docs, "docs_per_second", math.Round(rate)}
+	var fraction float64
+	if total > 0 {
+		fraction = float64(done) / float64(total)
+		status += fmt.Sprintf(", %s/%s", formatBytes(done), formatBytes(total))
+		args = append(args, "bytes", done, "total_bytes", total)
+		if done > 0 && !final {
+			eta := time.Duration(float64(elapsed) * float64(total-done) / float64(done)).Round(time.Second)
+			status += fmt.Sprintf(", ETA %s", eta)
+			args = append(args, "eta", eta.String())
+		}
+	}
+
+	if !p.tty {
+		slog.Info("progress", args...)
+		return
+	}
+	const width = 30
+	filled := int(fraction * width)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	fmt.Fprintf(os.Stderr, "\r\033[K[%s] %3.0f%% %s", bar, fraction*100, status)
+	if final {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
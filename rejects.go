@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+const (
+	OnErrorFail    = "fail"
+	OnErrorSkip    = "skip"
+	OnErrorCollect = "collect"
+
+	// minErrorRateSample is the number of records read before
+	// --max-error-rate is enforced, so early failures don't abort a run.
+	minErrorRateSample = 100
+)
+
+// rejectEntry is one line of the rejects file: the raw input line, where
+// it came from, and why it was rejected.
+type rejectEntry struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+	Raw   string `json:"raw"`
+}
+
+// errorHandler applies the --on-error policy to records that could not be
+// read or converted.
+type errorHandler struct {
+	policy    string
+	maxErrors int
+	maxRate   float64
+	records   int
+	errors    int
+	file      io.WriteCloser
+	writer    *bufio.Writer
+}
+
+func newErrorHandler(policy, rejectsFile string, maxErrors int, maxRate float64, dryRun bool) *errorHandler {
+	h := &errorHandler{policy: policy, maxErrors: maxErrors, maxRate: maxRate}
+	switch policy {
+	case OnErrorFail, OnErrorSkip:
+	case OnErrorCollect:
+		file, err := createFile(rejectsFile, dryRun)
+		if err != nil {
+			fatal("failed to create rejects file", "error", err)
+		}
+		h.file = file
+		h.writer = bufio.NewWriter(file)
+	default:
+		fatal("invalid -on-error value", "value", policy)
+	}
+	return h
+}
+
+// read counts a record taken from the input, failed or not.
+func (h *errorHandler) read() {
+	h.records++
+}
+
+// handle records a failed record, or ends the run under the fail policy or
+// once the error thresholds are exceeded.
+func (h *errorHandler) handle(rec inputRecord, err error) {
+	if h.policy == OnErrorFail {
+		fatal("failed to convert document", "line", rec.line, "error", err)
+	}
+	h.errors++
+	defer h.check(false)
+	if h.writer == nil {
+		return
+	}
+	raw := rec.raw
+	if raw == "" {
+		data, _ := json.Marshal(rec.doc)
+		raw = string(data)
+	}
+	line, err := json.Marshal(rejectEntry{Line: rec.line, Error: err.Error(), Raw: raw})
+	if err != nil {
+		fatal("failed to marshal reject", "error", err)
+	}
+	h.writer.Write(line)
+	h.writer.WriteByte('\n')
+}
+
+// check aborts the run when --max-errors or --max-error-rate is exceeded.
+// The rate is only enforced after minErrorRateSample records unless final
+// is set.
+func (h *errorHandler) check(final bool) {
+	if h.maxErrors > 0 && h.errors > h.maxErrors {
+		h.abort(fmt.Sprintf("more than %d documents failed", h.maxErrors))
+	}
+	if h.maxRate > 0 && h.records > 0 && (final || h.records >= minErrorRateSample) {
+		if rate := float64(h.errors) / float64(h.records); rate > h.maxRate {
+			h.abort(fmt.Sprintf("error rate %.2f%% exceeds %.2f%%", rate*100, h.maxRate*100))
+		}
+	}
+}
+
+func (h *errorHandler) abort(reason string) {
+	if h.file != nil {
+		h.writer.Flush()
+		h.file.Close()
+	}
+	fatal("aborting: "+reason, "errors", h.errors, "docs", h.records)
+}
+
+func (h *errorHandler) flush() error {
+	if h.writer == nil {
+		return nil
+	}
+	return h.writer.Flush()
+}
+
+func (h *errorHandler) Close() error {
+	h.check(true)
+	if h.file == nil {
+		return nil
+	}
+	if err := h.writer.Flush(); err != nil {
+		return err
+	}
+	return h.file.Close()
+}
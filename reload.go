@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// reloadSettle is how long the mapping file must stay unmodified before a
+// change is picked up, so an editor's save is read once and complete.
+const reloadSettle = 500 * time.Millisecond
+
+// watchMapping calls apply with every new version of the mapping file until
+// ctx is done. A version that fails to load, or that apply rejects, is
+// logged and ignored, leaving the previous one in effect.
+func watchMapping(ctx context.Context, path string, apply func(converter.FieldMapping) error) error {
+	path = filepath.Clean(path)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// The directory is watched rather than the file, since editors and
+	// deployment tools usually replace the file instead of writing to it.
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		timer := time.NewTimer(0)
+		<-timer.C
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-watcher.Errors:
+				slog.Error("mapping watch error", "error", err)
+			case event := <-watcher.Events:
+				if filepath.Clean(event.Name) == path && (event.Has(fsnotify.Create) || event.Has(fsnotify.Write)) {
+					timer.Reset(reloadSettle)
+				}
+			case <-timer.C:
+				mapping, err := converter.LoadMapping(path)
+				if err == nil {
+					err = apply(mapping)
+				}
+				if err != nil {
+					slog.Error("mapping change rejected, keeping the previous mapping", "mapping", path, "error", err)
+					continue
+				}
+				slog.Info("mapping reloaded", "mapping", path)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+type lookupReport struct {
+	Name   string `json:"name"`
+	Hits   int    `json:"hits"`
+	Misses int    `json:"misses"`
+	OnMiss string `json:"on_miss"`
+}
+
+type outputReport struct {
+	Path  string `json:"path"`
+	Bytes int64  `json:"bytes"`
+}
+
+// runReport is the machine-readable summary written by --report.
+type runReport struct {
+	RunID            string             `json:"run_id,omitempty"`
+	StartedAt        time.Time          `json:"started_at"`
+	DurationSeconds  float64            `json:"duration_seconds"`
+	DocsRead         int                `json:"docs_read"`
+	DocsConverted    int                `json:"docs_converted"`
+	DocsDropped      int                `json:"docs_dropped"`
+	DocsUnchanged    int                `json:"docs_unchanged"`
+	DocsSeen         int                `json:"docs_seen"`
+	DocsRejected     int                `json:"docs_rejected"`
+	SchemaViolations int                `json:"schema_violations"`
+	DocsPerSecond    float64            `json:"docs_per_second"`
+	DryRun           bool               `json:"dry_run"`
+	Interrupted      bool               `json:"interrupted"`
+	Resources        resourceUsage      `json:"resources"`
+	StageSeconds     map[string]float64 `json:"stage_seconds,omitempty"`
+	Rules            map[string]int     `json:"rules"`
+	Lookups          []lookupReport     `json:"lookups"`
+	Outputs          []outputReport     `json:"outputs"`
+}
+
+func (r *runReport) finish(start time.Time, stats converter.Stats) {
+	elapsed := time.Since(start)
+	r.StartedAt = start
+	r.DurationSeconds = elapsed.Seconds()
+	if elapsed > 0 {
+		r.DocsPerSecond = float64(r.DocsRead) / elapsed.Seconds()
+	}
+	r.Rules = make(map[string]int, len(stats.Rules))
+	for _, rule := range stats.Rules {
+		r.Rules[rule.Rule] = rule.Count
+	}
+	for _, lookup := range stats.Lookups {
+		r.Lookups = append(r.Lookups, lookupReport{
+			Name:   lookup.Name,
+			Hits:   lookup.Hits,
+			Misses: lookup.Misses,
+			OnMiss: lookup.OnMiss,
+		})
+	}
+}
+
+// addOutputs records the size of every path that exists on disk.
+func (r *runReport) addOutputs(paths ...string) {
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			r.Outputs = append(r.Outputs, outputReport{Path: path, Bytes: info.Size()})
+		}
+	}
+}
+
+func (r *runReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func logStats(stats converter.Stats, showRuleStats bool) {
+	for _, lookup := range stats.Lookups {
+		slog.Info("lookup misses", "lookup", lookup.Name, "misses", lookup.Misses, "on_miss", lookup.OnMiss)
+	}
+	if showRuleStats {
+		for _, rule := range stats.Rules {
+			slog.Info("rule stats", "rule", rule.Rule, "docs", rule.Count)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	runtimemetrics "runtime/metrics"
+	"sync"
+	"time"
+)
+
+// resourceSampleInterval is how often the heap is sampled for its peak.
+const resourceSampleInterval = 50 * time.Millisecond
+
+// resourceUsage is what a run used of the process, from runtime/metrics.
+// The peak heap is sampled, so a spike shorter than the sample interval
+// may be missed; CPU time is user plus system time of the process.
+type resourceUsage struct {
+	PeakHeapBytes uint64  `json:"peak_heap_bytes"`
+	AllocBytes    uint64  `json:"alloc_bytes"`
+	Allocs        uint64  `json:"allocs"`
+	GCCycles      uint64  `json:"gc_cycles"`
+	CPUSeconds    float64 `json:"cpu_seconds"`
+}
+
+var resourceMetrics = []string{
+	"/memory/classes/heap/objects:bytes",
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/allocs:objects",
+	"/gc/cycles/total:gc-cycles",
+}
+
+// resourceMonitor measures a run from its start, sampling the heap in the
+// background until finish.
+type resourceMonitor struct {
+	start resourceUsage
+	stop  chan struct{}
+	done  sync.WaitGroup
+
+	mu   sync.Mutex
+	peak uint64
+}
+
+func startResourceMonitor() *resourceMonitor {
+	m := &resourceMonitor{start: readResources(), stop: make(chan struct{})}
+	m.peak = m.start.PeakHeapBytes
+	m.done.Add(1)
+	go func() {
+		defer m.done.Done()
+		ticker := time.NewTicker(resourceSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.sample(readResources().PeakHeapBytes)
+			}
+		}
+	}()
+	return m
+}
+
+func (m *resourceMonitor) sample(heap uint64) {
+	m.mu.Lock()
+	m.peak = max(m.peak, heap)
+	m.mu.Unlock()
+}
+
+// finish stops sampling and returns the usage since the monitor started.
+func (m *resourceMonitor) finish() resourceUsage {
+	close(m.stop)
+	m.done.Wait()
+	end := readResources()
+	m.sample(end.PeakHeapBytes)
+	return resourceUsage{
+		PeakHeapBytes: m.peak,
+		AllocBytes:    end.AllocBytes - m.start.AllocBytes,
+		Allocs:        end.Allocs - m.start.Allocs,
+		GCCycles:      end.GCCycles - m.start.GCCycles,
+		CPUSeconds:    end.CPUSeconds - m.start.CPUSeconds,
+	}
+}
+
+// readResources returns the current runtime counters and CPU time, with
+// the current heap size as PeakHeapBytes.
+func readResources() resourceUsage {
+	samples := make([]runtimemetrics.Sample, len(resourceMetrics))
+	for i, name := range resourceMetrics {
+		samples[i].Name = name
+	}
+	runtimemetrics.Read(samples)
+	value := func(i int) uint64 {
+		if samples[i].Value.Kind() != runtimemetrics.KindUint64 {
+			return 0
+		}
+		return samples[i].Value.Uint64()
+	}
+	return resourceUsage{
+		PeakHeapBytes: value(0),
+		AllocBytes:    value(1),
+		Allocs:        value(2),
+		GCCycles:      value(3),
+		CPUSeconds:    processCPU(),
+	}
+}
+
+// logArgs returns the usage as slog key-value pairs.
+func (u resourceUsage) logArgs() []any {
+	return []any{
+		"peak_heap_mb", u.PeakHeapBytes / (1 << 20),
+		"alloc_mb", u.AllocBytes / (1 << 20),
+		"allocs", u.Allocs,
+		"gc_cycles", u.GCCycles,
+		"cpu", (time.Duration(u.CPUSeconds * float64(time.Second))).Round(time.Millisecond).String(),
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package main
+
+import runtimemetrics "runtime/metrics"
+
+// processCPU returns the runtime's estimate of the CPU time spent running
+// Go code, collecting garbage and returning memory to the system. The
+// estimate is brought up to date at each garbage collection.
+func processCPU() float64 {
+	samples := []runtimemetrics.Sample{
+		{Name: "/cpu/classes/user:cpu-seconds"},
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/scavenge/total:cpu-seconds"},
+	}
+	runtimemetrics.Read(samples)
+	var seconds float64
+	for _, sample := range samples {
+		if sample.Value.Kind() == runtimemetrics.KindFloat64 {
+			seconds += sample.Value.Float64()
+		}
+	}
+	return seconds
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// sampleReader passes on a random subset of the records of another reader:
+// each record with probability fraction, or exactly n records chosen by
+// reservoir sampling and returned in input order.
+type sampleReader struct {
+	reader   docReader
+	rn       *rand.Rand
+	fraction float64
+	n        int
+	limit    int
+	count    int
+
+	reservoir []inputRecord
+	filled    bool
+}
+
+func (r *sampleReader) Next() (inputRecord, bool) {
+	if r.limit > 0 && r.count >= r.limit {
+		return inputRecord{}, false
+	}
+	if r.n > 0 {
+		if !r.filled {
+			r.fill()
+		}
+		if len(r.reservoir) == 0 {
+			return inputRecord{}, false
+		}
+		rec := r.reservoir[0]
+		r.reservoir = r.reservoir[1:]
+		r.count++
+		return rec, true
+	}
+	for {
+		rec, ok := r.reader.Next()
+		if !ok {
+			return inputRecord{}, false
+		}
+		if r.rn.Float64() < r.fraction {
+			r.count++
+			return rec, true
+		}
+	}
+}
+
+func (r *sampleReader) fill() {
+	r.filled = true
+	seen := 0
+	for {
+		rec, ok := r.reader.Next()
+		if !ok {
+			break
+		}
+		seen++
+		if len(r.reservoir) < r.n {
+			r.reservoir = append(r.reservoir, rec)
+		} else if i := r.rn.Intn(seen); i < r.n {
+			r.reservoir[i] = rec
+		}
+	}
+	sort.Slice(r.reservoir, func(i, j int) bool {
+		return r.reservoir[i].line < r.reservoir[j].line
+	})
+}
+
+func (r *sampleReader) Err() error {
+	return r.reader.Err()
+}
+
+func (r *sampleReader) Progress() (int64, int64) {
+	return r.reader.Progress()
+}
+
+func (r *sampleReader) Close() error {
+	return r.reader.Close()
+}
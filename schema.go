@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+type schemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// schemaValidator checks output documents against a JSON Schema and writes
+// every violating document, with its errors, to an errors file.
+type schemaValidator struct {
+	schema     *jsonschema.Schema
+	file       io.WriteCloser
+	writer     *bufio.Writer
+	violations int
+}
+
+func openSchemaValidator(schemaPath, errorsPath string, dryRun bool) (*schemaValidator, error) {
+	schema, err := jsonschema.NewCompiler().Compile(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	file, err := createFile(errorsPath, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaValidator{schema: schema, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// validate reports whether the document's source satisfies the schema,
+// recording it in the errors file when it does not.
+func (v *schemaValidator) validate(doc converter.ESDoc) (bool, error) {
+	err := v.schema.Validate(doc.Source)
+	if err == nil {
+		return true, nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false, err
+	}
+	v.violations++
+
+	var violations []schemaViolation
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error != nil {
+			violations = append(violations, schemaViolation{Path: unit.InstanceLocation, Message: unit.Error.String()})
+		}
+	}
+	line, err := json.Marshal(map[string]interface{}{
+		"_id":    doc.ID,
+		"errors": violations,
+		"doc":    doc,
+	})
+	if err != nil {
+		return false, err
+	}
+	v.writer.Write(line)
+	return false, v.writer.WriteByte('\n')
+}
+
+func (v *schemaValidator) flush() error {
+	return v.writer.Flush()
+}
+
+func (v *schemaValidator) Close() error {
+	if err := v.writer.Flush(); err != nil {
+		return err
+	}
+	return v.file.Close()
+}
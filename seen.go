@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"os"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// seenState remembers the documents already emitted, by _index and _id,
+// across runs. It holds either the exact keys or, when the expected count is
+// known, a bloom filter of them: one that stays small for large exports at
+// the cost of dropping a document as seen now and then. The state file is
+// {"keys": {"<key>": true, ...}, "count": n} or, for a bloom filter,
+// {"bits": "<base64>", "m": bits, "k": hashes, "count": n}.
+type seenState struct {
+	keys *spillMap
+	// Bloom filter: bits hold m bits, set by k hashes per key.
+	bits   []byte
+	m      uint64
+	k      int
+	count  int
+	loaded int
+}
+
+// newSeenState sizes a bloom filter for expected keys at the false positive
+// rate, or an exact set when expected is 0.
+func newSeenState(expected int, rate float64) *seenState {
+	if expected <= 0 {
+		return &seenState{keys: newSpillMap("seen")}
+	}
+	m := math.Ceil(-float64(expected) * math.Log(rate) / (math.Ln2 * math.Ln2))
+	k := max(int(math.Round(m/float64(expected)*math.Ln2)), 1)
+	size := uint64(m+7) / 8
+	return &seenState{bits: make([]byte, size), m: size * 8, k: k}
+}
+
+// loadSeenState reads the state at path, or returns fresh when the file does
+// not exist yet. The kind of a stored state wins over the flags.
+func loadSeenState(path string, fresh *seenState) (*seenState, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return fresh, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	state := &seenState{}
+	dec := json.NewDecoder(bufio.NewReader(file))
+	err = decodeObject(dec, func(name string) error {
+		switch name {
+		case "keys":
+			state.keys = newSpillMap("seen")
+			return decodeObject(dec, func(key string) error {
+				var seen bool
+				if err := dec.Decode(&seen); err != nil {
+					return err
+				}
+				return state.keys.set(key, "")
+			})
+		case "bits":
+			return dec.Decode(&state.bits)
+		case "m":
+			return dec.Decode(&state.m)
+		case "k":
+			return dec.Decode(&state.k)
+		case "count":
+			return dec.Decode(&state.count)
+		}
+		var skipped json.RawMessage
+		return dec.Decode(&skipped)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid seen state %s: %w", path, err)
+	}
+	if state.m > 0 {
+		if state.k <= 0 || uint64(len(state.bits))*8 != state.m {
+			return nil, fmt.Errorf("invalid seen state %s: bad bloom filter size", path)
+		}
+		if fresh.m > 0 && fresh.m != state.m {
+			slog.Warn("seen state keeps its bloom filter size", "state", path, "bits", state.m)
+		}
+	} else if state.keys == nil {
+		state.keys = newSpillMap("seen")
+	}
+	state.loaded = state.count
+	return state, nil
+}
+
+// has reports whether doc was emitted before. Documents without an _id
+// cannot repeat and are never seen.
+func (s *seenState) has(doc converter.ESDoc) (bool, error) {
+	key, ok := seenKey(doc)
+	if !ok {
+		return false, nil
+	}
+	if s.keys != nil {
+		_, ok, err := s.keys.get(key)
+		return ok, err
+	}
+	for _, bit := range s.positions(key) {
+		if s.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// add records doc once it is written.
+func (s *seenState) add(doc converter.ESDoc) error {
+	key, ok := seenKey(doc)
+	if !ok {
+		return nil
+	}
+	if s.keys != nil {
+		if _, ok, err := s.keys.get(key); ok || err != nil {
+			return err
+		}
+		s.count++
+		return s.keys.set(key, "")
+	}
+	fresh := false
+	for _, bit := range s.positions(key) {
+		if s.bits[bit/8]&(1<<(bit%8)) == 0 {
+			s.bits[bit/8] |= 1 << (bit % 8)
+			fresh = true
+		}
+	}
+	if fresh {
+		s.count++
+	}
+	return nil
+}
+
+func seenKey(doc converter.ESDoc) (string, bool) {
+	if doc.ID == nil {
+		return "", false
+	}
+	if doc.Index != nil {
+		return *doc.Index + "/" + *doc.ID, true
+	}
+	return *doc.ID, true
+}
+
+// positions returns the k bloom filter bits of key.
+func (s *seenState) positions(key string) []uint64 {
+	// Double hashing: the i-th position is h1 + i*h2.
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+	bits := make([]uint64, s.k)
+	for i := range bits {
+		bits[i] = (h1 + uint64(i)*h2) % s.m
+	}
+	return bits
+}
+
+func (s *seenState) save(path string) error {
+	return writeAtomic(path, func(w *bufio.Writer) error {
+		if s.keys == nil {
+			data, err := json.Marshal(map[string]interface{}{"bits": s.bits, "m": s.m, "k": s.k, "count": s.count})
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		w.WriteString(`{"keys":`)
+		err := s.keys.writeJSON(w, func(string) ([]byte, error) {
+			return []byte("true"), nil
+		})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, `,"count":%d}`, s.count)
+		return err
+	})
+}
+
+func (s *seenState) log(path string) {
+	args := []any{"state", path, "previous", s.loaded, "added", s.count - s.loaded}
+	if s.m > 0 {
+		// Chance that a new document is taken for one already emitted.
+		rate := math.Pow(1-math.Exp(-float64(s.k)*float64(s.count)/float64(s.m)), float64(s.k))
+		args = append(args, "false_positive_rate", fmt.Sprintf("%.2g", rate))
+	}
+	slog.Info("seen documents", args...)
+}
+
+func (s *seenState) close() {
+	if s.keys != nil {
+		s.keys.close()
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// server exposes a preloaded converter over HTTP. The converter is not
+// safe for concurrent use, so requests are serialized.
+type server struct {
+	mu      sync.Mutex
+	conv    *converter.Converter
+	maxBody int64
+	metrics *metrics
+}
+
+func newServer(conv *converter.Converter, maxBody int64) *server {
+	s := &server{conv: conv, maxBody: maxBody}
+	s.metrics = newMetrics(func() converter.Stats {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.conv.Stats()
+	})
+	return s
+}
+
+// serve runs the HTTP conversion API:
+//
+//	POST /convert  one document, or an NDJSON batch with Content-Type
+//	               application/x-ndjson; responds in the same form
+//	GET  /stats    rule and lookup counters
+//	GET  /metrics  Prometheus metrics
+//	GET  /healthz  liveness
+//	GET  /debug/pprof/  Go profiles, with -pprof
+//
+// and, with -grpc-port, the streaming gRPC service of proto/converter.proto
+// with health and reflection, sharing the same converter.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	mappingFile := fs.String("mapping", "./data/mapping.json", "Path to mapping JSON file")
+	addr := fs.String("addr", "", "Address to listen on (overrides -port)")
+	port := fs.Int("port", 8080, "Port to listen on")
+	grpcPort := fs.Int("grpc-port", 0, "Port for the gRPC conversion service (0 disables it)")
+	maxBody := fs.Int64("max-body", 64<<20, "Maximum request body size in bytes")
+	reload := fs.Bool("reload", true, "Reload the mapping file when it changes")
+	debug := fs.Bool("pprof", false, "Serve Go profiles under /debug/pprof/; only enable it where the port is not public")
+	parseFlags(fs, args)
+
+	if *addr == "" {
+		*addr = fmt.Sprintf(":%d", *port)
+	}
+	_, conv := openConverter(*mappingFile, "serve", false)
+	s := newServer(conv, *maxBody)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /convert", s.handleConvert)
+	mux.HandleFunc("GET /stats", s.handleStats)
+	mux.Handle("GET /metrics", s.metrics)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	if *debug {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	httpServer := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	var grpcServer *grpc.Server
+	if *grpcPort > 0 {
+		var healthServer *health.Server
+		var err error
+		grpcServer, healthServer, err = newGRPCServer(s)
+		if err != nil {
+			fatal("failed to set up gRPC service", "error", err)
+		}
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *grpcPort))
+		if err != nil {
+			fatal("failed to listen", "port", *grpcPort, "error", err)
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				fatal("gRPC server failed", "error", err)
+			}
+		}()
+		defer healthServer.Shutdown()
+		slog.Info("serving gRPC conversion service", "port", *grpcPort)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *reload {
+		if err := watchMapping(ctx, *mappingFile, s.swap); err != nil {
+			fatal("failed to watch mapping file", "mapping", *mappingFile, "error", err)
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("serving conversion API", "addr", *addr, "mapping", *mappingFile)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("server failed", "error", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.conv.Close(); err != nil {
+		fatal(err.Error())
+	}
+	slog.Info("server stopped")
+}
+
+func (s *server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBody))
+	if err != nil {
+		s.metrics.errors.Add(1)
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	batch := strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-ndjson")
+
+	var docs []converter.ESDoc
+	if batch {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for line := 1; scanner.Scan(); line++ {
+			if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+				continue
+			}
+			var doc converter.ESDoc
+			if err = unmarshalDoc(scanner.Bytes(), &doc); err != nil {
+				s.metrics.errors.Add(1)
+				http.Error(w, fmt.Sprintf("line %d: invalid JSON: %v", line, err), http.StatusBadRequest)
+				return
+			}
+			docs = append(docs, doc)
+		}
+		if err = scanner.Err(); err != nil {
+			s.metrics.errors.Add(1)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		var doc converter.ESDoc
+		if err = unmarshalDoc(body, &doc); err != nil {
+			s.metrics.errors.Add(1)
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		docs = append(docs, doc)
+	}
+
+	results, err := s.convert(docs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// A single document answers with JSON, unless explode split it.
+	if !batch {
+		switch len(results[0]) {
+		case 0:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case 1:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(results[0][0])
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, result := range results {
+		for _, newDoc := range result {
+			w.Write(newDoc)
+			w.Write([]byte("\n"))
+		}
+	}
+	if batch {
+		s.metrics.batchLatency.observe(time.Since(start))
+	}
+}
+
+// convert converts docs under the lock, giving the output documents of
+// each: none when it was dropped, several when explode split it.
+func (s *server) convert(docs []converter.ESDoc) ([][][]byte, error) {
+	s.metrics.queueDepth.Add(1)
+	s.mu.Lock()
+	s.metrics.queueDepth.Add(-1)
+	defer s.mu.Unlock()
+	if err := s.conv.Prefetch(docs); err != nil {
+		s.metrics.errors.Add(1)
+		return nil, err
+	}
+	results := make([][][]byte, len(docs))
+	for i, doc := range docs {
+		start := time.Now()
+		newDocs, _, err := convertDoc(s.conv, doc)
+		s.metrics.docLatency.observe(time.Since(start))
+		if err != nil {
+			s.metrics.errors.Add(1)
+			return nil, fmt.Errorf("failed to process doc %s: %w", converter.DocID(doc), err)
+		}
+		if len(newDocs) == 0 {
+			s.metrics.docsDropped.Add(1)
+		} else {
+			s.metrics.docsConverted.Add(1)
+		}
+		for _, newDoc := range newDocs {
+			docJson, err := json.Marshal(newDoc)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = append(results[i], docJson)
+		}
+	}
+	return results, nil
+}
+
+// swap replaces the converter with one built from mapping. In-flight
+// requests finish on the old converter, which is then closed, flushing its
+// misses, before the new one appends to the same files. Its rule counters
+// are not carried over, its lookup counts only into the metrics.
+func (s *server) swap(mapping converter.FieldMapping) error {
+	conv, err := converter.New(mapping, converter.Options{OutputFile: "serve", CreateFile: appendFile})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics.retire(s.conv.Stats().Lookups)
+	if err := s.conv.Close(); err != nil {
+		slog.Warn("failed to close previous converter", "error", err)
+	}
+	s.conv = conv
+	return nil
+}
+
+// appendFile opens path for appending, creating it if needed.
+func appendFile(path string) (io.WriteCloser, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+}
+
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stats := s.conv.Stats()
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
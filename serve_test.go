@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+func mappingFromJSON(t *testing.T, text string) converter.FieldMapping {
+	t.Helper()
+	var mapping converter.FieldMapping
+	if err := json.Unmarshal([]byte(text), &mapping); err != nil {
+		t.Fatal(err)
+	}
+	return mapping
+}
+
+func TestServeConvert(t *testing.T) {
+	conv, err := newConverter(mappingFromJSON(t, `{"field_mapping": {"full_name": "name"}}`), "serve", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(conv, 1<<20)
+	defer s.conv.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(`{"_id":"1","_source":{"name":"Alice"}}`))
+	rec := httptest.NewRecorder()
+	s.handleConvert(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"full_name":"Alice"`) {
+		t.Errorf("POST /convert = %d %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader("{\"_id\":\"1\",\"_source\":{\"name\":\"A\"}}\n\n{\"_id\":\"2\",\"_source\":{\"name\":\"B\"}}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	rec = httptest.NewRecorder()
+	s.handleConvert(rec, req)
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); rec.Code != http.StatusOK || len(lines) != 2 {
+		t.Errorf("POST /convert batch = %d %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(`{"_id":`))
+	rec = httptest.NewRecorder()
+	s.handleConvert(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /convert with invalid JSON = %d, want 400", rec.Code)
+	}
+}
+
+func TestServeSwapKeepsMisses(t *testing.T) {
+	dir := t.TempDir()
+	lookup := filepath.Join(dir, "lookup.csv")
+	if err := os.WriteFile(lookup, []byte("id,city\n1,Paris\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	misses := filepath.Join(dir, "misses.ndjson")
+	mapping := mappingFromJSON(t, `{"lookups": [{"path": "`+lookup+`", "on_miss": "route", "misses_file": "`+misses+`"}]}`)
+	conv, err := newConverter(mapping, "serve", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(conv, 1<<20)
+
+	if _, err = s.convert([]converter.ESDoc{testDoc("before")}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.swap(mapping); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.convert([]converter.ESDoc{testDoc("after")}); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.conv.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(misses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"before"`) || !strings.Contains(lines[1], `"after"`) {
+		t.Errorf("misses file after reload = %q", data)
+	}
+}
+
+func testDoc(id string) converter.ESDoc {
+	return converter.ESDoc{ESMeta: converter.ESMeta{ID: &id}, Source: map[string]interface{}{}}
+}
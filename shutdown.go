@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// shutdown traps SIGINT and SIGTERM so the run can stop between documents
+// and finalize its output. A second signal gets the default behaviour and
+// kills the process immediately.
+type shutdown struct {
+	signals  chan os.Signal
+	received os.Signal
+}
+
+func trapSignals() *shutdown {
+	s := &shutdown{signals: make(chan os.Signal, 1)}
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+	return s
+}
+
+// requested reports whether a signal has arrived.
+func (s *shutdown) requested() bool {
+	if s.received != nil {
+		return true
+	}
+	select {
+	case s.received = <-s.signals:
+		signal.Stop(s.signals)
+		return true
+	default:
+		return false
+	}
+}
+
+// exitCode follows the shell convention of 128 plus the signal number.
+func (s *shutdown) exitCode() int {
+	if sig, ok := s.received.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return 1
+}
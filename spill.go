@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// spillEntryOverhead estimates the map bookkeeping per entry of a spillMap
+// held in memory.
+const spillEntryOverhead = 64
+
+// spillMap is a string map held in memory while the memory budget allows,
+// then moved to temporary files: an append-only log of entries and an
+// open-addressing hash index of their log offsets, like the disk storage of
+// lookups. It is not safe for concurrent use.
+type spillMap struct {
+	name     string
+	mem      map[string]string
+	reserved int64
+
+	dir   string
+	log   *os.File
+	size  int64
+	index *os.File
+	slots uint64
+	count int
+}
+
+func newSpillMap(name string) *spillMap {
+	return &spillMap{name: name, mem: map[string]string{}}
+}
+
+func (m *spillMap) len() int {
+	if m.log == nil {
+		return len(m.mem)
+	}
+	return m.count
+}
+
+func (m *spillMap) get(key string) (string, bool, error) {
+	if m.log == nil {
+		value, ok := m.mem[key]
+		return value, ok, nil
+	}
+	_, offset, err := m.find(key)
+	if err != nil || offset == 0 {
+		return "", false, err
+	}
+	_, value, err := m.record(offset - 1)
+	return value, err == nil, err
+}
+
+func (m *spillMap) set(key, value string) error {
+	if m.log == nil {
+		if _, ok := m.mem[key]; ok {
+			m.mem[key] = value
+			return nil
+		}
+		size := int64(len(key) + len(value) + spillEntryOverhead)
+		if budget.reserve(size) {
+			m.reserved += size
+			m.mem[key] = value
+			return nil
+		}
+		if err := m.spill(); err != nil {
+			return err
+		}
+	}
+	return m.put(key, value)
+}
+
+// each calls fn for every entry, in no particular order.
+func (m *spillMap) each(fn func(key, value string) error) error {
+	if m.log == nil {
+		for key, value := range m.mem {
+			if err := fn(key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	reader := bufio.NewReader(io.NewSectionReader(m.index, 0, int64(m.slots)*8))
+	slot := make([]byte, 8)
+	for i := uint64(0); i < m.slots; i++ {
+		if _, err := io.ReadFull(reader, slot); err != nil {
+			return err
+		}
+		if offset := binary.LittleEndian.Uint64(slot); offset != 0 {
+			key, value, err := m.record(int64(offset - 1))
+			if err != nil {
+				return err
+			}
+			if err = fn(key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// close removes the temporary files and returns the reserved memory.
+func (m *spillMap) close() {
+	budget.release(m.reserved)
+	m.reserved = 0
+	if m.log != nil {
+		m.log.Close()
+		m.index.Close()
+		os.RemoveAll(m.dir)
+	}
+}
+
+// spill moves the entries held in memory to temporary files, where all
+// later entries go too.
+func (m *spillMap) spill() error {
+	var err error
+	if m.dir, err = os.MkdirTemp("", "converter-"+m.name+"-"); err != nil {
+		return err
+	}
+	if m.log, err = os.Create(m.dir + "/log"); err != nil {
+		return err
+	}
+	m.slots = 1024
+	for m.slots < uint64(len(m.mem))*2 {
+		m.slots *= 2
+	}
+	if m.index, err = m.newIndex(m.slots); err != nil {
+		return err
+	}
+	slog.Info("memory budget exceeded, moving to disk", "set", m.name, "entries", len(m.mem), "dir", m.dir)
+	for key, value := range m.mem {
+		if err = m.put(key, value); err != nil {
+			return err
+		}
+	}
+	m.mem = nil
+	budget.release(m.reserved)
+	m.reserved = 0
+	return nil
+}
+
+func (m *spillMap) newIndex(slots uint64) (*os.File, error) {
+	index, err := os.CreateTemp(m.dir, "index")
+	if err != nil {
+		return nil, err
+	}
+	return index, index.Truncate(int64(slots) * 8)
+}
+
+func (m *spillMap) put(key, value string) error {
+	if uint64(m.count+1)*2 > m.slots {
+		if err := m.grow(); err != nil {
+			return err
+		}
+	}
+	slot, offset, err := m.find(key)
+	if err != nil {
+		return err
+	}
+	if offset == 0 {
+		m.count++
+	}
+	record := binary.LittleEndian.AppendUint32(nil, uint32(len(key)))
+	record = binary.LittleEndian.AppendUint32(record, uint32(len(value)))
+	record = append(append(record, key...), value...)
+	if _, err = m.log.WriteAt(record, m.size); err != nil {
+		return err
+	}
+	err = m.writeSlot(m.index, slot, uint64(m.size)+1)
+	m.size += int64(len(record))
+	return err
+}
+
+// find returns the slot of key and the stored log offset plus one, or 0
+// and the free slot to use when key is absent.
+func (m *spillMap) find(key string) (uint64, int64, error) {
+	slot := spillHash(key) & (m.slots - 1)
+	buf := make([]byte, 8)
+	for {
+		if _, err := m.index.ReadAt(buf, int64(slot)*8); err != nil {
+			return 0, 0, err
+		}
+		stored := binary.LittleEndian.Uint64(buf)
+		if stored == 0 {
+			return slot, 0, nil
+		}
+		storedKey, _, err := m.record(int64(stored - 1))
+		if err != nil {
+			return 0, 0, err
+		}
+		if storedKey == key {
+			return slot, int64(stored), nil
+		}
+		slot = (slot + 1) & (m.slots - 1)
+	}
+}
+
+func (m *spillMap) record(offset int64) (key, value string, err error) {
+	header := make([]byte, 8)
+	if _, err = m.log.ReadAt(header, offset); err != nil {
+		return "", "", err
+	}
+	keyLen, valueLen := binary.LittleEndian.Uint32(header), binary.LittleEndian.Uint32(header[4:])
+	data := make([]byte, keyLen+valueLen)
+	if _, err = m.log.ReadAt(data, offset+8); err != nil {
+		return "", "", err
+	}
+	return string(data[:keyLen]), string(data[keyLen:]), nil
+}
+
+func (m *spillMap) writeSlot(index *os.File, slot, stored uint64) error {
+	_, err := index.WriteAt(binary.LittleEndian.AppendUint64(nil, stored), int64(slot)*8)
+	return err
+}
+
+// grow doubles the index, rehashing the current entries into a new file.
+func (m *spillMap) grow() error {
+	old, oldSlots := m.index, m.slots
+	index, err := m.newIndex(oldSlots * 2)
+	if err != nil {
+		return err
+	}
+	m.index, m.slots = index, oldSlots*2
+	reader := bufio.NewReader(io.NewSectionReader(old, 0, int64(oldSlots)*8))
+	buf := make([]byte, 8)
+	for i := uint64(0); i < oldSlots; i++ {
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return err
+		}
+		stored := binary.LittleEndian.Uint64(buf)
+		if stored == 0 {
+			continue
+		}
+		key, _, err := m.record(int64(stored - 1))
+		if err != nil {
+			return err
+		}
+		slot, _, err := m.find(key)
+		if err != nil {
+			return err
+		}
+		if err = m.writeSlot(index, slot, stored); err != nil {
+			return err
+		}
+	}
+	old.Close()
+	return os.Remove(old.Name())
+}
+
+// writeJSON writes the map as a JSON object, encoding each value with
+// value, without holding the whole of it in memory.
+func (m *spillMap) writeJSON(w *bufio.Writer, value func(string) ([]byte, error)) error {
+	w.WriteByte('{')
+	first := true
+	err := m.each(func(key, v string) error {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		encoded, err := value(v)
+		if err != nil {
+			return err
+		}
+		if !first {
+			w.WriteByte(',')
+		}
+		first = false
+		w.Write(name)
+		w.WriteByte(':')
+		_, err = w.Write(encoded)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return w.WriteByte('}')
+}
+
+// decodeObject reads a JSON object from dec member by member, calling fn
+// with each name to decode its value.
+func decodeObject(dec *json.Decoder, fn func(name string) error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected an object")
+	}
+	for dec.More() {
+		if token, err = dec.Token(); err != nil {
+			return err
+		}
+		if err = fn(token.(string)); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// writeAtomic writes a file through a temporary one like checkpoints.
+func writeAtomic(path string, write func(w *bufio.Writer) error) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err = write(w); err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func spillHash(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestSpillMap(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		limit int64
+	}{
+		{"memory", 1 << 30},
+		{"disk", 1024},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			saved := budget
+			budget = &memoryBudget{limit: tc.limit}
+			t.Cleanup(func() { budget = saved })
+
+			m := newSpillMap("test")
+			defer m.close()
+			const n = 3000
+			for i := range n {
+				if err := m.set(fmt.Sprint(i), fmt.Sprint(i*2)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.set("7", "seven"); err != nil {
+				t.Fatal(err)
+			}
+			if spilled := m.log != nil; spilled != (tc.name == "disk") {
+				t.Fatalf("spilled = %v", spilled)
+			}
+			if m.len() != n {
+				t.Errorf("len = %d, want %d", m.len(), n)
+			}
+			for key, want := range map[string]string{"0": "0", "7": "seven", "2999": "5998"} {
+				if got, ok, err := m.get(key); err != nil || !ok || got != want {
+					t.Errorf("get(%s) = %q, %v, %v; want %q", key, got, ok, err, want)
+				}
+			}
+			if _, ok, err := m.get("missing"); ok || err != nil {
+				t.Errorf("get(missing) = %v, %v; want not found", ok, err)
+			}
+
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			if err := m.writeJSON(w, func(v string) ([]byte, error) { return json.Marshal(v) }); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+			var decoded map[string]string
+			if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if len(decoded) != n || decoded["7"] != "seven" {
+				t.Errorf("writeJSON wrote %d entries with 7 = %q", len(decoded), decoded["7"])
+			}
+		})
+	}
+}
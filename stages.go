@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// pipelineStages are the stages of convert in pipeline order; other is
+// everything between them, such as delta, seen and schema checks.
+var pipelineStages = []string{"read", "parse", "map", "enrich", "generate", "filter", "encode", "write", "other"}
+
+// stageTimes splits the time of a run between pipeline stages: each mark
+// books the time since the previous one to a stage. A nil stageTimes books
+// nothing, so the marks cost nothing without -stage-times.
+type stageTimes struct {
+	last  time.Time
+	times map[string]time.Duration
+}
+
+// stageTimer is the timer of a -stage-times run, which the input readers
+// mark too, or nil.
+var stageTimer *stageTimes
+
+func newStageTimes() *stageTimes {
+	return &stageTimes{last: time.Now(), times: map[string]time.Duration{}}
+}
+
+func (s *stageTimes) mark(stage string) {
+	if s == nil {
+		return
+	}
+	now := time.Now()
+	s.times[stage] += now.Sub(s.last)
+	s.last = now
+}
+
+// observe marks the stage of every rule conv applies. Field rules map,
+// lookups and processors enrich, and random_generate generates.
+func (s *stageTimes) observe(conv *converter.Converter) {
+	if s == nil {
+		return
+	}
+	conv.Observe = func(rule string, _ map[string]interface{}) {
+		section, _, _ := strings.Cut(rule, "[")
+		// Rules of a multi-mapping file are prefixed by their mapping.
+		if i := strings.LastIndex(section, "/"); i >= 0 {
+			section = section[i+1:]
+		}
+		switch section {
+		case "lookup", "processor":
+			s.mark("enrich")
+		case "random_generate":
+			s.mark("generate")
+		default:
+			s.mark("map")
+		}
+	}
+}
+
+// seconds returns the time of each stage that took any.
+func (s *stageTimes) seconds() map[string]float64 {
+	if s == nil {
+		return nil
+	}
+	seconds := make(map[string]float64, len(s.times))
+	for stage, d := range s.times {
+		seconds[stage] = d.Seconds()
+	}
+	return seconds
+}
+
+func (s *stageTimes) log() {
+	if s == nil {
+		return
+	}
+	var total time.Duration
+	for _, d := range s.times {
+		total += d
+	}
+	for _, stage := range pipelineStages {
+		if d, ok := s.times[stage]; ok && total > 0 {
+			slog.Info("stage time", "stage", stage, "duration", d.Round(time.Microsecond).String(),
+				"percent", float64(int(1000*d/total))/10)
+		}
+	}
+}
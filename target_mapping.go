@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+)
+
+// targetMapping holds the field types of a destination index mapping,
+// keyed by dotted path.
+type targetMapping struct {
+	types      map[string]string
+	mismatches map[string]int
+	examples   map[string]string
+}
+
+// loadTargetMapping reads an index mapping as returned by GET <index>/_mapping,
+// or just its "mappings" or "properties" part.
+func loadTargetMapping(path string) (*targetMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	properties := findProperties(raw)
+	if properties == nil {
+		return nil, fmt.Errorf("no properties found in %s", path)
+	}
+	m := &targetMapping{
+		types:      map[string]string{},
+		mismatches: map[string]int{},
+		examples:   map[string]string{},
+	}
+	m.addProperties(properties, "")
+	return m, nil
+}
+
+func findProperties(raw map[string]interface{}) map[string]interface{} {
+	if properties, ok := raw["properties"].(map[string]interface{}); ok {
+		return properties
+	}
+	if mappings, ok := raw["mappings"].(map[string]interface{}); ok {
+		return findProperties(mappings)
+	}
+	if len(raw) == 1 {
+		for _, index := range raw {
+			if nested, ok := index.(map[string]interface{}); ok {
+				return findProperties(nested)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *targetMapping) addProperties(properties map[string]interface{}, prefix string) {
+	for name, def := range properties {
+		field, ok := def.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typ, _ := field["type"].(string)
+		if typ == "" {
+			typ = "object"
+		}
+		m.types[prefix+name] = typ
+		if nested, ok := field["properties"].(map[string]interface{}); ok {
+			m.addProperties(nested, prefix+name+".")
+		}
+	}
+}
+
+// check records every field of source whose value does not fit the
+// mapped type, or that is not mapped at all.
+func (m *targetMapping) check(id string, source map[string]interface{}, prefix string) {
+	for key, value := range source {
+		path := prefix + key
+		typ, ok := m.types[path]
+		if !ok {
+			m.record(id, path, "unmapped dynamic field")
+			continue
+		}
+		m.checkValue(id, path, typ, value)
+	}
+}
+
+func (m *targetMapping) checkValue(id, path, typ string, value interface{}) {
+	if value == nil {
+		return
+	}
+	if list, ok := value.([]interface{}); ok && typ != "geo_point" {
+		for _, item := range list {
+			m.checkValue(id, path, typ, item)
+		}
+		return
+	}
+
+	var valid bool
+	switch typ {
+	case "object", "nested", "flattened":
+		object, ok := value.(map[string]interface{})
+		if ok && typ != "flattened" {
+			m.check(id, object, path+".")
+		}
+		valid = ok
+	case "long", "integer", "short", "byte", "unsigned_long":
+		switch typed := value.(type) {
+		case float64:
+			valid = typed == math.Trunc(typed)
+		case int64, int, json.Number:
+			valid = true
+		}
+	case "double", "float", "half_float", "scaled_float":
+		switch value.(type) {
+		case float64, int64, int, json.Number:
+			valid = true
+		}
+	case "boolean":
+		_, valid = value.(bool)
+	case "date", "date_nanos":
+		switch value.(type) {
+		case string, float64, int64, int:
+			valid = true
+		}
+	case "keyword", "text", "match_only_text", "wildcard", "constant_keyword", "ip", "version", "binary", "search_as_you_type":
+		switch value.(type) {
+		case string, float64, int64, int, bool:
+			valid = true
+		}
+	case "geo_point":
+		switch value.(type) {
+		case string, map[string]interface{}, []interface{}:
+			valid = true
+		}
+	default:
+		valid = true
+	}
+	if !valid {
+		m.record(id, path, fmt.Sprintf("%s where %s expected", jsonTypeName(value), typ))
+	}
+}
+
+func (m *targetMapping) record(id, path, problem string) {
+	key := path + ": " + problem
+	if m.mismatches[key] == 0 {
+		m.examples[key] = id
+	}
+	m.mismatches[key]++
+}
+
+func (m *targetMapping) log() {
+	keys := make([]string, 0, len(m.mismatches))
+	for key := range m.mismatches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		slog.Warn("mapping mismatch", "problem", key, "docs", m.mismatches[key], "example", m.examples[key])
+	}
+	if len(keys) == 0 {
+		slog.Info("all documents match the target mapping")
+	}
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int64, int, json.Number:
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// testCase is one file of the -cases directory: an input document and the
+// document the mapping must turn it into, or dropped when a lookup miss
+// policy must remove it.
+type testCase struct {
+	Name     string                 `json:"name"`
+	Input    converter.ESDoc        `json:"input"`
+	Expected map[string]interface{} `json:"expected"`
+	Dropped  bool                   `json:"dropped"`
+	// Ignore lists output fields that are only checked for presence, for
+	// values that differ from run to run. random_generate targets are
+	// always treated this way.
+	Ignore []string `json:"ignore"`
+}
+
+// testMapping runs the cases of a directory against a mapping file and
+// reports the differences from the expected to the actual output, exiting
+// with status 1 when a case fails.
+func testMapping(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	casesDir := fs.String("cases", "./tests", "Directory of test case JSON files")
+	verbose := fs.Bool("v", false, "List passing cases too")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: test [flags] mapping.json\n")
+		fs.PrintDefaults()
+	}
+	positional := parseArgs(fs, args)
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	mapping, conv := openConverter(positional[0], "test", true)
+	defer conv.Close()
+
+	files, err := filepath.Glob(filepath.Join(*casesDir, "*.json"))
+	if err != nil {
+		fatal(err.Error())
+	}
+	if len(files) == 0 {
+		fatal("no test cases found", "dir", *casesDir)
+	}
+	sort.Strings(files)
+
+	generated := map[string]bool{}
+	for _, variant := range mapping.Variants() {
+		for field := range variant.RandomGenerate {
+			generated["_source."+field] = true
+		}
+	}
+
+	var failed int
+	for _, file := range files {
+		tc, err := loadTestCase(file)
+		if err != nil {
+			fatal("failed to load test case", "file", file, "error", err)
+		}
+		problems := runTestCase(conv, tc, generated)
+		if len(problems) == 0 {
+			if *verbose {
+				fmt.Printf("PASS %s\n", tc.Name)
+			}
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", tc.Name)
+		for _, problem := range problems {
+			fmt.Printf("    %s\n", problem)
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(files)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func loadTestCase(path string) (testCase, error) {
+	var tc testCase
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tc, err
+	}
+	if err = docJSON.unmarshal(data, &tc); err != nil {
+		return tc, err
+	}
+	converter.ExactNumbers(tc.Input.Source)
+	converter.ExactNumbers(tc.Expected)
+	if tc.Name == "" {
+		tc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if tc.Expected == nil && !tc.Dropped {
+		return tc, fmt.Errorf("expected or dropped is required")
+	}
+	return tc, nil
+}
+
+// runTestCase converts the case's input and describes how the result
+// differs from the expectation.
+func runTestCase(conv *converter.Converter, tc testCase, generated map[string]bool) []string {
+	docs := []converter.ESDoc{tc.Input}
+	if err := conv.Prefetch(docs); err != nil {
+		return []string{err.Error()}
+	}
+	newDoc, err := conv.Convert(tc.Input)
+	switch {
+	case errors.Is(err, converter.ErrDropped) && tc.Dropped:
+		return nil
+	case errors.Is(err, converter.ErrDropped):
+		return []string{"document was dropped"}
+	case err != nil:
+		return []string{err.Error()}
+	case tc.Dropped:
+		return []string{"document was not dropped"}
+	}
+	actual, err := genericJSON(newDoc)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	ignored := map[string]bool{}
+	for field := range generated {
+		ignored[field] = true
+	}
+	for _, field := range tc.Ignore {
+		ignored[field] = true
+	}
+	var problems []string
+	for _, diff := range diffDocs(tc.Expected, actual) {
+		if ignored[diff.Field] && diff.Kind == "changed" {
+			continue
+		}
+		problems = append(problems, diff.String())
+	}
+	return problems
+}
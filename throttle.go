@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle paces work to perSecond units a second, such as documents or
+// bytes, the way lookup rate limits space out requests: each wait takes
+// its turn, then books the time its n units take at the rate. A nil
+// throttle does not limit.
+type throttle struct {
+	mu        sync.Mutex
+	perSecond float64
+	next      time.Time
+}
+
+// newThrottle returns a throttle for perSecond units a second, or nil for
+// a zero rate.
+func newThrottle(perSecond float64) *throttle {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &throttle{perSecond: perSecond}
+}
+
+func (t *throttle) wait(n int) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	delay := t.next.Sub(now)
+	t.next = t.next.Add(time.Duration(float64(n) / t.perSecond * float64(time.Second)))
+	t.mu.Unlock()
+	time.Sleep(delay)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+//go:embed ui.html
+var uiPage []byte
+
+// uiRequest is a preview the page asks for: a document, and the mapping to
+// convert it with as JSON or YAML.
+type uiRequest struct {
+	Document string `json:"document"`
+	Mapping  string `json:"mapping"`
+	Format   string `json:"format"`
+}
+
+// uiResponse is the outcome of a preview: the converted documents as
+// indented JSON, which the page shows as is so that large numbers keep
+// their digits, the problems validate-mapping finds in the mapping, and
+// the error that stopped the conversion, if any.
+type uiResponse struct {
+	Output   []string            `json:"output"`
+	Problems []converter.Problem `json:"problems"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// ui serves a local page for trying out a mapping: paste a document, edit
+// the mapping and see the converted output and the mapping's problems as
+// you type. Every preview converts with a converter of its own, opened
+// like preview opens one, so lookups and processors work as in convert.
+//
+//	GET  /             the page
+//	GET  /api/initial  the -mapping file and first -input document
+//	POST /api/preview  a uiRequest, answered with a uiResponse
+//
+// Since a preview runs whatever the mapping says, scripts included, the
+// API only answers the page: requests must name the listen address as
+// their Host, and Origin when they send one, which shuts out other sites
+// and DNS rebinding, and carry the token of this launch, which the page
+// holds.
+func ui(args []string) {
+	fs := flag.NewFlagSet("ui", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8090", "Address to listen on; keep it local, the page runs any mapping it is sent")
+	mappingFile := fs.String("mapping", "./data/mapping.json", "Mapping file to start the page with, if it exists")
+	inputFile := fs.String("input", "./data/input.json", "Input file whose first document starts the page, if it exists")
+	maxBody := fs.Int64("max-body", 8<<20, "Maximum request body size in bytes")
+	parseFlags(fs, args)
+
+	token, err := newUIToken()
+	if err != nil {
+		fatal("failed to create the page token", "error", err)
+	}
+	handler := newUIHandler(*addr, token, *mappingFile, *inputFile, *maxBody)
+	httpServer := &http.Server{Addr: *addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("serving mapping preview page", "url", "http://"+*addr+"/")
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("server failed", "error", err)
+	}
+}
+
+// newUIToken returns a random token for the page of one launch.
+func newUIToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newUIHandler returns the routes of ui, for a server listening on addr.
+func newUIHandler(addr, token, mappingFile, inputFile string, maxBody int64) http.Handler {
+	page := bytes.ReplaceAll(uiPage, []byte("{{token}}"), []byte(token))
+	hosts := uiHosts(addr)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("GET /api/initial", func(w http.ResponseWriter, r *http.Request) {
+		if !checkUIToken(w, r, token) {
+			return
+		}
+		writeUIJSON(w, map[string]string{
+			"mapping":  readOptional(mappingFile),
+			"document": firstDocument(inputFile),
+		})
+	})
+	mux.HandleFunc("POST /api/preview", func(w http.ResponseWriter, r *http.Request) {
+		if !checkUIToken(w, r, token) {
+			return
+		}
+		// A form can post across sites without a preflight; JSON cannot.
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req uiRequest
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		if err == nil {
+			err = json.Unmarshal(body, &req)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeUIJSON(w, uiPreview(req))
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hosts[strings.ToLower(r.Host)] {
+			http.Error(w, "unexpected Host "+r.Host, http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && !hosts[strings.ToLower(strings.TrimPrefix(origin, "http://"))] {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// uiHosts returns the Host values that name addr: addr itself, and the
+// loopback names with its port when addr is a loopback or unspecified
+// address.
+func uiHosts(addr string) map[string]bool {
+	hosts := map[string]bool{strings.ToLower(addr): true}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return hosts
+	}
+	ip := net.ParseIP(host)
+	if host == "" || host == "localhost" || ip != nil && (ip.IsLoopback() || ip.IsUnspecified()) {
+		for _, name := range []string{"localhost", "127.0.0.1", "::1"} {
+			hosts[net.JoinHostPort(name, port)] = true
+		}
+	}
+	return hosts
+}
+
+// checkUIToken reports whether r carries token, answering it otherwise.
+func checkUIToken(w http.ResponseWriter, r *http.Request, token string) bool {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Preview-Token")), []byte(token)) != 1 {
+		http.Error(w, "missing or wrong X-Preview-Token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// uiPreview converts the document of req with its mapping.
+func uiPreview(req uiRequest) uiResponse {
+	resp := uiResponse{Output: []string{}, Problems: []converter.Problem{}}
+	data := []byte(req.Mapping)
+	if req.Format == "yaml" {
+		var err error
+		if data, err = yamlToJSON(data); err != nil {
+			resp.Error = "invalid YAML: " + err.Error()
+			return resp
+		}
+	}
+	problems, err := converter.LintMappingData(data)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.Problems = append(resp.Problems, problems...)
+	mapping, err := converter.ParseMapping(data)
+	if err != nil {
+		resp.Error = "invalid mapping: " + err.Error()
+		return resp
+	}
+	doc, err := parseUIDocument(req.Document)
+	if err != nil {
+		resp.Error = "invalid document: " + err.Error()
+		return resp
+	}
+	conv, err := newConverter(mapping, os.DevNull, true)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	defer conv.Close()
+	if err = conv.Prefetch([]converter.ESDoc{doc}); err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	docs, _, err := convertDoc(conv, doc)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	for _, newDoc := range docs {
+		data, err := json.MarshalIndent(newDoc, "", "  ")
+		if err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		resp.Output = append(resp.Output, string(data))
+	}
+	return resp
+}
+
+// parseUIDocument reads a pasted document: an export line with _source,
+// or the source object alone.
+func parseUIDocument(text string) (converter.ESDoc, error) {
+	var doc converter.ESDoc
+	if err := unmarshalDoc([]byte(text), &doc); err != nil {
+		return doc, err
+	}
+	if doc.Source == nil {
+		var source map[string]interface{}
+		if err := unmarshalNumbers([]byte(text), &source); err != nil {
+			return doc, err
+		}
+		doc.Source = converter.ExactNumbers(source).(map[string]interface{})
+	}
+	return doc, nil
+}
+
+// yamlToJSON re-encodes a YAML mapping as JSON, the form the engine reads.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("the mapping is empty")
+	}
+	return json.Marshal(value)
+}
+
+// readOptional returns the contents of path, or "" when it cannot be read.
+func readOptional(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// firstDocument returns the first non-blank line of path, or "" when it
+// cannot be read.
+func firstDocument(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func writeUIJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUIHandler(t *testing.T) {
+	const addr, token = "127.0.0.1:8090", "secret"
+	handler := newUIHandler(addr, token, "", "", 1<<20)
+	body := `{"document": "{\"name\": \"Alice\"}", "mapping": "{\"field_mapping\": {\"who\": \"name\"}}"}`
+	preview := func(host string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/preview", strings.NewReader(body))
+		req.Host = host
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Preview-Token", token)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, host := range []string{addr, "localhost:8090", "[::1]:8090"} {
+		rec := preview(host, map[string]string{"Origin": "http://" + host})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("preview on %s = %d %s", host, rec.Code, rec.Body)
+		}
+		var resp uiResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Error != "" || len(resp.Output) != 1 || !strings.Contains(resp.Output[0], `"who": "Alice"`) {
+			t.Errorf("preview on %s = %+v", host, resp)
+		}
+	}
+
+	rejected := []struct {
+		name   string
+		host   string
+		header map[string]string
+		code   int
+	}{
+		{"rebound host", "evil.example:8090", nil, http.StatusForbidden},
+		{"other port", "127.0.0.1:9000", nil, http.StatusForbidden},
+		{"foreign origin", addr, map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+		{"null origin", addr, map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"no token", addr, map[string]string{"X-Preview-Token": ""}, http.StatusForbidden},
+		{"wrong token", addr, map[string]string{"X-Preview-Token": "guess"}, http.StatusForbidden},
+		{"form post", addr, map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range rejected {
+		if rec := preview(tt.host, tt.header); rec.Code != tt.code {
+			t.Errorf("%s: code = %d, want %d", tt.name, rec.Code, tt.code)
+		}
+	}
+
+	// The page carries the token; the initial files need it too.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = addr
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `content="secret"`) {
+		t.Error("page does not carry the token")
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/initial", nil)
+	req.Host = addr
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("initial without token = %d, want 403", rec.Code)
+	}
+}
+
+func TestUIHosts(t *testing.T) {
+	if hosts := uiHosts(":8090"); !hosts["localhost:8090"] || !hosts["127.0.0.1:8090"] {
+		t.Errorf("uiHosts(:8090) = %v", hosts)
+	}
+	if hosts := uiHosts("192.168.1.5:8090"); len(hosts) != 1 || !hosts["192.168.1.5:8090"] {
+		t.Errorf("uiHosts(192.168.1.5:8090) = %v", hosts)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// sourceCoverage knows which source paths a mapping reads, directly or
+// through an ancestor object.
+type sourceCoverage map[string]bool
+
+func newSourceCoverage(mapping converter.FieldMapping, lookups []converter.LookupConfig) sourceCoverage {
+	covered := sourceCoverage{}
+	for _, variant := range mapping.Variants() {
+		for _, oldField := range variant.FieldMapping {
+			for _, field := range converter.SourcePaths(oldField) {
+				covered[field] = true
+			}
+		}
+		for _, text := range variant.Templates {
+			for _, field := range converter.TemplateFields(text) {
+				covered[field] = true
+			}
+		}
+		for _, field := range variant.Exclude {
+			covered[field] = true
+		}
+		for _, field := range variant.Passthrough {
+			covered[field] = true
+		}
+	}
+	for _, lookup := range lookups {
+		if lookup.KeyField != "_id" {
+			covered[lookup.KeyField] = true
+		}
+	}
+	return covered
+}
+
+func (c sourceCoverage) covers(path string) bool {
+	for {
+		if c[path] {
+			return true
+		}
+		i := strings.LastIndex(path, ".")
+		if i == -1 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+// unmapped returns the sorted leaf paths of source that no rule covers.
+func (c sourceCoverage) unmapped(source map[string]interface{}) []string {
+	var fields []string
+	c.collect(source, "", &fields)
+	sort.Strings(fields)
+	return fields
+}
+
+func (c sourceCoverage) collect(data map[string]interface{}, prefix string, fields *[]string) {
+	for key, value := range data {
+		path := prefix + key
+		if c[path] {
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			c.collect(nested, path+".", fields)
+			continue
+		}
+		if !c.covers(path) {
+			*fields = append(*fields, path)
+		}
+	}
+}
+
+type unmappedField struct {
+	Field string `json:"field"`
+	Count int    `json:"count"`
+}
+
+// writeUnmappedReport writes the unmapped fields, most frequent first.
+func writeUnmappedReport(path string, counts map[string]int) error {
+	report := make([]unmappedField, 0, len(counts))
+	for field, count := range counts {
+		report = append(report, unmappedField{Field: field, Count: count})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Count != report[j].Count {
+			return report[i].Count > report[j].Count
+		}
+		return report[i].Field < report[j].Field
+	})
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// validate checks already converted documents against a JSON Schema and/or
+// a destination index mapping, exiting with status 1 on any violation.
+func validate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	schemaFile := fs.String("schema", "", "Path to a JSON Schema every document must satisfy")
+	schemaErrorsFile := fs.String("schema-errors", "", "Path to write documents violating the schema")
+	targetMappingFile := fs.String("target-mapping", "", "Path to the destination index mapping JSON")
+	parseFlags(fs, args)
+
+	if *schemaFile == "" && *targetMappingFile == "" {
+		fatal("validate needs -schema, -target-mapping or both")
+	}
+	var (
+		validator *schemaValidator
+		target    *targetMapping
+		err       error
+	)
+	if *schemaFile != "" {
+		if validator, err = openSchemaValidator(*schemaFile, *schemaErrorsFile, *schemaErrorsFile == ""); err != nil {
+			fatal("failed to load schema", "error", err)
+		}
+	}
+	if *targetMappingFile != "" {
+		if target, err = loadTargetMapping(*targetMappingFile); err != nil {
+			fatal("failed to load target mapping", "error", err)
+		}
+	}
+
+	reader := input.open(-1)
+	docs := 0
+	for {
+		rec, ok := reader.Next()
+		if !ok {
+			break
+		}
+		if rec.err != nil {
+			fatal("failed to read document", "line", rec.line, "error", rec.err)
+		}
+		docs++
+		if target != nil {
+			target.check(converter.DocID(rec.doc), rec.doc.Source, "")
+		}
+		if validator != nil {
+			if _, err = validator.validate(rec.doc); err != nil {
+				fatal("failed to validate doc", "id", converter.DocID(rec.doc), "error", err)
+			}
+		}
+	}
+	if err = reader.Err(); err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	reader.Close()
+
+	failed := false
+	slog.Info("validated docs", "docs", docs)
+	if target != nil {
+		target.log()
+		failed = len(target.mismatches) > 0
+	}
+	if validator != nil {
+		if err = validator.Close(); err != nil {
+			fatal("failed to write schema errors file", "error", err)
+		}
+		slog.Info("schema violations", "docs", validator.violations)
+		failed = failed || validator.violations > 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
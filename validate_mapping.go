@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// validateMapping lints the mapping files given as arguments, printing one
+// line per problem and exiting with status 1 when any has errors.
+func validateMapping(args []string) {
+	fs := flag.NewFlagSet("validate-mapping", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the problems as JSON")
+	strict := fs.Bool("strict", false, "Treat warnings as errors")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: validate-mapping [flags] mapping.json...\n")
+		fs.PrintDefaults()
+	}
+	paths := parseArgs(fs, args)
+	if len(paths) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	results := map[string][]converter.Problem{}
+	for _, path := range paths {
+		problems, err := converter.LintMapping(path)
+		if err != nil {
+			fatal("failed to read mapping file", "mapping", path, "error", err)
+		}
+		results[path] = problems
+		for _, problem := range problems {
+			if !problem.Warning || *strict {
+				failed = true
+			}
+		}
+		if !*asJSON {
+			for _, problem := range problems {
+				fmt.Printf("%s: %s\n", path, problem)
+			}
+			if len(problems) == 0 {
+				fmt.Printf("%s: ok\n", path)
+			}
+		}
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			fatal(err.Error())
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
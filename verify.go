@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+type mgetResponse struct {
+	Docs []struct {
+		Index  string                 `json:"_index"`
+		ID     string                 `json:"_id"`
+		Found  bool                   `json:"found"`
+		Source map[string]interface{} `json:"_source"`
+		Error  json.RawMessage        `json:"error"`
+	} `json:"docs"`
+}
+
+// verifyReport lists the converted documents that did not make it to the
+// target index intact.
+type verifyReport struct {
+	Checked   int      `json:"checked"`
+	Found     int      `json:"found"`
+	Missing   []string `json:"missing"`
+	Divergent []string `json:"divergent"`
+}
+
+// verify checks that every converted document exists in the target index
+// under its _id, and with -content that its _source is unchanged, exiting
+// with status 1 when any is missing or divergent.
+func verify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	var input inputOptions
+	input.register(fs)
+	inputFlag := fs.Lookup("input")
+	inputFlag.DefValue = "./data/output.json"
+	inputFlag.Value.Set(inputFlag.DefValue)
+	inputFlag.Usage = "Path to the converted documents to look for"
+	url := fs.String("url", "http://localhost:9200", "URL of the target cluster")
+	index := fs.String("index", "", "Index to look in (default: the _index of each document)")
+	content := fs.Bool("content", false, "Also compare a hash of each document's _source")
+	batchSize := fs.Int("batch-size", 500, "Documents per _mget request")
+	reportFile := fs.String("report", "", "Path to write the IDs of missing and divergent documents as JSON")
+	limit := fs.Int("limit", -1, "Limit of documents to check (-1 for all)")
+	parseFlags(fs, args)
+
+	client := newESClient(*url, nil)
+	reader := input.open(*limit)
+	defer reader.Close()
+
+	start := time.Now()
+	report := verifyReport{Missing: []string{}, Divergent: []string{}}
+	var batch []converter.ESDoc
+	check := func() {
+		if len(batch) == 0 {
+			return
+		}
+		docs := make([]map[string]interface{}, len(batch))
+		for i, doc := range batch {
+			target := *index
+			if target == "" && doc.Index != nil {
+				target = *doc.Index
+			}
+			docs[i] = map[string]interface{}{"_index": target, "_id": converter.DocID(doc)}
+			if !*content {
+				docs[i]["_source"] = false
+			}
+		}
+		var resp mgetResponse
+		if err := client.doJSON(http.MethodPost, "/_mget", map[string]interface{}{"docs": docs}, &resp); err != nil {
+			fatal("mget request failed", "error", err)
+		}
+		for i, doc := range batch {
+			id := converter.DocID(doc)
+			report.Checked++
+			if i >= len(resp.Docs) || !resp.Docs[i].Found {
+				if i < len(resp.Docs) && len(resp.Docs[i].Error) > 0 {
+					slog.Error("failed to get doc", "id", id, "error", string(resp.Docs[i].Error))
+				}
+				report.Missing = append(report.Missing, id)
+				continue
+			}
+			report.Found++
+			if *content && sourceHash(doc.Source) != sourceHash(resp.Docs[i].Source) {
+				report.Divergent = append(report.Divergent, id)
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		rec, ok := reader.Next()
+		if !ok {
+			break
+		}
+		if rec.err != nil {
+			fatal("failed to unmarshal input data", "line", rec.line, "error", rec.err)
+		}
+		if converter.DocID(rec.doc) == "" {
+			slog.Warn("skipping doc without _id", "line", rec.line)
+			continue
+		}
+		batch = append(batch, rec.doc)
+		if len(batch) >= *batchSize {
+			check()
+		}
+	}
+	if err := reader.Err(); err != nil {
+		fatal("failed to read input", "error", err)
+	}
+	check()
+
+	for i, id := range report.Missing {
+		if i == 10 {
+			break
+		}
+		slog.Warn("missing doc", "id", id)
+	}
+	for i, id := range report.Divergent {
+		if i == 10 {
+			break
+		}
+		slog.Warn("divergent doc", "id", id)
+	}
+	slog.Info("verify finished", "duration", time.Since(start).String(), "checked", report.Checked, "found", report.Found,
+		"missing", len(report.Missing), "divergent", len(report.Divergent))
+	if *reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fatal(err.Error())
+		}
+		if err = os.WriteFile(*reportFile, data, 0644); err != nil {
+			fatal("failed to write report", "error", err)
+		}
+	}
+	if len(report.Missing) > 0 || len(report.Divergent) > 0 {
+		os.Exit(1)
+	}
+}
+
+// sourceHash hashes the canonical JSON of source; encoding/json sorts map
+// keys, so equal documents hash equally whatever their key order.
+func sourceHash(source map[string]interface{}) [sha256.Size]byte {
+	generic, err := genericJSON(source)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	data, _ := json.Marshal(generic)
+	return sha256.Sum256(data)
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/ishtiaqhimel/converter/pkg/converter"
+)
+
+// watchOptions configures -watch mode, in which convert monitors a
+// directory and converts every export file that appears in it.
+type watchOptions struct {
+	dir       string
+	outputDir string
+	doneDir   string
+	failedDir string
+	settle    time.Duration
+	metrics   string
+}
+
+func (o *watchOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.dir, "watch", "", "Directory to monitor; new or modified export files in it are converted as they appear")
+	fs.StringVar(&o.outputDir, "watch-output", "", "Directory for the converted files in -watch mode (default: <watch>/output)")
+	fs.StringVar(&o.doneDir, "watch-done", "", "Directory processed files are moved to in -watch mode (default: <watch>/done)")
+	fs.StringVar(&o.failedDir, "watch-failed", "", "Directory files that failed to convert are moved to in -watch mode (default: <watch>/failed)")
+	fs.DurationVar(&o.settle, "watch-settle", 2*time.Second, "Time a file must go unmodified before it is converted in -watch mode")
+	fs.StringVar(&o.metrics, "watch-metrics", "", "Address to serve Prometheus metrics on at /metrics in -watch mode, e.g. :9100")
+}
+
+// watchedFile reports whether name looks like a finished export file.
+func watchedFile(name string) bool {
+	base := filepath.Base(name)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".json", ".ndjson", ".jsonl", ".xlsx":
+		return true
+	}
+	return false
+}
+
+// watch converts the files of a directory until interrupted. Each file is
+// converted by a child convert process with the same flags, so a failing
+// file cannot take the daemon down. Children read a validated copy of the
+// mapping file that is replaced whenever the mapping changes and still
+// opens, so a broken edit never reaches a conversion.
+func watch(fs *flag.FlagSet, opts watchOptions) {
+	if opts.outputDir == "" {
+		opts.outputDir = filepath.Join(opts.dir, "output")
+	}
+	if opts.doneDir == "" {
+		opts.doneDir = filepath.Join(opts.dir, "done")
+	}
+	if opts.failedDir == "" {
+		opts.failedDir = filepath.Join(opts.dir, "failed")
+	}
+	for _, dir := range []string{opts.outputDir, opts.doneDir, opts.failedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			fatal("failed to create directory", "dir", dir, "error", err)
+		}
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fatal("failed to locate executable", "error", err)
+	}
+	args, sideFiles := childArgs(fs)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mappingFile := fs.Lookup("mapping").Value.String()
+	snapshotDir, err := os.MkdirTemp("", "converter-watch-")
+	if err != nil {
+		fatal("failed to create mapping snapshot directory", "error", err)
+	}
+	defer os.RemoveAll(snapshotDir)
+	snapshot := filepath.Join(snapshotDir, "mapping.json")
+	mapping, err := converter.LoadMapping(mappingFile)
+	if err != nil {
+		fatal("failed to load mapping file", "error", err)
+	}
+	if err = saveMappingSnapshot(mapping, snapshot); err != nil {
+		fatal(err.Error())
+	}
+	if err = watchMapping(ctx, mappingFile, func(mapping converter.FieldMapping) error {
+		return saveMappingSnapshot(mapping, snapshot)
+	}); err != nil {
+		fatal("failed to watch mapping file", "mapping", mappingFile, "error", err)
+	}
+	args = append(args, "-mapping="+snapshot)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fatal("failed to start watcher", "error", err)
+	}
+	defer watcher.Close()
+	if err = watcher.Add(opts.dir); err != nil {
+		fatal("failed to watch directory", "dir", opts.dir, "error", err)
+	}
+
+	// pending holds the time of the last change of each file not yet
+	// converted; files present at startup are picked up too.
+	pending := map[string]time.Time{}
+	entries, err := os.ReadDir(opts.dir)
+	if err != nil {
+		fatal("failed to read directory", "dir", opts.dir, "error", err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && watchedFile(entry.Name()) {
+			pending[filepath.Join(opts.dir, entry.Name())] = time.Time{}
+		}
+	}
+	var m *metrics
+	if opts.metrics != "" {
+		m = newMetrics(nil)
+		serveMetrics(opts.metrics, m)
+	}
+	slog.Info("watching for files", "dir", opts.dir, "output", opts.outputDir)
+
+	ticker := time.NewTicker(opts.settle / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("stopped watching", "dir", opts.dir)
+			return
+		case err := <-watcher.Errors:
+			slog.Error("watch error", "error", err)
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				if watchedFile(event.Name) {
+					pending[event.Name] = time.Now()
+					m.setQueueDepth(len(pending))
+				}
+			}
+		case now := <-ticker.C:
+			for path, changed := range pending {
+				if now.Sub(changed) < opts.settle {
+					continue
+				}
+				delete(pending, path)
+				m.setQueueDepth(len(pending))
+				if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				convertWatched(ctx, self, args, sideFiles, path, opts, m)
+				if ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// sideFileFlags are the convert flags naming a side file of the run, which
+// -watch mode names after the output of each file instead, with these
+// suffixes.
+var sideFileFlags = map[string]string{
+	"report":   ".report.json",
+	"audit":    ".audit.ndjson",
+	"manifest": ".manifest.json",
+}
+
+// childArgs returns the convert flags that were set, minus the mapping and
+// those that -watch mode sets per file, and which side files were asked for.
+func childArgs(fs *flag.FlagSet) ([]string, map[string]bool) {
+	var args []string
+	sideFiles := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		switch {
+		case strings.HasPrefix(f.Name, "watch"), f.Name == "config", f.Name == "input", f.Name == "output",
+			f.Name == "resume", f.Name == "checkpoint", f.Name == "mapping":
+		case sideFileFlags[f.Name] != "":
+			sideFiles[f.Name] = f.Value.String() != ""
+		default:
+			args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value.String()))
+		}
+	})
+	return args, sideFiles
+}
+
+// convertWatched converts path in a child process and moves it to the done
+// or failed directory. With metrics, the child always writes a report, to
+// a temporary file unless -report asked for one, to count its documents.
+func convertWatched(ctx context.Context, self string, args []string, sideFiles map[string]bool, path string, opts watchOptions, m *metrics) {
+	base := filepath.Base(path)
+	output := filepath.Join(opts.outputDir, strings.TrimSuffix(base, filepath.Ext(base))+".json")
+	childArgs := append([]string{"convert"}, args...)
+	childArgs = append(childArgs, "-input="+path, "-output="+output)
+	for name, suffix := range sideFileFlags {
+		if sideFiles[name] && name != "report" {
+			childArgs = append(childArgs, "-"+name+"="+output+suffix)
+		}
+	}
+	var report string
+	if sideFiles["report"] {
+		report = output + sideFileFlags["report"]
+	} else if m != nil {
+		if tmp, err := os.CreateTemp("", "converter-watch-report-*.json"); err == nil {
+			tmp.Close()
+			report = tmp.Name()
+			defer os.Remove(report)
+		}
+	}
+	if report != "" {
+		childArgs = append(childArgs, "-report="+report)
+	}
+
+	slog.Info("converting file", "input", path, "output", output)
+	start := time.Now()
+	cmd := exec.Command(self, childArgs...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		slog.Warn("interrupted, leaving file in place", "input", path)
+		return
+	}
+	m.recordFile(report, time.Since(start), err)
+
+	dest := opts.doneDir
+	if err != nil {
+		slog.Error("failed to convert file", "input", path, "error", err)
+		dest = opts.failedDir
+	}
+	target := filepath.Join(dest, base)
+	if _, statErr := os.Stat(target); statErr == nil {
+		target = filepath.Join(dest, fmt.Sprintf("%s.%s", base, time.Now().Format("20060102T150405")))
+	}
+	if err = os.Rename(path, target); err != nil {
+		slog.Error("failed to move file", "input", path, "target", target, "error", err)
+	}
+}
+
+// saveMappingSnapshot checks that mapping opens and writes it to path,
+// replacing the previous snapshot atomically.
+func saveMappingSnapshot(mapping converter.FieldMapping, path string) error {
+	conv, err := newConverter(mapping, path, true)
+	if err != nil {
+		return err
+	}
+	if err = conv.Close(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}